@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"slices"
 	"sync"
 	"time"
 
@@ -236,6 +237,9 @@ func (cp *channelProcessor) process() error {
 			cp.orphanMessages = append(cp.orphanMessages, msgID)
 		}
 	}
+	if err := cp.dropReferencedOrphans(); err != nil {
+		return fmt.Errorf("failed to query part references for channel %d: %w", cp.id, err)
+	}
 	cp.totalPartsDB += len(allPartIDs)
 	msgCount := len(msgMap)
 	if _, hasMsg1 := msgMap[1]; hasMsg1 {
@@ -283,6 +287,21 @@ func (cp *channelProcessor) loadUploadPartMap(out map[int]bool) error {
 	return nil
 }
 
+// dropReferencedOrphans keeps messages that only trashed files or file
+// versions still use.
+func (cp *channelProcessor) dropReferencedOrphans() error {
+	referenced, err := cp.repos.Files.ListReferencedParts(cp.ctx, cp.userID, cp.id, cp.orphanMessages)
+	if err != nil {
+		return err
+	}
+	skip := make(map[int]bool, len(referenced))
+	for _, id := range referenced {
+		skip[id] = true
+	}
+	cp.orphanMessages = slices.DeleteFunc(cp.orphanMessages, func(id int) bool { return skip[id] })
+	return nil
+}
+
 func (cp *channelProcessor) deleteFilesBulk(fileIDs []uuid.UUID, userID int64) error {
 	query := `
 WITH RECURSIVE target_folders AS (
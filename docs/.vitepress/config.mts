@@ -45,7 +45,10 @@ export default defineConfig({
         items: [
           { text: 'API Keys', link: '/docs/guides/api-keys.md' },
           { text: 'rclone', link: '/docs/guides/rclone.md' },
+          { text: 'WebDAV', link: '/docs/guides/webdav.md' },
+          { text: 'S3 Gateway', link: '/docs/guides/s3.md' },
           { text: 'Media Servers', link: '/docs/guides/jellyfin.md' },
+          { text: 'Webhooks', link: '/docs/guides/webhooks.md' },
         ]
       },
       {
@@ -53,6 +56,7 @@ export default defineConfig({
         collapsed: false,
         items: [
           { text: 'Jobs and Sync', link: '/docs/guides/jobs-and-sync.md' },
+          { text: 'Multi-user Instances', link: '/docs/guides/multi-user.md' },
           { text: 'Deploy with Caddy and Cloudflare', link: '/docs/guides/caddy-cloudflare.md' },
           { text: 'Database Backup', link: '/docs/guides/db-backup.md' },
         ]
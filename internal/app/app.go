@@ -7,6 +7,7 @@ import (
 	"net"
 	"net/http"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -104,14 +105,17 @@ func New(ctx context.Context, cfg *config.ServerCmdConfig) (_ *App, err error) {
 
 	cacher := cache.NewCache(ctx, cfg.Cache.MaxSize, redisClient, log)
 	botSelector := tgc.NewBotSelector(redisClient)
-	broadcaster := events.NewBroadcaster(ctx, repos.Events, redisClient, cfg.Events.PollInterval, events.BroadcasterConfig{
+	broadcaster, err := events.NewBroadcaster(ctx, cfg.Events.Broadcaster, repos.Events, redisClient, pool, cfg.Events.PollInterval, events.BroadcasterConfig{
 		DBWorkers:        cfg.Events.DBWorkers,
 		DBBufferSize:     cfg.Events.DBBufferSize,
 		DeduplicationTTL: cfg.Events.DeduplicationTTL,
 	}, logging.Component("EVENT"))
+	if err != nil {
+		return nil, fmt.Errorf("create event broadcaster: %w", err)
+	}
 	cleanups = append(cleanups, broadcaster.Shutdown)
 
-	httpServer, riverClient, err := buildHTTPServer(cfg, repos, cacher, log, botSelector, broadcaster)
+	httpServer, s3Server, riverClient, err := buildHTTPServer(cfg, repos, cacher, log, botSelector, broadcaster)
 	if err != nil {
 		return nil, err
 	}
@@ -163,6 +167,25 @@ func New(ctx context.Context, cfg *config.ServerCmdConfig) (_ *App, err error) {
 			return nil
 		}},
 	}
+	if s3Server != nil {
+		app.hooks = append(app.hooks, Hook{Name: "s3", Start: func(context.Context) error {
+			go func() {
+				log.Info("s3.started", zap.String("address", fmt.Sprintf("http://localhost:%d", cfg.S3.Port)))
+				if err := s3Server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					select {
+					case app.serverErrCh <- err:
+					default:
+					}
+				}
+			}()
+			return nil
+		}, Stop: func(ctx context.Context) error {
+			if err := s3Server.Shutdown(ctx); err != nil {
+				return fmt.Errorf("shutdown s3 server: %w", err)
+			}
+			return nil
+		}})
+	}
 
 	cleanups = nil
 	return app, nil
@@ -256,7 +279,7 @@ func findAvailablePort(startPort int) (int, error) {
 	return 0, fmt.Errorf("no available ports found between %d and %d", startPort, startPort+100)
 }
 
-func buildHTTPServer(cfg *config.ServerCmdConfig, repos *repositories.Repositories, cacher cache.Cacher, log *zap.Logger, botSelector tgc.BotSelector, broadcaster events.EventBroadcaster) (*http.Server, *river.Client[pgx.Tx], error) {
+func buildHTTPServer(cfg *config.ServerCmdConfig, repos *repositories.Repositories, cacher cache.Cacher, log *zap.Logger, botSelector tgc.BotSelector, broadcaster events.EventBroadcaster) (*http.Server, *http.Server, *river.Client[pgx.Tx], error) {
 	channelManager := tgc.NewChannelManager(repos, cacher, &cfg.TG)
 	telegramService := services.NewTelegramService(repos, cacher, &cfg.TG, botSelector)
 	jobClientRef := services.NewJobClientRef()
@@ -265,19 +288,21 @@ func buildHTTPServer(cfg *config.ServerCmdConfig, repos *repositories.Repositori
 	apiSrv := services.NewApiService(repos, channelManager, cfg, cacher, telegramService, broadcaster, jobClientRef, periodicRegistryRef)
 	riverClient, err := queue.NewClient(repos.Pool, services.NewJobExecutor(apiSrv), cfg.Queue, cfg.Jobs)
 	if err != nil {
-		return nil, nil, fmt.Errorf("create river client: %w", err)
+		return nil, nil, nil, fmt.Errorf("create river client: %w", err)
 	}
 	jobClientRef.Set(riverClient)
 	periodicRegistryRef.Set(riverClient.PeriodicJobs())
+	jobEvents, _ := riverClient.Subscribe(river.EventKindJobCompleted, river.EventKindJobFailed, river.EventKindJobCancelled)
+	go apiSrv.RecordJobEvents(jobEvents)
 	if err := apiSrv.RegisterPeriodicJobs(context.Background()); err != nil {
-		return nil, nil, fmt.Errorf("register periodic jobs: %w", err)
+		return nil, nil, nil, fmt.Errorf("register periodic jobs: %w", err)
 	}
 
 	sec := auth.NewSecurityHandler(repos.Sessions, repos.APIKeys, cacher, &cfg.JWT)
 	rawSrv := services.NewRawService(apiSrv)
 	srv, err := api.NewServer(apiSrv, rawSrv, sec)
 	if err != nil {
-		return nil, nil, fmt.Errorf("create api server: %w", err)
+		return nil, nil, nil, fmt.Errorf("create api server: %w", err)
 	}
 
 	mux := chi.NewRouter()
@@ -298,8 +323,30 @@ func buildHTTPServer(cfg *config.ServerCmdConfig, repos *repositories.Repositori
 	}))
 	mux.Use(requestmeta.Middleware)
 	mux.Mount("/api/", http.StripPrefix("/api", srv))
+	if cfg.WebDAV.Enabled {
+		prefix := "/" + strings.Trim(cfg.WebDAV.Prefix, "/")
+		mux.Mount(prefix, services.NewWebDAVHandler(apiSrv, prefix))
+	}
 	mux.Handle("/*", middleware.SPAHandler(ui.StaticFS))
 
+	var s3Server *http.Server
+	if cfg.S3.Enabled {
+		s3Mux := chi.NewRouter()
+		s3Mux.Use(chimiddleware.Recoverer)
+		s3Mux.Use(chimiddleware.RealIP)
+		s3Mux.Use(middleware.InjectLogger(log))
+		s3Mux.Use(chizap.ChizapWithConfig(logging.Component("S3"), &chizap.Config{HTTPConfig: &cfg.Log.HTTP}))
+		s3Mux.Handle("/*", services.NewS3Handler(apiSrv))
+		s3Server = &http.Server{
+			Addr:              fmt.Sprintf(":%d", cfg.S3.Port),
+			Handler:           s3Mux,
+			ReadTimeout:       cfg.Server.ReadTimeout,
+			WriteTimeout:      cfg.Server.WriteTimeout,
+			ReadHeaderTimeout: defaultReadHeaderTimeout,
+			IdleTimeout:       defaultIdleTimeout,
+		}
+	}
+
 	return &http.Server{
 		Addr:              fmt.Sprintf(":%d", cfg.Server.Port),
 		Handler:           mux,
@@ -307,5 +354,5 @@ func buildHTTPServer(cfg *config.ServerCmdConfig, repos *repositories.Repositori
 		WriteTimeout:      cfg.Server.WriteTimeout,
 		ReadHeaderTimeout: defaultReadHeaderTimeout,
 		IdleTimeout:       defaultIdleTimeout,
-	}, riverClient, nil
+	}, s3Server, riverClient, nil
 }
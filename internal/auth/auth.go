@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
@@ -156,6 +157,19 @@ type cachedAPIKeyAuth struct {
 	SessionID string
 	TgSession string
 	UserID    int64
+	TokenHash string
+	Scopes    []string
+	FolderID  *uuid.UUID
+}
+
+func (c cachedAPIKeyAuth) claims() *types.JWTClaims {
+	return &types.JWTClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(c.UserID, 10)},
+		SessionID:        uuid.MustParse(c.SessionID),
+		TgSession:        c.TgSession,
+		Scopes:           c.Scopes,
+		FolderID:         c.FolderID,
+	}
 }
 
 func (s *securityHandler) HandleAccessTokenCookieAuth(ctx context.Context, operationName api.OperationName, t api.AccessTokenCookieAuth) (context.Context, error) {
@@ -163,18 +177,26 @@ func (s *securityHandler) HandleAccessTokenCookieAuth(ctx context.Context, opera
 }
 
 func (s *securityHandler) HandleXApiKeyHeaderAuth(ctx context.Context, operationName api.OperationName, t api.XApiKeyHeaderAuth) (context.Context, error) {
-	return s.handleAPIKeyAuth(ctx, t.APIKey)
+	return s.handleAPIKeyAuth(ctx, operationName, t.APIKey)
 }
 
 func (s *securityHandler) HandleBearerAuth(ctx context.Context, operationName api.OperationName, t api.BearerAuth) (context.Context, error) {
 	return s.handleJWTAuth(ctx, t.Token, AuthSourceBearer)
 }
 
+// sessionHashOperations lists the operations that accept a bare session ID
+// as credentials. None do for now; allowing one is a change of its own.
+var sessionHashOperations = map[api.OperationName]bool{}
+
 func (s *securityHandler) HandleSessionHashAuth(ctx context.Context, operationName api.OperationName, t api.SessionHashAuth) (context.Context, error) {
-	if operationName != api.FilesStreamOperation || operationName != api.FilesStreamHeadOperation {
+	if !sessionHashOperations[operationName] {
 		return nil, &ogenerrors.SecurityError{Err: ErrAuthSessionInvalid}
 	}
-	session, err := SessionByID(ctx, s.sessions, s.cache, uuid.MustParse(t.APIKey))
+	sessionID, err := uuid.Parse(t.APIKey)
+	if err != nil {
+		return nil, &ogenerrors.SecurityError{Err: ErrAuthSessionInvalid}
+	}
+	session, err := SessionByID(ctx, s.sessions, s.cache, sessionID)
 	if err != nil {
 		return nil, &ogenerrors.SecurityError{Err: ErrAuthSessionInvalid}
 	}
@@ -212,11 +234,14 @@ func (s *securityHandler) handleJWTAuth(ctx context.Context, token string, sourc
 	return ctx, nil
 }
 
-func (s *securityHandler) handleAPIKeyAuth(ctx context.Context, token string) (context.Context, error) {
+func (s *securityHandler) handleAPIKeyAuth(ctx context.Context, operationName api.OperationName, token string) (context.Context, error) {
 	claims, err := s.verifyAPIKey(ctx, token)
 	if err != nil {
 		return nil, &ogenerrors.SecurityError{Err: err}
 	}
+	if err := CheckScope(claims, operationName); err != nil {
+		return nil, &ogenerrors.SecurityError{Err: err}
+	}
 
 	ctx = context.WithValue(ctx, authKey, claims)
 	ctx = context.WithValue(ctx, authSourceKey, AuthSourceAPIKey)
@@ -224,42 +249,82 @@ func (s *securityHandler) handleAPIKeyAuth(ctx context.Context, token string) (c
 }
 
 func (s *securityHandler) verifyAPIKey(ctx context.Context, token string) (*types.JWTClaims, error) {
+	return VerifyAPIKey(ctx, s.sessions, s.apiKeys, s.cache, token)
+}
+
+// VerifyAPIKey resolves an API key to the claims of the user that owns it.
+func VerifyAPIKey(ctx context.Context, sessions repositories.SessionRepository, apiKeys repositories.APIKeyRepository, c cache.Cacher, token string) (*types.JWTClaims, error) {
 	tokenHash := hashToken(token)
 	cacheKey := cache.KeyAPIKeyAuth(tokenHash)
 
-	cached, err := cache.Fetch(ctx, s.cache, cacheKey, 24*time.Hour, func() (cachedAPIKeyAuth, error) {
-		key, err := s.apiKeys.GetActiveByTokenHash(ctx, tokenHash, time.Now().UTC())
+	cached, err := cache.Fetch(ctx, c, cacheKey, 24*time.Hour, func() (cachedAPIKeyAuth, error) {
+		key, err := apiKeys.GetActiveByTokenHash(ctx, tokenHash, time.Now().UTC())
 		if err != nil {
 			return cachedAPIKeyAuth{}, ErrAuthAPIKeyInvalid
 		}
+		return apiKeyAuth(ctx, sessions, apiKeys, key)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		sessions, err := s.sessions.GetByUserID(ctx, key.UserID)
-		if err != nil {
-			return cachedAPIKeyAuth{}, ErrAuthAPIKeySessionMiss
-		}
-		if len(sessions) == 0 {
-			return cachedAPIKeyAuth{}, ErrAuthAPIKeySessionMiss
-		}
+	return cached.claims(), nil
+}
 
-		_ = s.apiKeys.TouchLastUsed(ctx, key.ID, time.Now().UTC())
+// VerifyS3AccessKey resolves an S3 access key ID, which is the ID of an API
+// key, to the claims of its owner and the secret the request must be signed
+// with.
+func VerifyS3AccessKey(ctx context.Context, sessions repositories.SessionRepository, apiKeys repositories.APIKeyRepository, c cache.Cacher, jwtSecret, accessKeyID string) (*types.JWTClaims, string, error) {
+	id, err := uuid.Parse(accessKeyID)
+	if err != nil {
+		return nil, "", ErrAuthAPIKeyInvalid
+	}
 
-		return cachedAPIKeyAuth{
-			UserID:    key.UserID,
-			SessionID: sessions[0].ID.String(),
-			TgSession: sessions[0].TgSession,
-		}, nil
+	cached, err := cache.Fetch(ctx, c, cache.KeyS3AccessKey(id.String()), 24*time.Hour, func() (cachedAPIKeyAuth, error) {
+		key, err := apiKeys.GetActiveByID(ctx, id, time.Now().UTC())
+		if err != nil {
+			return cachedAPIKeyAuth{}, ErrAuthAPIKeyInvalid
+		}
+		return apiKeyAuth(ctx, sessions, apiKeys, key)
 	})
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	claims := &types.JWTClaims{
-		RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(cached.UserID, 10)},
-		SessionID:        uuid.MustParse(cached.SessionID),
-		TgSession:        cached.TgSession,
+	return cached.claims(), S3SecretKey(jwtSecret, cached.TokenHash), nil
+}
+
+// S3SecretKey derives the S3 secret access key of an API key from its token
+// hash. The secret is never stored, so a leaked database alone is not enough
+// to sign requests.
+func S3SecretKey(jwtSecret, tokenHash string) string {
+	mac := hmac.New(sha256.New, []byte(jwtSecret))
+	mac.Write([]byte("s3:" + tokenHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func apiKeyAuth(ctx context.Context, sessions repositories.SessionRepository, apiKeys repositories.APIKeyRepository, key *jetmodel.APIKeys) (cachedAPIKeyAuth, error) {
+	userSessions, err := sessions.GetByUserID(ctx, key.UserID)
+	if err != nil {
+		return cachedAPIKeyAuth{}, ErrAuthAPIKeySessionMiss
+	}
+	if len(userSessions) == 0 {
+		return cachedAPIKeyAuth{}, ErrAuthAPIKeySessionMiss
 	}
 
-	return claims, nil
+	_ = apiKeys.TouchLastUsed(ctx, key.ID, time.Now().UTC())
+
+	auth := cachedAPIKeyAuth{
+		UserID:    key.UserID,
+		SessionID: userSessions[0].ID.String(),
+		TgSession: userSessions[0].TgSession,
+		TokenHash: key.TokenHash,
+		FolderID:  key.FolderID,
+	}
+	if key.Scopes != nil {
+		auth.Scopes = key.Scopes.Data
+	}
+	return auth, nil
 }
 
 func hashToken(token string) string {
@@ -8,4 +8,5 @@ var (
 	ErrAuthSessionInvalid    = errors.New("auth.session_invalid")
 	ErrAuthAPIKeyInvalid     = errors.New("auth.api_key_invalid")
 	ErrAuthAPIKeySessionMiss = errors.New("auth.api_key_session_missing")
+	ErrAuthAPIKeyScope       = errors.New("auth.api_key_scope")
 )
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"slices"
+
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/pkg/queue"
+	"github.com/tgdrive/teldrive/pkg/types"
+)
+
+// API key scopes. A key without scopes and without a folder has the full
+// power of its user.
+const (
+	ScopeFilesRead  = "files:read"
+	ScopeFilesWrite = "files:write"
+	ScopeUploads    = "uploads"
+	ScopeJobs       = "jobs"
+	ScopeShares     = "shares"
+)
+
+// operationScopes maps the operations a restricted key may call to the scope
+// they need. Operations missing here need an unrestricted key.
+var operationScopes = map[api.OperationName]string{
+	api.FilesListOperation:           ScopeFilesRead,
+	api.FilesArchiveOperation:        ScopeFilesRead,
+	api.FilesGetByIdOperation:        ScopeFilesRead,
+	api.FilesStreamOperation:         ScopeFilesRead,
+	api.FilesStreamHeadOperation:     ScopeFilesRead,
+	api.FilesStreamVersionOperation:  ScopeFilesRead,
+	api.FilesListVersionsOperation:   ScopeFilesRead,
+	api.FilesCategoryStatsOperation:  ScopeFilesRead,
+	api.FilesChangesOperation:        ScopeFilesRead,
+	api.FilesListTrashOperation:      ScopeFilesRead,
+	api.FilesCreateOperation:         ScopeFilesWrite,
+	api.FilesUpdateOperation:         ScopeFilesWrite,
+	api.FilesCopyOperation:           ScopeFilesWrite,
+	api.FilesMoveOperation:           ScopeFilesWrite,
+	api.FilesDeleteOperation:         ScopeFilesWrite,
+	api.FilesDeleteByIdOperation:     ScopeFilesWrite,
+	api.FilesRestoreVersionOperation: ScopeFilesWrite,
+	api.FilesRestoreTrashOperation:   ScopeFilesWrite,
+	api.FilesEmptyTrashOperation:     ScopeFilesWrite,
+	api.UploadsUploadOperation:       ScopeUploads,
+	api.UploadsPartsByIdOperation:    ScopeUploads,
+	api.UploadsStatsOperation:        ScopeUploads,
+	api.UploadsDeleteOperation:       ScopeUploads,
+	api.JobsListOperation:            ScopeJobs,
+	api.JobsGetOperation:             ScopeJobs,
+	api.JobsInsertOperation:          ScopeJobs,
+	api.JobsCancelOperation:          ScopeJobs,
+	api.JobsDeleteOperation:          ScopeJobs,
+	api.PeriodicJobsListOperation:    ScopeJobs,
+	api.PeriodicJobsGetOperation:     ScopeJobs,
+	api.PeriodicJobsCreateOperation:  ScopeJobs,
+	api.PeriodicJobsUpdateOperation:  ScopeJobs,
+	api.PeriodicJobsDeleteOperation:  ScopeJobs,
+	api.PeriodicJobsEnableOperation:  ScopeJobs,
+	api.PeriodicJobsDisableOperation: ScopeJobs,
+	api.PeriodicJobsRunOperation:     ScopeJobs,
+	api.PeriodicJobsPlanOperation:    ScopeJobs,
+	api.FilesListSharesOperation:     ScopeShares,
+	api.FilesCreateShareOperation:    ScopeShares,
+	api.FilesEditShareOperation:      ScopeShares,
+	api.FilesDeleteShareOperation:    ScopeShares,
+}
+
+// driveWideOperations act on the whole drive rather than on given files, so
+// keys limited to a folder cannot call them.
+var driveWideOperations = map[api.OperationName]bool{
+	api.FilesCategoryStatsOperation:  true,
+	api.FilesChangesOperation:        true,
+	api.FilesListTrashOperation:      true,
+	api.FilesRestoreTrashOperation:   true,
+	api.FilesEmptyTrashOperation:     true,
+	api.JobsListOperation:            true,
+	api.JobsGetOperation:             true,
+	api.JobsInsertOperation:          true,
+	api.JobsCancelOperation:          true,
+	api.JobsDeleteOperation:          true,
+	api.PeriodicJobsListOperation:    true,
+	api.PeriodicJobsGetOperation:     true,
+	api.PeriodicJobsCreateOperation:  true,
+	api.PeriodicJobsUpdateOperation:  true,
+	api.PeriodicJobsDeleteOperation:  true,
+	api.PeriodicJobsEnableOperation:  true,
+	api.PeriodicJobsDisableOperation: true,
+	api.PeriodicJobsRunOperation:     true,
+	api.PeriodicJobsPlanOperation:    true,
+}
+
+// jobKindScopes lists the scope a key needs, besides ScopeJobs, to start jobs
+// that read or change files.
+var jobKindScopes = map[string]string{
+	queue.JobKindFilesCopy:   ScopeFilesWrite,
+	queue.JobKindFilesMove:   ScopeFilesWrite,
+	queue.JobKindFilesDelete: ScopeFilesWrite,
+	queue.JobKindSyncRun:     ScopeFilesWrite,
+	queue.JobKindSyncPush:    ScopeFilesRead,
+}
+
+// Restricted reports whether claims come from a key with scopes or a folder.
+func Restricted(claims *types.JWTClaims) bool {
+	return claims != nil && (len(claims.Scopes) > 0 || claims.FolderID != nil)
+}
+
+// CheckScope returns ErrAuthAPIKeyScope when claims may not call operation.
+// A key limited to a folder but without scopes may use every scoped
+// operation inside that folder.
+func CheckScope(claims *types.JWTClaims, operation api.OperationName) error {
+	if !Restricted(claims) || operation == api.AuthSessionOperation {
+		return nil
+	}
+	scope, ok := operationScopes[operation]
+	if !ok {
+		return ErrAuthAPIKeyScope
+	}
+	if len(claims.Scopes) > 0 && !slices.Contains(claims.Scopes, scope) {
+		return ErrAuthAPIKeyScope
+	}
+	if claims.FolderID != nil && driveWideOperations[operation] {
+		return ErrAuthAPIKeyScope
+	}
+	return nil
+}
+
+// CheckJobScope returns ErrAuthAPIKeyScope when claims may not start a job of
+// kind, so a key limited to jobs cannot reach files through them.
+func CheckJobScope(claims *types.JWTClaims, kind string) error {
+	if !Restricted(claims) || len(claims.Scopes) == 0 {
+		return nil
+	}
+	scope, ok := jobKindScopes[kind]
+	if ok && !slices.Contains(claims.Scopes, scope) {
+		return ErrAuthAPIKeyScope
+	}
+	return nil
+}
+
+// CheckGatewayScope applies key restrictions to the WebDAV and S3 gateways.
+// They expose the whole drive, so keys limited to a folder are refused.
+func CheckGatewayScope(claims *types.JWTClaims, write bool) error {
+	if !Restricted(claims) {
+		return nil
+	}
+	if claims.FolderID != nil {
+		return ErrAuthAPIKeyScope
+	}
+	scope := ScopeFilesRead
+	if write {
+		scope = ScopeFilesWrite
+	}
+	if !slices.Contains(claims.Scopes, scope) {
+		return ErrAuthAPIKeyScope
+	}
+	return nil
+}
@@ -13,6 +13,10 @@ func KeyUserSessions(userID int64) string {
 	return Key("users", "sessions", userID)
 }
 
+func KeyUserWebhooks(userID int64) string {
+	return Key("users", "webhooks", userID)
+}
+
 // File Keys
 func KeyFile(fileID string) string {
 	return Key("files", fileID)
@@ -40,6 +44,10 @@ func KeyAPIKeyAuth(tokenHash string) string {
 	return Key("auth", "api_keys", tokenHash)
 }
 
+func KeyS3AccessKey(accessKeyID string) string {
+	return Key("auth", "api_keys", "s3", accessKeyID)
+}
+
 func KeyAPIKeyAuthPattern() string {
 	return Key("auth", "api_keys", "*")
 }
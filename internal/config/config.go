@@ -42,6 +42,7 @@ func getKey(f reflect.StructField) string {
 }
 
 type EventConfig struct {
+	Broadcaster      string        `default:"auto" description:"How events reach other instances: auto, polling, redis, postgres. auto uses redis when configured, polling otherwise"`
 	PollInterval     time.Duration `default:"10s" description:"Event polling interval for single-instance mode"`
 	DBWorkers        int           `default:"10" description:"Number of DB worker goroutines for event persistence"`
 	DBBufferSize     int           `default:"1000" description:"Size of DB worker queue buffer"`
@@ -59,6 +60,26 @@ type ServerCmdConfig struct {
 	Queue  QueueConfig
 	Jobs   JobsConfig
 	Events EventConfig
+	Trash  TrashConfig
+	WebDAV WebDAVConfig `koanf:"webdav"`
+	S3     S3Config
+}
+
+type TrashConfig struct {
+	Retention time.Duration `default:"30d" description:"How long deleted files stay in the trash before they are purged. Set to 0 to skip the trash"`
+}
+
+type WebDAVConfig struct {
+	Enabled   bool   `default:"false" description:"Serve the file tree over WebDAV"`
+	Prefix    string `default:"/webdav" description:"URL path the WebDAV server is mounted at"`
+	ChunkSize int64  `default:"524288000" description:"Part size in bytes for files written over WebDAV"`
+}
+
+type S3Config struct {
+	Enabled   bool   `default:"false" description:"Serve an S3-compatible API on its own port"`
+	Port      int    `default:"8090" description:"HTTP port for the S3 gateway to listen on"`
+	Region    string `default:"us-east-1" description:"Region reported to S3 clients"`
+	ChunkSize int64  `default:"524288000" description:"Part size in bytes for objects uploaded with a single PUT"`
 }
 
 type QueueConfig struct {
@@ -67,8 +88,13 @@ type QueueConfig struct {
 }
 
 type JobsConfig struct {
-	SyncRun      SyncRunJobConfig
-	SyncTransfer SyncTransferJobConfig
+	SyncRun        SyncRunJobConfig
+	SyncTransfer   SyncTransferJobConfig
+	SyncPush       SyncPushJobConfig
+	FilesCopy      FilesCopyJobConfig
+	FilesMove      FilesMoveJobConfig
+	FilesDelete    FilesDeleteJobConfig
+	WebhookDeliver WebhookDeliverJobConfig
 }
 
 type SyncRunJobConfig struct {
@@ -76,8 +102,32 @@ type SyncRunJobConfig struct {
 }
 
 type SyncTransferJobConfig struct {
-	MaxAttempts int           `default:"2" description:"Maximum retry attempts for sync.transfer jobs"`
-	Timeout     time.Duration `default:"3h" description:"Maximum execution time for sync.transfer jobs"`
+	MaxAttempts   int           `default:"2" description:"Maximum retry attempts for sync.transfer jobs"`
+	Timeout       time.Duration `default:"3h" description:"Maximum execution time for sync.transfer jobs"`
+	ParallelParts int           `default:"4" description:"Parts of one file a sync.transfer job uploads at the same time when the source supports ranged reads"`
+}
+
+type SyncPushJobConfig struct {
+	MaxAttempts int           `default:"3" description:"Maximum retry attempts for sync.push jobs"`
+	Timeout     time.Duration `default:"6h" description:"Maximum execution time for sync.push jobs"`
+}
+
+type FilesCopyJobConfig struct {
+	Timeout time.Duration `default:"3h" description:"Maximum execution time for files.copy jobs"`
+}
+
+type FilesMoveJobConfig struct {
+	Timeout time.Duration `default:"1h" description:"Maximum execution time for files.move jobs"`
+}
+
+type FilesDeleteJobConfig struct {
+	Timeout time.Duration `default:"1h" description:"Maximum execution time for files.delete jobs"`
+}
+
+type WebhookDeliverJobConfig struct {
+	MaxAttempts  int           `default:"8" description:"Maximum retry attempts for webhooks.deliver jobs"`
+	Timeout      time.Duration `default:"30s" description:"Maximum time to wait for a webhook endpoint to answer"`
+	AllowPrivate bool          `default:"false" description:"Allow webhooks to loopback, link-local and private network addresses"`
 }
 
 type CheckCmdConfig struct {
@@ -150,6 +200,7 @@ type JWTConfig struct {
 	Secret       string        `validate:"required" default:"" description:"JWT signing secret key"`
 	SessionTime  time.Duration `default:"30d" description:"JWT token validity duration"`
 	AllowedUsers []string      `default:"" description:"List of allowed usernames"`
+	AdminUsers   []string      `default:"" description:"List of usernames allowed to use the admin API"`
 }
 
 type DBPool struct {
@@ -169,6 +220,14 @@ type TGStream struct {
 	Buffers      int           `default:"8" description:"Number of stream buffers"`
 	ChunkTimeout time.Duration `default:"30s" description:"Chunk download timeout"`
 	BotsLimit    int           `default:"0" description:"Maximum number of bots for streaming (0 = use all bots)"`
+	MultiBots    bool          `default:"false" description:"Download chunks of one stream through several bots in parallel"`
+	Cache        TGStreamCache
+}
+
+type TGStreamCache struct {
+	Enabled bool   `default:"false" description:"Keep downloaded stream chunks on disk so repeated reads are served locally"`
+	Dir     string `default:"" description:"Directory for cached chunks (empty for $HOME/.teldrive/chunks)"`
+	MaxSize int64  `default:"10737418240" description:"Maximum size of the chunk cache in bytes"`
 }
 
 type TGUpload struct {
@@ -177,6 +236,7 @@ type TGUpload struct {
 	MaxRetries    int           `default:"10" description:"Maximum upload retry attempts"`
 	Retention     time.Duration `default:"7d" description:"Upload retention period"`
 	ChunkNaming   string        `default:"random" description:"Upload chunk naming mode (random, deterministic)"`
+	Dedup         bool          `default:"false" description:"Reuse the parts of an existing file with the same content hash instead of storing a second copy"`
 }
 
 type TGMTProxy struct {
@@ -9,6 +9,7 @@ package model
 
 import (
 	"github.com/google/uuid"
+	"github.com/tgdrive/teldrive/internal/database/types"
 	"time"
 )
 
@@ -22,4 +23,6 @@ type APIKeys struct {
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 	RevokedAt  *time.Time
+	Scopes     *types.JSONB[[]string]
+	FolderID   *uuid.UUID
 }
@@ -14,19 +14,21 @@ import (
 )
 
 type Files struct {
-	Name      string
-	Type      string
-	MimeType  string
-	Size      *int64
-	UserID    int64
-	Status    *string
-	ChannelID *int64
-	Parts     *types.JSONB[types.Parts]
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Encrypted bool
-	Category  *string
-	ID        uuid.UUID `sql:"primary_key"`
-	ParentID  *uuid.UUID
-	Hash      *string
+	Name        string
+	Type        string
+	MimeType    string
+	Size        *int64
+	UserID      int64
+	Status      *string
+	ChannelID   *int64
+	Parts       *types.JSONB[types.Parts]
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Encrypted   bool
+	Category    *string
+	ID          uuid.UUID `sql:"primary_key"`
+	ParentID    *uuid.UUID
+	Hash        *string
+	TrashedAt   *time.Time
+	TrashedPath *string
 }
@@ -26,6 +26,8 @@ type aPIKeysTable struct {
 	CreatedAt  postgres.ColumnTimestamp
 	UpdatedAt  postgres.ColumnTimestamp
 	RevokedAt  postgres.ColumnTimestamp
+	Scopes     postgres.ColumnString
+	FolderID   postgres.ColumnString
 
 	AllColumns     postgres.ColumnList
 	MutableColumns postgres.ColumnList
@@ -76,8 +78,10 @@ func newAPIKeysTableImpl(schemaName, tableName, alias string) aPIKeysTable {
 		CreatedAtColumn  = postgres.TimestampColumn("created_at")
 		UpdatedAtColumn  = postgres.TimestampColumn("updated_at")
 		RevokedAtColumn  = postgres.TimestampColumn("revoked_at")
-		allColumns       = postgres.ColumnList{IDColumn, UserIDColumn, NameColumn, TokenHashColumn, ExpiresAtColumn, LastUsedAtColumn, CreatedAtColumn, UpdatedAtColumn, RevokedAtColumn}
-		mutableColumns   = postgres.ColumnList{UserIDColumn, NameColumn, TokenHashColumn, ExpiresAtColumn, LastUsedAtColumn, CreatedAtColumn, UpdatedAtColumn, RevokedAtColumn}
+		ScopesColumn     = postgres.StringColumn("scopes")
+		FolderIDColumn   = postgres.StringColumn("folder_id")
+		allColumns       = postgres.ColumnList{IDColumn, UserIDColumn, NameColumn, TokenHashColumn, ExpiresAtColumn, LastUsedAtColumn, CreatedAtColumn, UpdatedAtColumn, RevokedAtColumn, ScopesColumn, FolderIDColumn}
+		mutableColumns   = postgres.ColumnList{UserIDColumn, NameColumn, TokenHashColumn, ExpiresAtColumn, LastUsedAtColumn, CreatedAtColumn, UpdatedAtColumn, RevokedAtColumn, ScopesColumn, FolderIDColumn}
 		defaultColumns   = postgres.ColumnList{IDColumn, CreatedAtColumn, UpdatedAtColumn}
 	)
 
@@ -94,6 +98,8 @@ func newAPIKeysTableImpl(schemaName, tableName, alias string) aPIKeysTable {
 		CreatedAt:  CreatedAtColumn,
 		UpdatedAt:  UpdatedAtColumn,
 		RevokedAt:  RevokedAtColumn,
+		Scopes:     ScopesColumn,
+		FolderID:   FolderIDColumn,
 
 		AllColumns:     allColumns,
 		MutableColumns: mutableColumns,
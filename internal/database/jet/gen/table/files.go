@@ -17,21 +17,23 @@ type filesTable struct {
 	postgres.Table
 
 	// Columns
-	Name      postgres.ColumnString
-	Type      postgres.ColumnString
-	MimeType  postgres.ColumnString
-	Size      postgres.ColumnInteger
-	UserID    postgres.ColumnInteger
-	Status    postgres.ColumnString
-	ChannelID postgres.ColumnInteger
-	Parts     postgres.ColumnString
-	CreatedAt postgres.ColumnTimestamp
-	UpdatedAt postgres.ColumnTimestamp
-	Encrypted postgres.ColumnBool
-	Category  postgres.ColumnString
-	ID        postgres.ColumnString
-	ParentID  postgres.ColumnString
-	Hash      postgres.ColumnString
+	Name        postgres.ColumnString
+	Type        postgres.ColumnString
+	MimeType    postgres.ColumnString
+	Size        postgres.ColumnInteger
+	UserID      postgres.ColumnInteger
+	Status      postgres.ColumnString
+	ChannelID   postgres.ColumnInteger
+	Parts       postgres.ColumnString
+	CreatedAt   postgres.ColumnTimestamp
+	UpdatedAt   postgres.ColumnTimestamp
+	Encrypted   postgres.ColumnBool
+	Category    postgres.ColumnString
+	ID          postgres.ColumnString
+	ParentID    postgres.ColumnString
+	Hash        postgres.ColumnString
+	TrashedAt   postgres.ColumnTimestamp
+	TrashedPath postgres.ColumnString
 
 	AllColumns     postgres.ColumnList
 	MutableColumns postgres.ColumnList
@@ -73,45 +75,49 @@ func newFilesTable(schemaName, tableName, alias string) *FilesTable {
 
 func newFilesTableImpl(schemaName, tableName, alias string) filesTable {
 	var (
-		NameColumn      = postgres.StringColumn("name")
-		TypeColumn      = postgres.StringColumn("type")
-		MimeTypeColumn  = postgres.StringColumn("mime_type")
-		SizeColumn      = postgres.IntegerColumn("size")
-		UserIDColumn    = postgres.IntegerColumn("user_id")
-		StatusColumn    = postgres.StringColumn("status")
-		ChannelIDColumn = postgres.IntegerColumn("channel_id")
-		PartsColumn     = postgres.StringColumn("parts")
-		CreatedAtColumn = postgres.TimestampColumn("created_at")
-		UpdatedAtColumn = postgres.TimestampColumn("updated_at")
-		EncryptedColumn = postgres.BoolColumn("encrypted")
-		CategoryColumn  = postgres.StringColumn("category")
-		IDColumn        = postgres.StringColumn("id")
-		ParentIDColumn  = postgres.StringColumn("parent_id")
-		HashColumn      = postgres.StringColumn("hash")
-		allColumns      = postgres.ColumnList{NameColumn, TypeColumn, MimeTypeColumn, SizeColumn, UserIDColumn, StatusColumn, ChannelIDColumn, PartsColumn, CreatedAtColumn, UpdatedAtColumn, EncryptedColumn, CategoryColumn, IDColumn, ParentIDColumn, HashColumn}
-		mutableColumns  = postgres.ColumnList{NameColumn, TypeColumn, MimeTypeColumn, SizeColumn, UserIDColumn, StatusColumn, ChannelIDColumn, PartsColumn, CreatedAtColumn, UpdatedAtColumn, EncryptedColumn, CategoryColumn, ParentIDColumn, HashColumn}
-		defaultColumns  = postgres.ColumnList{StatusColumn, CreatedAtColumn, UpdatedAtColumn, EncryptedColumn, IDColumn}
+		NameColumn        = postgres.StringColumn("name")
+		TypeColumn        = postgres.StringColumn("type")
+		MimeTypeColumn    = postgres.StringColumn("mime_type")
+		SizeColumn        = postgres.IntegerColumn("size")
+		UserIDColumn      = postgres.IntegerColumn("user_id")
+		StatusColumn      = postgres.StringColumn("status")
+		ChannelIDColumn   = postgres.IntegerColumn("channel_id")
+		PartsColumn       = postgres.StringColumn("parts")
+		CreatedAtColumn   = postgres.TimestampColumn("created_at")
+		UpdatedAtColumn   = postgres.TimestampColumn("updated_at")
+		EncryptedColumn   = postgres.BoolColumn("encrypted")
+		CategoryColumn    = postgres.StringColumn("category")
+		IDColumn          = postgres.StringColumn("id")
+		ParentIDColumn    = postgres.StringColumn("parent_id")
+		HashColumn        = postgres.StringColumn("hash")
+		TrashedAtColumn   = postgres.TimestampColumn("trashed_at")
+		TrashedPathColumn = postgres.StringColumn("trashed_path")
+		allColumns        = postgres.ColumnList{NameColumn, TypeColumn, MimeTypeColumn, SizeColumn, UserIDColumn, StatusColumn, ChannelIDColumn, PartsColumn, CreatedAtColumn, UpdatedAtColumn, EncryptedColumn, CategoryColumn, IDColumn, ParentIDColumn, HashColumn, TrashedAtColumn, TrashedPathColumn}
+		mutableColumns    = postgres.ColumnList{NameColumn, TypeColumn, MimeTypeColumn, SizeColumn, UserIDColumn, StatusColumn, ChannelIDColumn, PartsColumn, CreatedAtColumn, UpdatedAtColumn, EncryptedColumn, CategoryColumn, ParentIDColumn, HashColumn, TrashedAtColumn, TrashedPathColumn}
+		defaultColumns    = postgres.ColumnList{StatusColumn, CreatedAtColumn, UpdatedAtColumn, EncryptedColumn, IDColumn}
 	)
 
 	return filesTable{
 		Table: postgres.NewTable(schemaName, tableName, alias, allColumns...),
 
 		//Columns
-		Name:      NameColumn,
-		Type:      TypeColumn,
-		MimeType:  MimeTypeColumn,
-		Size:      SizeColumn,
-		UserID:    UserIDColumn,
-		Status:    StatusColumn,
-		ChannelID: ChannelIDColumn,
-		Parts:     PartsColumn,
-		CreatedAt: CreatedAtColumn,
-		UpdatedAt: UpdatedAtColumn,
-		Encrypted: EncryptedColumn,
-		Category:  CategoryColumn,
-		ID:        IDColumn,
-		ParentID:  ParentIDColumn,
-		Hash:      HashColumn,
+		Name:        NameColumn,
+		Type:        TypeColumn,
+		MimeType:    MimeTypeColumn,
+		Size:        SizeColumn,
+		UserID:      UserIDColumn,
+		Status:      StatusColumn,
+		ChannelID:   ChannelIDColumn,
+		Parts:       PartsColumn,
+		CreatedAt:   CreatedAtColumn,
+		UpdatedAt:   UpdatedAtColumn,
+		Encrypted:   EncryptedColumn,
+		Category:    CategoryColumn,
+		ID:          IDColumn,
+		ParentID:    ParentIDColumn,
+		Hash:        HashColumn,
+		TrashedAt:   TrashedAtColumn,
+		TrashedPath: TrashedPathColumn,
 
 		AllColumns:     allColumns,
 		MutableColumns: mutableColumns,
@@ -11,15 +11,20 @@ package table
 // this method only once at the beginning of the program.
 func UseSchema(schema string) {
 	APIKeys = APIKeys.FromSchema(schema)
+	AllowedUsers = AllowedUsers.FromSchema(schema)
 	Bots = Bots.FromSchema(schema)
 	Channels = Channels.FromSchema(schema)
 	CronJobs = CronJobs.FromSchema(schema)
+	EventPrunes = EventPrunes.FromSchema(schema)
 	Events = Events.FromSchema(schema)
 	FileShares = FileShares.FromSchema(schema)
+	FileVersions = FileVersions.FromSchema(schema)
 	Files = Files.FromSchema(schema)
 	Kv = Kv.FromSchema(schema)
 	PeriodicJobs = PeriodicJobs.FromSchema(schema)
 	Sessions = Sessions.FromSchema(schema)
 	Uploads = Uploads.FromSchema(schema)
 	Users = Users.FromSchema(schema)
+	WebhookDeliveries = WebhookDeliveries.FromSchema(schema)
+	Webhooks = Webhooks.FromSchema(schema)
 }
@@ -17,12 +17,16 @@ type usersTable struct {
 	postgres.Table
 
 	// Columns
-	UserID    postgres.ColumnInteger
-	Name      postgres.ColumnString
-	UserName  postgres.ColumnString
-	IsPremium postgres.ColumnBool
-	CreatedAt postgres.ColumnTimestampz
-	UpdatedAt postgres.ColumnTimestampz
+	UserID     postgres.ColumnInteger
+	Name       postgres.ColumnString
+	UserName   postgres.ColumnString
+	IsPremium  postgres.ColumnBool
+	CreatedAt  postgres.ColumnTimestampz
+	UpdatedAt  postgres.ColumnTimestampz
+	QuotaBytes postgres.ColumnInteger
+	QuotaFiles postgres.ColumnInteger
+	IsAdmin    postgres.ColumnBool
+	DisabledAt postgres.ColumnTimestampz
 
 	AllColumns     postgres.ColumnList
 	MutableColumns postgres.ColumnList
@@ -64,27 +68,35 @@ func newUsersTable(schemaName, tableName, alias string) *UsersTable {
 
 func newUsersTableImpl(schemaName, tableName, alias string) usersTable {
 	var (
-		UserIDColumn    = postgres.IntegerColumn("user_id")
-		NameColumn      = postgres.StringColumn("name")
-		UserNameColumn  = postgres.StringColumn("user_name")
-		IsPremiumColumn = postgres.BoolColumn("is_premium")
-		CreatedAtColumn = postgres.TimestampzColumn("created_at")
-		UpdatedAtColumn = postgres.TimestampzColumn("updated_at")
-		allColumns      = postgres.ColumnList{UserIDColumn, NameColumn, UserNameColumn, IsPremiumColumn, CreatedAtColumn, UpdatedAtColumn}
-		mutableColumns  = postgres.ColumnList{NameColumn, UserNameColumn, IsPremiumColumn, CreatedAtColumn, UpdatedAtColumn}
-		defaultColumns  = postgres.ColumnList{CreatedAtColumn, UpdatedAtColumn}
+		UserIDColumn     = postgres.IntegerColumn("user_id")
+		NameColumn       = postgres.StringColumn("name")
+		UserNameColumn   = postgres.StringColumn("user_name")
+		IsPremiumColumn  = postgres.BoolColumn("is_premium")
+		CreatedAtColumn  = postgres.TimestampzColumn("created_at")
+		UpdatedAtColumn  = postgres.TimestampzColumn("updated_at")
+		QuotaBytesColumn = postgres.IntegerColumn("quota_bytes")
+		QuotaFilesColumn = postgres.IntegerColumn("quota_files")
+		IsAdminColumn    = postgres.BoolColumn("is_admin")
+		DisabledAtColumn = postgres.TimestampzColumn("disabled_at")
+		allColumns       = postgres.ColumnList{UserIDColumn, NameColumn, UserNameColumn, IsPremiumColumn, CreatedAtColumn, UpdatedAtColumn, QuotaBytesColumn, QuotaFilesColumn, IsAdminColumn, DisabledAtColumn}
+		mutableColumns   = postgres.ColumnList{NameColumn, UserNameColumn, IsPremiumColumn, CreatedAtColumn, UpdatedAtColumn, QuotaBytesColumn, QuotaFilesColumn, IsAdminColumn, DisabledAtColumn}
+		defaultColumns   = postgres.ColumnList{CreatedAtColumn, UpdatedAtColumn, IsAdminColumn}
 	)
 
 	return usersTable{
 		Table: postgres.NewTable(schemaName, tableName, alias, allColumns...),
 
 		//Columns
-		UserID:    UserIDColumn,
-		Name:      NameColumn,
-		UserName:  UserNameColumn,
-		IsPremium: IsPremiumColumn,
-		CreatedAt: CreatedAtColumn,
-		UpdatedAt: UpdatedAtColumn,
+		UserID:     UserIDColumn,
+		Name:       NameColumn,
+		UserName:   UserNameColumn,
+		IsPremium:  IsPremiumColumn,
+		CreatedAt:  CreatedAtColumn,
+		UpdatedAt:  UpdatedAtColumn,
+		QuotaBytes: QuotaBytesColumn,
+		QuotaFiles: QuotaFilesColumn,
+		IsAdmin:    IsAdminColumn,
+		DisabledAt: DisabledAtColumn,
 
 		AllColumns:     allColumns,
 		MutableColumns: mutableColumns,
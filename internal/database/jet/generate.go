@@ -43,6 +43,12 @@ func Generate(dbURL, outputDir string) error {
 										Name:                  "*types.JSONB[any]",
 										AdditionalImportPaths: []string{"github.com/tgdrive/teldrive/internal/database/types"}}
 								}
+								if table.Name == "webhooks" &&
+									column.Name == "events" {
+									defaultTableModelField.Type = template.Type{
+										Name:                  "types.JSONB[[]string]",
+										AdditionalImportPaths: []string{"github.com/tgdrive/teldrive/internal/database/types"}}
+								}
 								return defaultTableModelField
 							})
 						}),
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE teldrive.files ADD COLUMN IF NOT EXISTS trashed_at timestamp;
+ALTER TABLE teldrive.files ADD COLUMN IF NOT EXISTS trashed_path text;
+
+CREATE INDEX IF NOT EXISTS idx_files_trash ON teldrive.files (user_id, trashed_at DESC, id DESC)
+  WHERE status = 'trashed' AND trashed_path IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE teldrive.files SET status = 'pending_deletion' WHERE status = 'trashed';
+DROP INDEX IF EXISTS teldrive.idx_files_trash;
+ALTER TABLE teldrive.files DROP COLUMN IF EXISTS trashed_path;
+ALTER TABLE teldrive.files DROP COLUMN IF EXISTS trashed_at;
+-- +goose StatementEnd
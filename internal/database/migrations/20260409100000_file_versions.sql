@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS teldrive.file_versions (
+    id uuid NOT NULL DEFAULT gen_random_uuid(),
+    file_id uuid NOT NULL,
+    user_id bigint NOT NULL,
+    version integer NOT NULL,
+    size bigint NULL,
+    parts jsonb NULL,
+    channel_id bigint NULL,
+    encrypted boolean NOT NULL DEFAULT false,
+    hash text NULL,
+    updated_at timestamp NOT NULL,
+    created_at timestamp NOT NULL DEFAULT timezone('utc'::text, now()),
+    CONSTRAINT file_versions_pkey PRIMARY KEY (id),
+    CONSTRAINT file_versions_file_id_version_key UNIQUE (file_id, version),
+    CONSTRAINT fk_file FOREIGN KEY (file_id) REFERENCES teldrive.files (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_versions_user_id ON teldrive.file_versions USING btree (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS teldrive.file_versions;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_files_hash ON teldrive.files (user_id, hash, size)
+  WHERE status = 'active' AND hash IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS teldrive.idx_files_hash;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE teldrive.users
+  ADD COLUMN IF NOT EXISTS quota_bytes BIGINT,
+  ADD COLUMN IF NOT EXISTS quota_files BIGINT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE teldrive.users
+  DROP COLUMN IF EXISTS quota_files,
+  DROP COLUMN IF EXISTS quota_bytes;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE teldrive.users
+  ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS teldrive.allowed_users (
+  user_name TEXT PRIMARY KEY,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now())
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS teldrive.allowed_users;
+
+ALTER TABLE teldrive.users
+  DROP COLUMN IF EXISTS disabled_at,
+  DROP COLUMN IF EXISTS is_admin;
+-- +goose StatementEnd
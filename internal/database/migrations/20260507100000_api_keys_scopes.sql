@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE teldrive.api_keys
+  ADD COLUMN IF NOT EXISTS scopes JSONB,
+  ADD COLUMN IF NOT EXISTS folder_id UUID;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE teldrive.api_keys
+  DROP COLUMN IF EXISTS folder_id,
+  DROP COLUMN IF EXISTS scopes;
+-- +goose StatementEnd
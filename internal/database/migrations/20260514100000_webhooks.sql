@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS teldrive.webhooks (
+    id uuid NOT NULL DEFAULT gen_random_uuid(),
+    user_id bigint NOT NULL,
+    name text NOT NULL,
+    url text NOT NULL,
+    events jsonb NOT NULL,
+    secret text NOT NULL,
+    enabled boolean NOT NULL DEFAULT true,
+    created_at timestamp NOT NULL DEFAULT timezone('utc'::text, now()),
+    updated_at timestamp NOT NULL DEFAULT timezone('utc'::text, now()),
+    CONSTRAINT webhooks_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON teldrive.webhooks USING btree (user_id);
+
+CREATE TABLE IF NOT EXISTS teldrive.webhook_deliveries (
+    id uuid NOT NULL DEFAULT gen_random_uuid(),
+    webhook_id uuid NOT NULL,
+    event_id uuid NOT NULL,
+    event_type text NOT NULL,
+    attempt integer NOT NULL,
+    status_code integer NULL,
+    error text NULL,
+    duration_ms bigint NOT NULL,
+    created_at timestamp NOT NULL DEFAULT timezone('utc'::text, now()),
+    CONSTRAINT webhook_deliveries_pkey PRIMARY KEY (id),
+    CONSTRAINT fk_webhook FOREIGN KEY (webhook_id) REFERENCES teldrive.webhooks (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON teldrive.webhook_deliveries USING btree (webhook_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS teldrive.webhook_deliveries;
+DROP TABLE IF EXISTS teldrive.webhooks;
+-- +goose StatementEnd
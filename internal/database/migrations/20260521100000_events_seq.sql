@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE teldrive.events ADD COLUMN IF NOT EXISTS seq bigint GENERATED ALWAYS AS IDENTITY;
+CREATE INDEX IF NOT EXISTS idx_events_user_id_seq ON teldrive.events USING btree (user_id, seq);
+
+CREATE TABLE IF NOT EXISTS teldrive.event_prunes (
+    user_id bigint PRIMARY KEY,
+    seq bigint NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS teldrive.event_prunes;
+DROP INDEX IF EXISTS teldrive.idx_events_user_id_seq;
+ALTER TABLE teldrive.events DROP COLUMN IF EXISTS seq;
+-- +goose StatementEnd
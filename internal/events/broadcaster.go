@@ -16,18 +16,36 @@ import (
 type EventType = api.EventType
 
 const (
-	OpCreate         EventType = api.EventTypeFilesCreated
-	OpUpdate         EventType = api.EventTypeFilesUpdated
-	OpDelete         EventType = api.EventTypeFilesDeleted
-	OpMove           EventType = api.EventTypeFilesMoved
-	OpCopy           EventType = api.EventTypeFilesCopied
-	OpUploadProgress EventType = api.EventTypeUploadsProgress
-	OpJobProgress    EventType = api.EventTypeJobsProgress
+	OpCreate          EventType = api.EventTypeFilesCreated
+	OpUpdate          EventType = api.EventTypeFilesUpdated
+	OpDelete          EventType = api.EventTypeFilesDeleted
+	OpMove            EventType = api.EventTypeFilesMoved
+	OpCopy            EventType = api.EventTypeFilesCopied
+	OpUploadProgress  EventType = api.EventTypeUploadsProgress
+	OpJobProgress     EventType = api.EventTypeJobsProgress
+	OpJobComplete     EventType = api.EventTypeJobsCompleted
+	OpJobFail         EventType = api.EventTypeJobsFailed
+	OpShareCreate     EventType = api.EventTypeSharesCreated
+	OpShareUnlock     EventType = api.EventTypeSharesUnlocked
+	OpChannelRollover EventType = api.EventTypeChannelsRollover
+	OpSessionCreate   EventType = api.EventTypeSessionsCreated
+	OpAPIKeyCreate    EventType = api.EventTypeApiKeysCreated
+	OpWebhookTest     EventType = api.EventTypeWebhooksTest
 )
 
 const (
 	// Redis channel name for events
 	redisChannel = "teldrive:events"
+	// Postgres channel name for events
+	postgresChannel = "teldrive_events"
+	// Largest NOTIFY payload sent, Postgres refuses more than 8000 bytes
+	postgresMaxPayload = 7900
+	// Events read back from the database per query after a reconnect
+	postgresCatchUpPageSize = 1000
+	// How far before the last sign of a live connection a catch-up starts,
+	// for events whose notification was still in flight. Anything seen twice
+	// is dropped by deduplication.
+	postgresCatchUpMargin = time.Minute
 	// Reconnect delay
 	reconnectDelay = 5 * time.Second
 	// Default values (used if not configured)
@@ -41,9 +59,15 @@ type EventBroadcaster interface {
 	Subscribe(userID int64, eventTypes []EventType) chan dto.Event
 	Unsubscribe(userID int64, ch chan dto.Event)
 	Record(eventType EventType, userID int64, source *dto.Source)
+	AddRecordHook(hook RecordHook)
 	Shutdown()
 }
 
+// RecordHook is called with every event recorded on this instance, before it
+// is persisted or fanned out. Hooks run on the caller of Record, so they must
+// not block.
+type RecordHook func(evt dto.Event)
+
 type eventSubscriber struct {
 	ch      chan dto.Event
 	filters map[EventType]struct{}
@@ -78,6 +102,8 @@ type baseBroadcaster struct {
 	recentEvents map[string]time.Time // Event ID -> timestamp for deduplication
 	eventMu      sync.RWMutex
 	config       BroadcasterConfig
+	hooks        []RecordHook
+	hookMu       sync.RWMutex
 }
 
 // newBaseBroadcaster creates a new base broadcaster with DB worker pool
@@ -258,6 +284,24 @@ func (b *baseBroadcaster) Unsubscribe(userID int64, ch chan dto.Event) {
 		zap.Int64("user_id", userID))
 }
 
+// AddRecordHook registers hook to run for every recorded event
+func (b *baseBroadcaster) AddRecordHook(hook RecordHook) {
+	b.hookMu.Lock()
+	b.hooks = append(b.hooks, hook)
+	b.hookMu.Unlock()
+}
+
+// runRecordHooks passes a freshly recorded event to the registered hooks
+func (b *baseBroadcaster) runRecordHooks(evt dto.Event) {
+	b.hookMu.RLock()
+	hooks := b.hooks
+	b.hookMu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(evt)
+	}
+}
+
 // queueForDB queues event for DB write (non-blocking)
 func (b *baseBroadcaster) queueForDB(evt dto.Event) bool {
 	select {
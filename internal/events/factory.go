@@ -2,19 +2,43 @@ package events
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
 	"github.com/tgdrive/teldrive/pkg/repositories"
 	"go.uber.org/zap"
 )
 
-func NewBroadcaster(ctx context.Context, eventsRepo repositories.EventRepository, redisClient *redis.Client, pollInterval time.Duration, config BroadcasterConfig, logger *zap.Logger) EventBroadcaster {
-	if redisClient != nil {
+// NewBroadcaster creates the broadcaster named by kind: polling, redis or
+// postgres. An empty kind or auto picks Redis when it is configured and
+// polling otherwise.
+func NewBroadcaster(ctx context.Context, kind string, eventsRepo repositories.EventRepository, redisClient *redis.Client, pool *pgxpool.Pool, pollInterval time.Duration, config BroadcasterConfig, logger *zap.Logger) (EventBroadcaster, error) {
+	switch kind {
+	case "", "auto":
+		if redisClient != nil {
+			logger.Debug("events.using_redis_broadcaster")
+			return NewRedisBroadcaster(ctx, eventsRepo, redisClient, config, logger), nil
+		}
+	case "polling":
+	case "redis":
+		if redisClient == nil {
+			return nil, errors.New("redis event broadcaster needs redis to be configured")
+		}
 		logger.Debug("events.using_redis_broadcaster")
-		return NewRedisBroadcaster(ctx, eventsRepo, redisClient, config, logger)
+		return NewRedisBroadcaster(ctx, eventsRepo, redisClient, config, logger), nil
+	case "postgres", "postgresql", "pg":
+		if pool == nil {
+			return nil, errors.New("postgres event broadcaster needs a database pool")
+		}
+		logger.Debug("events.using_postgres_broadcaster")
+		return NewPostgresBroadcaster(ctx, eventsRepo, pool, config, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown event broadcaster: %s", kind)
 	}
 
 	logger.Debug("events.using_polling_broadcaster", zap.Duration("poll_interval", pollInterval))
-	return NewPollingBroadcaster(ctx, eventsRepo, pollInterval, config, logger)
+	return NewPollingBroadcaster(ctx, eventsRepo, pollInterval, config, logger), nil
 }
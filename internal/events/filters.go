@@ -8,13 +8,20 @@ import (
 )
 
 var validEventTypes = map[EventType]struct{}{
-	api.EventTypeFilesCreated:    {},
-	api.EventTypeFilesUpdated:    {},
-	api.EventTypeFilesDeleted:    {},
-	api.EventTypeFilesMoved:      {},
-	api.EventTypeFilesCopied:     {},
-	api.EventTypeUploadsProgress: {},
-	api.EventTypeJobsProgress:    {},
+	api.EventTypeFilesCreated:     {},
+	api.EventTypeFilesUpdated:     {},
+	api.EventTypeFilesDeleted:     {},
+	api.EventTypeFilesMoved:       {},
+	api.EventTypeFilesCopied:      {},
+	api.EventTypeUploadsProgress:  {},
+	api.EventTypeJobsProgress:     {},
+	api.EventTypeJobsCompleted:    {},
+	api.EventTypeJobsFailed:       {},
+	api.EventTypeSharesCreated:    {},
+	api.EventTypeSharesUnlocked:   {},
+	api.EventTypeChannelsRollover: {},
+	api.EventTypeSessionsCreated:  {},
+	api.EventTypeApiKeysCreated:   {},
 }
 
 var eventTypeGroups = map[string][]EventType{
@@ -30,6 +37,21 @@ var eventTypeGroups = map[string][]EventType{
 	},
 	"jobs.*": {
 		api.EventTypeJobsProgress,
+		api.EventTypeJobsCompleted,
+		api.EventTypeJobsFailed,
+	},
+	"shares.*": {
+		api.EventTypeSharesCreated,
+		api.EventTypeSharesUnlocked,
+	},
+	"channels.*": {
+		api.EventTypeChannelsRollover,
+	},
+	"sessions.*": {
+		api.EventTypeSessionsCreated,
+	},
+	"apiKeys.*": {
+		api.EventTypeApiKeysCreated,
 	},
 }
 
@@ -19,12 +19,13 @@ func eventToModel(evt dto.Event) (*jetmodel.Events, error) {
 		return nil, err
 	}
 
+	// CreatedAt is left to the database, so stored times follow the order
+	// in which events get their sequence numbers.
 	return &jetmodel.Events{
-		ID:        id,
-		Type:      evt.Type,
-		UserID:    evt.UserID,
-		Source:    source,
-		CreatedAt: evt.CreatedAt,
+		ID:     id,
+		Type:   evt.Type,
+		UserID: evt.UserID,
+		Source: source,
 	}, nil
 }
 
@@ -218,6 +218,7 @@ func (b *PollingBroadcaster) Unsubscribe(userID int64, ch chan dto.Event) {
 func (b *PollingBroadcaster) Record(eventType EventType, userID int64, source *dto.Source) {
 	evt := createEvent(eventType, userID, source)
 	// ID is already generated by createEvent()
+	b.runRecordHooks(evt)
 
 	b.broadcast(evt)
 	// Only save to DB - poll() will discover and broadcast it
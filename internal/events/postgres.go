@@ -0,0 +1,248 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/tgdrive/teldrive/pkg/dto"
+	"github.com/tgdrive/teldrive/pkg/repositories"
+	"go.uber.org/zap"
+)
+
+// PostgresBroadcaster implements EventBroadcaster using Postgres LISTEN/NOTIFY
+// for distributed setups that do not run Redis
+type PostgresBroadcaster struct {
+	*baseBroadcaster
+	pool     *pgxpool.Pool
+	lastSeen time.Time // Last successful LISTEN or notification
+	lostAt   time.Time // Where to catch up from after a reconnect, zero while connected
+}
+
+// NewPostgresBroadcaster creates a new Postgres-based event broadcaster
+func NewPostgresBroadcaster(ctx context.Context, eventsRepo repositories.EventRepository, pool *pgxpool.Pool, config BroadcasterConfig, logger *zap.Logger) *PostgresBroadcaster {
+	ctx, cancel := context.WithCancel(ctx)
+	b := &PostgresBroadcaster{
+		baseBroadcaster: newBaseBroadcaster(eventsRepo, logger, ctx, cancel, config),
+		pool:            pool,
+	}
+
+	b.wg.Add(1)
+	go b.listen()
+
+	logger.Info("events.postgres_broadcaster_created")
+	return b
+}
+
+// listen keeps a LISTEN connection open and reconnects when it breaks
+func (b *PostgresBroadcaster) listen() {
+	defer b.wg.Done()
+
+	for {
+		err := b.listenOnce()
+		if b.ctx.Err() != nil {
+			return
+		}
+		b.logger.Error("events.listen_failed", zap.Error(err))
+		if b.lostAt.IsZero() {
+			// Notifications sent shortly before the last one may never have
+			// arrived, so the catch-up starts a little earlier.
+			lastSeen := b.lastSeen
+			if lastSeen.IsZero() {
+				lastSeen = time.Now().UTC()
+			}
+			b.lostAt = lastSeen.Add(-postgresCatchUpMargin)
+		}
+
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// listenOnce listens on one connection until it fails
+func (b *PostgresBroadcaster) listenOnce() error {
+	poolConn, err := b.pool.Acquire(b.ctx)
+	if err != nil {
+		return err
+	}
+	// A listening connection must not be handed to other queries, so it is
+	// taken out of the pool and closed when done
+	conn := poolConn.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(b.ctx, "LISTEN "+postgresChannel); err != nil {
+		return err
+	}
+	b.logger.Info("events.listening", zap.String("channel", postgresChannel))
+	b.lastSeen = time.Now().UTC()
+
+	if !b.lostAt.IsZero() {
+		b.catchUp(b.lostAt)
+		b.lostAt = time.Time{}
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(b.ctx)
+		if err != nil {
+			return err
+		}
+		b.lastSeen = time.Now().UTC()
+		b.handlePayload(notification.Payload)
+	}
+}
+
+// catchUp broadcasts events stored by other instances while disconnected,
+// paging through them until none are left
+func (b *PostgresBroadcaster) catchUp(since time.Time) {
+	var (
+		afterSeq int64
+		total    int
+	)
+	for {
+		events, err := b.eventsRepo.ListSince(b.ctx, since, afterSeq, postgresCatchUpPageSize)
+		if err != nil {
+			b.logger.Error("events.catch_up_failed", zap.Error(err))
+			return
+		}
+
+		for _, evt := range events {
+			event := eventFromModel(evt)
+			if !b.shouldProcess(event.ID) {
+				continue
+			}
+			b.broadcast(event)
+		}
+		total += len(events)
+		if len(events) < postgresCatchUpPageSize {
+			break
+		}
+		afterSeq = events[len(events)-1].Seq
+	}
+
+	b.logger.Info("events.caught_up", zap.Int("events", total), zap.Time("since", since))
+}
+
+func (b *PostgresBroadcaster) handlePayload(payload string) {
+	var evt dto.Event
+	if err := json.Unmarshal([]byte(payload), &evt); err != nil {
+		b.logger.Error("events.failed_to_unmarshal",
+			zap.Error(err),
+			zap.String("payload", payload))
+		return
+	}
+
+	// Deduplication check, also drops events this instance recorded
+	if !b.shouldProcess(evt.ID) {
+		b.logger.Debug("events.duplicate_skipped",
+			zap.String("id", evt.ID))
+		return
+	}
+
+	// Events too large for NOTIFY only carry their ID
+	if evt.Type == "" {
+		id, err := uuid.Parse(evt.ID)
+		if err != nil {
+			b.logger.Error("events.invalid_id", zap.String("id", evt.ID))
+			return
+		}
+		stored, err := b.eventsRepo.GetByIDAndUserID(b.ctx, id, evt.UserID)
+		if err != nil {
+			b.logger.Error("events.load_failed", zap.Error(err), zap.String("id", evt.ID))
+			return
+		}
+		evt = eventFromModel(*stored)
+	}
+
+	b.logger.Debug("events.received",
+		zap.String("id", evt.ID),
+		zap.Int64("user_id", evt.UserID),
+		zap.String("type", evt.Type))
+
+	b.broadcast(evt)
+}
+
+// Record broadcasts an event locally, saves it to the database and notifies
+// the other instances through Postgres
+func (b *PostgresBroadcaster) Record(eventType EventType, userID int64, source *dto.Source) {
+	evt := createEvent(eventType, userID, source)
+	b.runRecordHooks(evt)
+
+	// Mark as processed so the notification coming back is skipped
+	b.shouldProcess(evt.ID)
+	b.broadcast(evt)
+
+	payload, err := marshalEvent(evt)
+	if err != nil {
+		b.logger.Error("events.failed_to_marshal", zap.Error(err))
+		b.queueForDB(evt)
+		return
+	}
+
+	if len(payload) <= postgresMaxPayload {
+		b.queueForDB(evt)
+		go b.notify(evt, payload)
+		return
+	}
+
+	// Too large for NOTIFY: save it first so the other instances can load it
+	go func() {
+		eventModel, err := eventToModel(evt)
+		if err != nil {
+			b.logger.Error("events.model_mapping_failed", zap.Error(err))
+			return
+		}
+		if err := b.eventsRepo.Create(b.ctx, eventModel); err != nil {
+			b.logger.Error("events.db_save_failed",
+				zap.Error(err),
+				zap.String("id", evt.ID),
+				zap.String("type", evt.Type),
+				zap.Int64("user_id", evt.UserID))
+			return
+		}
+		stub, err := marshalEvent(dto.Event{ID: evt.ID, UserID: evt.UserID})
+		if err != nil {
+			b.logger.Error("events.failed_to_marshal", zap.Error(err))
+			return
+		}
+		b.notify(evt, stub)
+	}()
+}
+
+func (b *PostgresBroadcaster) notify(evt dto.Event, payload []byte) {
+	if _, err := b.pool.Exec(b.ctx, "SELECT pg_notify($1, $2)", postgresChannel, string(payload)); err != nil {
+		b.logger.Error("events.postgres_notify_failed", zap.Error(err))
+		return
+	}
+
+	b.logger.Debug("events.published",
+		zap.String("id", evt.ID),
+		zap.Int64("user_id", evt.UserID),
+		zap.String("type", evt.Type))
+}
+
+// Shutdown gracefully stops the broadcaster
+func (b *PostgresBroadcaster) Shutdown() {
+	b.logger.Info("events.postgres_broadcaster_shutting_down")
+	b.cancel()
+
+	// Wait for workers with timeout to prevent hanging
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		// Normal shutdown
+	case <-time.After(5 * time.Second):
+		b.logger.Warn("events.shutdown_timeout")
+	}
+
+	b.logger.Info("events.postgres_broadcaster_shutdown_complete")
+}
@@ -115,6 +115,7 @@ func (b *RedisBroadcaster) subscribe() {
 // when the message comes back from Redis (ensuring single broadcast)
 func (b *RedisBroadcaster) Record(eventType EventType, userID int64, source *dto.Source) {
 	evt := createEvent(eventType, userID, source)
+	b.runRecordHooks(evt)
 
 	// Queue for DB write (non-blocking)
 	if !b.queueForDB(evt) {
@@ -1,7 +1,9 @@
 package http_range
 
 import (
+	"cmp"
 	"errors"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -17,50 +19,81 @@ var (
 	ErrInvalid = errors.New("invalid range")
 )
 
+// Parse parses a Range header for content of size bytes. The returned ranges
+// are sorted, with overlapping and adjacent ones merged. Like net/http, it
+// returns no ranges when together they ask for more bytes than the content
+// has, and the caller should then send the whole content.
 func Parse(header string, size int64) ([]*Range, error) {
-	index := strings.Index(header, "=")
-
-	if index == -1 {
+	unit, specs, ok := strings.Cut(header, "=")
+	if !ok || strings.TrimSpace(unit) != "bytes" {
 		return nil, ErrInvalid
 	}
 
-	arr := strings.Split(header[index+1:], ",")
-	ranges := make([]*Range, 0, len(arr))
-
-	for _, value := range arr {
-		r := strings.Split(value, "-")
-		start, startErr := strconv.ParseInt(r[0], 10, 64)
-		end, endErr := strconv.ParseInt(r[1], 10, 64)
-
-		if startErr != nil && endErr != nil {
+	var (
+		ranges []*Range
+		total  int64
+	)
+	for spec := range strings.SplitSeq(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
 			continue
 		}
-
-		// -nnn and nnn-
-		if startErr != nil {
-			start = size - end
-			end = size - 1
-		} else if endErr != nil {
-			end = size - 1
-		}
-
-		if end >= size {
-			end = size - 1
+		first, last, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, ErrInvalid
 		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
 
-		if start > end || start < 0 {
-			continue
+		var r Range
+		if first == "" {
+			// -nnn is the last nnn bytes
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, ErrInvalid
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			r = Range{Start: size - min(n, size), End: size - 1}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, ErrInvalid
+			}
+			if start >= size {
+				continue
+			}
+			r = Range{Start: start, End: size - 1}
+			if last != "" {
+				end, err := strconv.ParseInt(last, 10, 64)
+				if err != nil || end < start {
+					return nil, ErrInvalid
+				}
+				r.End = min(end, size-1)
+			}
 		}
-
-		ranges = append(ranges, &Range{
-			Start: start,
-			End:   end,
-		})
+		total += r.End - r.Start + 1
+		ranges = append(ranges, &r)
 	}
 
 	if len(ranges) == 0 {
 		return nil, ErrNoOverlap
 	}
+	if total > size {
+		return nil, nil
+	}
 
-	return ranges, nil
+	slices.SortFunc(ranges, func(a, b *Range) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		prev := merged[len(merged)-1]
+		if r.Start <= prev.End+1 {
+			prev.End = max(prev.End, r.End)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged, nil
 }
@@ -0,0 +1,51 @@
+package http_range
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		header string
+		want   []Range
+		err    error
+	}{
+		{name: "single", header: "bytes=0-4", want: []Range{{0, 4}}},
+		{name: "open end", header: "bytes=90-", want: []Range{{90, 99}}},
+		{name: "suffix", header: "bytes=-10", want: []Range{{90, 99}}},
+		{name: "suffix larger than size", header: "bytes=-1000", want: []Range{{0, 99}}},
+		{name: "end past size", header: "bytes=95-200", want: []Range{{95, 99}}},
+		{name: "spaces", header: "bytes= 0-4 , 10-12 ,", want: []Range{{0, 4}, {10, 12}}},
+		{name: "sorted", header: "bytes=10-12,0-4", want: []Range{{0, 4}, {10, 12}}},
+		{name: "overlapping", header: "bytes=0-10,5-20", want: []Range{{0, 20}}},
+		{name: "adjacent", header: "bytes=0-4,5-9", want: []Range{{0, 9}}},
+		{name: "more than size", header: "bytes=0-59,40-99", want: nil},
+		{name: "past end skipped", header: "bytes=0-4,200-300", want: []Range{{0, 4}}},
+		{name: "no overlap", header: "bytes=200-300", err: ErrNoOverlap},
+		{name: "wrong unit", header: "items=0-4", err: ErrInvalid},
+		{name: "missing dash", header: "bytes=5", err: ErrInvalid},
+		{name: "end before start", header: "bytes=5-1", err: ErrInvalid},
+		{name: "not a number", header: "bytes=a-b", err: ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ranges, err := Parse(tt.header, 100)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			var got []Range
+			for _, r := range ranges {
+				got = append(got, *r)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
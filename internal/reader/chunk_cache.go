@@ -0,0 +1,282 @@
+package reader
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tgdrive/teldrive/internal/logging"
+	"go.uber.org/zap"
+)
+
+const chunkTempPrefix = ".tmp-"
+
+// ChunkCache keeps downloaded chunks on disk so repeated range reads of a file
+// are served without going back to Telegram. Entries are evicted least
+// recently used first once the cache grows past its size limit.
+//
+// Chunks cannot be checked against the BLAKE3 block hashes taken on upload:
+// those cover 16 MiB blocks of the plaintext rather than the chunks read here,
+// which are stored as downloaded and so encrypted for encrypted files. They
+// are also optional, and are dropped with the upload rows once the file is
+// created, leaving only the tree hash on the file. Instead each entry starts
+// with the SHA-256 of its data, taken when it was downloaded and checked on
+// every read, which catches disk corruption and torn writes.
+type ChunkCache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List
+	entries map[string]*list.Element
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+	corrupted atomic.Int64
+}
+
+type chunkEntry struct {
+	name string
+	size int64
+}
+
+// ChunkCacheStats reports the state of a ChunkCache for tuning.
+type ChunkCacheStats struct {
+	Entries   int
+	Size      int64
+	MaxSize   int64
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Corrupted int64
+}
+
+// NewChunkCache opens the cache in dir, picking up chunks stored by earlier
+// runs. Their last modification time stands in for their last use.
+func NewChunkCache(dir string, maxSize int64) (*ChunkCache, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("chunk cache size must be positive")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create chunk cache dir: %w", err)
+	}
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read chunk cache dir: %w", err)
+	}
+	type stored struct {
+		chunkEntry
+		modTime time.Time
+	}
+	var found []stored
+	for _, e := range dirEntries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		if strings.HasPrefix(e.Name(), chunkTempPrefix) {
+			_ = os.Remove(filepath.Join(dir, e.Name()))
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		found = append(found, stored{chunkEntry{name: e.Name(), size: info.Size()}, info.ModTime()})
+	}
+	slices.SortFunc(found, func(a, b stored) int { return a.modTime.Compare(b.modTime) })
+
+	c := &ChunkCache{
+		dir:     dir,
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, len(found)),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range found {
+		c.entries[s.name] = c.order.PushFront(&s.chunkEntry)
+		c.size += s.size
+	}
+	c.evictLocked()
+	return c, nil
+}
+
+// ChunkKey identifies the chunk of a file part starting at offset.
+func ChunkKey(fileID string, partID, offset, limit int64) string {
+	return fmt.Sprintf("%s/%d/%d/%d", fileID, partID, offset, limit)
+}
+
+func chunkName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the cached chunk for key. Entries failing the integrity check
+// are dropped and reported as misses.
+func (c *ChunkCache) Get(key string) ([]byte, bool) {
+	name := chunkName(key)
+	c.mu.Lock()
+	el, ok := c.entries[name]
+	if ok {
+		c.order.MoveToFront(el)
+	}
+	c.mu.Unlock()
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	raw, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		c.remove(name)
+		c.misses.Add(1)
+		return nil, false
+	}
+	if len(raw) < sha256.Size {
+		c.dropCorrupted(name)
+		return nil, false
+	}
+	data := raw[sha256.Size:]
+	sum := sha256.Sum256(data)
+	if !bytes.Equal(sum[:], raw[:sha256.Size]) {
+		c.dropCorrupted(name)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return data, true
+}
+
+// Put stores data under key, evicting old entries to stay within the limit.
+// Failures only cost a later cache miss, so they are logged and ignored.
+func (c *ChunkCache) Put(key string, data []byte) {
+	name := chunkName(key)
+	size := int64(sha256.Size + len(data))
+	if size > c.maxSize {
+		return
+	}
+	c.mu.Lock()
+	_, exists := c.entries[name]
+	c.mu.Unlock()
+	if exists {
+		return
+	}
+
+	if err := c.write(name, data); err != nil {
+		logging.Component("READER").Warn("chunk_cache.write_failed", zap.Error(err))
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[name]; exists {
+		return
+	}
+	c.entries[name] = c.order.PushFront(&chunkEntry{name: name, size: size})
+	c.size += size
+	c.evictLocked()
+}
+
+func (c *ChunkCache) write(name string, data []byte) error {
+	f, err := os.CreateTemp(c.dir, chunkTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	_, err = f.Write(sum[:])
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(c.dir, name))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+// Stats returns the current counters of the cache.
+func (c *ChunkCache) Stats() ChunkCacheStats {
+	c.mu.Lock()
+	entries, size := len(c.entries), c.size
+	c.mu.Unlock()
+	return ChunkCacheStats{
+		Entries:   entries,
+		Size:      size,
+		MaxSize:   c.maxSize,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Corrupted: c.corrupted.Load(),
+	}
+}
+
+func (c *ChunkCache) dropCorrupted(name string) {
+	c.corrupted.Add(1)
+	c.misses.Add(1)
+	c.remove(name)
+}
+
+func (c *ChunkCache) remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[name]; ok {
+		c.removeLocked(el)
+	}
+}
+
+func (c *ChunkCache) evictLocked() {
+	for c.size > c.maxSize {
+		el := c.order.Back()
+		if el == nil {
+			return
+		}
+		c.removeLocked(el)
+		c.evictions.Add(1)
+	}
+}
+
+func (c *ChunkCache) removeLocked(el *list.Element) {
+	entry := el.Value.(*chunkEntry)
+	c.order.Remove(el)
+	delete(c.entries, entry.name)
+	c.size -= entry.size
+	_ = os.Remove(filepath.Join(c.dir, entry.name))
+}
+
+// cachedChunkSource serves chunks of one file part from a ChunkCache and
+// stores the chunks it has to download.
+type cachedChunkSource struct {
+	ChunkSource
+	cache  *ChunkCache
+	fileID string
+	partID int64
+}
+
+func (c *cachedChunkSource) Chunk(ctx context.Context, offset int64, limit int64) ([]byte, error) {
+	key := ChunkKey(c.fileID, c.partID, offset, limit)
+	if data, ok := c.cache.Get(key); ok {
+		return data, nil
+	}
+	data, err := c.ChunkSource.Chunk(ctx, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.Put(key, data)
+	return data, nil
+}
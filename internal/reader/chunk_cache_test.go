@@ -0,0 +1,102 @@
+package reader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type countingSource struct {
+	calls int
+}
+
+func (s *countingSource) ChunkSize(start, end int64) int64 { return 4 }
+
+func (s *countingSource) Chunk(ctx context.Context, offset int64, limit int64) ([]byte, error) {
+	s.calls++
+	return []byte{byte(offset), 1, 2, 3}, nil
+}
+
+func TestChunkCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ServesRepeatedReadsLocally", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := NewChunkCache(t.TempDir(), 1024)
+		require.NoError(t, err)
+		src := &countingSource{}
+		cached := &cachedChunkSource{ChunkSource: src, cache: c, fileID: "file", partID: 1}
+
+		first, err := cached.Chunk(context.Background(), 8, 4)
+		require.NoError(t, err)
+		second, err := cached.Chunk(context.Background(), 8, 4)
+		require.NoError(t, err)
+
+		require.Equal(t, first, second)
+		require.Equal(t, 1, src.calls)
+		stats := c.Stats()
+		require.Equal(t, int64(1), stats.Hits)
+		require.Equal(t, int64(1), stats.Misses)
+	})
+
+	t.Run("EvictsLeastRecentlyUsed", func(t *testing.T) {
+		t.Parallel()
+
+		// Each entry takes 32 bytes of hash plus 8 bytes of data.
+		c, err := NewChunkCache(t.TempDir(), 80)
+		require.NoError(t, err)
+		data := []byte("01234567")
+		c.Put("a", data)
+		c.Put("b", data)
+		_, ok := c.Get("a")
+		require.True(t, ok)
+		c.Put("c", data)
+
+		_, ok = c.Get("b")
+		require.False(t, ok)
+		_, ok = c.Get("a")
+		require.True(t, ok)
+		require.Equal(t, int64(1), c.Stats().Evictions)
+		require.Equal(t, int64(80), c.Stats().Size)
+	})
+
+	t.Run("DropsCorruptedEntries", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		c, err := NewChunkCache(dir, 1024)
+		require.NoError(t, err)
+		c.Put("a", []byte("payload"))
+
+		path := filepath.Join(dir, chunkName("a"))
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err)
+		raw[len(raw)-1] ^= 0xff
+		require.NoError(t, os.WriteFile(path, raw, 0o600))
+
+		_, ok := c.Get("a")
+		require.False(t, ok)
+		require.Equal(t, int64(1), c.Stats().Corrupted)
+		require.Equal(t, 0, c.Stats().Entries)
+		require.NoFileExists(t, path)
+	})
+
+	t.Run("ReopensStoredEntries", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		c, err := NewChunkCache(dir, 1024)
+		require.NoError(t, err)
+		c.Put("a", []byte("payload"))
+
+		reopened, err := NewChunkCache(dir, 1024)
+		require.NoError(t, err)
+		data, ok := reopened.Get("a")
+		require.True(t, ok)
+		require.Equal(t, "payload", string(data))
+	})
+}
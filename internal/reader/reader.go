@@ -27,12 +27,18 @@ type Reader struct {
 	reader      io.ReadCloser
 	remaining   int64
 	config      *config.TGConfig
-	client      *tg.Client
+	bots        []Bot
 	concurrency int
 	cache       cache.Cacher
+	chunkCache  *ChunkCache
 	closeOnce   sync.Once
 	closeErr    error
-	botID       string
+}
+
+// Bot is a running Telegram client that chunks can be downloaded through.
+type Bot struct {
+	Client *tg.Client
+	ID     string
 }
 
 type FileRef struct {
@@ -58,31 +64,41 @@ func calculatePartByteRanges(start, end, partSize int64) []Range {
 	return ranges
 }
 
+// NewReader returns a reader over bytes [start, end] of file. With several
+// bots, chunks are fetched through all of them in turn and each bot keeps up
+// to config.Stream.Concurrency requests in flight.
 func NewReader(ctx context.Context,
-	client *tg.Client,
 	cache cache.Cacher,
+	chunkCache *ChunkCache,
 	file *FileRef,
 	parts []types.Part,
 	start,
 	end int64,
 	config *config.TGConfig,
-	botID string,
+	bots []Bot,
 ) (io.ReadCloser, error) {
+	if len(bots) == 0 {
+		return nil, fmt.Errorf("no telegram client to read from")
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("file has no parts to read")
+	}
 
 	size := parts[0].Size
 	if file.Encrypted {
 		size = parts[0].DecryptedSize
 	}
 	r := &Reader{
-		ctx:       ctx,
-		parts:     parts,
-		file:      file,
-		remaining: end - start + 1,
-		ranges:    calculatePartByteRanges(start, end, size),
-		config:    config,
-		client:    client,
-		cache:     cache,
-		botID:     botID,
+		ctx:         ctx,
+		parts:       parts,
+		file:        file,
+		remaining:   end - start + 1,
+		ranges:      calculatePartByteRanges(start, end, size),
+		config:      config,
+		bots:        bots,
+		concurrency: max(config.Stream.Concurrency, 1) * len(bots),
+		cache:       cache,
+		chunkCache:  chunkCache,
 	}
 
 	if err := r.initializeReader(); err != nil {
@@ -155,13 +171,22 @@ func (r *Reader) getPartReader() (io.ReadCloser, error) {
 	}
 	partId := r.parts[currentRange.PartNo].ID
 
-	chunkSrc := &chunkSource{
-		channelId:   r.file.ChannelID,
-		partId:      partId,
-		client:      r.client,
-		concurrency: r.concurrency,
-		cache:       r.cache,
-		key:         cache.KeyFileLocation(r.config.SessionInstance, r.botID, r.file.ID, partId),
+	sources := make([]ChunkSource, len(r.bots))
+	for i, bot := range r.bots {
+		sources[i] = &chunkSource{
+			channelId: r.file.ChannelID,
+			partId:    partId,
+			client:    bot.Client,
+			cache:     r.cache,
+			key:       cache.KeyFileLocation(r.config.SessionInstance, bot.ID, r.file.ID, partId),
+		}
+	}
+	src := sources[0]
+	if len(sources) > 1 {
+		src = &multiBotSource{sources: sources}
+	}
+	if r.chunkCache != nil {
+		src = &cachedChunkSource{ChunkSource: src, cache: r.chunkCache, fileID: r.file.ID, partID: partId}
 	}
 
 	var (
@@ -169,7 +194,7 @@ func (r *Reader) getPartReader() (io.ReadCloser, error) {
 		err    error
 	)
 
-	reader, err = newTGMultiReader(r.ctx, currentRange.Start, currentRange.End, r.config, chunkSrc)
+	reader, err = newTGMultiReader(r.ctx, currentRange.Start, currentRange.End, r.config, r.concurrency, src)
 
 	if r.file.Encrypted {
 		salt := r.parts[r.ranges[r.pos].PartNo].Salt
@@ -184,7 +209,7 @@ func (r *Reader) getPartReader() (io.ReadCloser, error) {
 					end = min(r.parts[r.ranges[r.pos].PartNo].Size-1, underlyingOffset+underlyingLimit-1)
 				}
 
-				return newTGMultiReader(r.ctx, underlyingOffset, end, r.config, chunkSrc)
+				return newTGMultiReader(r.ctx, underlyingOffset, end, r.config, r.concurrency, src)
 
 			}, currentRange.Start, currentRange.End-currentRange.Start+1)
 	}
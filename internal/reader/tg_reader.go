@@ -29,12 +29,11 @@ type ChunkSource interface {
 }
 
 type chunkSource struct {
-	channelId   int64
-	partId      int64
-	concurrency int
-	client      *tg.Client
-	key         string
-	cache       cache.Cacher
+	channelId int64
+	partId    int64
+	client    *tg.Client
+	key       string
+	cache     cache.Cacher
 }
 
 func (c *chunkSource) ChunkSize(start, end int64) int64 {
@@ -62,6 +61,21 @@ func (c *chunkSource) Chunk(ctx context.Context, offset int64, limit int64) ([]b
 
 }
 
+// multiBotSource spreads the chunks of a part over several bots. Chunk n goes
+// to bot n mod len(sources), so a batch of consecutive chunks keeps every bot
+// equally busy. tgMultiReader puts the results back in order.
+type multiBotSource struct {
+	sources []ChunkSource
+}
+
+func (m *multiBotSource) ChunkSize(start, end int64) int64 {
+	return m.sources[0].ChunkSize(start, end)
+}
+
+func (m *multiBotSource) Chunk(ctx context.Context, offset int64, limit int64) ([]byte, error) {
+	return m.sources[(offset/limit)%int64(len(m.sources))].Chunk(ctx, offset, limit)
+}
+
 type tgMultiReader struct {
 	ctx         context.Context
 	cancel      context.CancelFunc
@@ -86,6 +100,7 @@ func newTGMultiReader(
 	start int64,
 	end int64,
 	config *config.TGConfig,
+	concurrency int,
 	chunkSrc ChunkSource,
 ) (*tgMultiReader, error) {
 	chunkSize := chunkSrc.ChunkSize(start, end)
@@ -98,7 +113,7 @@ func newTGMultiReader(
 		cancel:      cancel,
 		limit:       end - start + 1,
 		bufferChan:  make(chan *buffer, config.Stream.Buffers),
-		concurrency: config.Stream.Concurrency,
+		concurrency: concurrency,
 		leftCut:     start - offset,
 		rightCut:    (end % chunkSize) + 1,
 		totalParts:  int((end - offset + chunkSize) / chunkSize),
@@ -0,0 +1,46 @@
+package reader
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tgdrive/teldrive/internal/config"
+)
+
+// sliceSource serves chunks of data and records the offsets it was asked for.
+type sliceSource struct {
+	data    []byte
+	mu      sync.Mutex
+	offsets []int64
+}
+
+func (s *sliceSource) ChunkSize(start, end int64) int64 { return 4 }
+
+func (s *sliceSource) Chunk(ctx context.Context, offset int64, limit int64) ([]byte, error) {
+	s.mu.Lock()
+	s.offsets = append(s.offsets, offset)
+	s.mu.Unlock()
+	return s.data[offset:min(offset+limit, int64(len(s.data)))], nil
+}
+
+func TestMultiBotSource(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("abcdefghijklmnopqrstuvwx")
+	first, second := &sliceSource{data: data}, &sliceSource{data: data}
+	cfg := &config.TGConfig{Stream: config.TGStream{Buffers: 2, ChunkTimeout: time.Second}}
+
+	r, err := newTGMultiReader(context.Background(), 2, 21, cfg, 4, &multiBotSource{sources: []ChunkSource{first, second}})
+	require.NoError(t, err)
+	defer r.Close()
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	require.Equal(t, string(data[2:22]), string(got))
+	require.ElementsMatch(t, []int64{0, 8, 16}, first.offsets)
+	require.ElementsMatch(t, []int64{4, 12, 20}, second.offsets)
+}
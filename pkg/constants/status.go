@@ -6,6 +6,8 @@ const (
 	FileStatusActive FileStatus = "active"
 
 	FileStatusPendingDeletion FileStatus = "pending_deletion"
+
+	FileStatusTrashed FileStatus = "trashed"
 )
 
 func (s FileStatus) String() string {
@@ -9,6 +9,13 @@ type Source struct {
 	ParentID     string
 	DestParentID string
 	Path         string
+
+	// Lifecycle events that are not about a single file
+	ShareID           string
+	JobID             int64
+	ChannelID         int64
+	PreviousChannelID int64
+	Error             string
 }
 
 type Event struct {
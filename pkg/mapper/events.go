@@ -27,7 +27,7 @@ func eventOut(id string, typ string, createdAt time.Time, src *dto.Source) api.E
 		src = &dto.Source{}
 	}
 
-	return api.Event{
+	out := api.Event{
 		ID:        UUIDFromString(id),
 		Type:      api.EventType(typ),
 		CreatedAt: createdAt,
@@ -37,6 +37,20 @@ func eventOut(id string, typ string, createdAt time.Time, src *dto.Source) api.E
 			Name:         src.Name,
 			ParentId:     UUIDFromString(src.ParentID),
 			DestParentId: OptUUIDFromString(src.DestParentID),
+			ShareId:      OptUUIDFromString(src.ShareID),
 		},
 	}
+	if src.JobID != 0 {
+		out.Source.JobId = api.NewOptInt64(src.JobID)
+	}
+	if src.ChannelID != 0 {
+		out.Source.ChannelId = api.NewOptInt64(src.ChannelID)
+	}
+	if src.PreviousChannelID != 0 {
+		out.Source.PreviousChannelId = api.NewOptInt64(src.PreviousChannelID)
+	}
+	if src.Error != "" {
+		out.Source.Error = api.NewOptString(src.Error)
+	}
+	return out
 }
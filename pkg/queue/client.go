@@ -17,14 +17,35 @@ func NewClient(pool *pgxpool.Pool, exec Executor, cfg config.QueueConfig, jobsCf
 	if jobsCfg.SyncTransfer.Timeout == 0 {
 		jobsCfg.SyncTransfer.Timeout = 3 * time.Hour
 	}
+	if jobsCfg.SyncPush.Timeout == 0 {
+		jobsCfg.SyncPush.Timeout = 6 * time.Hour
+	}
+	if jobsCfg.FilesCopy.Timeout == 0 {
+		jobsCfg.FilesCopy.Timeout = 3 * time.Hour
+	}
+	if jobsCfg.FilesMove.Timeout == 0 {
+		jobsCfg.FilesMove.Timeout = time.Hour
+	}
+	if jobsCfg.FilesDelete.Timeout == 0 {
+		jobsCfg.FilesDelete.Timeout = time.Hour
+	}
+	if jobsCfg.WebhookDeliver.Timeout == 0 {
+		jobsCfg.WebhookDeliver.Timeout = 30 * time.Second
+	}
 
 	workers := river.NewWorkers()
+	river.AddWorker(workers, &filesCopyWorker{exec: exec, timeout: jobsCfg.FilesCopy.Timeout})
+	river.AddWorker(workers, &filesMoveWorker{exec: exec, timeout: jobsCfg.FilesMove.Timeout})
+	river.AddWorker(workers, &filesDeleteWorker{exec: exec, timeout: jobsCfg.FilesDelete.Timeout})
 	river.AddWorker(workers, &syncRunWorker{exec: exec})
 	river.AddWorker(workers, &syncTransferWorker{exec: exec, timeout: jobsCfg.SyncTransfer.Timeout})
+	river.AddWorker(workers, &syncPushWorker{exec: exec, timeout: jobsCfg.SyncPush.Timeout})
+	river.AddWorker(workers, &webhookDeliverWorker{exec: exec, timeout: jobsCfg.WebhookDeliver.Timeout})
 	river.AddWorker(workers, &cleanOldEventsWorker{exec: exec})
 	river.AddWorker(workers, &cleanStaleUploadsWorker{exec: exec})
 	river.AddWorker(workers, &cleanPendingFilesWorker{exec: exec})
 	river.AddWorker(workers, &refreshFolderSizesWorker{exec: exec})
+	river.AddWorker(workers, &cleanFileVersionsWorker{exec: exec})
 
 	if cfg.DefaultWorkers <= 0 {
 		cfg.DefaultWorkers = 50
@@ -43,6 +64,48 @@ func NewClient(pool *pgxpool.Pool, exec Executor, cfg config.QueueConfig, jobsCf
 	})
 }
 
+type filesCopyWorker struct {
+	river.WorkerDefaults[FilesCopyJobArgs]
+	exec    Executor
+	timeout time.Duration
+}
+
+func (w *filesCopyWorker) Timeout(*river.Job[FilesCopyJobArgs]) time.Duration {
+	return w.timeout
+}
+
+func (w *filesCopyWorker) Work(ctx context.Context, job *river.Job[FilesCopyJobArgs]) error {
+	return w.exec.FilesCopy(ctx, job.Args, job.ID)
+}
+
+type filesMoveWorker struct {
+	river.WorkerDefaults[FilesMoveJobArgs]
+	exec    Executor
+	timeout time.Duration
+}
+
+func (w *filesMoveWorker) Timeout(*river.Job[FilesMoveJobArgs]) time.Duration {
+	return w.timeout
+}
+
+func (w *filesMoveWorker) Work(ctx context.Context, job *river.Job[FilesMoveJobArgs]) error {
+	return w.exec.FilesMove(ctx, job.Args, job.ID)
+}
+
+type filesDeleteWorker struct {
+	river.WorkerDefaults[FilesDeleteJobArgs]
+	exec    Executor
+	timeout time.Duration
+}
+
+func (w *filesDeleteWorker) Timeout(*river.Job[FilesDeleteJobArgs]) time.Duration {
+	return w.timeout
+}
+
+func (w *filesDeleteWorker) Work(ctx context.Context, job *river.Job[FilesDeleteJobArgs]) error {
+	return w.exec.FilesDelete(ctx, job.Args, job.ID)
+}
+
 type syncRunWorker struct {
 	river.WorkerDefaults[SyncRunJobArgs]
 	exec Executor
@@ -66,6 +129,34 @@ func (w *syncTransferWorker) Work(ctx context.Context, job *river.Job[SyncTransf
 	return w.exec.SyncTransfer(ctx, job.Args, job.ID)
 }
 
+type syncPushWorker struct {
+	river.WorkerDefaults[SyncPushJobArgs]
+	exec    Executor
+	timeout time.Duration
+}
+
+func (w *syncPushWorker) Timeout(*river.Job[SyncPushJobArgs]) time.Duration {
+	return w.timeout
+}
+
+func (w *syncPushWorker) Work(ctx context.Context, job *river.Job[SyncPushJobArgs]) error {
+	return w.exec.SyncPush(ctx, job.Args, job.ID)
+}
+
+type webhookDeliverWorker struct {
+	river.WorkerDefaults[WebhookDeliverArgs]
+	exec    Executor
+	timeout time.Duration
+}
+
+func (w *webhookDeliverWorker) Timeout(*river.Job[WebhookDeliverArgs]) time.Duration {
+	return w.timeout
+}
+
+func (w *webhookDeliverWorker) Work(ctx context.Context, job *river.Job[WebhookDeliverArgs]) error {
+	return w.exec.WebhookDeliver(ctx, job.Args, job.Attempt)
+}
+
 type cleanOldEventsWorker struct {
 	river.WorkerDefaults[CleanOldEventsArgs]
 	exec Executor
@@ -101,3 +192,12 @@ type refreshFolderSizesWorker struct {
 func (w *refreshFolderSizesWorker) Work(ctx context.Context, job *river.Job[RefreshFolderSizesArgs]) error {
 	return w.exec.RefreshFolderSizesForUser(ctx, job.Args.UserID)
 }
+
+type cleanFileVersionsWorker struct {
+	river.WorkerDefaults[CleanFileVersionsArgs]
+	exec Executor
+}
+
+func (w *cleanFileVersionsWorker) Work(ctx context.Context, job *river.Job[CleanFileVersionsArgs]) error {
+	return w.exec.CleanFileVersionsForUser(ctx, job.Args)
+}
@@ -1,6 +1,9 @@
 package queue
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+)
 
 const (
 	JobKindFilesCopy    = "files.copy"
@@ -8,11 +11,15 @@ const (
 	JobKindFilesDelete  = "files.delete"
 	JobKindSyncRun      = "sync.run"
 	JobKindSyncTransfer = "sync.transfer"
+	JobKindSyncPush     = "sync.push"
+
+	JobKindWebhookDeliver = "webhooks.deliver"
 
 	JobKindCleanOldEvents    = "clean.old_events"
 	JobKindCleanStaleUpload  = "clean.stale_uploads"
 	JobKindCleanPendingFile  = "clean.pending_files"
 	JobKindRefreshFolderSize = "refresh.folder_sizes"
+	JobKindCleanFileVersions = "clean.file_versions"
 )
 
 type JobItem struct {
@@ -20,6 +27,29 @@ type JobItem struct {
 	DestinationName string `json:"destinationName,omitempty"`
 }
 
+type FilesCopyJobArgs struct {
+	UserID      int64     `json:"userId"`
+	Items       []JobItem `json:"items"`
+	Destination string    `json:"destination"`
+}
+
+func (FilesCopyJobArgs) Kind() string { return JobKindFilesCopy }
+
+type FilesMoveJobArgs struct {
+	UserID      int64     `json:"userId"`
+	Items       []JobItem `json:"items"`
+	Destination string    `json:"destination"`
+}
+
+func (FilesMoveJobArgs) Kind() string { return JobKindFilesMove }
+
+type FilesDeleteJobArgs struct {
+	UserID int64     `json:"userId"`
+	Items  []JobItem `json:"items"`
+}
+
+func (FilesDeleteJobArgs) Kind() string { return JobKindFilesDelete }
+
 type SyncRunJobArgs struct {
 	UserID         int64             `json:"userId" river:"unique"`
 	RunID          string            `json:"runId,omitempty"`
@@ -41,9 +71,51 @@ type SyncFilters struct {
 }
 
 type SyncOptions struct {
-	PartSize  int64 `json:"partSize,omitempty"`
-	Encrypted bool  `json:"encrypted,omitempty"`
-	Sync      bool  `json:"sync,omitempty"`
+	PartSize  int64  `json:"partSize,omitempty"`
+	Encrypted bool   `json:"encrypted,omitempty"`
+	Sync      bool   `json:"sync,omitempty"`
+	Conflict  string `json:"conflict,omitempty"`
+	Deletion  string `json:"deletion,omitempty"`
+	BackupDir string `json:"backupDir,omitempty"`
+}
+
+// Policies for destination files that differ from the source.
+const (
+	SyncConflictOverwrite = "overwrite"
+	SyncConflictSkip      = "skip"
+	SyncConflictKeepBoth  = "keep-both"
+	SyncConflictNewer     = "newer"
+)
+
+// Policies for destination entries that are missing from the source.
+const (
+	SyncDeletionDelete = "delete"
+	SyncDeletionBackup = "backup"
+	SyncDeletionIgnore = "ignore"
+)
+
+// ConflictPolicy returns the conflict policy, falling back to overwrite when
+// Sync is set and skip otherwise.
+func (o SyncOptions) ConflictPolicy() string {
+	if o.Conflict != "" {
+		return o.Conflict
+	}
+	if o.Sync {
+		return SyncConflictOverwrite
+	}
+	return SyncConflictSkip
+}
+
+// DeletionPolicy returns the deletion policy, falling back to delete when
+// Sync is set and ignore otherwise.
+func (o SyncOptions) DeletionPolicy() string {
+	if o.Deletion != "" {
+		return o.Deletion
+	}
+	if o.Sync {
+		return SyncDeletionDelete
+	}
+	return SyncDeletionIgnore
 }
 
 func (SyncRunJobArgs) Kind() string { return JobKindSyncRun }
@@ -67,6 +139,20 @@ type SyncTransferJobArgs struct {
 
 func (SyncTransferJobArgs) Kind() string { return JobKindSyncTransfer }
 
+// SyncPushJobArgs mirrors the Teldrive folder SourceDir, a path or folder id,
+// to the writable remote Destination.
+type SyncPushJobArgs struct {
+	UserID      int64             `json:"userId" river:"unique"`
+	SourceDir   string            `json:"sourceDir"`
+	Destination string            `json:"destination"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Proxy       string            `json:"proxy,omitempty"`
+	Filters     SyncFilters       `json:"filters,omitempty"`
+	Options     SyncOptions       `json:"options,omitempty"`
+}
+
+func (SyncPushJobArgs) Kind() string { return JobKindSyncPush }
+
 type CleanOldEventsArgs struct {
 	UserID    int64  `json:"userId"`
 	Retention string `json:"retention"`
@@ -93,11 +179,36 @@ type RefreshFolderSizesArgs struct {
 
 func (RefreshFolderSizesArgs) Kind() string { return JobKindRefreshFolderSize }
 
+type CleanFileVersionsArgs struct {
+	UserID int64 `json:"userId"`
+	Keep   int   `json:"keep"`
+}
+
+func (CleanFileVersionsArgs) Kind() string { return JobKindCleanFileVersions }
+
+// WebhookDeliverArgs posts one recorded event to one webhook. Payload is the
+// event exactly as it is sent, so retries deliver the same body.
+type WebhookDeliverArgs struct {
+	UserID    int64           `json:"userId"`
+	WebhookID string          `json:"webhookId"`
+	EventID   string          `json:"eventId"`
+	EventType string          `json:"eventType"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+func (WebhookDeliverArgs) Kind() string { return JobKindWebhookDeliver }
+
 type Executor interface {
+	FilesCopy(ctx context.Context, args FilesCopyJobArgs, jobID int64) error
+	FilesMove(ctx context.Context, args FilesMoveJobArgs, jobID int64) error
+	FilesDelete(ctx context.Context, args FilesDeleteJobArgs, jobID int64) error
 	SyncRun(ctx context.Context, args SyncRunJobArgs, jobID int64) error
 	SyncTransfer(ctx context.Context, args SyncTransferJobArgs, jobID int64) error
+	SyncPush(ctx context.Context, args SyncPushJobArgs, jobID int64) error
 	CleanOldEventsForUser(ctx context.Context, args CleanOldEventsArgs) error
 	CleanStaleUploadsForUser(ctx context.Context, args CleanStaleUploadsArgs) error
 	CleanPendingFilesForUser(ctx context.Context, userID int64) error
 	RefreshFolderSizesForUser(ctx context.Context, userID int64) error
+	CleanFileVersionsForUser(ctx context.Context, args CleanFileVersionsArgs) error
+	WebhookDeliver(ctx context.Context, args WebhookDeliverArgs, attempt int) error
 }
@@ -0,0 +1,248 @@
+package httpfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/tgdrive/teldrive/pkg/remotes"
+	"golang.org/x/net/html"
+)
+
+const (
+	listTimeout = 60 * time.Second
+	// maxIndexSize caps how much of a directory index page is parsed.
+	maxIndexSize = 8 << 20
+)
+
+// FS reads a single file from a direct download URL, or every file below a
+// plain HTML directory index such as the ones served by nginx, Apache or
+// python -m http.server. A URL whose path ends in a slash and serves HTML is
+// treated as a directory index.
+type FS struct {
+	root *url.URL
+}
+
+func New(source string) (*FS, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported source scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("missing http host")
+	}
+	u.Fragment = ""
+	return &FS{root: u}, nil
+}
+
+func (f *FS) List(ctx context.Context, nameOverride string, headers map[string]string, proxyURL string) ([]remotes.Entry, error) {
+	client, err := remotes.HTTPClient(proxyURL, listTimeout)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.head(ctx, client, f.root.String(), headers)
+	if err != nil {
+		return nil, err
+	}
+	final := resp.Request.URL
+	if strings.HasSuffix(final.Path, "/") && remotes.ParseMimeType(resp.Header.Get("Content-Type")) == "text/html" {
+		out := make([]remotes.Entry, 0, 128)
+		if err := f.walk(ctx, client, final, final, headers, map[string]bool{}, &out); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+
+	entry := entryFromResponse(resp, final.String())
+	if name := strings.TrimSpace(nameOverride); name != "" {
+		entry.Name = name
+	}
+	if entry.Name == "" {
+		return nil, fmt.Errorf("cannot tell the file name of %q", final.Redacted())
+	}
+	entry.RelPath = entry.Name
+	return []remotes.Entry{entry}, nil
+}
+
+// walk adds the files linked from the index page at dir and recurses into
+// linked subdirectories. Only links below root are followed.
+func (f *FS) walk(ctx context.Context, client *http.Client, root, dir *url.URL, headers map[string]string, seen map[string]bool, out *[]remotes.Entry) error {
+	if seen[dir.String()] {
+		return nil
+	}
+	seen[dir.String()] = true
+
+	links, err := f.indexLinks(ctx, client, dir, headers)
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		if link.Host != root.Host || !strings.HasPrefix(link.Path, root.Path) || link.Path == root.Path || link.RawQuery != "" {
+			continue
+		}
+		if strings.HasSuffix(link.Path, "/") {
+			if err := f.walk(ctx, client, root, link, headers, seen, out); err != nil {
+				return err
+			}
+			continue
+		}
+		if seen[link.String()] {
+			continue
+		}
+		seen[link.String()] = true
+		resp, err := f.head(ctx, client, link.String(), headers)
+		if err != nil {
+			return err
+		}
+		entry := entryFromResponse(resp, link.String())
+		entry.RelPath = strings.TrimPrefix(link.Path, root.Path)
+		entry.Name = path.Base(entry.RelPath)
+		*out = append(*out, entry)
+	}
+	return nil
+}
+
+func (f *FS) indexLinks(ctx context.Context, client *http.Client, dir *url.URL, headers map[string]string) ([]*url.URL, error) {
+	resp, err := f.do(ctx, client, http.MethodGet, dir.String(), headers, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	doc, err := html.Parse(io.LimitReader(resp.Body, maxIndexSize))
+	if err != nil {
+		return nil, fmt.Errorf("parse directory index %q: %w", dir.Redacted(), err)
+	}
+	var links []*url.URL
+	var visit func(*html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			for _, attr := range n.Attr {
+				if attr.Key != "href" {
+					continue
+				}
+				if ref, err := url.Parse(strings.TrimSpace(attr.Val)); err == nil {
+					link := dir.ResolveReference(ref)
+					link.Fragment = ""
+					links = append(links, link)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(doc)
+	return links, nil
+}
+
+func (f *FS) Open(ctx context.Context, sourcePath string, headers map[string]string, proxyURL string, sizeHint int64) (io.ReadCloser, int64, string, error) {
+	if sourcePath == "" {
+		sourcePath = f.root.String()
+	}
+	client, err := remotes.HTTPClient(proxyURL, 0)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	resp, err := f.do(ctx, client, http.MethodGet, sourcePath, headers, "")
+	if err != nil {
+		return nil, 0, "", err
+	}
+	size := sizeHint
+	if size <= 0 {
+		size = remotes.ParseContentLength(resp.Header.Get("Content-Length"))
+	}
+	return resp.Body, size, entryFromResponse(resp, sourcePath).MimeType, nil
+}
+
+// OpenRange starts reading sourcePath at offset with a ranged GET. Servers
+// that answer with the whole file instead of 206 Partial Content get
+// remotes.ErrRangeNotSupported.
+func (f *FS) OpenRange(ctx context.Context, sourcePath string, headers map[string]string, proxyURL string, offset int64) (io.ReadCloser, error) {
+	if sourcePath == "" {
+		sourcePath = f.root.String()
+	}
+	client, err := remotes.HTTPClient(proxyURL, 0)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.do(ctx, client, http.MethodGet, sourcePath, headers, fmt.Sprintf("bytes=%d-", offset))
+	if err != nil {
+		return nil, err
+	}
+	var start int64 = -1
+	if resp.StatusCode == http.StatusPartialContent {
+		_, _ = fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start)
+	}
+	if start != offset {
+		_ = resp.Body.Close()
+		return nil, remotes.ErrRangeNotSupported
+	}
+	return resp.Body, nil
+}
+
+// head returns the headers of rawURL. Servers that refuse HEAD are asked with
+// a GET whose body is dropped.
+func (f *FS) head(ctx context.Context, client *http.Client, rawURL string, headers map[string]string) (*http.Response, error) {
+	resp, err := f.do(ctx, client, http.MethodHead, rawURL, headers, "")
+	if err == nil {
+		_ = resp.Body.Close()
+		return resp, nil
+	}
+	resp, getErr := f.do(ctx, client, http.MethodGet, rawURL, headers, "")
+	if getErr != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	return resp, nil
+}
+
+func (f *FS) do(ctx context.Context, client *http.Client, method, rawURL string, headers map[string]string, rangeHeader string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("http %s %q failed: status=%d body=%s", method, req.URL.Redacted(), resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+func entryFromResponse(resp *http.Response, rawURL string) remotes.Entry {
+	name := remotes.ParseFileNameFromHeadersOrURL(resp.Header.Get("Content-Disposition"), rawURL)
+	mimeType := remotes.ParseMimeType(resp.Header.Get("Content-Type"))
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		if byExt := mime.TypeByExtension(path.Ext(name)); byExt != "" {
+			mimeType = byExt
+		}
+	}
+	return remotes.Entry{
+		SourcePath: rawURL,
+		Name:       name,
+		Size:       remotes.ParseContentLength(resp.Header.Get("Content-Length")),
+		MimeType:   mimeType,
+		ModifiedAt: remotes.ParseHTTPTime(resp.Header.Get("Last-Modified")),
+	}
+}
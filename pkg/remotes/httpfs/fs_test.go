@@ -0,0 +1,138 @@
+package httpfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tgdrive/teldrive/pkg/remotes"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	files := map[string]string{
+		"/pub/a.txt":       "alpha",
+		"/pub/sub/b.bin":   "bravo-bytes",
+		"/other/c.txt":     "charlie",
+		"/direct/file.mkv": "0123456789",
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pub/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/pub/":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = io.WriteString(w, `<html><body>
+<a href="?C=N;O=D">Name</a>
+<a href="../">Parent</a>
+<a href="a.txt">a.txt</a>
+<a href="sub/">sub/</a>
+<a href="/other/c.txt">outside</a>
+</body></html>`)
+		case "/pub/sub/":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = io.WriteString(w, `<a href="/pub/">up</a><a href="b.bin">b.bin</a>`)
+		default:
+			content, ok := files[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			http.ServeContent(w, r, r.URL.Path, modTime, strings.NewReader(content))
+		}
+	})
+	mux.HandleFunc("/direct/", func(w http.ResponseWriter, r *http.Request) {
+		// Serves the whole file and ignores Range, like some CDNs do.
+		w.Header().Set("Content-Disposition", `attachment; filename="movie.mkv"`)
+		w.Header().Set("Content-Type", "video/x-matroska")
+		w.Header().Set("Content-Length", "10")
+		_, _ = io.WriteString(w, files["/direct/file.mkv"])
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestListDirectoryIndex(t *testing.T) {
+	srv := newTestServer(t)
+	fs, err := New(srv.URL + "/pub/")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	entries, err := fs.List(context.Background(), "", map[string]string{"X-Token": "secret"}, "")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].RelPath < entries[j].RelPath })
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if entries[0].RelPath != "a.txt" || entries[0].Size != 5 || entries[0].ModifiedAt.IsZero() {
+		t.Fatalf("unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].RelPath != "sub/b.bin" || entries[1].Name != "b.bin" || entries[1].Size != 11 {
+		t.Fatalf("unexpected second entry: %+v", entries[1])
+	}
+
+	if _, err := fs.List(context.Background(), "", nil, ""); err == nil {
+		t.Fatal("expected error without the required header")
+	}
+}
+
+func TestListSingleURL(t *testing.T) {
+	srv := newTestServer(t)
+	fs, err := New(srv.URL + "/direct/file.mkv")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	entries, err := fs.List(context.Background(), "", nil, "")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name != "movie.mkv" || entries[0].Size != 10 || entries[0].MimeType != "video/x-matroska" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	entries, err = fs.List(context.Background(), "renamed.mkv", nil, "")
+	if err != nil || entries[0].RelPath != "renamed.mkv" {
+		t.Fatalf("expected name override, got %+v err=%v", entries, err)
+	}
+}
+
+func TestOpenRange(t *testing.T) {
+	srv := newTestServer(t)
+	fs, err := New(srv.URL + "/pub/")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	headers := map[string]string{"X-Token": "secret"}
+	rc, err := fs.OpenRange(context.Background(), srv.URL+"/pub/sub/b.bin", headers, "", 6)
+	if err != nil {
+		t.Fatalf("OpenRange() error = %v", err)
+	}
+	got, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(got) != "bytes" {
+		t.Fatalf("unexpected ranged content %q", got)
+	}
+
+	if _, err := fs.OpenRange(context.Background(), srv.URL+"/direct/file.mkv", nil, "", 4); !errors.Is(err, remotes.ErrRangeNotSupported) {
+		t.Fatalf("expected ErrRangeNotSupported, got %v", err)
+	}
+}
+
+func TestNewRejectsUnsupportedScheme(t *testing.T) {
+	if _, err := New("ftp://example.com/root"); err == nil {
+		t.Fatal("expected error for unsupported scheme")
+	}
+}
@@ -2,10 +2,15 @@ package remotes
 
 import (
 	"context"
+	"errors"
 	"io"
 	"time"
 )
 
+// ErrRangeNotSupported is returned by RangeFS.OpenRange when the remote
+// ignores the requested offset.
+var ErrRangeNotSupported = errors.New("remote does not support ranged reads")
+
 type Entry struct {
 	RelPath    string
 	SourcePath string
@@ -20,3 +25,26 @@ type FS interface {
 	List(ctx context.Context, nameOverride string, headers map[string]string, proxyURL string) ([]Entry, error)
 	Open(ctx context.Context, sourcePath string, headers map[string]string, proxyURL string, sizeHint int64) (io.ReadCloser, int64, string, error)
 }
+
+// RangeFS is implemented by remotes that can start reading a file at an
+// offset, so resumed transfers skip the bytes already uploaded without
+// downloading them again.
+type RangeFS interface {
+	FS
+	OpenRange(ctx context.Context, sourcePath string, headers map[string]string, proxyURL string, offset int64) (io.ReadCloser, error)
+}
+
+// WritableFS is implemented by remotes that can be the destination of a push
+// sync. Paths are relative to the root of the remote URL and use forward
+// slashes.
+type WritableFS interface {
+	FS
+	// Mkdir creates relPath and any missing parents.
+	Mkdir(ctx context.Context, relPath string, headers map[string]string, proxyURL string) error
+	// Create writes size bytes from r to relPath, replacing an existing file,
+	// and sets its modification time where the remote allows it.
+	Create(ctx context.Context, relPath string, r io.Reader, size int64, modTime time.Time, headers map[string]string, proxyURL string) error
+	// Remove deletes a file, or a directory with everything below it. Paths
+	// that do not exist are not an error.
+	Remove(ctx context.Context, relPath string, headers map[string]string, proxyURL string) error
+}
@@ -10,6 +10,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/tgdrive/teldrive/pkg/remotes"
 )
@@ -101,6 +102,67 @@ func (l *FS) Open(_ context.Context, sourcePath string, _ map[string]string, _ s
 	return f, size, mime.TypeByExtension(filepath.Ext(fullPath)), nil
 }
 
+// OpenRange opens sourcePath and seeks to offset.
+func (l *FS) OpenRange(_ context.Context, sourcePath string, _ map[string]string, _ string, offset int64) (io.ReadCloser, error) {
+	if sourcePath == "" {
+		sourcePath = l.root
+	}
+	f, err := os.Open(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (l *FS) Mkdir(_ context.Context, relPath string, _ map[string]string, _ string) error {
+	return os.MkdirAll(l.abs(relPath), 0o755)
+}
+
+// Create writes to a temporary file next to the target and renames it into
+// place, so an interrupted push never leaves a truncated file behind.
+func (l *FS) Create(_ context.Context, relPath string, r io.Reader, size int64, modTime time.Time, _ map[string]string, _ string) error {
+	target := l.abs(relPath)
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*.partial")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	n, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("short write to %q: wrote %d of %d bytes", target, n, size)
+	}
+	if !modTime.IsZero() {
+		if err := os.Chtimes(tmp.Name(), modTime, modTime); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+func (l *FS) Remove(_ context.Context, relPath string, _ map[string]string, _ string) error {
+	target := l.abs(relPath)
+	if target == l.root {
+		return fmt.Errorf("refusing to remove the remote root")
+	}
+	return os.RemoveAll(target)
+}
+
+// abs maps a slash separated path below the root to a local path. Cleaning
+// it as an absolute path first keeps ".." from leaving the root.
+func (l *FS) abs(relPath string) string {
+	return filepath.Join(l.root, filepath.FromSlash(path.Clean("/"+relPath)))
+}
+
 func pathFromURI(u *url.URL) string {
 	host := strings.TrimSpace(u.Host)
 	p := strings.TrimSpace(u.Path)
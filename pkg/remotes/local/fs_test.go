@@ -0,0 +1,79 @@
+package local
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWritableRoundTrip(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "backup")
+	ctx := context.Background()
+	fs, err := New("local://" + root)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := fs.Mkdir(ctx, "docs", nil, ""); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := fs.Create(ctx, "docs/a.txt", strings.NewReader("hello"), 5, modTime, nil, ""); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := fs.Create(ctx, "docs/short.txt", strings.NewReader("hi"), 5, modTime, nil, ""); err == nil {
+		t.Fatal("expected error for a short write")
+	}
+	if _, err := os.Stat(filepath.Join(root, "docs", "short.txt")); !os.IsNotExist(err) {
+		t.Fatalf("short write left a file behind: %v", err)
+	}
+
+	entries, err := fs.List(ctx, "", nil, "")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].RelPath != "docs/a.txt" || entries[0].Size != 5 || !entries[0].ModifiedAt.Equal(modTime) {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	if err := fs.Create(ctx, "../escape.txt", strings.NewReader("x"), 1, time.Time{}, nil, ""); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "escape.txt")); err != nil {
+		t.Fatalf("expected .. to stay inside the root: %v", err)
+	}
+
+	if err := fs.Remove(ctx, "docs", nil, ""); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "docs")); !os.IsNotExist(err) {
+		t.Fatalf("expected docs to be removed: %v", err)
+	}
+	if err := fs.Remove(ctx, "/", nil, ""); err == nil {
+		t.Fatal("expected error when removing the root")
+	}
+}
+
+func TestOpenRangeSeeksToOffset(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.bin"), []byte("0123456789"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	fs, err := New("local://" + dir)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	rc, err := fs.OpenRange(context.Background(), filepath.Join(dir, "a.bin"), nil, "", 6)
+	if err != nil {
+		t.Fatalf("OpenRange() error = %v", err)
+	}
+	got, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(got) != "6789" {
+		t.Fatalf("unexpected ranged content %q", got)
+	}
+}
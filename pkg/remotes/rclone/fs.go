@@ -119,34 +119,66 @@ func (r *FS) Open(ctx context.Context, sourcePath string, _ map[string]string, _
 	if sourcePath == "" {
 		sourcePath = r.root
 	}
-	objectPath := r.relativeObjectPath(sourcePath)
-	serveURL := r.serveObjectURL(r.serveBaseRemote(), objectPath)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serveURL, nil)
+	resp, err := r.serveGet(ctx, sourcePath, "")
 	if err != nil {
 		return nil, 0, "", err
 	}
+	size := sizeHint
+	if size <= 0 {
+		size = remotes.ParseContentLength(resp.Header.Get("Content-Length"))
+	}
+	mimeType := remotes.ParseMimeType(resp.Header.Get("Content-Type"))
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(path.Ext(sourcePath))
+	}
+	return resp.Body, size, mimeType, nil
+}
+
+// OpenRange starts reading sourcePath at offset with a ranged GET against the
+// rclone serve endpoint. A response other than 206 Partial Content for the
+// requested offset gets remotes.ErrRangeNotSupported.
+func (r *FS) OpenRange(ctx context.Context, sourcePath string, _ map[string]string, _ string, offset int64) (io.ReadCloser, error) {
+	if sourcePath == "" {
+		sourcePath = r.root
+	}
+	resp, err := r.serveGet(ctx, sourcePath, fmt.Sprintf("bytes=%d-", offset))
+	if err != nil {
+		return nil, err
+	}
+	var start int64 = -1
+	if resp.StatusCode == http.StatusPartialContent {
+		_, _ = fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start)
+	}
+	if start != offset {
+		_ = resp.Body.Close()
+		return nil, remotes.ErrRangeNotSupported
+	}
+	return resp.Body, nil
+}
+
+func (r *FS) serveGet(ctx context.Context, sourcePath, rangeHeader string) (*http.Response, error) {
+	serveURL := r.serveObjectURL(r.serveBaseRemote(), r.relativeObjectPath(sourcePath))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serveURL, nil)
+	if err != nil {
+		return nil, err
+	}
 	if r.rcURL.User != nil {
 		pw, _ := r.rcURL.User.Password()
 		req.SetBasicAuth(r.rcURL.User.Username(), pw)
 	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, 0, "", err
+		return nil, err
 	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		defer resp.Body.Close()
 		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		return nil, 0, "", fmt.Errorf("rclone serve get failed for %q: status=%d body=%s", serveURL, resp.StatusCode, strings.TrimSpace(string(msg)))
+		return nil, fmt.Errorf("rclone serve get failed for %q: status=%d body=%s", serveURL, resp.StatusCode, strings.TrimSpace(string(msg)))
 	}
-	size := sizeHint
-	if size <= 0 {
-		size = remotes.ParseContentLength(resp.Header.Get("Content-Length"))
-	}
-	mimeType := remotes.ParseMimeType(resp.Header.Get("Content-Type"))
-	if mimeType == "" {
-		mimeType = mime.TypeByExtension(path.Ext(sourcePath))
-	}
-	return resp.Body, size, mimeType, nil
+	return resp, nil
 }
 
 func (r *FS) callJSON(ctx context.Context, endpoint string, payload map[string]any, out any) error {
@@ -0,0 +1,224 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/tgdrive/teldrive/pkg/remotes"
+)
+
+// Sync job headers that carry credentials, so they do not have to be stored
+// in the source URL.
+const (
+	HeaderAccessKeyID     = "X-S3-Access-Key-Id"
+	HeaderSecretAccessKey = "X-S3-Secret-Access-Key"
+	HeaderSessionToken    = "X-S3-Session-Token"
+)
+
+const defaultRegion = "us-east-1"
+
+// FS reads objects from an S3 compatible bucket. Sources look like
+//
+//	s3://ACCESS_KEY:SECRET_KEY@host[:port]/bucket/prefix?region=eu-west-1&scheme=http
+//
+// The endpoint is reached over HTTPS unless scheme=http is given. Buckets are
+// addressed path-style, which works with MinIO and most S3 clones; add
+// lookup=dns for virtual-host style.
+type FS struct {
+	endpoint  string
+	secure    bool
+	region    string
+	lookup    minio.BucketLookupType
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+}
+
+func New(source string) (*FS, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(u.Scheme, "s3") {
+		return nil, fmt.Errorf("unsupported source scheme %q", u.Scheme)
+	}
+	if strings.TrimSpace(u.Host) == "" {
+		return nil, fmt.Errorf("s3 endpoint host is required")
+	}
+	rawPath := strings.Trim(u.Path, "/")
+	if rawPath == "" {
+		return nil, fmt.Errorf("s3 bucket is required as /<bucket>/<prefix>")
+	}
+	parts := strings.SplitN(rawPath, "/", 2)
+	f := &FS{
+		endpoint: u.Host,
+		secure:   !strings.EqualFold(u.Query().Get("scheme"), "http"),
+		region:   strings.TrimSpace(u.Query().Get("region")),
+		lookup:   minio.BucketLookupPath,
+		bucket:   parts[0],
+	}
+	if len(parts) == 2 {
+		f.prefix = parts[1]
+	}
+	if f.region == "" {
+		f.region = defaultRegion
+	}
+	if strings.EqualFold(u.Query().Get("lookup"), "dns") {
+		f.lookup = minio.BucketLookupDNS
+	}
+	if u.User != nil {
+		f.accessKey = u.User.Username()
+		f.secretKey, _ = u.User.Password()
+	}
+	return f, nil
+}
+
+// client builds a client for one call, taking credentials from the job
+// headers first and the source URL second.
+func (f *FS) client(headers map[string]string, proxyURL string) (*minio.Client, error) {
+	accessKey, secretKey := f.accessKey, f.secretKey
+	sessionToken := ""
+	for k, v := range headers {
+		switch http.CanonicalHeaderKey(k) {
+		case HeaderAccessKeyID:
+			accessKey = v
+		case HeaderSecretAccessKey:
+			secretKey = v
+		case HeaderSessionToken:
+			sessionToken = v
+		}
+	}
+	httpClient, err := remotes.HTTPClient(proxyURL, 0)
+	if err != nil {
+		return nil, err
+	}
+	opts := &minio.Options{
+		Secure:       f.secure,
+		Region:       f.region,
+		BucketLookup: f.lookup,
+		Transport:    httpClient.Transport,
+	}
+	if accessKey != "" || secretKey != "" {
+		opts.Creds = credentials.NewStaticV4(accessKey, secretKey, sessionToken)
+	}
+	return minio.New(f.endpoint, opts)
+}
+
+func (f *FS) List(ctx context.Context, nameOverride string, headers map[string]string, proxyURL string) ([]remotes.Entry, error) {
+	client, err := f.client(headers, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// A prefix without a trailing slash may name a single object.
+	if f.prefix != "" && !strings.HasSuffix(f.prefix, "/") {
+		info, err := client.StatObject(ctx, f.bucket, f.prefix, minio.StatObjectOptions{})
+		if err == nil {
+			name := path.Base(f.prefix)
+			if strings.TrimSpace(nameOverride) != "" {
+				name = strings.TrimSpace(nameOverride)
+			}
+			entry := entryFromObject(info, name)
+			entry.RelPath = name
+			return []remotes.Entry{entry}, nil
+		}
+		if code := minio.ToErrorResponse(err).Code; code != minio.NoSuchKey && code != "NotFound" {
+			return nil, err
+		}
+	}
+
+	prefix := f.prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	out := make([]remotes.Entry, 0, 128)
+	for obj := range client.ListObjects(ctx, f.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		// Zero byte keys ending in a slash are folder markers.
+		if strings.HasSuffix(obj.Key, "/") {
+			continue
+		}
+		rel := strings.TrimPrefix(obj.Key, prefix)
+		entry := entryFromObject(obj, path.Base(rel))
+		entry.RelPath = rel
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+func (f *FS) Open(ctx context.Context, sourcePath string, headers map[string]string, proxyURL string, sizeHint int64) (io.ReadCloser, int64, string, error) {
+	obj, info, err := f.getObject(ctx, sourcePath, headers, proxyURL, 0)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	size := sizeHint
+	if size <= 0 {
+		size = info.Size
+	}
+	return obj, size, entryFromObject(info, path.Base(info.Key)).MimeType, nil
+}
+
+// OpenRange starts reading the object at offset.
+func (f *FS) OpenRange(ctx context.Context, sourcePath string, headers map[string]string, proxyURL string, offset int64) (io.ReadCloser, error) {
+	obj, _, err := f.getObject(ctx, sourcePath, headers, proxyURL, offset)
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (f *FS) getObject(ctx context.Context, key string, headers map[string]string, proxyURL string, offset int64) (*minio.Object, minio.ObjectInfo, error) {
+	if key == "" {
+		key = f.prefix
+	}
+	client, err := f.client(headers, proxyURL)
+	if err != nil {
+		return nil, minio.ObjectInfo{}, err
+	}
+	obj, err := client.GetObject(ctx, f.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, minio.ObjectInfo{}, err
+	}
+	// GetObject is lazy; Stat sends the request so errors surface here
+	// rather than on the first read. Seeking makes the first read a ranged
+	// GET from offset.
+	info, err := obj.Stat()
+	if err == nil && offset > 0 {
+		_, err = obj.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		_ = obj.Close()
+		return nil, minio.ObjectInfo{}, err
+	}
+	return obj, info, nil
+}
+
+func entryFromObject(info minio.ObjectInfo, name string) remotes.Entry {
+	mimeType := remotes.ParseMimeType(info.ContentType)
+	if mimeType == "" || mimeType == "application/octet-stream" || mimeType == "binary/octet-stream" {
+		if byExt := mime.TypeByExtension(path.Ext(name)); byExt != "" {
+			mimeType = byExt
+		}
+	}
+	return remotes.Entry{
+		SourcePath: info.Key,
+		Name:       name,
+		Size:       info.Size,
+		MimeType:   mimeType,
+		// The ETag is left out: it is not a content hash for multipart
+		// uploads and never matches Teldrive's own, so sync compares size
+		// and time instead.
+		ModifiedAt: info.LastModified.UTC(),
+	}
+}
@@ -0,0 +1,209 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeS3 serves just enough of the S3 API for the client: HEAD and GET of
+// objects and ListObjectsV2 in pages of two keys.
+type fakeS3 struct {
+	bucket    string
+	objects   map[string]string
+	accessKey string
+	listCalls int
+}
+
+type listContent struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+}
+
+type listResult struct {
+	XMLName               xml.Name      `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string        `xml:"Name"`
+	Prefix                string        `xml:"Prefix"`
+	KeyCount              int           `xml:"KeyCount"`
+	MaxKeys               int           `xml:"MaxKeys"`
+	IsTruncated           bool          `xml:"IsTruncated"`
+	NextContinuationToken string        `xml:"NextContinuationToken,omitempty"`
+	Contents              []listContent `xml:"Contents"`
+}
+
+var fakeModTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func etag(content string) string {
+	sum := md5.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Authorization"), "Credential="+s.accessKey+"/") {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = io.WriteString(w, `<Error><Code>InvalidAccessKeyId</Code></Error>`)
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != s.bucket {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `<Error><Code>NoSuchBucket</Code></Error>`)
+		return
+	}
+	if key == "" {
+		s.list(w, r.URL.Query())
+		return
+	}
+	content, ok := s.objects[key]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		if r.Method != http.MethodHead {
+			_, _ = io.WriteString(w, `<Error><Code>NoSuchKey</Code></Error>`)
+		}
+		return
+	}
+	w.Header().Set("ETag", `"`+etag(content)+`"`)
+	w.Header().Set("Content-Type", "binary/octet-stream")
+	http.ServeContent(w, r, key, fakeModTime, strings.NewReader(content))
+}
+
+func (s *fakeS3) list(w http.ResponseWriter, q url.Values) {
+	s.listCalls++
+	prefix := q.Get("prefix")
+	keys := make([]string, 0, len(s.objects))
+	for k := range s.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	start, _ := strconv.Atoi(q.Get("continuation-token"))
+	end := min(start+2, len(keys))
+	res := listResult{Name: s.bucket, Prefix: prefix, MaxKeys: 2, KeyCount: end - start, IsTruncated: end < len(keys)}
+	if res.IsTruncated {
+		res.NextContinuationToken = strconv.Itoa(end)
+	}
+	for _, k := range keys[start:end] {
+		res.Contents = append(res.Contents, listContent{
+			Key:          k,
+			LastModified: fakeModTime.Format("2006-01-02T15:04:05.000Z"),
+			ETag:         `"` + etag(s.objects[k]) + `"`,
+			Size:         int64(len(s.objects[k])),
+		})
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(res)
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, string) {
+	t.Helper()
+	fake := &fakeS3{
+		bucket:    "backups",
+		accessKey: "minio",
+		objects: map[string]string{
+			"photos/":           "",
+			"photos/a.jpg":      "alpha",
+			"photos/b.txt":      "bravo",
+			"photos/2024/c.bin": "charlie-bytes",
+			"other/d.txt":       "delta",
+		},
+	}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	return fake, strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestListPaginatesPrefix(t *testing.T) {
+	fake, host := newFakeS3(t)
+	fs, err := New("s3://minio:secret@" + host + "/backups/photos?scheme=http")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	entries, err := fs.List(context.Background(), "", nil, "")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if fake.listCalls < 2 {
+		t.Fatalf("expected a paginated listing, got %d list calls", fake.listCalls)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].RelPath < entries[j].RelPath })
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", entries)
+	}
+	first := entries[0]
+	if first.RelPath != "2024/c.bin" || first.Name != "c.bin" || first.SourcePath != "photos/2024/c.bin" || first.Size != 13 {
+		t.Fatalf("unexpected first entry: %+v", first)
+	}
+	if first.Hash != "" || !first.ModifiedAt.Equal(fakeModTime) {
+		t.Fatalf("unexpected hash or time: %+v", first)
+	}
+	if entries[1].RelPath != "a.jpg" || entries[1].MimeType != "image/jpeg" {
+		t.Fatalf("unexpected second entry: %+v", entries[1])
+	}
+}
+
+func TestListSingleObject(t *testing.T) {
+	_, host := newFakeS3(t)
+	fs, err := New("s3://minio:secret@" + host + "/backups/other/d.txt?scheme=http")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	entries, err := fs.List(context.Background(), "renamed.txt", nil, "")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].RelPath != "renamed.txt" || entries[0].SourcePath != "other/d.txt" || entries[0].Hash != "" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestOpenWithHeaderCredentials(t *testing.T) {
+	fake, host := newFakeS3(t)
+	fake.accessKey = "from-header"
+	fs, err := New("s3://" + host + "/backups?scheme=http")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, _, _, err := fs.Open(context.Background(), "photos/b.txt", nil, "", 0); err == nil {
+		t.Fatal("expected error without credentials")
+	}
+
+	headers := map[string]string{"x-s3-access-key-id": "from-header", "X-S3-Secret-Access-Key": "secret"}
+	rc, size, _, err := fs.Open(context.Background(), "photos/b.txt", headers, "", 0)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	got, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(got) != "bravo" || size != 5 {
+		t.Fatalf("unexpected content %q size=%d", got, size)
+	}
+
+	rc, err = fs.OpenRange(context.Background(), "photos/2024/c.bin", headers, "", 8)
+	if err != nil {
+		t.Fatalf("OpenRange() error = %v", err)
+	}
+	got, _ = io.ReadAll(rc)
+	_ = rc.Close()
+	if string(got) != "bytes" {
+		t.Fatalf("unexpected ranged content %q", got)
+	}
+}
+
+func TestNewRequiresBucket(t *testing.T) {
+	if _, err := New("s3://minio.local:9000/"); err == nil {
+		t.Fatal("expected error without bucket")
+	}
+}
@@ -2,10 +2,12 @@ package sftp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"net/url"
+	"os"
 	"path"
 	"strings"
 	"time"
@@ -148,6 +150,108 @@ func (s *FS) Open(_ context.Context, sourcePath string, _ map[string]string, _ s
 	return &compoundReadCloser{Reader: f, closers: []io.Closer{f, client, conn}}, size, mime.TypeByExtension(path.Ext(sourcePath)), nil
 }
 
+// OpenRange opens sourcePath on a new connection and seeks to offset, so
+// several ranges of one file can be read at the same time.
+func (s *FS) OpenRange(_ context.Context, sourcePath string, _ map[string]string, _ string, offset int64) (io.ReadCloser, error) {
+	if sourcePath == "" {
+		sourcePath = s.root
+	}
+	conn, client, err := s.dial()
+	if err != nil {
+		return nil, err
+	}
+	f, err := client.Open(sourcePath)
+	if err == nil {
+		_, err = f.Seek(offset, io.SeekStart)
+		if err != nil {
+			_ = f.Close()
+		}
+	}
+	if err != nil {
+		_ = client.Close()
+		_ = conn.Close()
+		return nil, err
+	}
+	return &compoundReadCloser{Reader: f, closers: []io.Closer{f, client, conn}}, nil
+}
+
+func (s *FS) Mkdir(_ context.Context, relPath string, _ map[string]string, _ string) error {
+	conn, client, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Close()
+		_ = conn.Close()
+	}()
+	return client.MkdirAll(s.abs(relPath))
+}
+
+// Create uploads to a temporary name and renames it over the target once the
+// upload is complete.
+func (s *FS) Create(_ context.Context, relPath string, r io.Reader, size int64, modTime time.Time, _ map[string]string, _ string) error {
+	conn, client, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Close()
+		_ = conn.Close()
+	}()
+
+	target := s.abs(relPath)
+	tmp := path.Join(path.Dir(target), "."+path.Base(target)+".partial")
+	f, err := client.Create(tmp)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n != size {
+		err = fmt.Errorf("short write to %q: wrote %d of %d bytes", target, n, size)
+	}
+	if err == nil && !modTime.IsZero() {
+		err = client.Chtimes(tmp, modTime, modTime)
+	}
+	if err == nil {
+		// Plain SFTP rename fails when the target exists; the posix-rename
+		// extension replaces it atomically where the server offers it.
+		if err = client.PosixRename(tmp, target); err != nil {
+			_ = client.Remove(target)
+			err = client.Rename(tmp, target)
+		}
+	}
+	if err != nil {
+		_ = client.Remove(tmp)
+	}
+	return err
+}
+
+func (s *FS) Remove(_ context.Context, relPath string, _ map[string]string, _ string) error {
+	target := s.abs(relPath)
+	if target == path.Clean(s.root) {
+		return fmt.Errorf("refusing to remove the remote root")
+	}
+	conn, client, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Close()
+		_ = conn.Close()
+	}()
+	if err := client.RemoveAll(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FS) abs(relPath string) string {
+	return path.Join(s.root, path.Clean("/"+relPath))
+}
+
 type compoundReadCloser struct {
 	io.Reader
 	closers []io.Closer
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/studio-b12/gowebdav"
 	"github.com/tgdrive/teldrive/pkg/remotes"
@@ -138,3 +139,41 @@ func (w *FS) Open(_ context.Context, sourcePath string, _ map[string]string, _ s
 	}
 	return rc, size, mime.TypeByExtension(path.Ext(sourcePath)), nil
 }
+
+// OpenRange reads sourcePath from offset with a ranged GET. The client falls
+// back to skipping offset bytes when the server ignores the range.
+func (w *FS) OpenRange(_ context.Context, sourcePath string, _ map[string]string, _ string, offset int64) (io.ReadCloser, error) {
+	if sourcePath == "" {
+		sourcePath = w.root
+	}
+	st, err := w.client.Stat(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	if offset >= st.Size() {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	return w.client.ReadStreamRange(sourcePath, offset, st.Size()-offset)
+}
+
+func (w *FS) Mkdir(_ context.Context, relPath string, _ map[string]string, _ string) error {
+	return w.client.MkdirAll(w.abs(relPath), 0o755)
+}
+
+// Create uploads the file with a single PUT. WebDAV has no portable way to set
+// the modification time, so the server records the upload time instead.
+func (w *FS) Create(_ context.Context, relPath string, r io.Reader, size int64, _ time.Time, _ map[string]string, _ string) error {
+	return w.client.WriteStreamWithLength(w.abs(relPath), r, size, 0o644)
+}
+
+func (w *FS) Remove(_ context.Context, relPath string, _ map[string]string, _ string) error {
+	target := w.abs(relPath)
+	if target == path.Clean(w.root) {
+		return fmt.Errorf("refusing to remove the remote root")
+	}
+	return w.client.RemoveAll(target)
+}
+
+func (w *FS) abs(relPath string) string {
+	return path.Join(w.root, path.Clean("/"+relPath))
+}
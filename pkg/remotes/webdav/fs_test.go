@@ -1,6 +1,15 @@
 package webdav
 
-import "testing"
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
 
 func TestNewSchemeMapping(t *testing.T) {
 	tests := []struct {
@@ -55,3 +64,58 @@ func TestNewRejectsUnsupportedScheme(t *testing.T) {
 		t.Fatal("expected error for unsupported scheme")
 	}
 }
+
+func TestWritableRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(&webdav.Handler{FileSystem: webdav.NewMemFS(), LockSystem: webdav.NewMemLS()})
+	t.Cleanup(srv.Close)
+	ctx := context.Background()
+
+	fs, err := New("dav://" + strings.TrimPrefix(srv.URL, "http://") + "/backup")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := fs.Mkdir(ctx, "docs/2024", nil, ""); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+	for _, name := range []string{"docs/2024/a.txt", "docs/b.txt"} {
+		if err := fs.Create(ctx, name, strings.NewReader("hello"), 5, time.Time{}, nil, ""); err != nil {
+			t.Fatalf("Create(%q) error = %v", name, err)
+		}
+	}
+
+	rc, size, _, err := fs.Open(ctx, "/backup/docs/2024/a.txt", nil, "", 0)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	got, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(got) != "hello" || size != 5 {
+		t.Fatalf("unexpected content %q size=%d", got, size)
+	}
+	rc, err = fs.OpenRange(ctx, "/backup/docs/2024/a.txt", nil, "", 3)
+	if err != nil {
+		t.Fatalf("OpenRange() error = %v", err)
+	}
+	got, _ = io.ReadAll(rc)
+	_ = rc.Close()
+	if string(got) != "lo" {
+		t.Fatalf("unexpected ranged content %q", got)
+	}
+
+	if err := fs.Remove(ctx, "docs/2024", nil, ""); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := fs.Remove(ctx, "missing.txt", nil, ""); err != nil {
+		t.Fatalf("Remove() of a missing path error = %v", err)
+	}
+	if err := fs.Remove(ctx, "", nil, ""); err == nil {
+		t.Fatal("expected error when removing the root")
+	}
+	entries, err := fs.List(ctx, "", nil, "")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].RelPath != "docs/b.txt" {
+		t.Fatalf("unexpected entries after remove: %+v", entries)
+	}
+}
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-jet/jet/v2/postgres"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/database/jet/gen/table"
+)
+
+type JetAllowedUserRepository struct {
+	db jetDB
+}
+
+func NewJetAllowedUserRepository(pool *pgxpool.Pool) *JetAllowedUserRepository {
+	return &JetAllowedUserRepository{db: newJetDB(pool)}
+}
+
+func (r *JetAllowedUserRepository) List(ctx context.Context) ([]string, error) {
+	stmt := table.AllowedUsers.
+		SELECT(table.AllowedUsers.UserName).
+		FROM(table.AllowedUsers).
+		ORDER_BY(table.AllowedUsers.UserName.ASC())
+
+	var rows []model.AllowedUsers
+	if err := r.db.query(ctx, stmt, &rows); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(rows))
+	for _, row := range rows {
+		names = append(names, row.UserName)
+	}
+
+	return names, nil
+}
+
+func (r *JetAllowedUserRepository) Add(ctx context.Context, userName string) error {
+	stmt := table.AllowedUsers.
+		INSERT(table.AllowedUsers.AllColumns).
+		MODEL(model.AllowedUsers{UserName: userName, CreatedAt: time.Now().UTC()}).
+		ON_CONFLICT(table.AllowedUsers.UserName).
+		DO_NOTHING()
+
+	return r.db.exec(ctx, stmt)
+}
+
+func (r *JetAllowedUserRepository) Remove(ctx context.Context, userName string) error {
+	stmt := table.AllowedUsers.DELETE().WHERE(table.AllowedUsers.UserName.EQ(postgres.String(userName)))
+
+	tag, err := r.db.execTag(ctx, stmt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -78,6 +78,29 @@ func (r *JetAPIKeyRepository) GetActiveByTokenHash(ctx context.Context, tokenHas
 	return &out, nil
 }
 
+func (r *JetAPIKeyRepository) GetActiveByID(ctx context.Context, id uuid.UUID, now time.Time) (*model.APIKeys, error) {
+	stmt := table.APIKeys.
+		SELECT(table.APIKeys.AllColumns).
+		FROM(table.APIKeys).
+		WHERE(
+			table.APIKeys.ID.EQ(postgres.UUID(id)).
+				AND(table.APIKeys.RevokedAt.IS_NULL()).
+				AND(table.APIKeys.ExpiresAt.IS_NULL().OR(table.APIKeys.ExpiresAt.GT(postgres.TimestampT(now)))),
+		).
+		LIMIT(1)
+
+	var out model.APIKeys
+	err := r.db.query(ctx, stmt, &out)
+	if err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &out, nil
+}
+
 func (r *JetAPIKeyRepository) TouchLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
 	stmt := table.APIKeys.UPDATE(table.APIKeys.LastUsedAt, table.APIKeys.UpdatedAt).
 		SET(postgres.TimestampT(usedAt), postgres.TimestampT(usedAt)).
@@ -109,3 +132,17 @@ func (r *JetAPIKeyRepository) Revoke(ctx context.Context, userID int64, id uuid.
 
 	return nil
 }
+
+// RevokeByUserID revokes every active API key of a user.
+func (r *JetAPIKeyRepository) RevokeByUserID(ctx context.Context, userID int64) error {
+	now := time.Now().UTC()
+
+	stmt := table.APIKeys.UPDATE(table.APIKeys.RevokedAt, table.APIKeys.UpdatedAt).
+		SET(postgres.TimestampT(now), postgres.TimestampT(now)).
+		WHERE(
+			table.APIKeys.UserID.EQ(postgres.Int64(userID)).
+				AND(table.APIKeys.RevokedAt.IS_NULL()),
+		)
+
+	return r.db.exec(ctx, stmt)
+}
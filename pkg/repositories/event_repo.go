@@ -2,9 +2,13 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/tgdrive/teldrive/internal/database/jet/gen/model"
@@ -19,15 +23,36 @@ func NewJetEventRepository(pool *pgxpool.Pool) *JetEventRepository {
 	return &JetEventRepository{db: newJetDB(pool)}
 }
 
+// Create stores event. Without a CreatedAt the database sets it when the row
+// is inserted.
 func (r *JetEventRepository) Create(ctx context.Context, event *model.Events) error {
+	columns := table.Events.AllColumns.Except(table.Events.Seq)
 	if event.CreatedAt.IsZero() {
-		event.CreatedAt = time.Now().UTC()
+		columns = columns.Except(table.Events.CreatedAt)
 	}
 
-	stmt := table.Events.INSERT(table.Events.AllColumns).MODEL(*event)
-	err := r.db.exec(ctx, stmt)
+	stmt := table.Events.INSERT(columns).MODEL(*event)
+	return r.db.exec(ctx, stmt)
+}
+
+func (r *JetEventRepository) GetByIDAndUserID(ctx context.Context, id uuid.UUID, userID int64) (*model.Events, error) {
+	stmt := table.Events.
+		SELECT(table.Events.AllColumns).
+		FROM(table.Events).
+		WHERE(
+			table.Events.ID.EQ(postgres.UUID(id)).
+				AND(table.Events.UserID.EQ(postgres.Int64(userID))),
+		)
+
+	var out model.Events
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
 
-	return err
+	return &out, nil
 }
 
 func (r *JetEventRepository) GetByUserID(ctx context.Context, userID int64, since time.Time) ([]model.Events, error) {
@@ -89,27 +114,132 @@ func (r *JetEventRepository) GetSince(ctx context.Context, since time.Time, limi
 	return out, nil
 }
 
-func (r *JetEventRepository) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
-	stmt := table.Events.DELETE().WHERE(table.Events.CreatedAt.LT(postgres.TimestampT(before)))
+// ListSince returns the events of all users created after since with a
+// sequence number above afterSeq, in sequence order, so callers can page
+// through them by passing the last sequence number they got.
+func (r *JetEventRepository) ListSince(ctx context.Context, since time.Time, afterSeq int64, limit int) ([]model.Events, error) {
+	stmt := table.Events.
+		SELECT(table.Events.AllColumns).
+		FROM(table.Events).
+		WHERE(
+			table.Events.CreatedAt.GT(postgres.TimestampT(since)).
+				AND(table.Events.Seq.GT(postgres.Int64(afterSeq))),
+		).
+		ORDER_BY(table.Events.Seq.ASC())
 
-	tag, err := r.db.execTag(ctx, stmt)
-	if err != nil {
-		return 0, err
+	if limit > 0 {
+		stmt = stmt.LIMIT(int64(limit))
+	}
+
+	var out []model.Events
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		return nil, err
 	}
 
-	return tag.RowsAffected(), nil
+	return out, nil
 }
 
-func (r *JetEventRepository) DeleteOlderThanForUser(ctx context.Context, userID int64, before time.Time) (int64, error) {
-	stmt := table.Events.DELETE().WHERE(
-		table.Events.UserID.EQ(postgres.Int64(userID)).
-			AND(table.Events.CreatedAt.LT(postgres.TimestampT(before))),
-	)
+// ListAfterSeq returns the events of a user after the given sequence number,
+// oldest first. Events past the last one created up to before are held back,
+// so the cut is made by sequence number and never skips an earlier event.
+func (r *JetEventRepository) ListAfterSeq(ctx context.Context, userID int64, afterSeq int64, before time.Time, types []string, limit int) ([]model.Events, error) {
+	latest := table.Events.
+		SELECT(postgres.MAX(table.Events.Seq)).
+		FROM(table.Events).
+		WHERE(
+			table.Events.UserID.EQ(postgres.Int64(userID)).
+				AND(table.Events.CreatedAt.LT_EQ(postgres.TimestampT(before))),
+		)
+	condition := table.Events.UserID.EQ(postgres.Int64(userID)).
+		AND(table.Events.Seq.GT(postgres.Int64(afterSeq))).
+		AND(table.Events.Seq.LT_EQ(postgres.IntExp(latest)))
+	if len(types) > 0 {
+		typeExprs := make([]postgres.Expression, 0, len(types))
+		for _, t := range types {
+			typeExprs = append(typeExprs, postgres.String(t))
+		}
+		condition = condition.AND(table.Events.Type.IN(typeExprs...))
+	}
+
+	stmt := table.Events.
+		SELECT(table.Events.AllColumns).
+		FROM(table.Events).
+		WHERE(condition).
+		ORDER_BY(table.Events.Seq.ASC())
+
+	if limit > 0 {
+		stmt = stmt.LIMIT(int64(limit))
+	}
+
+	var out []model.Events
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		return nil, err
+	}
 
-	tag, err := r.db.execTag(ctx, stmt)
-	if err != nil {
+	return out, nil
+}
+
+// LatestSeq returns the highest sequence number a user has seen up to before,
+// counting pruned events.
+func (r *JetEventRepository) LatestSeq(ctx context.Context, userID int64, before time.Time) (int64, error) {
+	query := `
+SELECT GREATEST(
+	COALESCE((SELECT max(seq) FROM teldrive.events WHERE user_id = $1 AND created_at <= $2), 0),
+	COALESCE((SELECT seq FROM teldrive.event_prunes WHERE user_id = $1), 0)
+);`
+
+	var seq int64
+	if err := r.db.executor(ctx).QueryRow(ctx, query, userID, before).Scan(&seq); err != nil {
+		return 0, normalizeDBError(err)
+	}
+	return seq, nil
+}
+
+// PrunedSeq returns the highest sequence number deleted for a user, or 0.
+func (r *JetEventRepository) PrunedSeq(ctx context.Context, userID int64) (int64, error) {
+	stmt := table.EventPrunes.
+		SELECT(table.EventPrunes.Seq).
+		FROM(table.EventPrunes).
+		WHERE(table.EventPrunes.UserID.EQ(postgres.Int64(userID)))
+
+	var out model.EventPrunes
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return 0, nil
+		}
 		return 0, err
 	}
+	return out.Seq, nil
+}
+
+// pruneEventsQuery deletes events and raises the pruned sequence number of
+// every affected user, so stale change cursors can be detected.
+const pruneEventsQuery = `
+WITH deleted AS (
+	DELETE FROM teldrive.events WHERE %s RETURNING user_id, seq
+), pruned AS (
+	INSERT INTO teldrive.event_prunes (user_id, seq)
+	SELECT user_id, max(seq) FROM deleted GROUP BY user_id
+	ON CONFLICT (user_id) DO UPDATE SET seq = GREATEST(teldrive.event_prunes.seq, EXCLUDED.seq)
+)
+SELECT count(*) FROM deleted;`
+
+func (r *JetEventRepository) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	query := fmt.Sprintf(pruneEventsQuery, "created_at < $1")
+	if err := r.db.executor(ctx).QueryRow(ctx, query, before).Scan(&deleted); err != nil {
+		return 0, normalizeDBError(err)
+	}
+
+	return deleted, nil
+}
+
+func (r *JetEventRepository) DeleteOlderThanForUser(ctx context.Context, userID int64, before time.Time) (int64, error) {
+	var deleted int64
+	query := fmt.Sprintf(pruneEventsQuery, "user_id = $1 AND created_at < $2")
+	if err := r.db.executor(ctx).QueryRow(ctx, query, userID, before).Scan(&deleted); err != nil {
+		return 0, normalizeDBError(err)
+	}
 
-	return tag.RowsAffected(), nil
+	return deleted, nil
 }
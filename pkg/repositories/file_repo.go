@@ -196,6 +196,34 @@ func (r *JetFileRepository) GetActiveByNameAndParent(ctx context.Context, userID
 	return &out, nil
 }
 
+// GetActiveByHash returns the oldest active file of a user with the given
+// content hash and size that still has parts to share.
+func (r *JetFileRepository) GetActiveByHash(ctx context.Context, userID int64, hash string, size int64) (*model.Files, error) {
+	stmt := selectFilesForRead(table.Files).
+		FROM(table.Files).
+		WHERE(
+			table.Files.UserID.EQ(postgres.Int64(userID)).
+				AND(table.Files.Hash.EQ(postgres.String(hash))).
+				AND(table.Files.Size.EQ(postgres.Int64(size))).
+				AND(table.Files.Type.EQ(postgres.String("file"))).
+				AND(table.Files.Status.EQ(postgres.String("active"))).
+				AND(table.Files.ChannelID.IS_NOT_NULL()).
+				AND(table.Files.Parts.IS_NOT_NULL()),
+		).
+		ORDER_BY(table.Files.CreatedAt.ASC(), table.Files.ID.ASC()).
+		LIMIT(1)
+
+	var out model.Files
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &out, nil
+}
+
 func (r *JetFileRepository) Update(ctx context.Context, id uuid.UUID, update FileUpdate) error {
 	_, err := r.UpdateReturning(ctx, id, update)
 	return err
@@ -472,10 +500,12 @@ WHERE f.id = cs.id AND f.user_id = $1 AND f.type = 'folder' AND f.status = 'acti
 }
 
 func (r *JetFileRepository) ListCheckFiles(ctx context.Context, userID, channelID int64, includePending bool) ([]CheckFile, error) {
-	statusExpr := table.Files.Status.EQ(postgres.String("active"))
+	// Trashed files can still be restored, so their parts count as in use.
+	statuses := []postgres.Expression{postgres.String("active"), postgres.String("trashed")}
 	if includePending {
-		statusExpr = table.Files.Status.IN(postgres.String("active"), postgres.String("pending_deletion"))
+		statuses = append(statuses, postgres.String("pending_deletion"))
 	}
+	statusExpr := table.Files.Status.IN(statuses...)
 
 	stmt := selectFilesForRead(table.Files).
 		FROM(table.Files).
@@ -540,6 +570,51 @@ func (r *JetFileRepository) CategoryStats(ctx context.Context, userID int64) ([]
 	return stats, nil
 }
 
+// StorageUsage counts the files a user keeps, including trashed ones, and
+// sums their size together with the size of their stored versions.
+func (r *JetFileRepository) StorageUsage(ctx context.Context, userID int64) (*UsageStats, error) {
+	query := `
+SELECT
+	(SELECT COUNT(*) FROM teldrive.files f
+	 WHERE f.user_id = $1 AND f.type = 'file' AND f.status IN ('active', 'trashed')),
+	((SELECT COALESCE(SUM(f.size), 0) FROM teldrive.files f
+	  WHERE f.user_id = $1 AND f.type = 'file' AND f.status IN ('active', 'trashed'))
+	 + (SELECT COALESCE(SUM(v.size), 0) FROM teldrive.file_versions v
+	  JOIN teldrive.files f ON f.id = v.file_id
+	  WHERE v.user_id = $1 AND f.status IN ('active', 'trashed')))::bigint`
+
+	var stats UsageStats
+	if err := r.db.executor(ctx).QueryRow(ctx, query, userID).Scan(&stats.TotalFiles, &stats.TotalSize); err != nil {
+		return nil, normalizeDBError(err)
+	}
+	return &stats, nil
+}
+
+// SubtreeStats counts the active files below a folder and sums their size.
+func (r *JetFileRepository) SubtreeStats(ctx context.Context, folderID uuid.UUID, userID int64) (*UsageStats, error) {
+	query := `
+WITH RECURSIVE subtree AS (
+	SELECT f.id, f.type, f.size
+	FROM teldrive.files f
+	WHERE f.id = $1 AND f.user_id = $2 AND f.status = 'active'
+
+	UNION ALL
+
+	SELECT f.id, f.type, f.size
+	FROM teldrive.files f
+	JOIN subtree s ON f.parent_id = s.id
+	WHERE f.status = 'active'
+)
+SELECT COUNT(*) FILTER (WHERE type = 'file'), COALESCE(SUM(size) FILTER (WHERE type = 'file'), 0)::bigint
+FROM subtree`
+
+	var stats UsageStats
+	if err := r.db.executor(ctx).QueryRow(ctx, query, folderID, userID).Scan(&stats.TotalFiles, &stats.TotalSize); err != nil {
+		return nil, normalizeDBError(err)
+	}
+	return &stats, nil
+}
+
 func (r *JetFileRepository) DeleteBulk(ctx context.Context, fileIDs []uuid.UUID, userID int64, targetStatus string) error {
 	_, err := r.DeleteBulkReturning(ctx, fileIDs, userID, targetStatus)
 	return err
@@ -595,6 +670,216 @@ func (r *JetFileRepository) DeleteBulkReturning(ctx context.Context, fileIDs []u
 	return out, nil
 }
 
+// TrashBulkReturning moves the given active entries and their active
+// descendants to the trash. Only the requested entries get a trashed_path, so
+// they are the ones listed in and restored from the trash.
+func (r *JetFileRepository) TrashBulkReturning(ctx context.Context, fileIDs []uuid.UUID, userID int64, trashedAt time.Time) ([]model.Files, error) {
+	if len(fileIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+WITH RECURSIVE roots AS (
+	SELECT f.id, f.parent_id, f.name
+	FROM teldrive.files f
+	WHERE f.id = ANY($1::uuid[]) AND f.user_id = $2 AND f.status = 'active' AND f.parent_id IS NOT NULL
+), ancestors AS (
+	SELECT r.id AS root_id, r.parent_id, r.name, 0 AS depth
+	FROM roots r
+
+	UNION ALL
+
+	SELECT a.root_id, p.parent_id, p.name, a.depth + 1
+	FROM ancestors a
+	JOIN teldrive.files p ON p.id = a.parent_id
+), paths AS (
+	SELECT root_id, '/' || string_agg(name, '/' ORDER BY depth DESC) FILTER (WHERE parent_id IS NOT NULL) AS path
+	FROM ancestors
+	GROUP BY root_id
+), subtree AS (
+	SELECT r.id FROM roots r
+
+	UNION ALL
+
+	SELECT f.id
+	FROM teldrive.files f
+	JOIN subtree s ON f.parent_id = s.id
+	WHERE f.status = 'active'
+)
+UPDATE teldrive.files f
+SET status = 'trashed',
+	trashed_at = $3,
+	trashed_path = (SELECT p.path FROM paths p WHERE p.root_id = f.id),
+	updated_at = $3
+WHERE f.id IN (SELECT id FROM subtree)
+RETURNING f.id::text;`
+
+	return r.execReturningFiles(ctx, query, fileIDs, userID, trashedAt)
+}
+
+func (r *JetFileRepository) GetTrashedByIDAndUser(ctx context.Context, id uuid.UUID, userID int64) (*model.Files, error) {
+	stmt := selectFilesForRead(table.Files).FROM(table.Files).WHERE(
+		table.Files.ID.EQ(postgres.UUID(id)).
+			AND(table.Files.UserID.EQ(postgres.Int64(userID))).
+			AND(table.Files.Status.EQ(postgres.String("trashed"))).
+			AND(table.Files.TrashedPath.IS_NOT_NULL()),
+	)
+
+	var out model.Files
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+func (r *JetFileRepository) ListTrashed(ctx context.Context, userID int64, cursor *TrashCursor, limit int) ([]model.Files, error) {
+	condition := table.Files.UserID.EQ(postgres.Int64(userID)).
+		AND(table.Files.Status.EQ(postgres.String("trashed"))).
+		AND(table.Files.TrashedPath.IS_NOT_NULL())
+	if cursor != nil {
+		trashedAt := postgres.TimestampT(cursor.TrashedAt)
+		condition = condition.AND(
+			table.Files.TrashedAt.LT(trashedAt).OR(
+				table.Files.TrashedAt.EQ(trashedAt).AND(table.Files.ID.LT(postgres.UUID(cursor.ID))),
+			),
+		)
+	}
+
+	stmt := selectFilesForRead(table.Files).FROM(table.Files).WHERE(condition).
+		ORDER_BY(table.Files.TrashedAt.DESC(), table.Files.ID.DESC()).
+		LIMIT(int64(limit))
+
+	var out []model.Files
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return []model.Files{}, nil
+		}
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// RestoreTrashed makes a trashed entry and everything trashed along with it
+// active again, placing the entry under parentID.
+func (r *JetFileRepository) RestoreTrashed(ctx context.Context, id uuid.UUID, userID int64, parentID uuid.UUID) ([]model.Files, error) {
+	query := `
+WITH RECURSIVE subtree AS (
+	SELECT f.id
+	FROM teldrive.files f
+	WHERE f.id = $1 AND f.user_id = $2 AND f.status = 'trashed' AND f.trashed_path IS NOT NULL
+
+	UNION ALL
+
+	SELECT f.id
+	FROM teldrive.files f
+	JOIN subtree s ON f.parent_id = s.id
+	WHERE f.status = 'trashed' AND f.trashed_path IS NULL
+)
+UPDATE teldrive.files f
+SET status = 'active',
+	trashed_at = NULL,
+	trashed_path = NULL,
+	parent_id = CASE WHEN f.id = $1 THEN $3 ELSE f.parent_id END,
+	updated_at = $4
+WHERE f.id IN (SELECT id FROM subtree)
+RETURNING f.id::text;`
+
+	return r.execReturningFiles(ctx, query, id, userID, parentID, time.Now().UTC())
+}
+
+// PurgeTrashed hands trashed entries over to the pending deletion cleanup.
+// With fileIDs set only those entries and their trashed descendants are
+// purged, otherwise everything trashed before the given time is.
+func (r *JetFileRepository) PurgeTrashed(ctx context.Context, userID int64, fileIDs []uuid.UUID, before *time.Time) error {
+	if len(fileIDs) == 0 {
+		condition := table.Files.UserID.EQ(postgres.Int64(userID)).
+			AND(table.Files.Status.EQ(postgres.String("trashed")))
+		if before != nil {
+			condition = condition.AND(table.Files.TrashedAt.LT(postgres.TimestampT(*before)))
+		}
+		stmt := table.Files.UPDATE().
+			SET(table.Files.Status.SET(postgres.String("pending_deletion"))).
+			WHERE(condition)
+		return r.db.exec(ctx, stmt)
+	}
+
+	idExprs := make([]postgres.Expression, 0, len(fileIDs))
+	for _, id := range fileIDs {
+		idExprs = append(idExprs, postgres.UUID(id))
+	}
+
+	subtreeID := postgres.StringColumn("id")
+	subtree := postgres.CTE("subtree", subtreeID)
+
+	stmt := postgres.WITH_RECURSIVE(
+		subtree.AS(
+			postgres.SELECT(table.Files.ID).
+				FROM(table.Files).
+				WHERE(
+					table.Files.ID.IN(idExprs...).
+						AND(table.Files.UserID.EQ(postgres.Int64(userID))).
+						AND(table.Files.Status.EQ(postgres.String("trashed"))).
+						AND(table.Files.TrashedPath.IS_NOT_NULL()),
+				).
+				UNION_ALL(
+					postgres.SELECT(table.Files.ID).
+						FROM(
+							table.Files.INNER_JOIN(subtree, table.Files.ParentID.EQ(subtreeID.From(subtree))),
+						).
+						WHERE(table.Files.Status.EQ(postgres.String("trashed"))),
+				),
+		),
+	)(
+		table.Files.UPDATE().
+			SET(table.Files.Status.SET(postgres.String("pending_deletion"))).
+			WHERE(table.Files.ID.IN(subtree.SELECT(subtreeID.From(subtree)))),
+	)
+
+	return r.db.exec(ctx, stmt)
+}
+
+func (r *JetFileRepository) execReturningFiles(ctx context.Context, query string, args ...any) ([]model.Files, error) {
+	rows, err := r.db.executor(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, normalizeDBError(err)
+	}
+	rawIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, normalizeDBError(err)
+	}
+	if len(rawIDs) == 0 {
+		return []model.Files{}, nil
+	}
+
+	idExprs := make([]postgres.Expression, 0, len(rawIDs))
+	for _, raw := range rawIDs {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		idExprs = append(idExprs, postgres.UUID(id))
+	}
+
+	stmt := selectFilesForRead(table.Files).FROM(table.Files).
+		WHERE(table.Files.ID.IN(idExprs...)).
+		ORDER_BY(table.Files.TrashedPath.IS_NULL(), table.Files.ID.ASC())
+
+	var out []model.Files
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return []model.Files{}, nil
+		}
+		return nil, err
+	}
+
+	return out, nil
+}
+
 func (r *JetFileRepository) CreateDirectories(ctx context.Context, userID int64, path string) (*uuid.UUID, error) {
 	if !strings.HasPrefix(path, "/root") {
 		path = "/root/" + strings.Trim(path, "/")
@@ -841,3 +1126,42 @@ func (r *JetFileRepository) CountPartsByChannel(ctx context.Context, channelID i
 
 	return count, nil
 }
+
+// ListReferencedParts returns the subset of partIDs in a channel that are still
+// used by a file of the user, either directly or through one of its versions.
+// Files pending deletion do not count, so their messages can be deleted once
+// no other file shares them.
+func (r *JetFileRepository) ListReferencedParts(ctx context.Context, userID, channelID int64, partIDs []int) ([]int, error) {
+	if len(partIDs) == 0 {
+		return []int{}, nil
+	}
+
+	query := `
+SELECT DISTINCT (p.value->>'id')::int
+FROM (
+	SELECT f.parts
+	FROM teldrive.files f
+	WHERE f.user_id = $1 AND f.channel_id = $2 AND f.type = 'file'
+	  AND f.status IS DISTINCT FROM 'pending_deletion'
+	UNION ALL
+	SELECT v.parts
+	FROM teldrive.file_versions v
+	JOIN teldrive.files f ON f.id = v.file_id
+	WHERE v.user_id = $1 AND v.channel_id = $2
+	  AND f.status IS DISTINCT FROM 'pending_deletion'
+) refs
+CROSS JOIN LATERAL jsonb_array_elements(
+	CASE WHEN jsonb_typeof(refs.parts) = 'array' THEN refs.parts ELSE '[]'::jsonb END
+) p
+WHERE (p.value->>'id')::int = ANY($3::int[]);`
+
+	rows, err := r.db.executor(ctx).Query(ctx, query, userID, channelID, partIDs)
+	if err != nil {
+		return nil, normalizeDBError(err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, normalizeDBError(err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,218 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/database/jet/gen/table"
+)
+
+type JetFileVersionRepository struct {
+	db jetDB
+}
+
+func NewJetFileVersionRepository(pool *pgxpool.Pool) *JetFileVersionRepository {
+	return &JetFileVersionRepository{db: newJetDB(pool)}
+}
+
+// CreateFromFile stores the current content of a file as its next version.
+func (r *JetFileVersionRepository) CreateFromFile(ctx context.Context, fileID uuid.UUID, userID int64) (*model.FileVersions, error) {
+	query := `
+INSERT INTO teldrive.file_versions (file_id, user_id, version, size, parts, channel_id, encrypted, hash, updated_at)
+SELECT f.id, f.user_id,
+	COALESCE((SELECT MAX(v.version) FROM teldrive.file_versions v WHERE v.file_id = f.id), 0) + 1,
+	f.size, f.parts, f.channel_id, f.encrypted, f.hash, f.updated_at
+FROM teldrive.files f
+WHERE f.id = $1 AND f.user_id = $2 AND f.type = 'file'
+RETURNING id::text;`
+
+	var rawID string
+	if err := r.db.executor(ctx).QueryRow(ctx, query, fileID, userID).Scan(&rawID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, normalizeDBError(err)
+	}
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(ctx, id)
+}
+
+func (r *JetFileVersionRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.FileVersions, error) {
+	stmt := table.FileVersions.
+		SELECT(table.FileVersions.AllColumns).
+		FROM(table.FileVersions).
+		WHERE(table.FileVersions.ID.EQ(postgres.UUID(id)))
+
+	var out model.FileVersions
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+func (r *JetFileVersionRepository) GetByIDAndFile(ctx context.Context, id, fileID uuid.UUID, userID int64) (*model.FileVersions, error) {
+	stmt := table.FileVersions.
+		SELECT(table.FileVersions.AllColumns).
+		FROM(table.FileVersions).
+		WHERE(
+			table.FileVersions.ID.EQ(postgres.UUID(id)).
+				AND(table.FileVersions.FileID.EQ(postgres.UUID(fileID))).
+				AND(table.FileVersions.UserID.EQ(postgres.Int64(userID))),
+		)
+
+	var out model.FileVersions
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+func (r *JetFileVersionRepository) ListByFileID(ctx context.Context, fileID uuid.UUID, userID int64) ([]model.FileVersions, error) {
+	stmt := table.FileVersions.
+		SELECT(table.FileVersions.AllColumns).
+		FROM(table.FileVersions).
+		WHERE(
+			table.FileVersions.FileID.EQ(postgres.UUID(fileID)).
+				AND(table.FileVersions.UserID.EQ(postgres.Int64(userID))),
+		).
+		ORDER_BY(table.FileVersions.Version.DESC())
+
+	var out []model.FileVersions
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return []model.FileVersions{}, nil
+		}
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// ListExcess returns the versions of each file beyond the newest keep ones.
+func (r *JetFileVersionRepository) ListExcess(ctx context.Context, userID int64, keep int) ([]model.FileVersions, error) {
+	query := `
+SELECT id::text FROM (
+	SELECT v.id, row_number() OVER (PARTITION BY v.file_id ORDER BY v.version DESC) AS rank
+	FROM teldrive.file_versions v
+	WHERE v.user_id = $1
+) ranked
+WHERE ranked.rank > $2;`
+
+	rows, err := r.db.executor(ctx).Query(ctx, query, userID, keep)
+	if err != nil {
+		return nil, normalizeDBError(err)
+	}
+	rawIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, normalizeDBError(err)
+	}
+	if len(rawIDs) == 0 {
+		return []model.FileVersions{}, nil
+	}
+
+	idExprs := make([]postgres.Expression, 0, len(rawIDs))
+	for _, raw := range rawIDs {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		idExprs = append(idExprs, postgres.UUID(id))
+	}
+
+	stmt := table.FileVersions.
+		SELECT(table.FileVersions.AllColumns).
+		FROM(table.FileVersions).
+		WHERE(table.FileVersions.ID.IN(idExprs...))
+
+	var out []model.FileVersions
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return []model.FileVersions{}, nil
+		}
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// ListPendingForDeletion returns the versions of files that are about to be
+// removed by the pending files cleanup.
+func (r *JetFileVersionRepository) ListPendingForDeletion(ctx context.Context, userID int64) ([]model.FileVersions, error) {
+	stmt := table.FileVersions.
+		SELECT(table.FileVersions.AllColumns).
+		FROM(table.FileVersions.INNER_JOIN(table.Files, table.Files.ID.EQ(table.FileVersions.FileID))).
+		WHERE(
+			table.Files.UserID.EQ(postgres.Int64(userID)).
+				AND(table.Files.Status.EQ(postgres.String("pending_deletion"))),
+		)
+
+	var out []model.FileVersions
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return []model.FileVersions{}, nil
+		}
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// RestoreToFile copies the content of a version back onto its file.
+func (r *JetFileVersionRepository) RestoreToFile(ctx context.Context, id uuid.UUID, updatedAt time.Time) error {
+	stmt := table.Files.UPDATE().
+		SET(
+			table.Files.Size.SET(table.FileVersions.Size),
+			table.Files.Parts.SET(table.FileVersions.Parts),
+			table.Files.ChannelID.SET(table.FileVersions.ChannelID),
+			table.Files.Encrypted.SET(table.FileVersions.Encrypted),
+			table.Files.Hash.SET(table.FileVersions.Hash),
+			table.Files.UpdatedAt.SET(postgres.TimestampT(updatedAt)),
+		).
+		FROM(table.FileVersions).
+		WHERE(
+			table.FileVersions.ID.EQ(postgres.UUID(id)).
+				AND(table.Files.ID.EQ(table.FileVersions.FileID)),
+		)
+
+	tag, err := r.db.execTag(ctx, stmt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *JetFileVersionRepository) DeleteByIDs(ctx context.Context, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	idExprs := make([]postgres.Expression, 0, len(ids))
+	for _, id := range ids {
+		idExprs = append(idExprs, postgres.UUID(id))
+	}
+
+	stmt := table.FileVersions.DELETE().WHERE(table.FileVersions.ID.IN(idExprs...))
+	return r.db.exec(ctx, stmt)
+}
@@ -43,6 +43,12 @@ type CategoryStats struct {
 	TotalSize  int64
 }
 
+// UsageStats represents the number and total size of a set of files
+type UsageStats struct {
+	TotalFiles int64
+	TotalSize  int64
+}
+
 type UploadStat struct {
 	UploadDate    time.Time
 	TotalUploaded int64
@@ -70,6 +76,12 @@ type CheckFile struct {
 	Parts     dbtypes.Parts
 }
 
+// TrashCursor marks the last entry of a trash listing page.
+type TrashCursor struct {
+	TrashedAt time.Time
+	ID        uuid.UUID
+}
+
 type FileUpdate struct {
 	Name      *string
 	Type      *string
@@ -96,13 +108,31 @@ type ShareUpdate struct {
 	UpdatedAt *time.Time
 }
 
+type WebhookUpdate struct {
+	Name    *string
+	URL     *string
+	Events  *[]string
+	Secret  *string
+	Enabled *bool
+}
+
 type UserUpdate struct {
 	Name      *string
 	UserName  *string
 	IsPremium *bool
+	IsAdmin   *bool
+	Disabled  *bool
 	UpdatedAt *time.Time
 }
 
+// UserSummary represents a user with their storage and channel counts
+type UserSummary struct {
+	model.Users
+	TotalFiles int64
+	TotalSize  int64
+	Channels   int64
+}
+
 // FileRepository defines operations for file persistence
 type FileRepository interface {
 	Create(ctx context.Context, file *model.Files) error
@@ -111,6 +141,7 @@ type FileRepository interface {
 	GetByIDAndUser(ctx context.Context, id uuid.UUID, userID int64) (*model.Files, error)
 	GetByChannelID(ctx context.Context, channelID int64) ([]model.Files, error)
 	GetActiveByNameAndParent(ctx context.Context, userID int64, name string, parentID *uuid.UUID) (*model.Files, error)
+	GetActiveByHash(ctx context.Context, userID int64, hash string, size int64) (*model.Files, error)
 	Update(ctx context.Context, id uuid.UUID, update FileUpdate) error
 	UpdateReturning(ctx context.Context, id uuid.UUID, update FileUpdate) (*model.Files, error)
 	MoveSingle(ctx context.Context, id uuid.UUID, userID int64, parentID *uuid.UUID, name *string) error
@@ -125,11 +156,19 @@ type FileRepository interface {
 	DeletePendingForDeletionByUser(ctx context.Context, userID int64) error
 	RefreshFolderSizesByUser(ctx context.Context, userID int64) error
 	CategoryStats(ctx context.Context, userID int64) ([]CategoryStats, error)
+	StorageUsage(ctx context.Context, userID int64) (*UsageStats, error)
+	SubtreeStats(ctx context.Context, folderID uuid.UUID, userID int64) (*UsageStats, error)
 	DeleteBulk(ctx context.Context, fileIDs []uuid.UUID, userID int64, targetStatus string) error
 	DeleteBulkReturning(ctx context.Context, fileIDs []uuid.UUID, userID int64, targetStatus string) ([]model.Files, error)
+	TrashBulkReturning(ctx context.Context, fileIDs []uuid.UUID, userID int64, trashedAt time.Time) ([]model.Files, error)
+	GetTrashedByIDAndUser(ctx context.Context, id uuid.UUID, userID int64) (*model.Files, error)
+	ListTrashed(ctx context.Context, userID int64, cursor *TrashCursor, limit int) ([]model.Files, error)
+	RestoreTrashed(ctx context.Context, id uuid.UUID, userID int64, parentID uuid.UUID) ([]model.Files, error)
+	PurgeTrashed(ctx context.Context, userID int64, fileIDs []uuid.UUID, before *time.Time) error
 	CreateDirectories(ctx context.Context, userID int64, path string) (*uuid.UUID, error)
 	ListCheckFiles(ctx context.Context, userID, channelID int64, includePending bool) ([]CheckFile, error)
 	CountPartsByChannel(ctx context.Context, channelID int64) (int64, error)
+	ListReferencedParts(ctx context.Context, userID, channelID int64, partIDs []int) ([]int, error)
 }
 
 // SessionRepository defines operations for session persistence
@@ -140,6 +179,7 @@ type SessionRepository interface {
 	GetByUserID(ctx context.Context, userID int64) ([]model.Sessions, error)
 	UpdateRefreshTokenHash(ctx context.Context, id uuid.UUID, refreshTokenHash string) error
 	Revoke(ctx context.Context, id uuid.UUID) error
+	DeleteByUserID(ctx context.Context, userID int64) error
 }
 
 // APIKeyRepository defines operations for API key persistence
@@ -147,8 +187,10 @@ type APIKeyRepository interface {
 	Create(ctx context.Context, key *model.APIKeys) error
 	ListByUserID(ctx context.Context, userID int64) ([]model.APIKeys, error)
 	GetActiveByTokenHash(ctx context.Context, tokenHash string, now time.Time) (*model.APIKeys, error)
+	GetActiveByID(ctx context.Context, id uuid.UUID, now time.Time) (*model.APIKeys, error)
 	TouchLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error
 	Revoke(ctx context.Context, userID int64, id uuid.UUID) error
+	RevokeByUserID(ctx context.Context, userID int64) error
 }
 
 // UploadRepository defines operations for upload part persistence
@@ -189,7 +231,17 @@ type BotRepository interface {
 type UserRepository interface {
 	Create(ctx context.Context, user *model.Users) error
 	GetByID(ctx context.Context, userID int64) (*model.Users, error)
+	GetByUserName(ctx context.Context, userName string) (*model.Users, error)
 	Update(ctx context.Context, userID int64, update UserUpdate) error
+	SetQuota(ctx context.Context, userID int64, quotaBytes, quotaFiles *int64) error
+	ListSummaries(ctx context.Context) ([]UserSummary, error)
+}
+
+// AllowedUserRepository defines operations for the runtime login allow-list
+type AllowedUserRepository interface {
+	List(ctx context.Context) ([]string, error)
+	Add(ctx context.Context, userName string) error
+	Remove(ctx context.Context, userName string) error
 }
 
 // ShareRepository defines operations for file share persistence
@@ -201,15 +253,45 @@ type ShareRepository interface {
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
+// FileVersionRepository defines operations for file version history
+type FileVersionRepository interface {
+	CreateFromFile(ctx context.Context, fileID uuid.UUID, userID int64) (*model.FileVersions, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*model.FileVersions, error)
+	GetByIDAndFile(ctx context.Context, id, fileID uuid.UUID, userID int64) (*model.FileVersions, error)
+	ListByFileID(ctx context.Context, fileID uuid.UUID, userID int64) ([]model.FileVersions, error)
+	ListExcess(ctx context.Context, userID int64, keep int) ([]model.FileVersions, error)
+	ListPendingForDeletion(ctx context.Context, userID int64) ([]model.FileVersions, error)
+	RestoreToFile(ctx context.Context, id uuid.UUID, updatedAt time.Time) error
+	DeleteByIDs(ctx context.Context, ids []uuid.UUID) error
+}
+
 // EventRepository defines operations for event persistence
 type EventRepository interface {
 	Create(ctx context.Context, event *model.Events) error
+	GetByIDAndUserID(ctx context.Context, id uuid.UUID, userID int64) (*model.Events, error)
 	GetRecent(ctx context.Context, userID int64, since time.Time, limit int) ([]model.Events, error)
 	GetSince(ctx context.Context, since time.Time, limit int) ([]model.Events, error)
+	ListSince(ctx context.Context, since time.Time, afterSeq int64, limit int) ([]model.Events, error)
+	ListAfterSeq(ctx context.Context, userID int64, afterSeq int64, before time.Time, types []string, limit int) ([]model.Events, error)
+	LatestSeq(ctx context.Context, userID int64, before time.Time) (int64, error)
+	PrunedSeq(ctx context.Context, userID int64) (int64, error)
 	DeleteOlderThan(ctx context.Context, before time.Time) (int64, error)
 	DeleteOlderThanForUser(ctx context.Context, userID int64, before time.Time) (int64, error)
 }
 
+// WebhookRepository defines operations for outgoing webhooks and their
+// delivery log
+type WebhookRepository interface {
+	Create(ctx context.Context, hook *model.Webhooks) error
+	ListByUserID(ctx context.Context, userID int64) ([]model.Webhooks, error)
+	ListEnabledByUserID(ctx context.Context, userID int64) ([]model.Webhooks, error)
+	GetByIDAndUserID(ctx context.Context, id uuid.UUID, userID int64) (*model.Webhooks, error)
+	Update(ctx context.Context, id uuid.UUID, userID int64, update WebhookUpdate) error
+	Delete(ctx context.Context, id uuid.UUID, userID int64) error
+	CreateDelivery(ctx context.Context, delivery *model.WebhookDeliveries, keep int) error
+	ListDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]model.WebhookDeliveries, error)
+}
+
 type PeriodicJobRepository interface {
 	Create(ctx context.Context, job *PeriodicJob) error
 	ListByUserID(ctx context.Context, userID int64) ([]PeriodicJob, error)
@@ -258,9 +340,12 @@ type SyncFiltersArgs struct {
 }
 
 type SyncOptionsArgs struct {
-	PartSize  *int64 `json:"partSize,omitempty"`
-	Encrypted *bool  `json:"encrypted,omitempty"`
-	Sync      *bool  `json:"sync,omitempty"`
+	PartSize  *int64  `json:"partSize,omitempty"`
+	Encrypted *bool   `json:"encrypted,omitempty"`
+	Sync      *bool   `json:"sync,omitempty"`
+	Conflict  *string `json:"conflict,omitempty"`
+	Deletion  *string `json:"deletion,omitempty"`
+	BackupDir *string `json:"backupDir,omitempty"`
 }
 
 type CleanOldEventsPeriodicArgs struct {
@@ -283,6 +368,12 @@ type RefreshFolderSizesPeriodicArgs struct{}
 
 func (RefreshFolderSizesPeriodicArgs) periodicJobArgs() {}
 
+type CleanFileVersionsPeriodicArgs struct {
+	Keep int `json:"keep"`
+}
+
+func (CleanFileVersionsPeriodicArgs) periodicJobArgs() {}
+
 // KVRepository defines operations for key-value storage
 type KVRepository interface {
 	Set(ctx context.Context, item *model.Kv) error
@@ -302,8 +393,11 @@ type Repositories struct {
 	Channels     ChannelRepository
 	Bots         BotRepository
 	Users        UserRepository
+	AllowedUsers AllowedUserRepository
 	Shares       ShareRepository
+	FileVersions FileVersionRepository
 	Events       EventRepository
 	PeriodicJobs PeriodicJobRepository
+	Webhooks     WebhookRepository
 	KV           KVRepository
 }
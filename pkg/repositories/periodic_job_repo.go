@@ -294,6 +294,12 @@ func encodePeriodicJobArgs(kind string, args PeriodicJobArgs) (string, error) {
 				return "", fmt.Errorf("invalid args type for kind %s", kind)
 			}
 		}
+	case "clean.file_versions":
+		if _, ok := args.(CleanFileVersionsPeriodicArgs); !ok {
+			if _, ok := args.(*CleanFileVersionsPeriodicArgs); !ok {
+				return "", fmt.Errorf("invalid args type for kind %s", kind)
+			}
+		}
 	default:
 		return "", fmt.Errorf("unsupported periodic job kind: %s", kind)
 	}
@@ -341,6 +347,12 @@ func decodePeriodicJobArgs(kind string, raw json.RawMessage) (PeriodicJobArgs, e
 			return nil, err
 		}
 		return out, nil
+	case "clean.file_versions":
+		var out CleanFileVersionsPeriodicArgs
+		if err := json.Unmarshal(raw, &out); err != nil {
+			return nil, err
+		}
+		return out, nil
 	default:
 		return nil, fmt.Errorf("unsupported periodic job kind: %s", kind)
 	}
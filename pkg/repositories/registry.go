@@ -13,9 +13,12 @@ func NewRepositories(pool *pgxpool.Pool) *Repositories {
 		Channels:     NewJetChannelRepository(pool),
 		Bots:         NewJetBotRepository(pool),
 		Users:        NewJetUserRepository(pool),
+		AllowedUsers: NewJetAllowedUserRepository(pool),
 		Shares:       NewJetShareRepository(pool),
+		FileVersions: NewJetFileVersionRepository(pool),
 		Events:       NewJetEventRepository(pool),
 		PeriodicJobs: NewJetPeriodicJobRepository(pool),
+		Webhooks:     NewJetWebhookRepository(pool),
 		KV:           NewJetKVRepository(pool),
 	}
 }
@@ -57,8 +57,23 @@ func (r *JetUserRepository) GetByID(ctx context.Context, userID int64) (*model.U
 	return &out, nil
 }
 
+func (r *JetUserRepository) GetByUserName(ctx context.Context, userName string) (*model.Users, error) {
+	stmt := table.Users.SELECT(table.Users.AllColumns).FROM(table.Users).WHERE(table.Users.UserName.EQ(postgres.String(userName)))
+
+	var out model.Users
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return &out, nil
+}
+
 func (r *JetUserRepository) Update(ctx context.Context, userID int64, update UserUpdate) error {
-	updates := make([]postgres.ColumnAssigment, 0, 4)
+	updates := make([]postgres.ColumnAssigment, 0, 6)
 
 	if update.Name != nil {
 		updates = append(updates, table.Users.Name.SET(postgres.String(*update.Name)))
@@ -69,6 +84,16 @@ func (r *JetUserRepository) Update(ctx context.Context, userID int64, update Use
 	if update.IsPremium != nil {
 		updates = append(updates, table.Users.IsPremium.SET(postgres.Bool(*update.IsPremium)))
 	}
+	if update.IsAdmin != nil {
+		updates = append(updates, table.Users.IsAdmin.SET(postgres.Bool(*update.IsAdmin)))
+	}
+	if update.Disabled != nil {
+		disabledAt := postgres.TimestampzExp(postgres.NULL)
+		if *update.Disabled {
+			disabledAt = postgres.TimestampzT(time.Now().UTC())
+		}
+		updates = append(updates, table.Users.DisabledAt.SET(disabledAt))
+	}
 
 	updates = append(updates, table.Users.UpdatedAt.SET(postgres.TimestampzT(time.Now().UTC())))
 
@@ -80,6 +105,85 @@ func (r *JetUserRepository) Update(ctx context.Context, userID int64, update Use
 	return err
 }
 
+// SetQuota replaces the storage limits of a user. A nil limit removes it.
+func (r *JetUserRepository) SetQuota(ctx context.Context, userID int64, quotaBytes, quotaFiles *int64) error {
+	limit := func(v *int64) postgres.IntegerExpression {
+		if v == nil {
+			return postgres.IntExp(postgres.NULL)
+		}
+		return postgres.Int64(*v)
+	}
+
+	stmt := table.Users.UPDATE().
+		SET(
+			table.Users.QuotaBytes.SET(limit(quotaBytes)),
+			table.Users.QuotaFiles.SET(limit(quotaFiles)),
+			table.Users.UpdatedAt.SET(postgres.TimestampzT(time.Now().UTC())),
+		).
+		WHERE(table.Users.UserID.EQ(postgres.Int64(userID)))
+
+	tag, err := r.db.execTag(ctx, stmt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListSummaries returns every user with the count and size of the files they
+// keep, counted as StorageUsage does, and the number of channels they own.
+func (r *JetUserRepository) ListSummaries(ctx context.Context) ([]UserSummary, error) {
+	query := `
+SELECT u.user_id, u.name, u.user_name, u.is_premium, u.created_at, u.updated_at,
+	u.quota_bytes, u.quota_files, u.is_admin, u.disabled_at,
+	COALESCE(f.total_files, 0), COALESCE(f.total_size, 0) + COALESCE(v.total_size, 0), COALESCE(c.channels, 0)
+FROM teldrive.users u
+LEFT JOIN (
+	SELECT user_id, COUNT(*) AS total_files, COALESCE(SUM(size), 0)::bigint AS total_size
+	FROM teldrive.files
+	WHERE type = 'file' AND status IN ('active', 'trashed')
+	GROUP BY user_id
+) f ON f.user_id = u.user_id
+LEFT JOIN (
+	SELECT v.user_id, COALESCE(SUM(v.size), 0)::bigint AS total_size
+	FROM teldrive.file_versions v
+	JOIN teldrive.files vf ON vf.id = v.file_id
+	WHERE vf.status IN ('active', 'trashed')
+	GROUP BY v.user_id
+) v ON v.user_id = u.user_id
+LEFT JOIN (
+	SELECT user_id, COUNT(*) AS channels
+	FROM teldrive.channels
+	GROUP BY user_id
+) c ON c.user_id = u.user_id
+ORDER BY u.user_id`
+
+	rows, err := r.db.executor(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, normalizeDBError(err)
+	}
+	defer rows.Close()
+
+	var out []UserSummary
+	for rows.Next() {
+		var item UserSummary
+		if err := rows.Scan(
+			&item.UserID, &item.Name, &item.UserName, &item.IsPremium, &item.CreatedAt, &item.UpdatedAt,
+			&item.QuotaBytes, &item.QuotaFiles, &item.IsAdmin, &item.DisabledAt,
+			&item.TotalFiles, &item.TotalSize, &item.Channels,
+		); err != nil {
+			return nil, normalizeDBError(err)
+		}
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, normalizeDBError(err)
+	}
+	return out, nil
+}
+
 func (r *JetUserRepository) Exists(ctx context.Context, userID int64) (bool, error) {
 	stmt := postgres.SELECT(postgres.COUNT(table.Users.UserID)).FROM(table.Users).WHERE(table.Users.UserID.EQ(postgres.Int64(userID)))
 
@@ -0,0 +1,196 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/database/jet/gen/table"
+)
+
+type JetWebhookRepository struct {
+	db jetDB
+}
+
+func NewJetWebhookRepository(pool *pgxpool.Pool) *JetWebhookRepository {
+	return &JetWebhookRepository{db: newJetDB(pool)}
+}
+
+func (r *JetWebhookRepository) Create(ctx context.Context, hook *model.Webhooks) error {
+	now := time.Now().UTC()
+	if hook.ID == uuid.Nil {
+		hook.ID = uuid.New()
+	}
+	if hook.CreatedAt.IsZero() {
+		hook.CreatedAt = now
+	}
+	if hook.UpdatedAt.IsZero() {
+		hook.UpdatedAt = now
+	}
+
+	stmt := table.Webhooks.INSERT(table.Webhooks.AllColumns).MODEL(*hook)
+	return r.db.exec(ctx, stmt)
+}
+
+func (r *JetWebhookRepository) ListByUserID(ctx context.Context, userID int64) ([]model.Webhooks, error) {
+	stmt := table.Webhooks.
+		SELECT(table.Webhooks.AllColumns).
+		FROM(table.Webhooks).
+		WHERE(table.Webhooks.UserID.EQ(postgres.Int64(userID))).
+		ORDER_BY(table.Webhooks.CreatedAt.ASC())
+
+	var out []model.Webhooks
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *JetWebhookRepository) ListEnabledByUserID(ctx context.Context, userID int64) ([]model.Webhooks, error) {
+	stmt := table.Webhooks.
+		SELECT(table.Webhooks.AllColumns).
+		FROM(table.Webhooks).
+		WHERE(
+			table.Webhooks.UserID.EQ(postgres.Int64(userID)).
+				AND(table.Webhooks.Enabled.IS_TRUE()),
+		).
+		ORDER_BY(table.Webhooks.CreatedAt.ASC())
+
+	var out []model.Webhooks
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *JetWebhookRepository) GetByIDAndUserID(ctx context.Context, id uuid.UUID, userID int64) (*model.Webhooks, error) {
+	stmt := table.Webhooks.
+		SELECT(table.Webhooks.AllColumns).
+		FROM(table.Webhooks).
+		WHERE(
+			table.Webhooks.ID.EQ(postgres.UUID(id)).
+				AND(table.Webhooks.UserID.EQ(postgres.Int64(userID))),
+		)
+
+	var out model.Webhooks
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (r *JetWebhookRepository) Update(ctx context.Context, id uuid.UUID, userID int64, update WebhookUpdate) error {
+	updates := make([]postgres.ColumnAssigment, 0, 6)
+	if update.Name != nil {
+		updates = append(updates, table.Webhooks.Name.SET(postgres.String(*update.Name)))
+	}
+	if update.URL != nil {
+		updates = append(updates, table.Webhooks.URL.SET(postgres.String(*update.URL)))
+	}
+	if update.Events != nil {
+		events, err := json.Marshal(*update.Events)
+		if err != nil {
+			return err
+		}
+		updates = append(updates, table.Webhooks.Events.SET(postgres.StringExp(postgres.CAST(postgres.String(string(events))).AS("jsonb"))))
+	}
+	if update.Secret != nil {
+		updates = append(updates, table.Webhooks.Secret.SET(postgres.String(*update.Secret)))
+	}
+	if update.Enabled != nil {
+		updates = append(updates, table.Webhooks.Enabled.SET(postgres.Bool(*update.Enabled)))
+	}
+	updates = append(updates, table.Webhooks.UpdatedAt.SET(postgres.TimestampT(time.Now().UTC())))
+
+	stmt := table.Webhooks.UPDATE().
+		WHERE(
+			table.Webhooks.ID.EQ(postgres.UUID(id)).
+				AND(table.Webhooks.UserID.EQ(postgres.Int64(userID))),
+		)
+	stmt = stmt.SET(updates[0], assignmentArgs(updates[1:])...)
+
+	tag, err := r.db.execTag(ctx, stmt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *JetWebhookRepository) Delete(ctx context.Context, id uuid.UUID, userID int64) error {
+	stmt := table.Webhooks.DELETE().
+		WHERE(
+			table.Webhooks.ID.EQ(postgres.UUID(id)).
+				AND(table.Webhooks.UserID.EQ(postgres.Int64(userID))),
+		)
+
+	tag, err := r.db.execTag(ctx, stmt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateDelivery logs one delivery attempt and drops the oldest entries of
+// the webhook beyond keep.
+func (r *JetWebhookRepository) CreateDelivery(ctx context.Context, delivery *model.WebhookDeliveries, keep int) error {
+	if delivery.ID == uuid.Nil {
+		delivery.ID = uuid.New()
+	}
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now().UTC()
+	}
+
+	stmt := table.WebhookDeliveries.INSERT(table.WebhookDeliveries.AllColumns).MODEL(*delivery)
+	if err := r.db.exec(ctx, stmt); err != nil {
+		return err
+	}
+	if keep <= 0 {
+		return nil
+	}
+
+	latest := table.WebhookDeliveries.
+		SELECT(table.WebhookDeliveries.ID).
+		FROM(table.WebhookDeliveries).
+		WHERE(table.WebhookDeliveries.WebhookID.EQ(postgres.UUID(delivery.WebhookID))).
+		ORDER_BY(table.WebhookDeliveries.CreatedAt.DESC()).
+		LIMIT(int64(keep))
+	trim := table.WebhookDeliveries.DELETE().
+		WHERE(
+			table.WebhookDeliveries.WebhookID.EQ(postgres.UUID(delivery.WebhookID)).
+				AND(table.WebhookDeliveries.ID.NOT_IN(latest)),
+		)
+	return r.db.exec(ctx, trim)
+}
+
+func (r *JetWebhookRepository) ListDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]model.WebhookDeliveries, error) {
+	stmt := table.WebhookDeliveries.
+		SELECT(table.WebhookDeliveries.AllColumns).
+		FROM(table.WebhookDeliveries).
+		WHERE(table.WebhookDeliveries.WebhookID.EQ(postgres.UUID(webhookID))).
+		ORDER_BY(table.WebhookDeliveries.CreatedAt.DESC())
+	if limit > 0 {
+		stmt = stmt.LIMIT(int64(limit))
+	}
+
+	var out []model.WebhookDeliveries
+	if err := r.db.query(ctx, stmt, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
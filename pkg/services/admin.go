@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/internal/auth"
+	"github.com/tgdrive/teldrive/internal/cache"
+	"github.com/tgdrive/teldrive/pkg/repositories"
+)
+
+// requireAdmin returns a 403 error unless the caller has the admin role or is
+// listed in jwt.admin-users.
+func (a *apiService) requireAdmin(ctx context.Context) error {
+	user, err := a.repo.Users.GetByID(ctx, auth.User(ctx))
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return &apiError{err: errors.New("admin access required"), code: 403}
+		}
+		return &apiError{err: err}
+	}
+	if !user.IsAdmin && !slices.Contains(a.cnf.JWT.AdminUsers, user.UserName) {
+		return &apiError{err: errors.New("admin access required"), code: 403}
+	}
+	return nil
+}
+
+func (a *apiService) AdminSetUserQuota(ctx context.Context, req *api.UserQuota, params api.AdminSetUserQuotaParams) error {
+	if err := a.requireAdmin(ctx); err != nil {
+		return err
+	}
+
+	var quotaBytes, quotaFiles *int64
+	if req.MaxBytes.IsSet() {
+		quotaBytes = &req.MaxBytes.Value
+	}
+	if req.MaxFiles.IsSet() {
+		quotaFiles = &req.MaxFiles.Value
+	}
+	if err := a.repo.Users.SetQuota(ctx, params.ID, quotaBytes, quotaFiles); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return &apiError{err: errors.New("user not found"), code: 404}
+		}
+		return &apiError{err: err}
+	}
+	return nil
+}
+
+func (a *apiService) AdminListUsers(ctx context.Context) ([]api.AdminUser, error) {
+	if err := a.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	summaries, err := a.repo.Users.ListSummaries(ctx)
+	if err != nil {
+		return nil, &apiError{err: err}
+	}
+	users := make([]api.AdminUser, 0, len(summaries))
+	for _, summary := range summaries {
+		user := api.AdminUser{
+			UserId:    summary.UserID,
+			UserName:  summary.UserName,
+			IsAdmin:   summary.IsAdmin || slices.Contains(a.cnf.JWT.AdminUsers, summary.UserName),
+			Disabled:  summary.DisabledAt != nil,
+			CreatedAt: summary.CreatedAt,
+			Usage:     api.StorageUsage{TotalFiles: summary.TotalFiles, TotalSize: summary.TotalSize},
+			Quota:     toUserQuota(&summary.Users),
+			Channels:  summary.Channels,
+		}
+		if summary.Name != nil {
+			user.Name = api.NewOptString(*summary.Name)
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (a *apiService) AdminUpdateUser(ctx context.Context, req *api.AdminUserUpdate, params api.AdminUpdateUserParams) error {
+	if err := a.requireAdmin(ctx); err != nil {
+		return err
+	}
+	if req.Disabled.Value && params.ID == auth.User(ctx) {
+		return &apiError{err: errors.New("cannot disable your own account"), code: 400}
+	}
+	if _, err := a.repo.Users.GetByID(ctx, params.ID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return &apiError{err: errors.New("user not found"), code: 404}
+		}
+		return &apiError{err: err}
+	}
+
+	update := repositories.UserUpdate{}
+	if req.IsAdmin.IsSet() {
+		update.IsAdmin = &req.IsAdmin.Value
+	}
+	if req.Disabled.IsSet() {
+		update.Disabled = &req.Disabled.Value
+	}
+	if update.IsAdmin == nil && update.Disabled == nil {
+		return nil
+	}
+	if err := a.repo.Users.Update(ctx, params.ID, update); err != nil {
+		return &apiError{err: err}
+	}
+	if req.Disabled.Value {
+		return a.revokeUserAccess(ctx, params.ID)
+	}
+	return nil
+}
+
+func (a *apiService) AdminRevokeUser(ctx context.Context, params api.AdminRevokeUserParams) error {
+	if err := a.requireAdmin(ctx); err != nil {
+		return err
+	}
+	if _, err := a.repo.Users.GetByID(ctx, params.ID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return &apiError{err: errors.New("user not found"), code: 404}
+		}
+		return &apiError{err: err}
+	}
+	return a.revokeUserAccess(ctx, params.ID)
+}
+
+// revokeUserAccess revokes every session and API key of a user and drops the
+// cached auth entries so existing tokens stop working right away.
+func (a *apiService) revokeUserAccess(ctx context.Context, userID int64) error {
+	sessions, err := a.repo.Sessions.GetByUserID(ctx, userID)
+	if err != nil {
+		return &apiError{err: err}
+	}
+	if err := a.repo.Sessions.DeleteByUserID(ctx, userID); err != nil {
+		return &apiError{err: err}
+	}
+	if err := a.repo.APIKeys.RevokeByUserID(ctx, userID); err != nil {
+		return &apiError{err: err}
+	}
+
+	keys := make([]string, 0, len(sessions)+1)
+	for _, session := range sessions {
+		keys = append(keys, cache.KeySessionID(session.ID.String()))
+	}
+	keys = append(keys, cache.KeyUserSessions(userID))
+	a.cache.Delete(ctx, keys...)
+	_ = a.cache.DeletePattern(ctx, cache.KeyAPIKeyAuthPattern())
+	return nil
+}
+
+func (a *apiService) AdminListAllowedUsers(ctx context.Context) ([]api.AllowedUser, error) {
+	if err := a.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	runtime, err := a.repo.AllowedUsers.List(ctx)
+	if err != nil {
+		return nil, &apiError{err: err}
+	}
+	users := make([]api.AllowedUser, 0, len(a.cnf.JWT.AllowedUsers)+len(runtime))
+	for _, userName := range a.cnf.JWT.AllowedUsers {
+		users = append(users, api.AllowedUser{UserName: userName, Source: api.AllowedUserSourceConfig})
+	}
+	for _, userName := range runtime {
+		users = append(users, api.AllowedUser{UserName: userName, Source: api.AllowedUserSourceRuntime})
+	}
+	return users, nil
+}
+
+func (a *apiService) AdminAddAllowedUser(ctx context.Context, req *api.AllowedUserCreate) error {
+	if err := a.requireAdmin(ctx); err != nil {
+		return err
+	}
+
+	userName := strings.TrimSpace(req.UserName)
+	if userName == "" {
+		return &apiError{err: errors.New("userName is required"), code: 400}
+	}
+	if err := a.repo.AllowedUsers.Add(ctx, userName); err != nil {
+		return &apiError{err: err}
+	}
+	return nil
+}
+
+func (a *apiService) AdminRemoveAllowedUser(ctx context.Context, params api.AdminRemoveAllowedUserParams) error {
+	if err := a.requireAdmin(ctx); err != nil {
+		return err
+	}
+
+	if err := a.repo.AllowedUsers.Remove(ctx, params.UserName); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return &apiError{err: errors.New("allowed user not found"), code: 404}
+		}
+		return &apiError{err: err}
+	}
+
+	// Sign the user out if the entry was the only thing letting them in
+	user, err := a.repo.Users.GetByUserName(ctx, params.UserName)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil
+		}
+		return &apiError{err: err}
+	}
+	allowed, err := a.allowListed(ctx, user, user.UserName)
+	if err != nil {
+		return &apiError{err: err}
+	}
+	if !allowed {
+		return a.revokeUserAccess(ctx, user.UserID)
+	}
+	return nil
+}
+
+func (a *apiService) AdminStreamCacheStats(ctx context.Context) (*api.StreamCacheStats, error) {
+	if err := a.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if a.chunkCache == nil {
+		return &api.StreamCacheStats{}, nil
+	}
+	stats := a.chunkCache.Stats()
+	return &api.StreamCacheStats{
+		Enabled:   true,
+		Entries:   int64(stats.Entries),
+		Size:      stats.Size,
+		MaxSize:   stats.MaxSize,
+		Hits:      stats.Hits,
+		Misses:    stats.Misses,
+		Evictions: stats.Evictions,
+		Corrupted: stats.Corrupted,
+	}, nil
+}
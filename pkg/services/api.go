@@ -3,6 +3,8 @@ package services
 import (
 	"context"
 	"net/http"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/go-faster/errors"
@@ -18,6 +20,7 @@ import (
 	"github.com/tgdrive/teldrive/internal/config"
 	"github.com/tgdrive/teldrive/internal/events"
 	"github.com/tgdrive/teldrive/internal/logging"
+	"github.com/tgdrive/teldrive/internal/reader"
 	"github.com/tgdrive/teldrive/internal/utils"
 	"github.com/tgdrive/teldrive/internal/version"
 	"github.com/tgdrive/teldrive/pkg/mapper"
@@ -34,6 +37,9 @@ type apiService struct {
 	repo           *repositories.Repositories
 	jobs           jobClient
 	periodicJobs   periodicJobRegistry
+	chunkCache     *reader.ChunkCache
+	streamBots     streamBots
+	webhookClients webhookClients
 }
 
 type periodicJobRegistry interface {
@@ -67,6 +73,9 @@ func (a *apiService) NewError(ctx context.Context, err error) *api.ErrorStatusCo
 	case errors.Is(err, ht.ErrNotImplemented):
 		code = http.StatusNotImplemented
 		message = http.StatusText(code)
+	case errors.Is(err, auth.ErrAuthAPIKeyScope):
+		code = http.StatusForbidden
+		message = "api key scope does not allow this operation"
 	case errors.As(err, &ogenErr):
 		code = ogenErr.Code()
 		message = ogenErr.Error()
@@ -93,7 +102,7 @@ func NewApiService(repo *repositories.Repositories,
 	jobs jobClient,
 	periodicJobs periodicJobRegistry) *apiService {
 
-	return &apiService{
+	a := &apiService{
 		repo:           repo,
 		cnf:            cnf,
 		cache:          cache,
@@ -103,7 +112,34 @@ func NewApiService(repo *repositories.Repositories,
 		telegram:       telegram,
 		jobs:           jobs,
 		periodicJobs:   periodicJobs,
+		chunkCache:     newChunkCache(cnf),
 	}
+	if events != nil {
+		events.AddRecordHook(a.dispatchWebhooks)
+	}
+	return a
+}
+
+// newChunkCache opens the on-disk stream chunk cache when it is enabled. A
+// cache that cannot be opened is logged and streaming goes on without it.
+func newChunkCache(cnf *config.ServerCmdConfig) *reader.ChunkCache {
+	if cnf == nil || !cnf.TG.Stream.Cache.Enabled {
+		return nil
+	}
+	dir := cnf.TG.Stream.Cache.Dir
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = os.TempDir()
+		}
+		dir = filepath.Join(home, ".teldrive", "chunks")
+	}
+	c, err := reader.NewChunkCache(dir, cnf.TG.Stream.Cache.MaxSize)
+	if err != nil {
+		logging.Component("FILE").Error("chunk_cache.open_failed", zap.String("dir", dir), zap.Error(err))
+		return nil
+	}
+	return c
 }
 
 func (a *apiService) syncRunMaxAttempts() int {
@@ -120,6 +156,38 @@ func (a *apiService) syncTransferMaxAttempts() int {
 	return a.cnf.Jobs.SyncTransfer.MaxAttempts
 }
 
+func (a *apiService) syncTransferParallelParts() int {
+	if a == nil || a.cnf == nil || a.cnf.Jobs.SyncTransfer.ParallelParts <= 0 {
+		return 4
+	}
+	return a.cnf.Jobs.SyncTransfer.ParallelParts
+}
+
+func (a *apiService) syncPushMaxAttempts() int {
+	if a == nil || a.cnf == nil || a.cnf.Jobs.SyncPush.MaxAttempts <= 0 {
+		return 3
+	}
+	return a.cnf.Jobs.SyncPush.MaxAttempts
+}
+
+func (a *apiService) webhookMaxAttempts() int {
+	if a == nil || a.cnf == nil || a.cnf.Jobs.WebhookDeliver.MaxAttempts <= 0 {
+		return 8
+	}
+	return a.cnf.Jobs.WebhookDeliver.MaxAttempts
+}
+
+func (a *apiService) webhookAllowPrivate() bool {
+	return a != nil && a.cnf != nil && a.cnf.Jobs.WebhookDeliver.AllowPrivate
+}
+
+func (a *apiService) webhookTimeout() time.Duration {
+	if a == nil || a.cnf == nil || a.cnf.Jobs.WebhookDeliver.Timeout <= 0 {
+		return 30 * time.Second
+	}
+	return a.cnf.Jobs.WebhookDeliver.Timeout
+}
+
 type apiError struct {
 	err  error
 	code int
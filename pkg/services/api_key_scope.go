@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	pathpkg "path"
+
+	"github.com/google/uuid"
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/internal/auth"
+	"github.com/tgdrive/teldrive/pkg/repositories"
+)
+
+var errOutsideKeyFolder = errors.New("file is outside the folder of this api key")
+
+// checkKeyFolder returns a 403 error when the request uses an API key limited
+// to a folder and one of ids lies outside that folder.
+func (a *apiService) checkKeyFolder(ctx context.Context, ids ...uuid.UUID) error {
+	claims := auth.JWTUser(ctx)
+	if claims == nil || claims.FolderID == nil {
+		return nil
+	}
+	userID := auth.User(ctx)
+	for _, id := range ids {
+		inside, err := a.folderContains(ctx, userID, *claims.FolderID, id)
+		if err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				return &apiError{err: errors.New("file not found"), code: http.StatusNotFound}
+			}
+			return &apiError{err: err}
+		}
+		if !inside {
+			return &apiError{err: errOutsideKeyFolder, code: http.StatusForbidden}
+		}
+	}
+	return nil
+}
+
+// checkKeyFolderPath is checkKeyFolder for a path that may not exist yet. The
+// nearest existing folder on the path must lie inside the key's folder.
+func (a *apiService) checkKeyFolderPath(ctx context.Context, path string) error {
+	claims := auth.JWTUser(ctx)
+	if claims == nil || claims.FolderID == nil {
+		return nil
+	}
+	userID := auth.User(ctx)
+	path = pathpkg.Clean("/" + path)
+	for {
+		id, err := a.repo.Files.ResolvePathID(ctx, path, userID)
+		if err == nil {
+			return a.checkKeyFolder(ctx, *id)
+		}
+		if !errors.Is(err, repositories.ErrNotFound) {
+			return &apiError{err: err}
+		}
+		if path == "/" {
+			return &apiError{err: errOutsideKeyFolder, code: http.StatusForbidden}
+		}
+		path = pathpkg.Dir(path)
+	}
+}
+
+// checkKeyFolderDestination checks a destination given either as a folder ID
+// or as a path.
+func (a *apiService) checkKeyFolderDestination(ctx context.Context, destination string) error {
+	if isUUID(destination) {
+		return a.checkKeyFolder(ctx, uuid.MustParse(destination))
+	}
+	return a.checkKeyFolderPath(ctx, destination)
+}
+
+// checkKeyFolderList limits listing and search to the key's folder. The
+// parent must be given explicitly, so a search cannot fall back to the whole
+// drive.
+func (a *apiService) checkKeyFolderList(ctx context.Context, params api.FilesListParams) error {
+	claims := auth.JWTUser(ctx)
+	if claims == nil || claims.FolderID == nil {
+		return nil
+	}
+	if params.ParentId.IsSet() {
+		return a.checkKeyFolder(ctx, uuid.UUID(params.ParentId.Value))
+	}
+	if params.Path.Value == "" {
+		return &apiError{err: errors.New("path or parentId is required for this api key"), code: http.StatusForbidden}
+	}
+	id, err := a.repo.Files.ResolvePathID(ctx, params.Path.Value, auth.User(ctx))
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return &apiError{err: errors.New("path not found"), code: http.StatusNotFound}
+		}
+		return &apiError{err: err}
+	}
+	return a.checkKeyFolder(ctx, *id)
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	pathpkg "path"
+
+	"github.com/google/uuid"
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/internal/auth"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/logging"
+	"github.com/tgdrive/teldrive/pkg/repositories"
+	"go.uber.org/zap"
+)
+
+// archiveWriter adds folders and files to a ZIP or TAR stream.
+type archiveWriter interface {
+	addFolder(name string, file *jetmodel.Files) error
+	addFile(name string, file *jetmodel.Files) (io.Writer, error)
+	Close() error
+}
+
+type zipArchive struct{ *zip.Writer }
+
+func (z zipArchive) addFolder(name string, file *jetmodel.Files) error {
+	_, err := z.CreateHeader(&zip.FileHeader{Name: name + "/", Modified: file.UpdatedAt})
+	return err
+}
+
+// addFile stores files without compression. Most large files are media that
+// does not compress, and storing keeps the stream fast.
+func (z zipArchive) addFile(name string, file *jetmodel.Files) (io.Writer, error) {
+	return z.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: file.UpdatedAt})
+}
+
+type tarArchive struct{ *tar.Writer }
+
+func (t tarArchive) addFolder(name string, file *jetmodel.Files) error {
+	return t.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: 0o755, ModTime: file.UpdatedAt})
+}
+
+func (t tarArchive) addFile(name string, file *jetmodel.Files) (io.Writer, error) {
+	var size int64
+	if file.Size != nil {
+		size = *file.Size
+	}
+	err := t.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: size, ModTime: file.UpdatedAt})
+	return t.Writer, err
+}
+
+func (s *rawService) FilesArchive(ctx context.Context, params api.FilesArchiveParams, w http.ResponseWriter) error {
+	user := auth.JWTUser(ctx)
+	userID := auth.User(ctx)
+	folderID := uuid.UUID(params.ID)
+
+	if err := s.api.checkKeyFolder(ctx, folderID); err != nil {
+		return err
+	}
+	folder, err := s.api.repo.Files.GetByIDAndUser(ctx, folderID, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return &apiError{err: errors.New("folder not found"), code: http.StatusNotFound}
+		}
+		return &apiError{err: err}
+	}
+	session := &jetmodel.Sessions{UserID: userID, TgSession: user.TgSession}
+	return s.serveArchive(ctx, w, folder, session, string(params.Format.Or(api.FilesArchiveFormatZip)))
+}
+
+func (s *rawService) SharesArchive(ctx context.Context, params api.SharesArchiveParams, w http.ResponseWriter) error {
+	share, err := s.api.validFileShare(ctx, uuid.UUID(params.ID), params.ShareToken.Or(""))
+	if err != nil {
+		return err
+	}
+	folderID, err := uuid.Parse(share.FileID)
+	if err != nil {
+		return &apiError{err: err, code: http.StatusBadRequest}
+	}
+	folder, err := s.api.repo.Files.GetByIDAndUser(ctx, folderID, share.UserID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return &apiError{err: errors.New("folder not found"), code: http.StatusNotFound}
+		}
+		return &apiError{err: err}
+	}
+	session := &jetmodel.Sessions{UserID: share.UserID}
+	return s.serveArchive(ctx, w, folder, session, string(params.Format.Or(api.SharesArchiveFormatZip)))
+}
+
+// serveArchive streams folder and its subtree as a ZIP or TAR archive. Files
+// are read from Telegram one after another and never buffered to disk. Once
+// the headers are sent, errors can only be logged and the archive ends early.
+func (s *rawService) serveArchive(ctx context.Context, w http.ResponseWriter, folder *jetmodel.Files, session *jetmodel.Sessions, format string) error {
+	if folder.Type != string(api.FileTypeFolder) {
+		return &apiError{err: errors.New("only folders can be archived"), code: http.StatusBadRequest}
+	}
+	logger := logging.Component("FILE").With(zap.String("file_id", folder.ID.String()), zap.Int64("user_id", session.UserID))
+
+	children, err := s.api.listFolder(ctx, session.UserID, folder.ID)
+	if err != nil {
+		return &apiError{err: err}
+	}
+	client, token, botID, err := s.api.streamClient(ctx, session, logger)
+	if err != nil {
+		return err
+	}
+
+	contentType := "application/zip"
+	if format == "tar" {
+		contentType = "application/x-tar"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": folder.Name + "." + format}))
+	w.WriteHeader(http.StatusOK)
+
+	var archive archiveWriter = zipArchive{zip.NewWriter(w)}
+	if format == "tar" {
+		archive = tarArchive{tar.NewWriter(w)}
+	}
+
+	var writeFolder func(ctx context.Context, dir string, children []jetmodel.Files) error
+	writeFolder = func(ctx context.Context, dir string, children []jetmodel.Files) error {
+		for i := range children {
+			child := &children[i]
+			name := pathpkg.Join(dir, child.Name)
+			if child.Type == string(api.FileTypeFolder) {
+				if err := archive.addFolder(name, child); err != nil {
+					return err
+				}
+				grandChildren, err := s.api.listFolder(ctx, session.UserID, child.ID)
+				if err != nil {
+					return err
+				}
+				if err := writeFolder(ctx, name, grandChildren); err != nil {
+					return err
+				}
+				continue
+			}
+			dst, err := archive.addFile(name, child)
+			if err != nil {
+				return err
+			}
+			if child.Size == nil || *child.Size == 0 {
+				continue
+			}
+			file, err := s.api.fileWithParts(ctx, child.ID, session.UserID)
+			if err != nil {
+				return err
+			}
+			lr, err := s.api.openFileReader(ctx, client, botID, file, 0, *child.Size-1)
+			if err != nil {
+				return err
+			}
+			_, err = io.CopyN(dst, lr, *child.Size)
+			_ = lr.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err = s.api.telegram.RunWithAuth(ctx, client, token, func(ctx context.Context) error {
+		if err := writeFolder(ctx, folder.Name, children); err != nil {
+			return err
+		}
+		return archive.Close()
+	})
+	if err != nil {
+		logger.Warn("archive.stream_failed", zap.String("format", format), zap.Error(err))
+	}
+	return nil
+}
@@ -20,10 +20,12 @@ import (
 	"github.com/tgdrive/teldrive/internal/auth"
 	"github.com/tgdrive/teldrive/internal/cache"
 	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/events"
 	"github.com/tgdrive/teldrive/internal/logging"
 	"github.com/tgdrive/teldrive/internal/requestmeta"
 	"github.com/tgdrive/teldrive/internal/utils"
 	"github.com/tgdrive/teldrive/pkg/constants"
+	"github.com/tgdrive/teldrive/pkg/dto"
 	"github.com/tgdrive/teldrive/pkg/repositories"
 	"github.com/tgdrive/teldrive/pkg/types"
 	"go.uber.org/zap"
@@ -38,8 +40,8 @@ const (
 
 func (a *apiService) AuthLogin(ctx context.Context, session *api.AuthAttemptSession) (*api.AuthLoginNoContent, error) {
 
-	if !checkUserIsAllowed(a.cnf.JWT.AllowedUsers, session.UserName) {
-		return nil, &apiError{code: http.StatusForbidden, err: errors.New("user not allowed")}
+	if err := a.checkLoginAllowed(ctx, session.UserId, session.UserName); err != nil {
+		return nil, err
 	}
 
 	now := time.Now().UTC()
@@ -151,6 +153,11 @@ func (a *apiService) AuthLogin(ctx context.Context, session *api.AuthAttemptSess
 		return nil, err
 	}
 
+	a.events.Record(events.OpSessionCreate, session.UserId, &dto.Source{
+		ID:   sessionID.String(),
+		Type: "session",
+	})
+
 	setRefreshCookie(ctx, refreshToken)
 	return &api.AuthLoginNoContent{
 		SetCookie: setCookie(ctx, authCookieName, jwtToken, int(a.cnf.JWT.SessionTime.Seconds())),
@@ -172,6 +179,9 @@ func (a *apiService) AuthRefresh(ctx context.Context, params api.AuthRefreshPara
 	if err != nil {
 		return nil, &apiError{err: err}
 	}
+	if user.DisabledAt != nil {
+		return nil, &apiError{code: http.StatusForbidden, err: errors.New("user disabled")}
+	}
 	name := user.UserName
 	if user.Name != nil && *user.Name != "" {
 		name = *user.Name
@@ -321,16 +331,45 @@ func generateTgSession(dcId int, authKey []byte, port int) string {
 	return "1" + base64Encoded
 }
 
-func checkUserIsAllowed(allowedUsers []string, userName string) bool {
-	found := false
-	if len(allowedUsers) > 0 {
-		if slices.Contains(allowedUsers, userName) {
-			found = true
-		}
-	} else {
-		found = true
+// checkLoginAllowed rejects disabled accounts and users that fail
+// allowListed.
+func (a *apiService) checkLoginAllowed(ctx context.Context, userID int64, userName string) error {
+	user, err := a.repo.Users.GetByID(ctx, userID)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		return &apiError{err: err}
+	}
+	if user != nil && user.DisabledAt != nil {
+		return &apiError{code: http.StatusForbidden, err: errors.New("user disabled")}
+	}
+	allowed, err := a.allowListed(ctx, user, userName)
+	if err != nil {
+		return &apiError{err: err}
+	}
+	if !allowed {
+		return &apiError{code: http.StatusForbidden, err: errors.New("user not allowed")}
+	}
+	return nil
+}
+
+// allowListed reports whether a username passes jwt.allowed-users and the
+// runtime allow-list. Admins and names in jwt.allowed-users always pass, so
+// editing the runtime list cannot lock them out. When both lists are empty
+// everyone passes. user is nil for someone who has never logged in.
+func (a *apiService) allowListed(ctx context.Context, user *jetmodel.Users, userName string) (bool, error) {
+	if user != nil && user.IsAdmin {
+		return true, nil
+	}
+	if slices.Contains(a.cnf.JWT.AdminUsers, userName) || slices.Contains(a.cnf.JWT.AllowedUsers, userName) {
+		return true, nil
+	}
+	allowed, err := a.repo.AllowedUsers.List(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(a.cnf.JWT.AllowedUsers) == 0 && len(allowed) == 0 {
+		return true, nil
 	}
-	return found
+	return slices.Contains(allowed, userName), nil
 }
 
 func prepareSession(user *TelegramUser, data *types.SessionData) *api.AuthAttemptSession {
@@ -388,8 +388,8 @@ func (a *apiService) executeQRLogin(ctx context.Context, attempt *authAttempt) e
 }
 
 func (a *apiService) completeAuthAttempt(ctx context.Context, attempt *authAttempt, user *TelegramUser) error {
-	if !checkUserIsAllowed(a.cnf.JWT.AllowedUsers, user.Username) {
-		attempt.publish(authAttemptEvent{Type: "error", Message: "user not allowed"})
+	if err := a.checkLoginAllowed(ctx, user.ID, user.Username); err != nil {
+		attempt.publish(authAttemptEvent{Type: "error", Message: err.Error()})
 		_ = a.telegram.LogOut(ctx, attempt.tgClient)
 		return nil
 	}
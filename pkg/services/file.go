@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"mime"
+	"mime/multipart"
 	"net/http"
+	pathpkg "path"
 	"strconv"
 	"strings"
 	"time"
@@ -29,6 +32,8 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+const folderListPageSize = 100
+
 var (
 	ErrorStreamAbandoned = errors.New("stream abandoned")
 	defaultContentType   = "application/octet-stream"
@@ -70,9 +75,11 @@ func (a *apiService) FilesCategoryStats(ctx context.Context) ([]api.CategoryStat
 func (a *apiService) FilesCopy(ctx context.Context, req *api.FileCopy, params api.FilesCopyParams) (*api.File, error) {
 	userId := auth.User(ctx)
 
-	client, err := a.telegram.AuthClient(ctx, auth.JWTUser(ctx).TgSession, 5)
-	if err != nil {
-		return nil, &apiError{err: err}
+	if err := a.checkKeyFolder(ctx, uuid.UUID(params.ID)); err != nil {
+		return nil, err
+	}
+	if err := a.checkKeyFolderDestination(ctx, req.Destination); err != nil {
+		return nil, err
 	}
 
 	file, err := a.repo.Files.GetByIDAndUser(ctx, uuid.UUID(params.ID), userId)
@@ -83,6 +90,38 @@ func (a *apiService) FilesCopy(ctx context.Context, req *api.FileCopy, params ap
 		return nil, &apiError{err: err}
 	}
 
+	var parentId string
+	if !isUUID(req.Destination) {
+		resolvedID, err := a.repo.Files.CreateDirectories(ctx, userId, req.Destination)
+		if err != nil {
+			return nil, &apiError{err: err}
+		}
+		if resolvedID == nil {
+			return nil, &apiError{err: errors.New("destination path not found"), code: 404}
+		}
+		parentId = resolvedID.String()
+	} else {
+		parentId = req.Destination
+	}
+
+	parentUUID, err := uuid.Parse(parentId)
+	if err != nil {
+		return nil, &apiError{err: err, code: 400}
+	}
+
+	if err := a.checkCopyQuota(ctx, userId, file); err != nil {
+		return nil, err
+	}
+
+	if file.Type == string(api.FileTypeFolder) {
+		return a.copyFolder(ctx, userId, file, parentUUID, req.NewName.Or(file.Name))
+	}
+
+	client, err := a.telegram.AuthClient(ctx, auth.JWTUser(ctx).TgSession, 5)
+	if err != nil {
+		return nil, &apiError{err: err}
+	}
+
 	var sourceParts, newIds []api.Part
 
 	for _, part := range file.Parts.Data {
@@ -111,25 +150,6 @@ func (a *apiService) FilesCopy(ctx context.Context, req *api.FileCopy, params ap
 		return nil, &apiError{err: errors.New("failed to copy all file parts")}
 	}
 
-	var parentId string
-	if !isUUID(req.Destination) {
-		resolvedID, err := a.repo.Files.CreateDirectories(ctx, userId, req.Destination)
-		if err != nil {
-			return nil, &apiError{err: err}
-		}
-		if resolvedID == nil {
-			return nil, &apiError{err: errors.New("destination path not found"), code: 404}
-		}
-		parentId = resolvedID.String()
-	} else {
-		parentId = req.Destination
-	}
-
-	parentUUID, err := uuid.Parse(parentId)
-	if err != nil {
-		return nil, &apiError{err: err, code: 400}
-	}
-
 	now := time.Now().UTC()
 	updatedAt := now
 	if req.UpdatedAt.IsSet() && !req.UpdatedAt.Value.IsZero() {
@@ -178,21 +198,43 @@ func (a *apiService) FilesCreate(ctx context.Context, fileIn *api.File) (*api.Fi
 	if err != nil {
 		return nil, err
 	}
+	if parentID != nil {
+		if err := a.checkKeyFolder(ctx, *parentID); err != nil {
+			return nil, err
+		}
+	}
 
 	fileDB := jetmodel.Files{ID: uuid.New(), UserID: userId, Encrypted: fileIn.Encrypted.Value}
 	fileDB.Status = utils.Ptr(constants.FileStatusActive.String())
 	fileDB.ParentID = parentID
 
-	var uploadId string
+	var (
+		uploadId string
+		uploads  []jetmodel.Uploads
+		deduped  bool
+	)
 	switch fileIn.Type {
 	case api.FileTypeFolder:
 		fileDB.MimeType = "drive/folder"
 	case api.FileTypeFile:
 		var err error
-		uploadId, _, err = a.prepareFileData(ctx, fileIn, &fileDB, userId)
+		uploadId, uploads, err = a.prepareFileData(ctx, fileIn, &fileDB, userId)
 		if err != nil {
 			return nil, &apiError{err: err}
 		}
+		var size int64
+		if fileDB.Size != nil {
+			size = *fileDB.Size
+		}
+		if err := a.checkQuota(ctx, userId, 1, size); err != nil {
+			return nil, err
+		}
+		if uploadId != "" {
+			deduped, err = a.dedupFileParts(ctx, &fileDB, userId)
+			if err != nil {
+				return nil, &apiError{err: err}
+			}
+		}
 	}
 
 	fileDB.Name = fileIn.Name
@@ -204,9 +246,17 @@ func (a *apiService) FilesCreate(ctx context.Context, fileIn *api.File) (*api.Fi
 		fileDB.UpdatedAt = time.Now().UTC()
 	}
 
-	if err := a.persistAndCleanup(ctx, fileDB, uploadId, userId); err != nil {
+	// A deduplicated upload keeps its rows until its messages are deleted.
+	persistUploadId := uploadId
+	if deduped {
+		persistUploadId = ""
+	}
+	if err := a.persistAndCleanup(ctx, fileDB, persistUploadId, userId); err != nil {
 		return nil, &apiError{err: err}
 	}
+	if deduped {
+		a.releaseUploadParts(ctx, uploadId, uploads)
+	}
 
 	return mapper.ToJetFileOut(fileDB), nil
 }
@@ -234,6 +284,59 @@ func (a *apiService) resolveParentID(ctx context.Context, fileIn *api.File, user
 	return nil, nil
 }
 
+// fileByPath looks up an active file or folder by its path below the user's
+// root folder.
+func (a *apiService) fileByPath(ctx context.Context, userID int64, name string) (*jetmodel.Files, error) {
+	id, err := a.repo.Files.ResolvePathID(ctx, pathpkg.Join("/root", name), userID)
+	if err != nil {
+		return nil, err
+	}
+	return a.repo.Files.GetByIDAndUser(ctx, *id, userID)
+}
+
+// listFolder returns every active child of a folder, sorted by name.
+func (a *apiService) listFolder(ctx context.Context, userID int64, folderID uuid.UUID) ([]jetmodel.Files, error) {
+	params := repositories.FileQueryParams{
+		UserID:    userID,
+		Operation: "list",
+		Status:    "active",
+		ParentID:  folderID.String(),
+		Sort:      "name",
+		Order:     "asc",
+		Limit:     folderListPageSize,
+	}
+
+	var out []jetmodel.Files
+	for {
+		items, err := a.repo.Files.List(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, items...)
+		if len(items) < folderListPageSize {
+			return out, nil
+		}
+		last := items[len(items)-1]
+		params.Cursor = fmt.Sprintf("%s:%s", last.Name, last.ID.String())
+	}
+}
+
+// fileWithParts loads the full row of a file owned by userID. Rows from
+// listFolder leave out parts, so anything that reads file content from a
+// listing goes through here.
+func (a *apiService) fileWithParts(ctx context.Context, fileID uuid.UUID, userID int64) (*jetmodel.Files, error) {
+	file, err := cache.Fetch(ctx, a.cache, cache.KeyFile(fileID.String()), 0, func() (*jetmodel.Files, error) {
+		return a.repo.Files.GetByIDAndUser(ctx, fileID, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if file.UserID != userID {
+		return nil, repositories.ErrNotFound
+	}
+	return file, nil
+}
+
 // prepareFileData prepares file-specific data (FileTypeFile only) including
 // channel resolution, parts handling, uploads, and hash computation.
 func (a *apiService) prepareFileData(ctx context.Context, fileIn *api.File, fileDB *jetmodel.Files, userId int64) (uploadId string, uploads []jetmodel.Uploads, err error) {
@@ -296,6 +399,17 @@ func (a *apiService) prepareFileData(ctx context.Context, fileIn *api.File, file
 // persistAndCleanup handles transaction persistence, cache invalidation, and event recording.
 func (a *apiService) persistAndCleanup(ctx context.Context, fileDB jetmodel.Files, uploadId string, userId int64) error {
 	if err := a.repo.WithTx(ctx, func(txCtx context.Context) error {
+		if fileDB.Type == "file" {
+			existing, err := a.repo.Files.GetActiveByNameAndParent(txCtx, userId, fileDB.Name, fileDB.ParentID)
+			if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+				return err
+			}
+			if existing != nil {
+				if err := a.keepFileVersion(txCtx, existing, mapper.ToAPIParts(fileDB.Parts)); err != nil {
+					return err
+				}
+			}
+		}
 		if err := a.repo.Files.UpsertActive(txCtx, &fileDB); err != nil {
 			return err
 		}
@@ -334,6 +448,10 @@ func (a *apiService) persistAndCleanup(ctx context.Context, fileDB jetmodel.File
 func (a *apiService) FilesCreateShare(ctx context.Context, req *api.FileShareCreate, params api.FilesCreateShareParams) error {
 	userId := auth.User(ctx)
 
+	if err := a.checkKeyFolder(ctx, uuid.UUID(params.ID)); err != nil {
+		return err
+	}
+
 	var fileShare jetmodel.FileShares
 
 	if req.Password.Value != "" {
@@ -355,6 +473,7 @@ func (a *apiService) FilesCreateShare(ctx context.Context, req *api.FileShareCre
 		return &apiError{err: err}
 	}
 
+	a.recordShareEvent(ctx, events.OpShareCreate, &fileShare)
 	return nil
 }
 
@@ -362,6 +481,9 @@ func (a *apiService) FilesDeleteById(ctx context.Context, params api.FilesDelete
 
 	userId := auth.User(ctx)
 
+	if err := a.checkKeyFolder(ctx, uuid.UUID(params.ID)); err != nil {
+		return err
+	}
 	req := &api.FileDelete{Ids: []api.UUID{params.ID}}
 
 	var fileDB struct {
@@ -373,7 +495,7 @@ func (a *apiService) FilesDeleteById(ctx context.Context, params api.FilesDelete
 
 	fileID := uuid.UUID(req.Ids[0])
 
-	deleted, err := a.repo.Files.DeleteBulkReturning(ctx, []uuid.UUID{fileID}, userId, "pending_deletion")
+	deleted, err := a.removeFiles(ctx, userId, []uuid.UUID{fileID})
 	if err != nil {
 		return &apiError{err: err}
 	}
@@ -418,12 +540,15 @@ func (a *apiService) FilesDelete(ctx context.Context, req *api.FileDelete) error
 	if len(req.Ids) == 0 {
 		return &apiError{err: errors.New("ids should not be empty"), code: 409}
 	}
+	if err := a.checkKeyFolder(ctx, utils.Map(req.Ids, func(id api.UUID) uuid.UUID { return uuid.UUID(id) })...); err != nil {
+		return err
+	}
 	ids := make([]uuid.UUID, 0, len(req.Ids))
 	for _, id := range req.Ids {
 		ids = append(ids, uuid.UUID(id))
 	}
 
-	deleted, err := a.repo.Files.DeleteBulkReturning(ctx, ids, userId, "pending_deletion")
+	deleted, err := a.removeFiles(ctx, userId, ids)
 	if err != nil {
 		return &apiError{err: err}
 	}
@@ -441,6 +566,9 @@ func (a *apiService) FilesDelete(ctx context.Context, req *api.FileDelete) error
 }
 
 func (a *apiService) FilesDeleteShare(ctx context.Context, params api.FilesDeleteShareParams) error {
+	if err := a.checkKeyFolder(ctx, uuid.UUID(params.ID)); err != nil {
+		return err
+	}
 	if err := a.repo.Shares.Delete(ctx, uuid.UUID(params.ShareId)); err != nil {
 		return &apiError{err: err}
 	}
@@ -449,6 +577,9 @@ func (a *apiService) FilesDeleteShare(ctx context.Context, params api.FilesDelet
 }
 
 func (a *apiService) FilesEditShare(ctx context.Context, req *api.FileShareCreate, params api.FilesEditShareParams) error {
+	if err := a.checkKeyFolder(ctx, uuid.UUID(params.ID)); err != nil {
+		return err
+	}
 	update := repositories.ShareUpdate{}
 
 	if req.Password.Value != "" {
@@ -470,6 +601,9 @@ func (a *apiService) FilesEditShare(ctx context.Context, req *api.FileShareCreat
 }
 
 func (a *apiService) FilesGetById(ctx context.Context, params api.FilesGetByIdParams) (*api.File, error) {
+	if err := a.checkKeyFolder(ctx, uuid.UUID(params.ID)); err != nil {
+		return nil, err
+	}
 
 	file, err := a.repo.Files.GetByID(ctx, uuid.UUID(params.ID))
 	if err != nil {
@@ -493,6 +627,10 @@ func (a *apiService) FilesGetById(ctx context.Context, params api.FilesGetByIdPa
 func (a *apiService) FilesList(ctx context.Context, params api.FilesListParams) (*api.FileList, error) {
 	userId := auth.User(ctx)
 
+	if err := a.checkKeyFolderList(ctx, params); err != nil {
+		return nil, err
+	}
+
 	qParams := repositories.FileQueryParams{
 		UserID:    userId,
 		Operation: string(params.Operation.Value),
@@ -554,6 +692,9 @@ func (a *apiService) FilesStreamHead(ctx context.Context, params api.FilesStream
 	userID := auth.User(ctx)
 	fileID := uuid.UUID(params.ID)
 
+	if err := a.checkKeyFolder(ctx, fileID); err != nil {
+		return nil, err
+	}
 	file, err := cache.Fetch(ctx, a.cache, cache.KeyFile(fileID.String()), 0, func() (*jetmodel.Files, error) {
 		return a.repo.Files.GetByIDAndUser(ctx, fileID, userID)
 	})
@@ -576,6 +717,10 @@ func (a *apiService) FilesStreamHead(ctx context.Context, params api.FilesStream
 	}
 	contentDisposition := mime.FormatMediaType(disposition, map[string]string{"filename": file.Name})
 	lastModified := file.UpdatedAt.UTC()
+	contentType := defaultContentType
+	if file.MimeType != "" {
+		contentType = file.MimeType
+	}
 
 	if rawRange, ok := params.Range.Get(); ok && rawRange != "" && contentLength > 0 {
 		ranges, err := http_range.Parse(rawRange, contentLength)
@@ -586,19 +731,29 @@ func (a *apiService) FilesStreamHead(ctx context.Context, params api.FilesStream
 			return nil, &apiError{err: err, code: http.StatusBadRequest}
 		}
 		if len(ranges) > 1 {
-			return nil, &apiError{err: fmt.Errorf("multiple ranges are not supported"), code: http.StatusRequestedRangeNotSatisfiable}
+			// Boundaries are random but always the same length, so this is
+			// the size of the body a GET would send.
+			boundary := multipart.NewWriter(io.Discard).Boundary()
+			return &api.FilesStreamHeadPartialContent{
+				AcceptRanges:       api.FilesStreamHeadPartialContentAcceptRangesBytes,
+				ContentDisposition: contentDisposition,
+				ContentLength:      strconv.FormatInt(byteRangesLength(ranges, boundary, contentType, contentLength), 10),
+				Etag:               etag,
+				LastModified:       lastModified,
+			}, nil
+		}
+		if len(ranges) == 1 {
+			start := ranges[0].Start
+			end := ranges[0].End
+			return &api.FilesStreamHeadPartialContent{
+				AcceptRanges:       api.FilesStreamHeadPartialContentAcceptRangesBytes,
+				ContentDisposition: contentDisposition,
+				ContentLength:      strconv.FormatInt(end-start+1, 10),
+				ContentRange:       api.NewOptString(fmt.Sprintf("bytes %d-%d/%d", start, end, contentLength)),
+				Etag:               etag,
+				LastModified:       lastModified,
+			}, nil
 		}
-
-		start := ranges[0].Start
-		end := ranges[0].End
-		return &api.FilesStreamHeadPartialContent{
-			AcceptRanges:       api.FilesStreamHeadPartialContentAcceptRangesBytes,
-			ContentDisposition: contentDisposition,
-			ContentLength:      strconv.FormatInt(end-start+1, 10),
-			ContentRange:       api.NewOptString(fmt.Sprintf("bytes %d-%d/%d", start, end, contentLength)),
-			Etag:               etag,
-			LastModified:       lastModified,
-		}, nil
 	}
 
 	return &api.FilesStreamHeadOK{
@@ -622,6 +777,13 @@ func (a *apiService) FilesMkdir(ctx context.Context, path string) error {
 func (a *apiService) FilesMove(ctx context.Context, req *api.FileMove) error {
 	userId := auth.User(ctx)
 
+	if err := a.checkKeyFolder(ctx, utils.Map(req.Ids, func(id api.UUID) uuid.UUID { return uuid.UUID(id) })...); err != nil {
+		return err
+	}
+	if err := a.checkKeyFolderDestination(ctx, req.DestinationParent); err != nil {
+		return err
+	}
+
 	var destParentID *uuid.UUID
 
 	if !isUUID(req.DestinationParent) {
@@ -716,6 +878,10 @@ func (a *apiService) FilesMove(ctx context.Context, req *api.FileMove) error {
 func (a *apiService) FilesListShares(ctx context.Context, params api.FilesListSharesParams) ([]api.FileShare, error) {
 	fileID := uuid.UUID(params.ID)
 
+	if err := a.checkKeyFolder(ctx, fileID); err != nil {
+		return nil, err
+	}
+
 	result, err := a.repo.Shares.GetByFileID(ctx, fileID)
 	if err != nil {
 		return nil, &apiError{err: err}
@@ -739,6 +905,15 @@ func (a *apiService) FilesListShares(ctx context.Context, params api.FilesListSh
 func (a *apiService) FilesUpdate(ctx context.Context, req *api.FileUpdate, params api.FilesUpdateParams) (*api.File, error) {
 	userId := auth.User(ctx)
 
+	if err := a.checkKeyFolder(ctx, uuid.UUID(params.ID)); err != nil {
+		return nil, err
+	}
+	if req.ParentId.IsSet() {
+		if err := a.checkKeyFolder(ctx, uuid.UUID(req.ParentId.Value)); err != nil {
+			return nil, err
+		}
+	}
+
 	update, uploadId, err := a.buildFileUpdate(ctx, req)
 	if err != nil {
 		return nil, &apiError{err: err}
@@ -748,6 +923,17 @@ func (a *apiService) FilesUpdate(ctx context.Context, req *api.FileUpdate, param
 
 	var file *jetmodel.Files
 	if err := a.repo.WithTx(ctx, func(txCtx context.Context) error {
+		if update.Size != nil {
+			current, err := a.repo.Files.GetByIDAndUser(txCtx, fileUUID, userId)
+			if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+				return err
+			}
+			if current != nil {
+				if err := a.keepFileVersion(txCtx, current, req.Parts); err != nil {
+					return err
+				}
+			}
+		}
 		updated, err := a.repo.Files.UpdateReturning(txCtx, fileUUID, update)
 		if err != nil {
 			return err
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/internal/auth"
+	"github.com/tgdrive/teldrive/internal/events"
+	"github.com/tgdrive/teldrive/internal/utils"
+	"github.com/tgdrive/teldrive/pkg/mapper"
+)
+
+const (
+	changesCursorPrefix = "v1:"
+	changesDefaultLimit = 500
+
+	// changesSettleDelay holds back the newest events, so an event that got
+	// its sequence number before another one but committed after it is not
+	// skipped by a client that already moved past the later one.
+	changesSettleDelay = 2 * time.Second
+)
+
+var (
+	errInvalidChangesCursor = errors.New("invalid cursor")
+	errChangesCursorExpired = errors.New("cursor expired, list the drive again and start from a new cursor")
+)
+
+func (a *apiService) FilesChanges(ctx context.Context, params api.FilesChangesParams) (*api.FileChanges, error) {
+	userID := auth.User(ctx)
+	before := time.Now().UTC().Add(-changesSettleDelay)
+
+	if params.Cursor.Value == "" {
+		seq, err := a.repo.Events.LatestSeq(ctx, userID, before)
+		if err != nil {
+			return nil, &apiError{err: err}
+		}
+		return &api.FileChanges{Changes: []api.Event{}, Cursor: encodeChangesCursor(seq)}, nil
+	}
+
+	after, err := decodeChangesCursor(params.Cursor.Value)
+	if err != nil {
+		return nil, &apiError{err: err, code: http.StatusBadRequest}
+	}
+	limit := int(params.Limit.Or(changesDefaultLimit))
+
+	rows, err := a.repo.Events.ListAfterSeq(ctx, userID, after, before, changeEventTypes(), limit+1)
+	if err != nil {
+		return nil, &apiError{err: err}
+	}
+
+	// Checked after listing: a prune that committed before the listing shows
+	// up here, and one that committed after it did not hide anything.
+	pruned, err := a.repo.Events.PrunedSeq(ctx, userID)
+	if err != nil {
+		return nil, &apiError{err: err}
+	}
+	if pruned > after {
+		return nil, &apiError{err: errChangesCursorExpired, code: http.StatusGone}
+	}
+
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+	next := after
+	if len(rows) > 0 {
+		next = rows[len(rows)-1].Seq
+	}
+	if !hasMore {
+		// Move past events that are not changes, so pruning them later does
+		// not expire a cursor that missed nothing.
+		latest, err := a.repo.Events.LatestSeq(ctx, userID, before)
+		if err != nil {
+			return nil, &apiError{err: err}
+		}
+		next = max(next, latest)
+	}
+
+	return &api.FileChanges{
+		Changes: utils.Map(rows, mapper.ToEventOut),
+		Cursor:  encodeChangesCursor(next),
+		HasMore: hasMore,
+	}, nil
+}
+
+func changeEventTypes() []string {
+	types, _ := events.ParseEventTypes([]string{"files.*"})
+	return utils.Map(types, func(t events.EventType) string { return string(t) })
+}
+
+func encodeChangesCursor(seq int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(changesCursorPrefix + strconv.FormatInt(seq, 10)))
+}
+
+func decodeChangesCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errInvalidChangesCursor
+	}
+	value, ok := strings.CutPrefix(string(raw), changesCursorPrefix)
+	if !ok {
+		return 0, errInvalidChangesCursor
+	}
+	seq, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, errInvalidChangesCursor
+	}
+	return seq, nil
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangesCursorRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, seq := range []int64{0, 1, 987654321} {
+		got, err := decodeChangesCursor(encodeChangesCursor(seq))
+		require.NoError(t, err)
+		require.Equal(t, seq, got)
+	}
+
+	for _, bad := range []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("42")),
+		base64.RawURLEncoding.EncodeToString([]byte("v1:abc")),
+		base64.RawURLEncoding.EncodeToString([]byte("v1:-3")),
+	} {
+		_, err := decodeChangesCursor(bad)
+		require.ErrorIs(t, err, errInvalidChangesCursor, bad)
+	}
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tgdrive/teldrive/internal/auth"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/logging"
+	"github.com/tgdrive/teldrive/pkg/repositories"
+	"go.uber.org/zap"
+)
+
+// dedupFileParts points fileDB at the parts of an existing active file with the
+// same content hash and size. It reports whether such a file was found, in
+// which case the freshly uploaded parts are no longer needed.
+func (a *apiService) dedupFileParts(ctx context.Context, fileDB *jetmodel.Files, userID int64) (bool, error) {
+	if !a.cnf.TG.Uploads.Dedup || fileDB.Hash == nil || fileDB.Size == nil || *fileDB.Size == 0 {
+		return false, nil
+	}
+	existing, err := a.repo.Files.GetActiveByHash(ctx, userID, *fileDB.Hash, *fileDB.Size)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	fileDB.ChannelID = existing.ChannelID
+	fileDB.Parts = existing.Parts
+	fileDB.Encrypted = existing.Encrypted
+	return true, nil
+}
+
+// releaseUploadParts deletes the messages of an upload whose content turned
+// out to be a duplicate, then drops its upload rows. When the messages cannot
+// be deleted the rows are kept, so the stale uploads cleanup retries later.
+func (a *apiService) releaseUploadParts(ctx context.Context, uploadID string, uploads []jetmodel.Uploads) {
+	logger := logging.FromContext(ctx).With(zap.String("upload_id", uploadID))
+
+	jwtUser := auth.JWTUser(ctx)
+	if jwtUser == nil || jwtUser.TgSession == "" {
+		return
+	}
+
+	partsByChannel := make(map[int64][]int)
+	for _, upload := range uploads {
+		partsByChannel[upload.ChannelID] = append(partsByChannel[upload.ChannelID], int(upload.PartID))
+	}
+
+	for channelID, ids := range partsByChannel {
+		if err := a.deleteChannelMessages(ctx, jwtUser.TgSession, channelID, ids); err != nil {
+			logger.Warn("dedup.release_messages_failed", zap.Int64("channel_id", channelID), zap.Error(err))
+			return
+		}
+	}
+	if err := a.repo.Uploads.Delete(ctx, uploadID); err != nil {
+		logger.Warn("dedup.release_uploads_failed", zap.Error(err))
+	}
+}
+
+// unreferencedParts drops the part IDs that are still used by a file of the
+// user, so shared messages are only deleted together with their last file.
+func (a *apiService) unreferencedParts(ctx context.Context, userID, channelID int64, partIDs []int) ([]int, error) {
+	referenced, err := a.repo.Files.ListReferencedParts(ctx, userID, channelID, partIDs)
+	if err != nil {
+		return nil, err
+	}
+	skip := make(map[int]bool, len(referenced))
+	for _, id := range referenced {
+		skip[id] = true
+	}
+	out := make([]int, 0, len(partIDs))
+	for _, id := range partIDs {
+		if skip[id] {
+			continue
+		}
+		skip[id] = true
+		out = append(out, id)
+	}
+	return out, nil
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/internal/auth"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/events"
+	"github.com/tgdrive/teldrive/pkg/dto"
+	"github.com/tgdrive/teldrive/pkg/mapper"
+	"github.com/tgdrive/teldrive/pkg/repositories"
+)
+
+func (a *apiService) FilesListVersions(ctx context.Context, params api.FilesListVersionsParams) ([]api.FileVersion, error) {
+	userID := auth.User(ctx)
+	fileID := uuid.UUID(params.ID)
+
+	if err := a.checkKeyFolder(ctx, fileID); err != nil {
+		return nil, err
+	}
+
+	if _, err := a.repo.Files.GetByIDAndUser(ctx, fileID, userID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, &apiError{err: errors.New("file not found"), code: 404}
+		}
+		return nil, &apiError{err: err}
+	}
+
+	rows, err := a.repo.FileVersions.ListByFileID(ctx, fileID, userID)
+	if err != nil {
+		return nil, &apiError{err: err}
+	}
+
+	out := make([]api.FileVersion, 0, len(rows))
+	for _, row := range rows {
+		item := api.FileVersion{
+			ID:        api.UUID(row.ID),
+			Version:   row.Version,
+			Encrypted: row.Encrypted,
+			UpdatedAt: row.UpdatedAt,
+			CreatedAt: row.CreatedAt,
+		}
+		if row.Size != nil {
+			item.Size = api.NewOptInt64(*row.Size)
+		}
+		if row.Hash != nil {
+			item.Hash = api.NewOptString(*row.Hash)
+		}
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+func (a *apiService) FilesRestoreVersion(ctx context.Context, params api.FilesRestoreVersionParams) (*api.File, error) {
+	userID := auth.User(ctx)
+	fileID := uuid.UUID(params.ID)
+
+	if err := a.checkKeyFolder(ctx, fileID); err != nil {
+		return nil, err
+	}
+	versionID := uuid.UUID(params.VersionId)
+
+	var file *jetmodel.Files
+	err := a.repo.WithTx(ctx, func(txCtx context.Context) error {
+		version, err := a.repo.FileVersions.GetByIDAndFile(txCtx, versionID, fileID, userID)
+		if err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				return &apiError{err: errors.New("file version not found"), code: 404}
+			}
+			return &apiError{err: err}
+		}
+		current, err := a.repo.Files.GetByIDAndUser(txCtx, fileID, userID)
+		if err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				return &apiError{err: errors.New("file not found"), code: 404}
+			}
+			return &apiError{err: err}
+		}
+		if err := a.keepFileVersion(txCtx, current, mapper.ToAPIParts(version.Parts)); err != nil {
+			return &apiError{err: err}
+		}
+		if err := a.repo.FileVersions.RestoreToFile(txCtx, versionID, time.Now().UTC()); err != nil {
+			return &apiError{err: err}
+		}
+		if err := a.repo.FileVersions.DeleteByIDs(txCtx, []uuid.UUID{versionID}); err != nil {
+			return &apiError{err: err}
+		}
+		file, err = a.repo.Files.GetByIDAndUser(txCtx, fileID, userID)
+		if err != nil {
+			return &apiError{err: err}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a.invalidateFileCache(ctx, fileID.String(), true)
+
+	var parentID string
+	if file.ParentID != nil {
+		parentID = file.ParentID.String()
+	}
+	a.events.Record(events.OpUpdate, userID, &dto.Source{
+		ID:       file.ID.String(),
+		Type:     file.Type,
+		Name:     file.Name,
+		ParentID: parentID,
+	})
+	return mapper.ToJetFileOut(*file), nil
+}
+
+// keepFileVersion records the current content of file as a version before it
+// is replaced. Files without parts have nothing to keep, and content that
+// shares messages with newParts is skipped so that cleaning up the version can
+// never delete messages the file still uses.
+func (a *apiService) keepFileVersion(ctx context.Context, file *jetmodel.Files, newParts []api.Part) error {
+	if file.Type != "file" || file.Parts == nil || len(file.Parts.Data) == 0 {
+		return nil
+	}
+	for _, part := range newParts {
+		for _, current := range file.Parts.Data {
+			if part.ID == current.ID {
+				return nil
+			}
+		}
+	}
+	_, err := a.repo.FileVersions.CreateFromFile(ctx, file.ID, file.UserID)
+	return err
+}
+
+// fileVersionContent returns a file model describing the content of a version,
+// so it can be served like any other file. The version ID stands in for the
+// file ID to keep cached message parts of versions apart from the live file.
+func (a *apiService) fileVersionContent(ctx context.Context, userID int64, fileID, versionID uuid.UUID) (*jetmodel.Files, error) {
+	file, err := a.repo.Files.GetByIDAndUser(ctx, fileID, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, &apiError{err: errors.New("file not found"), code: 404}
+		}
+		return nil, &apiError{err: err}
+	}
+	version, err := a.repo.FileVersions.GetByIDAndFile(ctx, versionID, fileID, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, &apiError{err: errors.New("file version not found"), code: 404}
+		}
+		return nil, &apiError{err: err}
+	}
+	return &jetmodel.Files{
+		ID:        version.ID,
+		Name:      file.Name,
+		Type:      file.Type,
+		MimeType:  file.MimeType,
+		Size:      version.Size,
+		UserID:    file.UserID,
+		ChannelID: version.ChannelID,
+		Parts:     version.Parts,
+		Encrypted: version.Encrypted,
+		Hash:      version.Hash,
+		UpdatedAt: version.UpdatedAt,
+	}, nil
+}
@@ -0,0 +1,615 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/riverqueue/river"
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/internal/cache"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/database/types"
+	"github.com/tgdrive/teldrive/internal/events"
+	"github.com/tgdrive/teldrive/internal/utils"
+	"github.com/tgdrive/teldrive/pkg/dto"
+	"github.com/tgdrive/teldrive/pkg/mapper"
+	"github.com/tgdrive/teldrive/pkg/queue"
+	"github.com/tgdrive/teldrive/pkg/repositories"
+)
+
+// copyBatchParts caps how many message parts are copied in one Telegram batch.
+const copyBatchParts = 100
+
+// filesJobChunk is how many items a files.move or files.delete job handles
+// between two progress checkpoints.
+const filesJobChunk = 500
+
+type copyTask struct {
+	source   jetmodel.Files
+	parentID uuid.UUID
+	name     string
+}
+
+// FilesCopy copies every item of args into the destination folder. Folders are
+// walked recursively and recreated, then file parts are copied in batches.
+// Folders and files that already exist at the destination are reused, so a
+// retried job continues where the previous attempt stopped.
+func (e *jobExecutor) FilesCopy(ctx context.Context, args queue.FilesCopyJobArgs, jobID int64) error {
+	destinationID, err := uuid.Parse(args.Destination)
+	if err != nil {
+		return fmt.Errorf("invalid destination %q: %w", args.Destination, err)
+	}
+	destination, err := e.api.repo.Files.GetByIDAndUser(ctx, destinationID, args.UserID)
+	if err != nil {
+		return err
+	}
+	if destination.Type != string(api.FileTypeFolder) {
+		return fmt.Errorf("destination %s is not a folder", destinationID)
+	}
+
+	var (
+		tasks []copyTask
+		roots []map[string]any
+	)
+	for _, item := range args.Items {
+		itemID, err := uuid.Parse(item.ID)
+		if err != nil {
+			return fmt.Errorf("invalid item id %q: %w", item.ID, err)
+		}
+		source, err := e.api.repo.Files.GetByIDAndUser(ctx, itemID, args.UserID)
+		if err != nil {
+			return err
+		}
+		name := item.DestinationName
+		if name == "" {
+			name = source.Name
+		}
+		if source.Type != string(api.FileTypeFolder) {
+			tasks = append(tasks, copyTask{source: *source, parentID: destinationID, name: name})
+			roots = append(roots, map[string]any{"id": source.ID.String(), "name": name})
+			continue
+		}
+		inside, err := e.api.folderContains(ctx, args.UserID, source.ID, destinationID)
+		if err != nil {
+			return err
+		}
+		if inside {
+			return fmt.Errorf("cannot copy folder %s into itself", source.ID)
+		}
+		root, err := e.api.ensureFolder(ctx, args.UserID, destinationID, name)
+		if err != nil {
+			return err
+		}
+		roots = append(roots, map[string]any{"id": source.ID.String(), "name": name, "destinationId": root.ID.String()})
+		folderTasks, err := e.collectCopyTasks(ctx, args.UserID, source.ID, root.ID)
+		if err != nil {
+			return err
+		}
+		tasks = append(tasks, folderTasks...)
+	}
+
+	session, err := latestTGSession(ctx, e.api, args.UserID)
+	if err != nil {
+		return err
+	}
+	client, err := e.api.telegram.AuthClient(ctx, session, 5)
+	if err != nil {
+		return err
+	}
+	channelID, err := e.api.channelManager.CurrentChannel(ctx, args.UserID)
+	if err != nil {
+		return err
+	}
+
+	progress := &filesJobProgress{ctx: ctx, api: e.api, jobID: jobID, userID: args.UserID, folder: destination, total: len(tasks), results: roots}
+	if err := progress.report(0); err != nil {
+		return err
+	}
+
+	done := 0
+	var batch []copyTask
+	batchParts := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := e.copyFileBatch(ctx, client, channelID, args.UserID, batch); err != nil {
+			return err
+		}
+		done += len(batch)
+		batch, batchParts = nil, 0
+		return progress.report(done)
+	}
+
+	for _, task := range tasks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		existing, err := e.api.repo.Files.GetActiveByNameAndParent(ctx, args.UserID, task.name, &task.parentID)
+		if err == nil {
+			// Only a copy left by an earlier attempt counts as done. Anything
+			// else holding the name is reported rather than overwritten.
+			if !isCopyOf(existing, &task.source) {
+				progress.results = append(progress.results, map[string]any{"id": task.source.ID.String(), "name": task.name, "error": "an item with this name already exists"})
+			}
+			done++
+			continue
+		}
+		if !errors.Is(err, repositories.ErrNotFound) {
+			return err
+		}
+		parts := copyTaskParts(task)
+		if len(batch) > 0 && (batchParts+len(parts) > copyBatchParts || !equalPtr(batch[0].source.ChannelID, task.source.ChannelID)) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		batch = append(batch, task)
+		batchParts += len(parts)
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	return progress.report(done)
+}
+
+// FilesMove moves every item of args into the destination folder, renaming the
+// ones that carry a destination name. Items that cannot be moved are listed in
+// the job results instead of failing the whole job.
+func (e *jobExecutor) FilesMove(ctx context.Context, args queue.FilesMoveJobArgs, jobID int64) error {
+	destinationID, err := uuid.Parse(args.Destination)
+	if err != nil {
+		return fmt.Errorf("invalid destination %q: %w", args.Destination, err)
+	}
+	destination, err := e.api.repo.Files.GetByIDAndUser(ctx, destinationID, args.UserID)
+	if err != nil {
+		return err
+	}
+	if destination.Type != string(api.FileTypeFolder) {
+		return fmt.Errorf("destination %s is not a folder", destinationID)
+	}
+
+	progress := &filesJobProgress{ctx: ctx, api: e.api, jobID: jobID, userID: args.UserID, folder: destination, total: len(args.Items)}
+	done := progress.checkpoint()
+	if err := progress.report(done); err != nil {
+		return err
+	}
+	for done < len(args.Items) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := min(done+filesJobChunk, len(args.Items))
+		for _, item := range args.Items[done:end] {
+			if err := e.moveItem(ctx, args.UserID, destinationID, item); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				progress.results = append(progress.results, map[string]any{"id": item.ID, "error": err.Error()})
+			}
+		}
+		done = end
+		if err := progress.report(done); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *jobExecutor) moveItem(ctx context.Context, userID int64, destinationID uuid.UUID, item queue.JobItem) error {
+	itemID, err := uuid.Parse(item.ID)
+	if err != nil {
+		return fmt.Errorf("invalid item id %q: %w", item.ID, err)
+	}
+	source, err := e.api.repo.Files.GetByIDAndUser(ctx, itemID, userID)
+	if err != nil {
+		return err
+	}
+	if source.Type == string(api.FileTypeFolder) {
+		inside, err := e.api.folderContains(ctx, userID, source.ID, destinationID)
+		if err != nil {
+			return err
+		}
+		if inside {
+			return errors.New("cannot move a folder into itself")
+		}
+	}
+
+	var name *string
+	if item.DestinationName != "" {
+		name = &item.DestinationName
+	}
+	moved, err := e.api.repo.Files.MoveSingleReturning(ctx, itemID, userID, &destinationID, name)
+	if err != nil {
+		return err
+	}
+
+	var parentID string
+	if source.ParentID != nil {
+		parentID = source.ParentID.String()
+	}
+	e.api.events.Record(events.OpMove, userID, &dto.Source{
+		ID:           moved.ID.String(),
+		Type:         moved.Type,
+		Name:         moved.Name,
+		ParentID:     parentID,
+		DestParentID: destinationID.String(),
+	})
+	return nil
+}
+
+// FilesDelete moves every item of args to the trash, or straight to pending
+// deletion when the trash is disabled, a chunk of items at a time.
+func (e *jobExecutor) FilesDelete(ctx context.Context, args queue.FilesDeleteJobArgs, jobID int64) error {
+	progress := &filesJobProgress{ctx: ctx, api: e.api, jobID: jobID, userID: args.UserID, total: len(args.Items)}
+	done := progress.checkpoint()
+	if err := progress.report(done); err != nil {
+		return err
+	}
+	for done < len(args.Items) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := min(done+filesJobChunk, len(args.Items))
+		ids := make([]uuid.UUID, 0, end-done)
+		for _, item := range args.Items[done:end] {
+			id, err := uuid.Parse(item.ID)
+			if err != nil {
+				progress.results = append(progress.results, map[string]any{"id": item.ID, "error": "invalid item id"})
+				continue
+			}
+			ids = append(ids, id)
+		}
+		if err := e.deleteItems(ctx, args.UserID, ids); err != nil {
+			return err
+		}
+		done = end
+		if err := progress.report(done); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *jobExecutor) deleteItems(ctx context.Context, userID int64, ids []uuid.UUID) error {
+	deleted, err := e.api.removeFiles(ctx, userID, ids)
+	if err != nil {
+		return err
+	}
+
+	requested := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		requested[id] = true
+	}
+	keys := make([]string, 0, len(deleted)*2)
+	for _, item := range deleted {
+		idStr := item.ID.String()
+		keys = append(keys, cache.KeyFile(idStr), cache.KeyFileMessages(idStr))
+		if !requested[item.ID] {
+			continue
+		}
+		var parentID string
+		if item.ParentID != nil {
+			parentID = item.ParentID.String()
+		}
+		e.api.events.Record(events.OpDelete, userID, &dto.Source{
+			ID:       idStr,
+			Type:     item.Type,
+			Name:     item.Name,
+			ParentID: parentID,
+		})
+	}
+	if len(keys) > 0 {
+		e.api.cache.Delete(ctx, keys...)
+	}
+	return nil
+}
+
+// collectCopyTasks recreates the folders below sourceID under destinationID
+// and returns the files that still need their parts copied.
+func (e *jobExecutor) collectCopyTasks(ctx context.Context, userID int64, sourceID, destinationID uuid.UUID) ([]copyTask, error) {
+	children, err := e.api.listFolder(ctx, userID, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []copyTask
+	for _, child := range children {
+		if child.Type != string(api.FileTypeFolder) {
+			source, err := e.api.fileWithParts(ctx, child.ID, userID)
+			if err != nil {
+				return nil, err
+			}
+			tasks = append(tasks, copyTask{source: *source, parentID: destinationID, name: child.Name})
+			continue
+		}
+		folder, err := e.api.ensureFolder(ctx, userID, destinationID, child.Name)
+		if err != nil {
+			return nil, err
+		}
+		nested, err := e.collectCopyTasks(ctx, userID, child.ID, folder.ID)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, nested...)
+	}
+	return tasks, nil
+}
+
+// copyFileBatch copies the parts of files that live in the same source channel
+// with a single Telegram call, stores the copies and announces each of them
+// with a files.copied event.
+func (e *jobExecutor) copyFileBatch(ctx context.Context, client TelegramClient, channelID, userID int64, batch []copyTask) error {
+	var (
+		sourceParts []api.Part
+		size        int64
+	)
+	for _, task := range batch {
+		sourceParts = append(sourceParts, copyTaskParts(task)...)
+		if task.source.Size != nil {
+			size += *task.source.Size
+		}
+	}
+	if err := e.api.checkQuota(ctx, userID, int64(len(batch)), size); err != nil {
+		// Retrying cannot help until the user frees space or gets a new limit.
+		if isQuotaError(err) {
+			return river.JobCancel(err)
+		}
+		return err
+	}
+
+	var copied []api.Part
+	if len(sourceParts) > 0 {
+		err := e.api.telegram.RunWithAuth(ctx, client, "", func(ctx context.Context) error {
+			out, err := e.api.telegram.CopyFileParts(ctx, client, *batch[0].source.ChannelID, channelID, sourceParts)
+			if err != nil {
+				return err
+			}
+			copied = out
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(copied) != len(sourceParts) {
+			return errors.New("failed to copy all file parts")
+		}
+	}
+
+	files := make([]*jetmodel.Files, 0, len(batch))
+	err := e.api.repo.WithTx(ctx, func(txCtx context.Context) error {
+		offset := 0
+		for _, task := range batch {
+			count := len(copyTaskParts(task))
+			file := copiedFile(task, userID, channelID, copied[offset:offset+count])
+			offset += count
+			if err := e.api.repo.Files.Create(txCtx, file); err != nil {
+				return err
+			}
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		e.api.events.Record(events.OpCopy, userID, &dto.Source{
+			ID:       file.ID.String(),
+			Type:     file.Type,
+			Name:     file.Name,
+			ParentID: file.ParentID.String(),
+		})
+	}
+	return nil
+}
+
+func copyTaskParts(task copyTask) []api.Part {
+	if task.source.Parts == nil || task.source.ChannelID == nil {
+		return nil
+	}
+	parts := make([]api.Part, 0, len(task.source.Parts.Data))
+	for _, part := range task.source.Parts.Data {
+		parts = append(parts, api.Part{ID: part.ID, Salt: api.NewOptString(part.Salt)})
+	}
+	return parts
+}
+
+func copiedFile(task copyTask, userID, channelID int64, parts []api.Part) *jetmodel.Files {
+	now := time.Now().UTC()
+	file := &jetmodel.Files{
+		ID:        uuid.New(),
+		Name:      task.name,
+		Type:      task.source.Type,
+		MimeType:  task.source.MimeType,
+		Size:      task.source.Size,
+		UserID:    userID,
+		Status:    utils.Ptr("active"),
+		Encrypted: task.source.Encrypted,
+		Category:  task.source.Category,
+		ParentID:  &task.parentID,
+		Hash:      task.source.Hash,
+		CreatedAt: now,
+		UpdatedAt: task.source.UpdatedAt,
+	}
+	if len(parts) > 0 {
+		dbParts := make(types.Parts, 0, len(parts))
+		for _, part := range parts {
+			dbParts = append(dbParts, types.Part{ID: part.ID, Salt: part.Salt.Value})
+		}
+		file.ChannelID = &channelID
+		file.Parts = utils.Ptr(types.NewJSONB(dbParts))
+	}
+	return file
+}
+
+// isCopyOf reports whether existing is a copy of source made by copiedFile,
+// which keeps the size, hash and modification time of the source.
+func isCopyOf(existing, source *jetmodel.Files) bool {
+	return existing.ID != source.ID &&
+		existing.Type == source.Type &&
+		equalPtr(existing.Size, source.Size) &&
+		equalPtr(existing.Hash, source.Hash) &&
+		existing.UpdatedAt.Equal(source.UpdatedAt)
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// copyFolder creates the top folder of a folder copy right away and leaves
+// copying its content to a files.copy job.
+func (a *apiService) copyFolder(ctx context.Context, userID int64, folder *jetmodel.Files, parentID uuid.UUID, name string) (*api.File, error) {
+	if a.jobs == nil {
+		return nil, &apiError{err: errors.New("jobs service is not configured"), code: 503}
+	}
+	inside, err := a.folderContains(ctx, userID, folder.ID, parentID)
+	if err != nil {
+		return nil, &apiError{err: err}
+	}
+	if inside {
+		return nil, &apiError{err: errors.New("cannot copy a folder into itself"), code: 400}
+	}
+	if _, err := a.repo.Files.GetActiveByNameAndParent(ctx, userID, name, &parentID); err == nil {
+		return nil, &apiError{err: errors.New("destination already exists"), code: 409}
+	} else if !errors.Is(err, repositories.ErrNotFound) {
+		return nil, &apiError{err: err}
+	}
+
+	root, err := a.ensureFolder(ctx, userID, parentID, name)
+	if err != nil {
+		return nil, &apiError{err: err}
+	}
+	if _, err := a.jobs.Insert(ctx, queue.FilesCopyJobArgs{
+		UserID:      userID,
+		Items:       []queue.JobItem{{ID: folder.ID.String(), DestinationName: name}},
+		Destination: parentID.String(),
+	}, nil); err != nil {
+		return nil, &apiError{err: err}
+	}
+
+	a.events.Record(events.OpCopy, userID, &dto.Source{
+		ID:       root.ID.String(),
+		Type:     root.Type,
+		Name:     root.Name,
+		ParentID: parentID.String(),
+	})
+	return mapper.ToJetFileOut(*root), nil
+}
+
+// folderContains reports whether id is folderID itself or lies below it.
+func (a *apiService) folderContains(ctx context.Context, userID int64, folderID, id uuid.UUID) (bool, error) {
+	for {
+		if id == folderID {
+			return true, nil
+		}
+		current, err := a.repo.Files.GetByIDAndUser(ctx, id, userID)
+		if err != nil {
+			return false, err
+		}
+		if current.ParentID == nil {
+			return false, nil
+		}
+		id = *current.ParentID
+	}
+}
+
+// ensureFolder returns the active folder called name below parentID, creating
+// it when it does not exist yet.
+func (a *apiService) ensureFolder(ctx context.Context, userID int64, parentID uuid.UUID, name string) (*jetmodel.Files, error) {
+	existing, err := a.repo.Files.GetActiveByNameAndParent(ctx, userID, name, &parentID)
+	if err == nil {
+		if existing.Type != string(api.FileTypeFolder) {
+			return nil, fmt.Errorf("%s already exists and is not a folder: %w", name, repositories.ErrConflict)
+		}
+		return existing, nil
+	}
+	if !errors.Is(err, repositories.ErrNotFound) {
+		return nil, err
+	}
+
+	folder := &jetmodel.Files{
+		ID:       uuid.New(),
+		Name:     name,
+		Type:     string(api.FileTypeFolder),
+		MimeType: "drive/folder",
+		UserID:   userID,
+		Status:   utils.Ptr("active"),
+		ParentID: &parentID,
+	}
+	if err := a.repo.Files.Create(ctx, folder); err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+type filesJobProgress struct {
+	ctx     context.Context
+	api     *apiService
+	jobID   int64
+	userID  int64
+	folder  *jetmodel.Files
+	total   int
+	results []map[string]any
+}
+
+// report stores the progress in the job output and, when the job works on a
+// folder, notifies subscribers with a jobs.progress event for it.
+func (p *filesJobProgress) report(done int) error {
+	output := jobProgressOutput(done, p.total, p.results)
+	if err := river.RecordOutput(p.ctx, output); err != nil {
+		return err
+	}
+	if p.api.jobs != nil && p.jobID > 0 {
+		if _, err := p.api.jobs.JobUpdate(p.ctx, p.jobID, &river.JobUpdateParams{Output: output}); err != nil {
+			return err
+		}
+	}
+
+	if p.folder == nil {
+		return nil
+	}
+	var parentID string
+	if p.folder.ParentID != nil {
+		parentID = p.folder.ParentID.String()
+	}
+	p.api.events.Record(events.OpJobProgress, p.userID, &dto.Source{
+		ID:       p.folder.ID.String(),
+		Type:     p.folder.Type,
+		Name:     p.folder.Name,
+		ParentID: parentID,
+	})
+	return nil
+}
+
+// checkpoint reads back the progress an earlier attempt of the job stored in
+// its output, so a retried job skips the items it already handled.
+func (p *filesJobProgress) checkpoint() int {
+	if p.api.jobs == nil || p.jobID <= 0 {
+		return 0
+	}
+	row, err := p.api.jobs.JobGet(p.ctx, p.jobID)
+	if err != nil {
+		return 0
+	}
+	var output struct {
+		Progress struct {
+			Done  int `json:"done"`
+			Total int `json:"total"`
+		} `json:"progress"`
+		Data struct {
+			Results []map[string]any `json:"results"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(row.Output(), &output); err != nil || output.Progress.Total != p.total {
+		return 0
+	}
+	p.results = output.Data.Results
+	return min(output.Progress.Done, p.total)
+}
@@ -4,12 +4,18 @@ import (
 	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/url"
 	"path"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,11 +28,15 @@ import (
 	"github.com/tgdrive/teldrive/internal/logging"
 	"github.com/tgdrive/teldrive/pkg/queue"
 	"github.com/tgdrive/teldrive/pkg/remotes"
+	"github.com/tgdrive/teldrive/pkg/remotes/httpfs"
 	"github.com/tgdrive/teldrive/pkg/remotes/local"
 	"github.com/tgdrive/teldrive/pkg/remotes/rclone"
+	"github.com/tgdrive/teldrive/pkg/remotes/s3"
 	"github.com/tgdrive/teldrive/pkg/remotes/sftp"
 	"github.com/tgdrive/teldrive/pkg/remotes/webdav"
 	"github.com/tgdrive/teldrive/pkg/repositories"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
 type sourceFile struct {
@@ -42,13 +52,27 @@ type sourceFile struct {
 
 const syncTransferTaskPrefix = "transfer_"
 
+// maxKeepBothCopies bounds the numbered names tried for one keep-both upload.
+const maxKeepBothCopies = 1000
+
 type syncRunPlan struct {
 	planned      int
 	queued       int
 	skipped      int
 	deleted      int
+	backedUp     int
 	bytesPlanned int64
 	depNames     []string
+	actions      []syncFileAction
+}
+
+// syncFileAction records what a run did with one path, relative to the
+// destination, for the run summary.
+type syncFileAction struct {
+	Path   string             `json:"path"`
+	Action api.SyncAction     `json:"action"`
+	Reason api.SyncPlanReason `json:"reason"`
+	Target string             `json:"target,omitempty"`
 }
 
 type syncDestinationEntry struct {
@@ -103,6 +127,7 @@ func (e *jobExecutor) SyncRun(ctx context.Context, args queue.SyncRunJobArgs, jo
 	if err != nil {
 		return err
 	}
+	var actions []syncFileAction
 	if all.Count() == 0 {
 		plan, err := e.initializeSyncWorkflow(workingCtx, workflow, client, args, runID, destinationRoot, files)
 		if err != nil {
@@ -111,6 +136,7 @@ func (e *jobExecutor) SyncRun(ctx context.Context, args queue.SyncRunJobArgs, jo
 		if err := recordSyncRunPlanOutput(workingCtx, runID, args.Source, destinationRoot, plan); err != nil {
 			return err
 		}
+		actions = plan.actions
 	}
 
 	fresh, err := workflow.LoadAll(workingCtx, nil)
@@ -128,7 +154,19 @@ func (e *jobExecutor) SyncRun(ctx context.Context, args queue.SyncRunJobArgs, jo
 		return river.JobSnooze(e.syncPollInterval(args.PollInterval))
 	}
 
-	return recordSyncRunSummaryOutput(workingCtx, runID, stats)
+	if all.Count() > 0 {
+		// The workflow was planned by an earlier attempt, whose plan output
+		// survives snoozes in the job metadata.
+		job, err := client.JobGet(workingCtx, jobID)
+		if err != nil {
+			return err
+		}
+		var out syncRunPlanOutput
+		if err := json.Unmarshal(job.Output(), &out); err == nil {
+			actions = out.Data.Actions
+		}
+	}
+	return recordSyncRunSummaryOutput(workingCtx, runID, stats, actions)
 }
 
 func (e *jobExecutor) SyncTransfer(ctx context.Context, args queue.SyncTransferJobArgs, jobID int64) error {
@@ -217,6 +255,12 @@ func (e *jobExecutor) resolveSyncDestinationParent(ctx context.Context, userID i
 	return parentID, nil
 }
 
+type syncRunPlanOutput struct {
+	Data struct {
+		Actions []syncFileAction `json:"actions"`
+	} `json:"data"`
+}
+
 type syncTransferOutput struct {
 	Data struct {
 		RunID    string `json:"runId"`
@@ -357,32 +401,137 @@ func (e *jobExecutor) resolveDestinationPath(ctx context.Context, userID int64,
 	return cleanPath(destination), nil
 }
 
-func (e *jobExecutor) compareDestinationFingerprint(ctx context.Context, userID int64, destinationPath, name string, src sourceFile) (bool, bool, error) {
+// destinationFile returns the active file called name in the folder at
+// destinationPath, or nil when there is none.
+func (e *jobExecutor) destinationFile(ctx context.Context, userID int64, destinationPath, name string) (*jetmodel.Files, error) {
 	parentID, err := e.api.repo.Files.ResolvePathID(ctx, destinationPath, userID)
 	if err != nil {
 		if errors.Is(err, repositories.ErrNotFound) {
-			return false, false, nil
+			return nil, nil
 		}
-		return false, false, err
+		return nil, err
 	}
 	existing, err := e.api.repo.Files.GetActiveByNameAndParent(ctx, userID, name, parentID)
 	if err != nil {
 		if errors.Is(err, repositories.ErrNotFound) {
-			return false, false, nil
+			return nil, nil
 		}
-		return false, false, err
+		return nil, err
 	}
+	return existing, nil
+}
 
+func destinationFingerprintMatches(existing *jetmodel.Files, src sourceFile) bool {
 	if src.hash != "" && existing.Hash != nil && *existing.Hash != "" {
-		return true, src.hash == *existing.Hash, nil
+		return src.hash == *existing.Hash
 	}
 	if existing.Size == nil || *existing.Size != src.size {
-		return true, false, nil
+		return false
 	}
 	if src.modified.IsZero() {
-		return true, false, nil
+		return false
+	}
+	return existing.UpdatedAt.UTC().Unix() == src.modified.UTC().Unix()
+}
+
+// sourceIsNewer reports whether src should replace existing under the newer
+// conflict policy. Sources without a modification time always win.
+func sourceIsNewer(existing *jetmodel.Files, src sourceFile) bool {
+	if src.modified.IsZero() {
+		return true
+	}
+	return src.modified.UTC().Unix() > existing.UpdatedAt.UTC().Unix()
+}
+
+// keepBothName picks the first free "name (n).ext" next to the conflicting
+// file. A numbered copy that already matches src means an earlier run kept
+// it, which is reported through unchanged. Names in reserved, relative to
+// the sync destination, are taken by other source files or earlier picks.
+func (e *jobExecutor) keepBothName(ctx context.Context, userID int64, destinationPath string, src sourceFile, reserved map[string]struct{}) (string, bool, error) {
+	dir := normalizeSourceDir(src.relPath)
+	for n := 1; n <= maxKeepBothCopies; n++ {
+		name := keepBothCopyName(src.name, n)
+		if _, ok := reserved[path.Join(dir, name)]; ok {
+			continue
+		}
+		existing, err := e.destinationFile(ctx, userID, destinationPath, name)
+		if err != nil {
+			return "", false, err
+		}
+		if existing == nil {
+			return name, false, nil
+		}
+		if destinationFingerprintMatches(existing, src) {
+			return name, true, nil
+		}
+	}
+	return "", false, fmt.Errorf("no free name for %q after %d copies", src.relPath, maxKeepBothCopies)
+}
+
+// keepBothCopyName is the name of the nth keep-both copy of name.
+func keepBothCopyName(name string, n int) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if base == "" {
+		base, ext = name, ""
 	}
-	return true, existing.UpdatedAt.UTC().Unix() == src.modified.UTC().Unix(), nil
+	return fmt.Sprintf("%s (%d)%s", base, n, ext)
+}
+
+var keepBothCopyPattern = regexp.MustCompile(`^(.*) \((\d+)\)(.*)$`)
+
+// keepBothOriginal returns the relative path of the file rel is a keep-both
+// copy of, if it has the shape of one.
+func keepBothOriginal(rel string) (string, bool) {
+	dir, name := path.Split(rel)
+	m := keepBothCopyPattern.FindStringSubmatch(name)
+	if m == nil {
+		return "", false
+	}
+	n, err := strconv.Atoi(m[2])
+	if err != nil || n < 1 || n > maxKeepBothCopies {
+		return "", false
+	}
+	original := m[1] + m[3]
+	if keepBothCopyName(original, n) != name {
+		return "", false
+	}
+	return dir + original, true
+}
+
+// validateSyncBackupDir checks that backupDir is an absolute folder outside
+// destination, so backed up files are never synced again.
+func validateSyncBackupDir(backupDir, destination string) error {
+	if backupDir == "" {
+		return fmt.Errorf("backupDir is required for the backup deletion policy")
+	}
+	if !strings.HasPrefix(backupDir, "/") {
+		return fmt.Errorf("backupDir must start with '/'")
+	}
+	backupDir, destination = cleanPath(backupDir), cleanPath(destination)
+	within := func(p, root string) bool {
+		return p == root || strings.HasPrefix(p, strings.TrimSuffix(root, "/")+"/")
+	}
+	if within(backupDir, destination) || within(destination, backupDir) {
+		return fmt.Errorf("backupDir %q overlaps the destination %q", backupDir, destination)
+	}
+	return nil
+}
+
+func validateSyncPolicies(opts queue.SyncOptions, destinationRoot string) error {
+	switch opts.ConflictPolicy() {
+	case queue.SyncConflictOverwrite, queue.SyncConflictSkip, queue.SyncConflictKeepBoth, queue.SyncConflictNewer:
+	default:
+		return fmt.Errorf("unknown conflict policy %q", opts.Conflict)
+	}
+	switch opts.DeletionPolicy() {
+	case queue.SyncDeletionDelete, queue.SyncDeletionIgnore:
+	case queue.SyncDeletionBackup:
+		return validateSyncBackupDir(opts.BackupDir, destinationRoot)
+	default:
+		return fmt.Errorf("unknown deletion policy %q", opts.Deletion)
+	}
+	return nil
 }
 
 func impliedSourceDirs(files []sourceFile) map[string]struct{} {
@@ -480,71 +629,175 @@ func remoteFSForSource(source string) (remotes.FS, error) {
 		return sftp.New(source)
 	case "rclone", "rclones":
 		return rclone.New(source)
+	case "http", "https":
+		return httpfs.New(source)
+	case "s3":
+		return s3.New(source)
 	default:
 		return nil, fmt.Errorf("unsupported source scheme %q", u.Scheme)
 	}
 }
 
-func (e *jobExecutor) initializeSyncWorkflow(ctx context.Context, workflow *river.WorkflowT[pgx.Tx], client *river.Client[pgx.Tx], args queue.SyncRunJobArgs, runID, destinationRoot string, files []sourceFile) (*syncRunPlan, error) {
-	plan := &syncRunPlan{depNames: make([]string, 0, len(files))}
+// syncPlanEntry is a source file in a sync preview together with the reason
+// it is uploaded or skipped and the action the conflict policy picked. name
+// is the destination file name, which differs from the source for keep-both.
+type syncPlanEntry struct {
+	src             sourceFile
+	destinationPath string
+	name            string
+	reason          api.SyncPlanReason
+	action          api.SyncAction
+}
+
+// target is the destination path of a keep-both upload relative to the sync
+// destination.
+func (p syncPlanEntry) target() string {
+	if p.action != api.SyncActionKeepBoth {
+		return ""
+	}
+	return path.Join(normalizeSourceDir(p.src.relPath), p.name)
+}
+
+// syncRunPreview lists what a sync run would change. Deletions are ordered
+// files first, then folders deepest first. With the backup policy files are
+// moved below backupDir and the emptied folders are deleted.
+type syncRunPreview struct {
+	upload    []syncPlanEntry
+	skip      []syncPlanEntry
+	delete    []syncDestinationEntry
+	backupDir string
+}
+
+// deleteAction is what happens to the destination entry when it is not in
+// the source, and target the backup path it is moved to, if any.
+func (p *syncRunPreview) deleteAction(entry syncDestinationEntry) (api.SyncAction, string) {
+	if p.backupDir == "" || entry.type_ != api.FileTypeFile {
+		return api.SyncActionDelete, ""
+	}
+	return api.SyncActionBackup, cleanPath(path.Join(p.backupDir, entry.rel))
+}
+
+// previewSyncRun compares the source files with the destination without
+// changing either. It backs both the run itself and the plan endpoint.
+func (e *jobExecutor) previewSyncRun(ctx context.Context, args queue.SyncRunJobArgs, destinationRoot string, files []sourceFile) (*syncRunPreview, error) {
+	if err := validateSyncPolicies(args.Options, destinationRoot); err != nil {
+		return nil, err
+	}
+	preview := &syncRunPreview{}
 	sourceSet := make(map[string]struct{}, len(files))
 	for _, src := range files {
 		sourceSet[src.relPath] = struct{}{}
 	}
 	sourceDirs := impliedSourceDirs(files)
-	if args.Options.Sync {
+	conflict := args.Options.ConflictPolicy()
+	deletion := args.Options.DeletionPolicy()
+	if deletion == queue.SyncDeletionBackup {
+		preview.backupDir = cleanPath(args.Options.BackupDir)
+	}
+	if deletion != queue.SyncDeletionIgnore {
 		extra, err := e.extraDestinationEntries(ctx, destinationRoot, sourceSet, sourceDirs)
 		if err != nil {
 			return nil, err
 		}
-		filesToDelete := make([]syncDestinationEntry, 0)
 		dirsToDelete := make([]syncDestinationEntry, 0)
 		for _, entry := range extra {
 			if entry.type_ == api.FileTypeFolder {
 				dirsToDelete = append(dirsToDelete, entry)
-			} else {
-				filesToDelete = append(filesToDelete, entry)
+				continue
 			}
-		}
-		for _, entry := range filesToDelete {
-			if err := e.api.FilesDeleteById(ctx, api.FilesDeleteByIdParams{ID: api.UUID(uuid.MustParse(entry.id))}); err != nil {
-				return nil, err
-			}
-			plan.deleted++
-		}
-		for i := range dirsToDelete {
-			for j := i + 1; j < len(dirsToDelete); j++ {
-				if dirsToDelete[j].depth > dirsToDelete[i].depth {
-					dirsToDelete[i], dirsToDelete[j] = dirsToDelete[j], dirsToDelete[i]
+			// Copies made by earlier keep-both runs hold versions the
+			// destination had, so they are kept rather than deleted
+			if conflict == queue.SyncConflictKeepBoth {
+				if original, ok := keepBothOriginal(entry.rel); ok {
+					if _, ok := sourceSet[original]; ok {
+						continue
+					}
 				}
 			}
+			preview.delete = append(preview.delete, entry)
 		}
-		for _, entry := range dirsToDelete {
-			id := entry.id
-			if err := e.api.FilesDeleteById(ctx, api.FilesDeleteByIdParams{ID: api.UUID(uuid.MustParse(id))}); err != nil {
-				return nil, err
-			}
-			plan.deleted++
-		}
+		// Deepest folders first so each one is empty when it is removed
+		sort.Slice(dirsToDelete, func(i, j int) bool {
+			return dirsToDelete[i].depth > dirsToDelete[j].depth
+		})
+		preview.delete = append(preview.delete, dirsToDelete...)
 	}
 
+	reserved := make(map[string]struct{}, len(sourceSet))
+	for rel := range sourceSet {
+		reserved[rel] = struct{}{}
+	}
 	for _, src := range files {
-		plan.planned++
 		destinationPath := cleanPath(path.Join(destinationRoot, normalizeSourceDir(src.relPath)))
-		exists, sameFingerprint, err := e.compareDestinationFingerprint(ctx, args.UserID, destinationPath, src.name, src)
+		existing, err := e.destinationFile(ctx, args.UserID, destinationPath, src.name)
 		if err != nil {
 			return nil, err
 		}
-		if sameFingerprint || (exists && !sameFingerprint && !args.Options.Sync) {
-			plan.skipped++
-			continue
+		entry := syncPlanEntry{src: src, destinationPath: destinationPath, name: src.name, reason: api.SyncPlanReasonChanged}
+		switch {
+		case existing == nil:
+			entry.reason, entry.action = api.SyncPlanReasonNew, api.SyncActionUpload
+		case destinationFingerprintMatches(existing, src):
+			entry.reason, entry.action = api.SyncPlanReasonUnchanged, api.SyncActionSkip
+		case conflict == queue.SyncConflictSkip:
+			entry.reason, entry.action = api.SyncPlanReasonExists, api.SyncActionSkip
+		case conflict == queue.SyncConflictNewer && !sourceIsNewer(existing, src):
+			entry.reason, entry.action = api.SyncPlanReasonOlder, api.SyncActionSkip
+		case conflict == queue.SyncConflictKeepBoth:
+			name, unchanged, err := e.keepBothName(ctx, args.UserID, destinationPath, src, reserved)
+			if err != nil {
+				return nil, err
+			}
+			entry.name, entry.action = name, api.SyncActionKeepBoth
+			if unchanged {
+				entry.reason, entry.action = api.SyncPlanReasonUnchanged, api.SyncActionSkip
+			}
+			reserved[path.Join(normalizeSourceDir(src.relPath), name)] = struct{}{}
+		default:
+			entry.action = api.SyncActionOverwrite
 		}
+		if entry.action == api.SyncActionSkip {
+			preview.skip = append(preview.skip, entry)
+		} else {
+			preview.upload = append(preview.upload, entry)
+		}
+	}
+	return preview, nil
+}
 
+func (e *jobExecutor) initializeSyncWorkflow(ctx context.Context, workflow *river.WorkflowT[pgx.Tx], client *river.Client[pgx.Tx], args queue.SyncRunJobArgs, runID, destinationRoot string, files []sourceFile) (*syncRunPlan, error) {
+	preview, err := e.previewSyncRun(ctx, args, destinationRoot, files)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &syncRunPlan{planned: len(files), skipped: len(preview.skip), depNames: make([]string, 0, len(preview.upload)), actions: make([]syncFileAction, 0, len(files)+len(preview.delete))}
+	for _, entry := range preview.delete {
+		action, target := preview.deleteAction(entry)
+		if action == api.SyncActionBackup {
+			if err := e.backupDestinationFile(ctx, args.UserID, entry, target); err != nil {
+				return nil, err
+			}
+			plan.backedUp++
+		} else {
+			if err := e.api.FilesDeleteById(ctx, api.FilesDeleteByIdParams{ID: api.UUID(uuid.MustParse(entry.id))}); err != nil {
+				return nil, err
+			}
+			plan.deleted++
+		}
+		plan.actions = append(plan.actions, syncFileAction{Path: entry.rel, Action: action, Reason: api.SyncPlanReasonExtra, Target: target})
+	}
+
+	for _, entry := range preview.skip {
+		plan.actions = append(plan.actions, syncFileAction{Path: entry.src.relPath, Action: entry.action, Reason: entry.reason})
+	}
+	for _, entry := range preview.upload {
 		taskName := syncTransferTaskName(plan.queued + 1)
-		workflow.Add(taskName, newSyncTransferJobArgs(args, runID, src, destinationPath), &river.InsertOpts{MaxAttempts: e.api.syncTransferMaxAttempts(), Queue: queue.QueueUploads}, nil)
+		workflow.Add(taskName, newSyncTransferJobArgs(args, runID, entry), &river.InsertOpts{MaxAttempts: e.api.syncTransferMaxAttempts(), Queue: queue.QueueUploads}, nil)
 		plan.depNames = append(plan.depNames, taskName)
 		plan.queued++
-		plan.bytesPlanned += src.size
+		plan.bytesPlanned += entry.src.size
+		plan.actions = append(plan.actions, syncFileAction{Path: entry.src.relPath, Action: entry.action, Reason: entry.reason, Target: entry.target()})
 	}
 
 	prepared, err := workflow.Prepare(ctx)
@@ -560,6 +813,23 @@ func (e *jobExecutor) initializeSyncWorkflow(ctx context.Context, workflow *rive
 	return plan, nil
 }
 
+// backupDestinationFile moves entry to target, replacing an older backup with
+// the same name.
+func (e *jobExecutor) backupDestinationFile(ctx context.Context, userID int64, entry syncDestinationEntry, target string) error {
+	parentID, err := e.api.repo.Files.CreateDirectories(ctx, userID, path.Dir(target))
+	if err != nil {
+		return err
+	}
+	if parentID == nil {
+		return fmt.Errorf("backup path could not be resolved")
+	}
+	return e.api.FilesMove(ctx, &api.FileMove{
+		Ids:               []api.UUID{api.UUID(uuid.MustParse(entry.id))},
+		DestinationParent: parentID.String(),
+		DestinationName:   api.NewOptString(path.Base(target)),
+	})
+}
+
 func summarizeSyncWorkflow(tasks *river.WorkflowTasks) (*syncWorkflowStats, error) {
 	stats := &syncWorkflowStats{files: make([]map[string]any, 0)}
 	for _, taskName := range tasks.Names() {
@@ -595,21 +865,58 @@ func (e *jobExecutor) uploadSyncTransferParts(ctx context.Context, stager *uploa
 	if err != nil {
 		return err
 	}
-	uploaded := resume.doneBytes
-	progress := newSyncTransferProgress(ctx, e.api, jobID, size)
-	if uploaded > 0 {
-		if _, err := io.CopyN(io.Discard, reader, uploaded); err != nil {
-			return fmt.Errorf("skip already uploaded bytes: %w", err)
+	pending := make([]int, 0, totalParts)
+	for partNo := 1; partNo <= totalParts; partNo++ {
+		if _, ok := resume.completed[partNo]; !ok {
+			pending = append(pending, partNo)
 		}
-		if err := progress.complete(uploaded, []map[string]any{{"resumedParts": resume.completedParts, "bytesDone": uploaded, "success": true}}); err != nil {
+	}
+	if err := e.api.checkUploadQuota(ctx, args.UserID, uploadID, size-resume.doneBytes); err != nil {
+		if isQuotaError(err) {
+			return river.JobCancel(err)
+		}
+		return err
+	}
+	progress := newSyncTransferProgress(ctx, e.api, jobID, size)
+	if resume.doneBytes > 0 {
+		if err := progress.complete(resume.doneBytes, []map[string]any{{"resumedParts": len(resume.completed), "bytesDone": resume.doneBytes, "success": true}}); err != nil {
 			return err
 		}
 	}
 
+	if workers := min(e.api.syncTransferParallelParts(), len(pending)); workers > 1 {
+		if rfs := e.rangeSyncSource(ctx, args, int64(pending[1]-1)*partSize); rfs != nil {
+			return e.uploadSyncTransferPartsParallel(ctx, stager, rfs, args, uploadID, size, partSize, pending, resume.doneBytes, progress, workers)
+		}
+	}
+
+	uploaded := resume.doneBytes
+	var position int64
+	var resumed io.ReadCloser
+	defer func() {
+		if resumed != nil {
+			_ = resumed.Close()
+		}
+	}()
+
 	return stager.Run(ctx, func(ctx context.Context) error {
-		for i := resume.completedParts; i < totalParts; i++ {
-			partNo := i + 1
-			chunkSize := minInt64(partSize, size-uploaded)
+		for _, partNo := range pending {
+			// Parts staged by an earlier attempt are skipped in the source.
+			// Only the first skip can start a new ranged read.
+			if start := int64(partNo-1) * partSize; start > position {
+				if position == 0 {
+					resumed, err = e.skipUploadedSourceBytes(ctx, args, reader, start)
+					if err != nil {
+						return err
+					}
+					reader = newContextReader(ctx, resumed)
+				} else if _, err := io.CopyN(io.Discard, reader, start-position); err != nil {
+					return fmt.Errorf("skip already uploaded bytes: %w", err)
+				}
+				position = start
+			}
+
+			chunkSize := expectedSyncPartSize(size, partSize, partNo)
 			partResults := []map[string]any{{"partNo": partNo, "size": chunkSize, "success": true}}
 			partReader := io.LimitReader(reader, chunkSize)
 			trackedReader := newProgressReader(partReader, progress.track(uploaded, chunkSize, partResults))
@@ -628,6 +935,7 @@ func (e *jobExecutor) uploadSyncTransferParts(ctx context.Context, stager *uploa
 			}
 
 			uploaded += chunkSize
+			position += chunkSize
 			if err := progress.complete(uploaded, partResults); err != nil {
 				return err
 			}
@@ -637,9 +945,135 @@ func (e *jobExecutor) uploadSyncTransferParts(ctx context.Context, stager *uploa
 	})
 }
 
+// rangeSyncSource returns the source as a RangeFS when a ranged read at
+// probeOffset works, so parts can be read independently of each other.
+func (e *jobExecutor) rangeSyncSource(ctx context.Context, args queue.SyncTransferJobArgs, probeOffset int64) remotes.RangeFS {
+	fs, err := remoteFSForSource(args.Source)
+	if err != nil {
+		return nil
+	}
+	rfs, ok := fs.(remotes.RangeFS)
+	if !ok {
+		return nil
+	}
+	rc, err := rfs.OpenRange(ctx, args.SourcePath, args.Headers, args.Proxy, probeOffset)
+	if err != nil {
+		if !errors.Is(err, remotes.ErrRangeNotSupported) {
+			logging.FromContext(ctx).Warn("sync.transfer.range_probe_failed", zap.String("source", args.Source), zap.Error(err))
+		}
+		return nil
+	}
+	_ = rc.Close()
+	return rfs
+}
+
+// uploadSyncTransferPartsParallel stages pending parts with several workers,
+// each reading its own range of the source and uploading through its own
+// upload client, so users with several bots spread the parts across them. A
+// failed part stops workers from taking new parts, but parts already in
+// flight finish, so a retry resumes with as many parts staged as possible.
+func (e *jobExecutor) uploadSyncTransferPartsParallel(ctx context.Context, stager *uploadStager, rfs remotes.RangeFS, args queue.SyncTransferJobArgs, uploadID string, size, partSize int64, pending []int, done int64, progress *syncTransferProgress, workers int) error {
+	parts := make(chan int, len(pending))
+	for _, partNo := range pending {
+		parts <- partNo
+	}
+	close(parts)
+
+	var (
+		uploaded atomic.Int64
+		failed   atomic.Bool
+	)
+	uploaded.Store(done)
+
+	stagePart := func(ctx context.Context, stager *uploadStager, partNo int) error {
+		start := int64(partNo-1) * partSize
+		chunkSize := expectedSyncPartSize(size, partSize, partNo)
+		rc, err := rfs.OpenRange(ctx, args.SourcePath, args.Headers, args.Proxy, start)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rc.Close() }()
+
+		partResults := []map[string]any{{"partNo": partNo, "size": chunkSize, "success": true}}
+		var read int64
+		trackedReader := newProgressReader(io.LimitReader(newContextReader(ctx, rc), chunkSize), func(n int64, force bool) error {
+			total := uploaded.Add(min(n, chunkSize) - read)
+			read = min(n, chunkSize)
+			return progress.report(total, force, partResults)
+		})
+		_, err = stager.StagePart(ctx, uploadStagePartRequest{
+			UploadID:  uploadID,
+			FileName:  args.Name,
+			PartNo:    partNo,
+			Reader:    trackedReader,
+			Size:      chunkSize,
+			Encrypted: args.Encrypted,
+			Hashing:   true,
+			Threads:   e.api.cnf.TG.Uploads.Threads,
+		}, logging.FromContext(ctx))
+		return err
+	}
+	work := func(stager *uploadStager) error {
+		return stager.Run(ctx, func(ctx context.Context) error {
+			for partNo := range parts {
+				if failed.Load() {
+					return nil
+				}
+				if err := stagePart(ctx, stager, partNo); err != nil {
+					failed.Store(true)
+					return fmt.Errorf("part %d: %w", partNo, err)
+				}
+			}
+			return nil
+		})
+	}
+
+	var g errgroup.Group
+	g.Go(func() error { return work(stager) })
+	for range workers - 1 {
+		g.Go(func() error {
+			extra, err := e.api.newUploadStager(ctx, args.UserID, stager.channelID)
+			if err != nil {
+				logging.FromContext(ctx).Warn("sync.transfer.extra_stager_failed", zap.String("upload_id", uploadID), zap.Error(err))
+				return nil
+			}
+			defer extra.Close()
+			return work(extra)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	return progress.complete(uploaded.Load(), []map[string]any{{"parts": len(pending), "success": true}})
+}
+
+// skipUploadedSourceBytes returns a reader positioned after the first offset
+// bytes of the source. Remotes that support ranged reads start a new read at
+// offset; for the others the bytes are read from reader and dropped.
+func (e *jobExecutor) skipUploadedSourceBytes(ctx context.Context, args queue.SyncTransferJobArgs, reader io.Reader, offset int64) (io.ReadCloser, error) {
+	if fs, err := remoteFSForSource(args.Source); err == nil {
+		if rfs, ok := fs.(remotes.RangeFS); ok {
+			rc, err := rfs.OpenRange(ctx, args.SourcePath, args.Headers, args.Proxy, offset)
+			if err == nil {
+				return rc, nil
+			}
+			if !errors.Is(err, remotes.ErrRangeNotSupported) {
+				return nil, err
+			}
+		}
+	}
+	if _, err := io.CopyN(io.Discard, reader, offset); err != nil {
+		return nil, fmt.Errorf("skip already uploaded bytes: %w", err)
+	}
+	return io.NopCloser(reader), nil
+}
+
+// syncTransferResume lists the parts an earlier attempt staged with the
+// expected size. Parallel uploads finish out of order, so the set may have
+// gaps.
 type syncTransferResume struct {
-	completedParts int
-	doneBytes      int64
+	completed map[int]struct{}
+	doneBytes int64
 }
 
 func (e *jobExecutor) syncTransferResumeState(ctx context.Context, uploadID string, totalSize, partSize int64, encrypted bool) (*syncTransferResume, error) {
@@ -647,10 +1081,10 @@ func (e *jobExecutor) syncTransferResumeState(ctx context.Context, uploadID stri
 	if err != nil {
 		return nil, err
 	}
-	if len(uploads) == 0 {
-		return &syncTransferResume{}, nil
-	}
+	return syncTransferResumeFromUploads(uploads, totalSize, partSize, encrypted), nil
+}
 
+func syncTransferResumeFromUploads(uploads []jetmodel.Uploads, totalSize, partSize int64, encrypted bool) *syncTransferResume {
 	byPart := make(map[int32]jetmodel.Uploads, len(uploads))
 	for _, upload := range uploads {
 		if existing, ok := byPart[upload.PartNo]; ok && existing.CreatedAt != nil && upload.CreatedAt != nil && existing.CreatedAt.After(*upload.CreatedAt) {
@@ -659,31 +1093,27 @@ func (e *jobExecutor) syncTransferResumeState(ctx context.Context, uploadID stri
 		byPart[upload.PartNo] = upload
 	}
 
-	resume := &syncTransferResume{}
-	for partNo := 1; ; partNo++ {
-		upload, ok := byPart[int32(partNo)]
-		if !ok {
-			break
-		}
-		expectedPlain := expectedSyncPartSize(totalSize, partSize, partNo)
+	resume := &syncTransferResume{completed: make(map[int]struct{}, len(byPart))}
+	for partNo, upload := range byPart {
+		expectedPlain := expectedSyncPartSize(totalSize, partSize, int(partNo))
 		if expectedPlain <= 0 {
-			break
+			continue
 		}
 		if upload.Encrypted != encrypted {
-			break
+			continue
 		}
 		expectedStored := expectedPlain
 		if encrypted {
 			expectedStored = crypt.EncryptedSize(expectedPlain)
 		}
 		if upload.Size != expectedStored {
-			break
+			continue
 		}
-		resume.completedParts++
+		resume.completed[int(partNo)] = struct{}{}
 		resume.doneBytes += expectedPlain
 	}
 
-	return resume, nil
+	return resume
 }
 
 func expectedSyncPartSize(totalSize, partSize int64, partNo int) int64 {
@@ -698,6 +1128,7 @@ func expectedSyncPartSize(totalSize, partSize int64, partNo int) int64 {
 }
 
 type syncTransferProgress struct {
+	mu           sync.Mutex
 	ctx          context.Context
 	api          *apiService
 	jobID        int64
@@ -731,12 +1162,18 @@ func (p *syncTransferProgress) complete(done int64, results []map[string]any) er
 }
 
 func (p *syncTransferProgress) report(done int64, force bool, results []map[string]any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if done < 0 {
 		done = 0
 	}
 	if p.total > 0 && done > p.total {
 		done = p.total
 	}
+	// Parallel parts may report slightly out of order.
+	if !force && done < p.lastDone {
+		return nil
+	}
 	if !force && done-p.lastDone < p.minStepBytes && !p.lastAt.IsZero() && time.Since(p.lastAt) < p.minInterval {
 		return nil
 	}
@@ -829,14 +1266,14 @@ func md5Hex(text string) string {
 func recordSyncRunPlanOutput(ctx context.Context, runID, source, destination string, plan *syncRunPlan) error {
 	return river.RecordOutput(ctx, map[string]any{
 		"progress": map[string]any{"total": plan.planned, "done": 0, "percent": 0},
-		"data":     map[string]any{"runId": runID, "source": source, "destination": destination, "planned": plan.planned, "queued": plan.queued, "skipped": plan.skipped, "deleted": plan.deleted, "bytesPlanned": plan.bytesPlanned},
+		"data":     map[string]any{"runId": runID, "source": source, "destination": destination, "planned": plan.planned, "queued": plan.queued, "skipped": plan.skipped, "deleted": plan.deleted, "backedUp": plan.backedUp, "bytesPlanned": plan.bytesPlanned, "actions": plan.actions},
 	})
 }
 
-func recordSyncRunSummaryOutput(ctx context.Context, runID string, stats *syncWorkflowStats) error {
+func recordSyncRunSummaryOutput(ctx context.Context, runID string, stats *syncWorkflowStats, actions []syncFileAction) error {
 	return river.RecordOutput(ctx, map[string]any{
 		"progress": map[string]any{"total": stats.total, "done": stats.total, "percent": 100},
-		"data":     map[string]any{"runId": runID, "completed": stats.completed, "failed": stats.failed, "files": stats.files},
+		"data":     map[string]any{"runId": runID, "completed": stats.completed, "failed": stats.failed, "files": stats.files, "actions": actions},
 	})
 }
 
@@ -857,8 +1294,9 @@ func normalizeSourceDir(relPath string) string {
 	return dir
 }
 
-func newSyncTransferJobArgs(args queue.SyncRunJobArgs, runID string, src sourceFile, destinationPath string) queue.SyncTransferJobArgs {
-	child := queue.SyncTransferJobArgs{UserID: args.UserID, RunID: runID, Source: args.Source, SourcePath: src.fullPath, DestinationPath: destinationPath, Name: src.name, Size: src.size, MimeType: src.mimeType, Hash: src.hash, Headers: args.Headers, Proxy: args.Proxy, PartSize: args.Options.PartSize, Encrypted: args.Options.Encrypted}
+func newSyncTransferJobArgs(args queue.SyncRunJobArgs, runID string, entry syncPlanEntry) queue.SyncTransferJobArgs {
+	src := entry.src
+	child := queue.SyncTransferJobArgs{UserID: args.UserID, RunID: runID, Source: args.Source, SourcePath: src.fullPath, DestinationPath: entry.destinationPath, Name: entry.name, Size: src.size, MimeType: src.mimeType, Hash: src.hash, Headers: args.Headers, Proxy: args.Proxy, PartSize: args.Options.PartSize, Encrypted: args.Options.Encrypted}
 	if !src.modified.IsZero() {
 		child.ModifiedAtUnixNano = src.modified.UTC().UnixNano()
 	}
@@ -2,8 +2,12 @@ package services
 
 import (
 	"testing"
+	"time"
 
 	"github.com/tgdrive/teldrive/internal/api"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/pkg/queue"
+	"github.com/tgdrive/teldrive/pkg/remotes"
 )
 
 func TestRemoteFSForSourceSupportsDavs(t *testing.T) {
@@ -16,8 +20,28 @@ func TestRemoteFSForSourceSupportsDavs(t *testing.T) {
 	}
 }
 
+func TestRemoteFSForSourceSupportsHTTPS(t *testing.T) {
+	fs, err := remoteFSForSource("https://example.com/files/video.mkv")
+	if err != nil {
+		t.Fatalf("remoteFSForSource() error = %v", err)
+	}
+	if _, ok := fs.(remotes.RangeFS); !ok {
+		t.Fatal("expected https source to support ranged reads")
+	}
+}
+
+func TestRemoteFSForSourceSupportsS3(t *testing.T) {
+	fs, err := remoteFSForSource("s3://key:secret@minio.local:9000/backups/photos?scheme=http")
+	if err != nil {
+		t.Fatalf("remoteFSForSource() error = %v", err)
+	}
+	if _, ok := fs.(remotes.RangeFS); !ok {
+		t.Fatal("expected s3 source to support ranged reads")
+	}
+}
+
 func TestRemoteFSForSourceRejectsUnsupportedScheme(t *testing.T) {
-	if _, err := remoteFSForSource("https://example.com/root/path"); err == nil {
+	if _, err := remoteFSForSource("ftp://example.com/root/path"); err == nil {
 		t.Fatal("expected unsupported scheme error")
 	}
 }
@@ -56,3 +80,144 @@ func TestToQueueOptionsUsesNormalizedSyncChunkSize(t *testing.T) {
 		t.Fatalf("normalized part size = %d, want %d", got, 496*1024*1024)
 	}
 }
+
+func TestToQueueOptionsCarriesPolicies(t *testing.T) {
+	options := api.SyncOptions{
+		Sync:      api.NewOptBool(false),
+		Conflict:  api.NewOptSyncConflictPolicy(api.SyncConflictPolicyKeepBoth),
+		Deletion:  api.NewOptSyncDeletionPolicy(api.SyncDeletionPolicyBackup),
+		BackupDir: api.NewOptString(" /backups "),
+	}
+	got := toQueueOptions(api.NewOptSyncOptions(options))
+	if got.ConflictPolicy() != queue.SyncConflictKeepBoth || got.DeletionPolicy() != queue.SyncDeletionBackup || got.BackupDir != "/backups" {
+		t.Fatalf("unexpected options: %+v", got)
+	}
+}
+
+func TestSyncOptionsPolicyDefaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  queue.SyncOptions
+		conflict string
+		deletion string
+	}{
+		{name: "sync", options: queue.SyncOptions{Sync: true}, conflict: queue.SyncConflictOverwrite, deletion: queue.SyncDeletionDelete},
+		{name: "copy", options: queue.SyncOptions{}, conflict: queue.SyncConflictSkip, deletion: queue.SyncDeletionIgnore},
+		{name: "explicit", options: queue.SyncOptions{Sync: true, Conflict: queue.SyncConflictNewer, Deletion: queue.SyncDeletionIgnore}, conflict: queue.SyncConflictNewer, deletion: queue.SyncDeletionIgnore},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.options.ConflictPolicy(); got != tt.conflict {
+				t.Fatalf("ConflictPolicy() = %q, want %q", got, tt.conflict)
+			}
+			if got := tt.options.DeletionPolicy(); got != tt.deletion {
+				t.Fatalf("DeletionPolicy() = %q, want %q", got, tt.deletion)
+			}
+		})
+	}
+}
+
+func TestValidateSyncBackupDir(t *testing.T) {
+	tests := []struct {
+		name        string
+		backupDir   string
+		destination string
+		wantErr     bool
+	}{
+		{name: "sibling", backupDir: "/backups/photos", destination: "/photos"},
+		{name: "shared prefix", backupDir: "/photos-old", destination: "/photos"},
+		{name: "missing", backupDir: "", destination: "/photos", wantErr: true},
+		{name: "relative", backupDir: "backups", destination: "/photos", wantErr: true},
+		{name: "inside destination", backupDir: "/photos/.trash", destination: "/photos", wantErr: true},
+		{name: "contains destination", backupDir: "/", destination: "/photos", wantErr: true},
+		{name: "same folder", backupDir: "/photos/", destination: "/photos", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSyncBackupDir(tt.backupDir, tt.destination)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateSyncBackupDir(%q, %q) error = %v, wantErr %v", tt.backupDir, tt.destination, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeepBothOriginal(t *testing.T) {
+	tests := []struct {
+		rel    string
+		want   string
+		wantOK bool
+	}{
+		{rel: "a (1).txt", want: "a.txt", wantOK: true},
+		{rel: "docs/report (12).tar.gz", wantOK: false},
+		{rel: "docs/report.tar (12).gz", want: "docs/report.tar.gz", wantOK: true},
+		{rel: ".bashrc (2)", want: ".bashrc", wantOK: true},
+		{rel: "notes (2)", want: "notes", wantOK: true},
+		{rel: "a (0).txt", wantOK: false},
+		{rel: "a (x).txt", wantOK: false},
+		{rel: "a.txt", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			got, ok := keepBothOriginal(tt.rel)
+			if ok != tt.wantOK || (ok && got != tt.want) {
+				t.Fatalf("keepBothOriginal(%q) = %q, %v, want %q, %v", tt.rel, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSourceIsNewer(t *testing.T) {
+	updated := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	existing := &jetmodel.Files{UpdatedAt: updated}
+	if sourceIsNewer(existing, sourceFile{modified: updated.Add(500 * time.Millisecond)}) {
+		t.Fatal("same second must not count as newer")
+	}
+	if !sourceIsNewer(existing, sourceFile{modified: updated.Add(time.Second)}) {
+		t.Fatal("expected later source to be newer")
+	}
+	if !sourceIsNewer(existing, sourceFile{}) {
+		t.Fatal("expected source without a time to win")
+	}
+}
+
+func TestDestinationFingerprintMatchesWithoutSourceHash(t *testing.T) {
+	updated := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	size, hash := int64(5), "teldrive-blake3"
+	existing := &jetmodel.Files{Size: &size, Hash: &hash, UpdatedAt: updated}
+	if !destinationFingerprintMatches(existing, sourceFile{size: 5, modified: updated}) {
+		t.Fatal("expected an unchanged source without a hash to match on size and time")
+	}
+	if destinationFingerprintMatches(existing, sourceFile{size: 6, modified: updated}) {
+		t.Fatal("expected a size change to be copied again")
+	}
+	if destinationFingerprintMatches(existing, sourceFile{size: 5, modified: updated.Add(time.Minute)}) {
+		t.Fatal("expected a time change to be copied again")
+	}
+}
+
+func TestSyncTransferResumeFromUploadsAllowsGaps(t *testing.T) {
+	const partSize = 10
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Minute)
+	uploads := []jetmodel.Uploads{
+		{PartNo: 2, Size: 10, CreatedAt: &older},
+		{PartNo: 2, Size: 7, CreatedAt: &newer},
+		{PartNo: 3, Size: 10},
+		{PartNo: 4, Size: 5},
+		{PartNo: 9, Size: 10},
+	}
+	resume := syncTransferResumeFromUploads(uploads, 35, partSize, false)
+	if len(resume.completed) != 2 || resume.doneBytes != 15 {
+		t.Fatalf("unexpected resume state: %+v", resume)
+	}
+	for _, partNo := range []int{3, 4} {
+		if _, ok := resume.completed[partNo]; !ok {
+			t.Fatalf("expected part %d to be resumed: %+v", partNo, resume.completed)
+		}
+	}
+
+	if got := syncTransferResumeFromUploads(uploads, 35, partSize, true); len(got.completed) != 0 {
+		t.Fatalf("expected plain parts to be ignored for an encrypted upload: %+v", got)
+	}
+}
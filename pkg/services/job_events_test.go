@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/stretchr/testify/require"
+	"github.com/tgdrive/teldrive/internal/events"
+	"github.com/tgdrive/teldrive/pkg/dto"
+	"github.com/tgdrive/teldrive/pkg/queue"
+)
+
+type recordedEvent struct {
+	eventType events.EventType
+	userID    int64
+	source    *dto.Source
+}
+
+type recordingBroadcaster struct {
+	events.EventBroadcaster
+	recorded []recordedEvent
+}
+
+func (b *recordingBroadcaster) Record(eventType events.EventType, userID int64, source *dto.Source) {
+	b.recorded = append(b.recorded, recordedEvent{eventType: eventType, userID: userID, source: source})
+}
+
+func TestRecordJobEvents(t *testing.T) {
+	t.Parallel()
+
+	job := func(id int64, kind string, state rivertype.JobState, args string, errs ...string) *rivertype.JobRow {
+		row := &rivertype.JobRow{ID: id, Kind: kind, State: state, EncodedArgs: []byte(args)}
+		for _, e := range errs {
+			row.Errors = append(row.Errors, rivertype.AttemptError{Error: e})
+		}
+		return row
+	}
+
+	ch := make(chan *river.Event, 8)
+	ch <- &river.Event{Kind: river.EventKindJobCompleted, Job: job(1, queue.JobKindFilesCopy, rivertype.JobStateCompleted, `{"userId":7}`)}
+	ch <- &river.Event{Kind: river.EventKindJobFailed, Job: job(2, queue.JobKindFilesCopy, rivertype.JobStateRetryable, `{"userId":7}`, "first")}
+	ch <- &river.Event{Kind: river.EventKindJobFailed, Job: job(3, queue.JobKindFilesCopy, rivertype.JobStateDiscarded, `{"userId":7}`, "first", "last")}
+	ch <- &river.Event{Kind: river.EventKindJobCompleted, Job: job(4, queue.JobKindWebhookDeliver, rivertype.JobStateCompleted, `{"userId":7}`)}
+	ch <- &river.Event{Kind: river.EventKindJobCompleted, Job: job(5, queue.JobKindFilesCopy, rivertype.JobStateCompleted, `{}`)}
+	ch <- &river.Event{Kind: river.EventKindJobCancelled, Job: job(6, queue.JobKindFilesCopy, rivertype.JobStateCancelled, `{"userId":7}`, "quota exceeded")}
+	close(ch)
+
+	b := &recordingBroadcaster{}
+	(&apiService{events: b}).RecordJobEvents(ch)
+
+	require.Len(t, b.recorded, 3)
+	require.Equal(t, events.OpJobComplete, b.recorded[0].eventType)
+	require.Equal(t, int64(7), b.recorded[0].userID)
+	require.Equal(t, &dto.Source{ID: "1", Type: "job", Name: queue.JobKindFilesCopy, JobID: 1}, b.recorded[0].source)
+	require.Equal(t, events.OpJobFail, b.recorded[1].eventType)
+	require.Equal(t, int64(3), b.recorded[1].source.JobID)
+	require.Equal(t, "last", b.recorded[1].source.Error)
+	require.Equal(t, events.OpJobFail, b.recorded[2].eventType)
+	require.Equal(t, int64(6), b.recorded[2].source.JobID)
+	require.Equal(t, "quota exceeded", b.recorded[2].source.Error)
+}
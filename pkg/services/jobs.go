@@ -11,7 +11,9 @@ import (
 	"github.com/riverqueue/river/rivertype"
 	"github.com/tgdrive/teldrive/internal/api"
 	"github.com/tgdrive/teldrive/internal/auth"
+	"github.com/tgdrive/teldrive/internal/events"
 	"github.com/tgdrive/teldrive/internal/utils"
+	"github.com/tgdrive/teldrive/pkg/dto"
 	"github.com/tgdrive/teldrive/pkg/queue"
 )
 
@@ -137,12 +139,18 @@ func (a *apiService) JobsInsert(ctx context.Context, req *api.JobInsertRequest)
 	if !isAllowedInsertKind(req.Kind) {
 		return nil, &apiError{err: errors.New("unknown job kind"), code: 400}
 	}
+	if err := auth.CheckJobScope(auth.JWTUser(ctx), req.Kind); err != nil {
+		return nil, err
+	}
 
 	insertOpts := &river.InsertOpts{
 		UniqueOpts: river.UniqueOpts{ByArgs: true},
 	}
-	if req.Kind == queue.JobKindSyncRun {
+	switch req.Kind {
+	case queue.JobKindSyncRun:
 		insertOpts.MaxAttempts = a.syncRunMaxAttempts()
+	case queue.JobKindSyncPush:
+		insertOpts.MaxAttempts = a.syncPushMaxAttempts()
 	}
 	if req.Queue.IsSet() {
 		insertOpts.Queue = req.Queue.Value
@@ -172,7 +180,7 @@ func (a *apiService) JobsInsert(ctx context.Context, req *api.JobInsertRequest)
 
 func isAllowedInsertKind(kind string) bool {
 	switch kind {
-	case queue.JobKindSyncRun:
+	case queue.JobKindSyncRun, queue.JobKindSyncPush, queue.JobKindFilesCopy, queue.JobKindFilesMove, queue.JobKindFilesDelete:
 		return true
 	default:
 		return false
@@ -253,17 +261,53 @@ func (a *apiService) JobsDelete(ctx context.Context, params api.JobsDeleteParams
 	return nil
 }
 
-func jobOwnedByUser(row *rivertype.JobRow, userID int64) bool {
-	if userID == 0 {
-		return false
+// RecordJobEvents turns finished jobs from a River subscription into events
+// for the user that owns them, until the channel is closed.
+func (a *apiService) RecordJobEvents(ch <-chan *river.Event) {
+	for event := range ch {
+		if event.Job == nil || event.Job.Kind == queue.JobKindWebhookDeliver {
+			// Deliveries are skipped so a webhook on jobs.* cannot feed itself
+			continue
+		}
+		userID := jobUserID(event.Job)
+		if userID == 0 {
+			continue
+		}
+		source := &dto.Source{
+			ID:    strconv.FormatInt(event.Job.ID, 10),
+			Type:  "job",
+			Name:  event.Job.Kind,
+			JobID: event.Job.ID,
+		}
+		switch event.Kind {
+		case river.EventKindJobCompleted:
+			a.events.Record(events.OpJobComplete, userID, source)
+		case river.EventKindJobFailed, river.EventKindJobCancelled:
+			// Failed attempts that will be retried are not reported; jobs
+			// that gave up with river.JobCancel count as failed
+			if event.Kind == river.EventKindJobFailed && event.Job.State != rivertype.JobStateDiscarded {
+				continue
+			}
+			if len(event.Job.Errors) > 0 {
+				source.Error = event.Job.Errors[len(event.Job.Errors)-1].Error
+			}
+			a.events.Record(events.OpJobFail, userID, source)
+		}
 	}
+}
+
+func jobUserID(row *rivertype.JobRow) int64 {
 	var encoded struct {
 		UserID int64 `json:"userId"`
 	}
 	if err := json.Unmarshal(row.EncodedArgs, &encoded); err != nil {
-		return false
+		return 0
 	}
-	return encoded.UserID == userID
+	return encoded.UserID
+}
+
+func jobOwnedByUser(row *rivertype.JobRow, userID int64) bool {
+	return userID != 0 && jobUserID(row) == userID
 }
 
 func toJobStatus(row *rivertype.JobRow) *api.JobStatus {
@@ -16,6 +16,7 @@ import (
 	"github.com/tgdrive/teldrive/internal/api"
 	"github.com/tgdrive/teldrive/internal/auth"
 	internalduration "github.com/tgdrive/teldrive/internal/duration"
+	"github.com/tgdrive/teldrive/internal/utils"
 	"github.com/tgdrive/teldrive/pkg/queue"
 	"github.com/tgdrive/teldrive/pkg/repositories"
 )
@@ -26,8 +27,10 @@ const (
 	periodicJobKindCleanStaleUpload  = "clean.stale_uploads"
 	periodicJobKindCleanPendingFile  = "clean.pending_files"
 	periodicJobKindRefreshFolderSize = "refresh.folder_sizes"
+	periodicJobKindCleanFileVersions = "clean.file_versions"
 	defaultOldEventsRetention        = "5d"
 	defaultStaleUploadRetention      = "1d"
+	defaultFileVersionsKeep          = 10
 )
 
 type periodicJobRow struct {
@@ -75,6 +78,9 @@ func (a *apiService) PeriodicJobsList(ctx context.Context) ([]api.PeriodicJobSum
 
 func (a *apiService) PeriodicJobsCreate(ctx context.Context, req *api.PeriodicJobCreate) (*api.PeriodicJobDetail, error) {
 	userID := auth.User(ctx)
+	if err := auth.CheckJobScope(auth.JWTUser(ctx), periodicJobKindSyncRun); err != nil {
+		return nil, err
+	}
 	if err := validatePeriodicSyncArgs(req.Args); err != nil {
 		return nil, &apiError{err: err, code: 400}
 	}
@@ -133,6 +139,9 @@ func (a *apiService) PeriodicJobsUpdate(ctx context.Context, req *api.PeriodicJo
 	if err != nil {
 		return nil, err
 	}
+	if err := auth.CheckJobScope(auth.JWTUser(ctx), row.Kind); err != nil {
+		return nil, err
+	}
 	isSyncJob := row.Kind == periodicJobKindSyncRun
 
 	name := row.Name
@@ -218,6 +227,9 @@ func (a *apiService) PeriodicJobsRun(ctx context.Context, params api.PeriodicJob
 	if err != nil {
 		return nil, err
 	}
+	if err := auth.CheckJobScope(auth.JWTUser(ctx), row.Kind); err != nil {
+		return nil, err
+	}
 	job, err := a.insertPeriodicRuntimeJob(ctx, row)
 	if err != nil {
 		return nil, err
@@ -248,6 +260,65 @@ func (a *apiService) PeriodicJobsTestConnection(ctx context.Context, req *api.Sy
 	return nil
 }
 
+// PeriodicJobsPlan lists what a sync run with req would upload, skip and
+// delete. Nothing is queued or changed.
+func (a *apiService) PeriodicJobsPlan(ctx context.Context, req *api.SyncArgs) (*api.SyncPlan, error) {
+	if err := validatePeriodicSyncArgs(*req); err != nil {
+		return nil, &apiError{err: err, code: 400}
+	}
+	args := queue.SyncRunJobArgs{
+		UserID:         auth.User(ctx),
+		Source:         req.Source,
+		DestinationDir: req.DestinationDir,
+		Headers:        map[string]string(req.Headers.Or(map[string]string{})),
+		Proxy:          strings.TrimSpace(req.Proxy.Or("")),
+		Filters:        toQueueFilters(req.Filters),
+		Options:        toQueueOptions(req.Options),
+	}
+
+	exec := &jobExecutor{api: a}
+	destinationRoot, err := exec.resolveDestinationPath(ctx, args.UserID, args.DestinationDir)
+	if err != nil {
+		return nil, &apiError{err: err, code: 400}
+	}
+	files, err := exec.listSourceFiles(ctx, args)
+	if err != nil {
+		return nil, &apiError{err: err, code: 400}
+	}
+	preview, err := exec.previewSyncRun(ctx, args, destinationRoot, files)
+	if err != nil {
+		return nil, &apiError{err: err}
+	}
+
+	toItems := func(entries []syncPlanEntry) []api.SyncPlanItem {
+		return utils.Map(entries, func(entry syncPlanEntry) api.SyncPlanItem {
+			item := api.SyncPlanItem{Path: entry.src.relPath, Type: api.SyncPlanItemTypeFile, Size: api.NewOptInt64(entry.src.size), Reason: entry.reason, Action: entry.action}
+			if target := entry.target(); target != "" {
+				item.Target = api.NewOptString(target)
+			}
+			return item
+		})
+	}
+	plan := &api.SyncPlan{
+		Destination: destinationRoot,
+		Planned:     len(files),
+		Upload:      toItems(preview.upload),
+		Skip:        toItems(preview.skip),
+		Delete: utils.Map(preview.delete, func(entry syncDestinationEntry) api.SyncPlanItem {
+			action, target := preview.deleteAction(entry)
+			item := api.SyncPlanItem{Path: entry.rel, Type: api.SyncPlanItemType(entry.type_), Reason: api.SyncPlanReasonExtra, Action: action}
+			if target != "" {
+				item.Target = api.NewOptString(target)
+			}
+			return item
+		}),
+	}
+	for _, entry := range preview.upload {
+		plan.BytesPlanned += entry.src.size
+	}
+	return plan, nil
+}
+
 func (a *apiService) setPeriodicJobEnabled(ctx context.Context, id string, enabled bool) error {
 	row, err := a.getPeriodicJobRow(ctx, id, auth.User(ctx))
 	if err != nil {
@@ -307,6 +378,7 @@ func defaultPeriodicJobPresets() []periodicJobPreset {
 		{Name: "Clean Stale Uploads", Kind: periodicJobKindCleanStaleUpload, CronExpression: "0 */12 * * *", Args: defaultCleanStaleUploadsPeriodicArgs(), System: true},
 		{Name: "Clean Pending Files", Kind: periodicJobKindCleanPendingFile, CronExpression: "0 * * * *", Args: repositories.CleanPendingFilesPeriodicArgs{}, System: true},
 		{Name: "Refresh Folder Sizes", Kind: periodicJobKindRefreshFolderSize, CronExpression: "0 * * * *", Args: repositories.RefreshFolderSizesPeriodicArgs{}, System: true},
+		{Name: "Clean File Versions", Kind: periodicJobKindCleanFileVersions, CronExpression: "0 */12 * * *", Args: defaultCleanFileVersionsPeriodicArgs(), System: true},
 	}
 }
 
@@ -318,6 +390,10 @@ func defaultCleanStaleUploadsPeriodicArgs() repositories.CleanStaleUploadsPeriod
 	return repositories.CleanStaleUploadsPeriodicArgs{Retention: defaultStaleUploadRetention}
 }
 
+func defaultCleanFileVersionsPeriodicArgs() repositories.CleanFileVersionsPeriodicArgs {
+	return repositories.CleanFileVersionsPeriodicArgs{Keep: defaultFileVersionsKeep}
+}
+
 func normalizePeriodicJobArgs(kind string, args repositories.PeriodicJobArgs) repositories.PeriodicJobArgs {
 	switch kind {
 	case periodicJobKindCleanOldEvents:
@@ -326,6 +402,8 @@ func normalizePeriodicJobArgs(kind string, args repositories.PeriodicJobArgs) re
 		return normalizeCleanStaleUploadsPeriodicArgs(args)
 	case periodicJobKindRefreshFolderSize:
 		return repositories.RefreshFolderSizesPeriodicArgs{}
+	case periodicJobKindCleanFileVersions:
+		return normalizeCleanFileVersionsPeriodicArgs(args)
 	default:
 		return args
 	}
@@ -365,6 +443,20 @@ func normalizeCleanStaleUploadsPeriodicArgs(args repositories.PeriodicJobArgs) r
 	return defaultArgs
 }
 
+func normalizeCleanFileVersionsPeriodicArgs(args repositories.PeriodicJobArgs) repositories.CleanFileVersionsPeriodicArgs {
+	switch v := args.(type) {
+	case repositories.CleanFileVersionsPeriodicArgs:
+		if v.Keep >= 0 {
+			return v
+		}
+	case *repositories.CleanFileVersionsPeriodicArgs:
+		if v != nil && v.Keep >= 0 {
+			return *v
+		}
+	}
+	return defaultCleanFileVersionsPeriodicArgs()
+}
+
 func normalizeRetentionString(raw string) (string, bool) {
 	d, err := internalduration.ParseDuration(strings.TrimSpace(raw))
 	if err != nil || d <= 0 {
@@ -434,6 +526,17 @@ func maintenanceArgsUpdateFromAPI(kind string, rawArgs api.PeriodicJobUpdateArgs
 		return nil, &apiError{err: errors.New("args cannot be updated for clean.pending_files jobs"), code: 400}
 	case periodicJobKindRefreshFolderSize:
 		return nil, &apiError{err: errors.New("args cannot be updated for refresh.folder_sizes jobs"), code: 400}
+	case periodicJobKindCleanFileVersions:
+		var args struct {
+			Keep *int `json:"keep"`
+		}
+		if err := json.Unmarshal(b, &args); err != nil {
+			return nil, &apiError{err: errors.New("invalid maintenance args payload"), code: 400}
+		}
+		if args.Keep == nil || *args.Keep < 0 {
+			return nil, &apiError{err: errors.New("keep must be a number of versions, zero or greater"), code: 400}
+		}
+		return repositories.CleanFileVersionsPeriodicArgs{Keep: *args.Keep}, nil
 	default:
 		return nil, &apiError{err: errors.New("args can only be updated for supported periodic jobs"), code: 400}
 	}
@@ -606,6 +709,9 @@ func validatePeriodicSyncArgs(args api.SyncArgs) error {
 	if !strings.HasPrefix(args.DestinationDir, "/") {
 		return fmt.Errorf("destinationDir must start with '/'")
 	}
+	if opts, ok := args.Options.Get(); ok && opts.Deletion.Or("") == api.SyncDeletionPolicyBackup {
+		return validateSyncBackupDir(strings.TrimSpace(opts.BackupDir.Or("")), args.DestinationDir)
+	}
 	return nil
 }
 
@@ -729,6 +835,9 @@ func (a *apiService) runtimePeriodicInsert(row *periodicJobRow) (river.JobArgs,
 		return queue.CleanPendingFilesArgs{UserID: row.UserID}, &river.InsertOpts{}, nil
 	case periodicJobKindRefreshFolderSize:
 		return queue.RefreshFolderSizesArgs{UserID: row.UserID}, &river.InsertOpts{}, nil
+	case periodicJobKindCleanFileVersions:
+		versionsArgs := normalizeCleanFileVersionsPeriodicArgs(row.Args)
+		return queue.CleanFileVersionsArgs{UserID: row.UserID, Keep: versionsArgs.Keep}, &river.InsertOpts{}, nil
 	default:
 		return nil, nil, &apiError{err: fmt.Errorf("unsupported periodic job kind: %s", row.Kind), code: 400}
 	}
@@ -776,6 +885,18 @@ func syncRunArgsFromAPI(v api.SyncArgs) repositories.SyncRunPeriodicArgs {
 			sync := v.Options.Value.Sync.Value
 			o.Sync = &sync
 		}
+		if v.Options.Value.Conflict.IsSet() {
+			conflict := string(v.Options.Value.Conflict.Value)
+			o.Conflict = &conflict
+		}
+		if v.Options.Value.Deletion.IsSet() {
+			deletion := string(v.Options.Value.Deletion.Value)
+			o.Deletion = &deletion
+		}
+		if v.Options.Value.BackupDir.IsSet() {
+			backupDir := v.Options.Value.BackupDir.Value
+			o.BackupDir = &backupDir
+		}
 		out.Options = &o
 	}
 	return out
@@ -810,6 +931,15 @@ func apiSyncArgsFromDomain(v repositories.SyncRunPeriodicArgs) api.SyncArgs {
 		if v.Options.Sync != nil {
 			o.Sync = api.NewOptBool(*v.Options.Sync)
 		}
+		if v.Options.Conflict != nil {
+			o.Conflict = api.NewOptSyncConflictPolicy(api.SyncConflictPolicy(*v.Options.Conflict))
+		}
+		if v.Options.Deletion != nil {
+			o.Deletion = api.NewOptSyncDeletionPolicy(api.SyncDeletionPolicy(*v.Options.Deletion))
+		}
+		if v.Options.BackupDir != nil {
+			o.BackupDir = api.NewOptString(*v.Options.BackupDir)
+		}
 		out.Options = api.NewOptSyncOptions(o)
 	}
 	return out
@@ -0,0 +1,361 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/riverqueue/river"
+	"github.com/tgdrive/teldrive/internal/api"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/logging"
+	"github.com/tgdrive/teldrive/pkg/queue"
+	"github.com/tgdrive/teldrive/pkg/remotes"
+	"go.uber.org/zap"
+)
+
+type pushFile struct {
+	src  sourceFile
+	file *jetmodel.Files
+}
+
+type syncPushPlan struct {
+	planned      int
+	transferred  int
+	skipped      int
+	deleted      int
+	bytesPlanned int64
+}
+
+// SyncPush mirrors a Teldrive folder to a writable remote. Files are read
+// from Telegram through the stream reader and written one after another, so
+// a retried job skips everything that already matches on the remote.
+func (e *jobExecutor) SyncPush(ctx context.Context, args queue.SyncPushJobArgs, jobID int64) error {
+	if args.UserID == 0 {
+		return fmt.Errorf("missing user id")
+	}
+	if strings.TrimSpace(args.Destination) == "" {
+		return fmt.Errorf("missing destination")
+	}
+	if err := validateSyncPushPolicies(args.Options); err != nil {
+		return err
+	}
+
+	fs, err := remoteFSForSource(args.Destination)
+	if err != nil {
+		return err
+	}
+	dst, ok := fs.(remotes.WritableFS)
+	if !ok {
+		return fmt.Errorf("destination %q is not writable", args.Destination)
+	}
+
+	workingCtx, err := e.workingContext(ctx, args.UserID)
+	if err != nil {
+		return err
+	}
+
+	sourceRoot, err := e.resolveDestinationPath(workingCtx, args.UserID, args.SourceDir)
+	if err != nil {
+		return err
+	}
+	files, err := e.listPushSourceFiles(workingCtx, args, sourceRoot)
+	if err != nil {
+		return err
+	}
+
+	if err := dst.Mkdir(workingCtx, "", args.Headers, args.Proxy); err != nil {
+		return err
+	}
+	remoteEntries, err := dst.List(workingCtx, "", args.Headers, args.Proxy)
+	if err != nil {
+		return err
+	}
+	remoteByPath := make(map[string]remotes.Entry, len(remoteEntries))
+	for _, entry := range remoteEntries {
+		remoteByPath[entry.RelPath] = entry
+	}
+
+	plan := &syncPushPlan{}
+	sourceFiles := make([]sourceFile, 0, len(files))
+	for _, f := range files {
+		sourceFiles = append(sourceFiles, f.src)
+	}
+	sourceDirs := impliedSourceDirs(sourceFiles)
+	if args.Options.DeletionPolicy() == queue.SyncDeletionDelete {
+		deleted, err := e.pruneRemoteExtras(workingCtx, dst, args, files, sourceDirs, remoteEntries)
+		if err != nil {
+			return err
+		}
+		plan.deleted = deleted
+	}
+
+	conflict := args.Options.ConflictPolicy()
+	pending := make([]pushFile, 0, len(files))
+	for _, f := range files {
+		plan.planned++
+		existing, exists := remoteByPath[f.src.relPath]
+		if exists && (pushFingerprintMatches(f.src, existing) || conflict == queue.SyncConflictSkip ||
+			(conflict == queue.SyncConflictNewer && !pushSourceIsNewer(f.src, existing))) {
+			plan.skipped++
+			continue
+		}
+		pending = append(pending, f)
+		plan.bytesPlanned += f.src.size
+	}
+
+	dirs := make([]string, 0, len(sourceDirs))
+	for dir := range sourceDirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		if err := dst.Mkdir(workingCtx, dir, args.Headers, args.Proxy); err != nil {
+			return err
+		}
+	}
+
+	if err := e.pushFiles(workingCtx, dst, args, pending, plan, jobID); err != nil {
+		return err
+	}
+
+	return river.RecordOutput(workingCtx, map[string]any{
+		"progress": map[string]any{"total": plan.bytesPlanned, "done": plan.bytesPlanned, "percent": 100},
+		"data": map[string]any{
+			"source":       sourceRoot,
+			"destination":  args.Destination,
+			"planned":      plan.planned,
+			"transferred":  plan.transferred,
+			"skipped":      plan.skipped,
+			"deleted":      plan.deleted,
+			"bytesPlanned": plan.bytesPlanned,
+		},
+	})
+}
+
+// listPushSourceFiles walks the Teldrive folder at root and returns its files
+// that pass the job filters, keyed by their path relative to root.
+func (e *jobExecutor) listPushSourceFiles(ctx context.Context, args queue.SyncPushJobArgs, root string) ([]pushFile, error) {
+	rootID, err := e.api.repo.Files.ResolvePathID(ctx, root, args.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("resolve source folder %q: %w", root, err)
+	}
+	folder, err := e.api.repo.Files.GetByIDAndUser(ctx, *rootID, args.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if folder.Type != string(api.FileTypeFolder) {
+		return nil, fmt.Errorf("source %q is not a folder", root)
+	}
+
+	byPath := make(map[string]*jetmodel.Files)
+	entries := make([]remotes.Entry, 0, 128)
+	var walk func(id uuid.UUID, dir string) error
+	walk = func(id uuid.UUID, dir string) error {
+		children, err := e.api.listFolder(ctx, args.UserID, id)
+		if err != nil {
+			return err
+		}
+		for i := range children {
+			child := &children[i]
+			rel := path.Join(dir, child.Name)
+			if child.Type == string(api.FileTypeFolder) {
+				if err := walk(child.ID, rel); err != nil {
+					return err
+				}
+				continue
+			}
+			entry := remotes.Entry{
+				RelPath:    rel,
+				SourcePath: child.ID.String(),
+				Name:       child.Name,
+				MimeType:   child.MimeType,
+				ModifiedAt: child.UpdatedAt.UTC(),
+			}
+			if child.Size != nil {
+				entry.Size = *child.Size
+			}
+			if child.Hash != nil {
+				entry.Hash = *child.Hash
+			}
+			byPath[rel] = child
+			entries = append(entries, entry)
+		}
+		return nil
+	}
+	if err := walk(*rootID, ""); err != nil {
+		return nil, err
+	}
+
+	entries = filterSourceEntries(entries, args.Filters)
+	out := make([]pushFile, 0, len(entries))
+	for _, entry := range entries {
+		out = append(out, pushFile{
+			src: sourceFile{
+				relPath:   entry.RelPath,
+				sourceURI: root,
+				size:      entry.Size,
+				name:      entry.Name,
+				mimeType:  entry.MimeType,
+				hash:      entry.Hash,
+				modified:  entry.ModifiedAt,
+				fullPath:  entry.SourcePath,
+			},
+			file: byPath[entry.RelPath],
+		})
+	}
+	return out, nil
+}
+
+// pruneRemoteExtras removes remote files that are not in the pushed set,
+// then the remote directories that no pushed file lives in, deepest first.
+func (e *jobExecutor) pruneRemoteExtras(ctx context.Context, dst remotes.WritableFS, args queue.SyncPushJobArgs, files []pushFile, sourceDirs map[string]struct{}, remoteEntries []remotes.Entry) (int, error) {
+	sourceSet := make(map[string]struct{}, len(files))
+	for _, f := range files {
+		sourceSet[f.src.relPath] = struct{}{}
+	}
+	remoteFiles := make([]sourceFile, 0, len(remoteEntries))
+	deleted := 0
+	for _, entry := range remoteEntries {
+		remoteFiles = append(remoteFiles, sourceFile{relPath: entry.RelPath})
+		if _, ok := sourceSet[entry.RelPath]; ok {
+			continue
+		}
+		if err := dst.Remove(ctx, entry.RelPath, args.Headers, args.Proxy); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	extraDirs := make([]string, 0)
+	for dir := range impliedSourceDirs(remoteFiles) {
+		if _, ok := sourceDirs[dir]; !ok {
+			extraDirs = append(extraDirs, dir)
+		}
+	}
+	sort.Slice(extraDirs, func(i, j int) bool {
+		return strings.Count(extraDirs[i], "/") > strings.Count(extraDirs[j], "/")
+	})
+	for _, dir := range extraDirs {
+		if err := dst.Remove(ctx, dir, args.Headers, args.Proxy); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// pushFiles writes pending to the remote. A Telegram client is only started
+// when at least one file has content to stream.
+func (e *jobExecutor) pushFiles(ctx context.Context, dst remotes.WritableFS, args queue.SyncPushJobArgs, pending []pushFile, plan *syncPushPlan, jobID int64) error {
+	if len(pending) == 0 {
+		return nil
+	}
+	progress := newSyncTransferProgress(ctx, e.api, jobID, plan.bytesPlanned)
+	var done int64
+
+	push := func(ctx context.Context, client TelegramClient, botID string) error {
+		for _, f := range pending {
+			var r io.Reader = strings.NewReader("")
+			var lr io.ReadCloser
+			if f.src.size > 0 {
+				file, err := e.api.fileWithParts(ctx, f.file.ID, args.UserID)
+				if err != nil {
+					return err
+				}
+				lr, err = e.api.openFileReader(ctx, client, botID, file, 0, f.src.size-1)
+				if err != nil {
+					return err
+				}
+				results := []map[string]any{{"path": f.src.relPath, "size": f.src.size, "success": true}}
+				r = newProgressReader(newContextReader(ctx, lr), progress.track(done, f.src.size, results))
+			}
+			err := dst.Create(ctx, f.src.relPath, r, f.src.size, f.src.modified, args.Headers, args.Proxy)
+			if lr != nil {
+				_ = lr.Close()
+			}
+			if err != nil {
+				return fmt.Errorf("push %q: %w", f.src.relPath, err)
+			}
+			done += f.src.size
+			plan.transferred++
+		}
+		return nil
+	}
+
+	needsTelegram := false
+	for _, f := range pending {
+		if f.src.size > 0 {
+			needsTelegram = true
+			break
+		}
+	}
+	if !needsTelegram {
+		return push(ctx, nil, "")
+	}
+
+	session, err := latestTGSession(ctx, e.api, args.UserID)
+	if err != nil {
+		return err
+	}
+	logger := logging.FromContext(ctx).With(zap.Int64("user_id", args.UserID), zap.String("destination", args.Destination))
+	client, token, botID, err := e.api.streamClient(ctx, &jetmodel.Sessions{UserID: args.UserID, TgSession: session}, logger)
+	if err != nil {
+		return err
+	}
+	return e.api.telegram.RunWithAuth(ctx, client, token, func(ctx context.Context) error {
+		return push(ctx, client, botID)
+	})
+}
+
+// pushFingerprintMatches reports whether the remote copy dst is current. Hashes
+// are compared when both sides have one. Otherwise the sizes must match and
+// the remote copy must be at least as new as the Teldrive file, since some
+// remotes record the upload time instead of the pushed modification time.
+// validateSyncPushPolicies rejects the policies a push cannot honour. The
+// remote has no folder to back files up into, and keep-both copies would be
+// deleted again by the next run that prunes extras.
+func validateSyncPushPolicies(opts queue.SyncOptions) error {
+	switch conflict := opts.ConflictPolicy(); conflict {
+	case queue.SyncConflictOverwrite, queue.SyncConflictSkip, queue.SyncConflictNewer:
+	case queue.SyncConflictKeepBoth:
+		return fmt.Errorf("conflict policy %q is not supported by sync.push", conflict)
+	default:
+		return fmt.Errorf("unknown conflict policy %q", opts.Conflict)
+	}
+	switch deletion := opts.DeletionPolicy(); deletion {
+	case queue.SyncDeletionDelete, queue.SyncDeletionIgnore:
+	case queue.SyncDeletionBackup:
+		return fmt.Errorf("deletion policy %q is not supported by sync.push", deletion)
+	default:
+		return fmt.Errorf("unknown deletion policy %q", opts.Deletion)
+	}
+	return nil
+}
+
+// pushSourceIsNewer reports whether the Teldrive file was modified after the
+// remote copy. Missing times count as newer so the file is pushed.
+func pushSourceIsNewer(src sourceFile, dst remotes.Entry) bool {
+	if src.modified.IsZero() || dst.ModifiedAt.IsZero() {
+		return true
+	}
+	return src.modified.UTC().Unix() > dst.ModifiedAt.UTC().Unix()
+}
+
+func pushFingerprintMatches(src sourceFile, dst remotes.Entry) bool {
+	if src.hash != "" && dst.Hash != "" {
+		return src.hash == dst.Hash
+	}
+	if src.size != dst.Size {
+		return false
+	}
+	if src.modified.IsZero() || dst.ModifiedAt.IsZero() {
+		return false
+	}
+	return !dst.ModifiedAt.Before(src.modified.Truncate(time.Second))
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tgdrive/teldrive/pkg/queue"
+	"github.com/tgdrive/teldrive/pkg/remotes"
+)
+
+func TestPushFingerprintMatches(t *testing.T) {
+	t.Parallel()
+
+	modified := time.Date(2024, 5, 6, 7, 8, 9, 500, time.UTC)
+	src := sourceFile{relPath: "a/b.txt", size: 10, modified: modified}
+
+	tests := []struct {
+		name string
+		src  sourceFile
+		dst  remotes.Entry
+		want bool
+	}{
+		{name: "same size and time", src: src, dst: remotes.Entry{Size: 10, ModifiedAt: modified.Truncate(time.Second)}, want: true},
+		{name: "remote newer", src: src, dst: remotes.Entry{Size: 10, ModifiedAt: modified.Add(time.Hour)}, want: true},
+		{name: "remote older", src: src, dst: remotes.Entry{Size: 10, ModifiedAt: modified.Add(-time.Hour)}, want: false},
+		{name: "size differs", src: src, dst: remotes.Entry{Size: 11, ModifiedAt: modified}, want: false},
+		{name: "no remote time", src: src, dst: remotes.Entry{Size: 10}, want: false},
+		{name: "hashes win", src: sourceFile{size: 10, hash: "abc", modified: modified}, dst: remotes.Entry{Size: 99, Hash: "abc"}, want: true},
+		{name: "hash mismatch", src: sourceFile{size: 10, hash: "abc", modified: modified}, dst: remotes.Entry{Size: 10, Hash: "def", ModifiedAt: modified}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, pushFingerprintMatches(tt.src, tt.dst))
+		})
+	}
+}
+
+func TestValidateSyncPushPolicies(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		opts    queue.SyncOptions
+		wantErr bool
+	}{
+		{name: "defaults with sync", opts: queue.SyncOptions{Sync: true}},
+		{name: "defaults without sync", opts: queue.SyncOptions{}},
+		{name: "sync without deletion", opts: queue.SyncOptions{Sync: true, Deletion: queue.SyncDeletionIgnore}},
+		{name: "newer", opts: queue.SyncOptions{Conflict: queue.SyncConflictNewer}},
+		{name: "keep both", opts: queue.SyncOptions{Conflict: queue.SyncConflictKeepBoth}, wantErr: true},
+		{name: "backup", opts: queue.SyncOptions{Deletion: queue.SyncDeletionBackup, BackupDir: "/old"}, wantErr: true},
+		{name: "unknown conflict", opts: queue.SyncOptions{Conflict: "merge"}, wantErr: true},
+		{name: "unknown deletion", opts: queue.SyncOptions{Deletion: "archive"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateSyncPushPolicies(tt.opts)
+			require.Equal(t, tt.wantErr, err != nil, "err = %v", err)
+		})
+	}
+}
@@ -7,11 +7,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/riverqueue/river"
 	"github.com/tgdrive/teldrive/internal/auth"
-	"github.com/tgdrive/teldrive/internal/config"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
 	internalduration "github.com/tgdrive/teldrive/internal/duration"
-	"github.com/tgdrive/teldrive/internal/tgc"
 	"github.com/tgdrive/teldrive/pkg/queue"
 	"github.com/tgdrive/teldrive/pkg/repositories"
 )
@@ -25,12 +25,16 @@ func NewJobExecutor(apiSvc *apiService) queue.Executor {
 }
 
 func writeJobProgress(ctx context.Context, done, total int, results []map[string]any) error {
+	return river.RecordOutput(ctx, jobProgressOutput(done, total, results))
+}
+
+func jobProgressOutput(done, total int, results []map[string]any) map[string]any {
 	percent := 0
 	if total > 0 {
 		percent = int(float64(done) * 100.0 / float64(total))
 	}
 
-	return river.RecordOutput(ctx, map[string]any{
+	return map[string]any{
 		"progress": map[string]any{
 			"total":   total,
 			"done":    done,
@@ -41,7 +45,7 @@ func writeJobProgress(ctx context.Context, done, total int, results []map[string
 			"updatedAt":   time.Now().UTC(),
 			"isCompleted": done == total,
 		},
-	})
+	}
 }
 
 func writeJobByteProgress(ctx context.Context, done, total int64, results []map[string]any) error {
@@ -127,7 +131,7 @@ func (e *jobExecutor) CleanStaleUploadsForUser(ctx context.Context, args queue.C
 
 	groups := groupStaleUploads(filtered, sessionByUser)
 	for key, group := range groups {
-		if err := deleteChannelMessages(ctx, &e.api.cnf.TG, key.Session, key.ChannelID, group.partIDs); err != nil {
+		if err := e.api.deleteChannelMessages(ctx, key.Session, key.ChannelID, group.partIDs); err != nil {
 			return err
 		}
 		if err := e.api.repo.Uploads.DeleteParts(ctx, key.ChannelID, group.userID, group.partIDs); err != nil {
@@ -186,6 +190,11 @@ type pendingFileGroup struct {
 }
 
 func (e *jobExecutor) CleanPendingFilesForUser(ctx context.Context, userID int64) error {
+	expiredBefore := time.Now().UTC().Add(-e.api.trashRetention())
+	if err := e.api.repo.Files.PurgeTrashed(ctx, userID, nil, &expiredBefore); err != nil {
+		return err
+	}
+
 	rows, err := e.api.repo.Files.ListPendingForDeletion(ctx)
 	if err != nil {
 		return err
@@ -204,6 +213,14 @@ func (e *jobExecutor) CleanPendingFilesForUser(ctx context.Context, userID int64
 		return nil
 	}
 
+	versions, err := e.api.repo.FileVersions.ListPendingForDeletion(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, version := range versions {
+		filtered = append(filtered, pendingFileFromVersion(version))
+	}
+
 	sessionByUser, err := latestSessionsByUsers(ctx, e.api, []int64{userID})
 	if err != nil {
 		return err
@@ -211,7 +228,11 @@ func (e *jobExecutor) CleanPendingFilesForUser(ctx context.Context, userID int64
 
 	groups := groupPendingFiles(filtered, sessionByUser)
 	for key, group := range groups {
-		if err := deleteChannelMessages(ctx, &e.api.cnf.TG, key.Session, key.ChannelID, group.partIDs); err != nil {
+		partIDs, err := e.api.unreferencedParts(ctx, key.UserID, key.ChannelID, group.partIDs)
+		if err != nil {
+			return err
+		}
+		if err := e.api.deleteChannelMessages(ctx, key.Session, key.ChannelID, partIDs); err != nil {
 			return err
 		}
 	}
@@ -222,6 +243,72 @@ func (e *jobExecutor) CleanPendingFilesForUser(ctx context.Context, userID int64
 	return nil
 }
 
+// CleanFileVersionsForUser deletes all but the newest args.Keep versions of
+// every file, together with the Telegram messages that hold their parts.
+func (e *jobExecutor) CleanFileVersionsForUser(ctx context.Context, args queue.CleanFileVersionsArgs) error {
+	if args.Keep < 0 {
+		return fmt.Errorf("keep must be zero or greater")
+	}
+
+	versions, err := e.api.repo.FileVersions.ListExcess(ctx, args.UserID, args.Keep)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+
+	sessionByUser, err := latestSessionsByUsers(ctx, e.api, []int64{args.UserID})
+	if err != nil {
+		return err
+	}
+	if sessionByUser[args.UserID] == "" {
+		return nil
+	}
+
+	rows := make([]repositories.PendingFile, 0, len(versions))
+	ids := make([]uuid.UUID, 0, len(versions))
+	for _, version := range versions {
+		rows = append(rows, pendingFileFromVersion(version))
+		ids = append(ids, version.ID)
+	}
+
+	// The versions are removed first so that their own parts no longer count
+	// as references. Messages left behind by a failed delete are orphans that
+	// the check command picks up.
+	if err := e.api.repo.FileVersions.DeleteByIDs(ctx, ids); err != nil {
+		return err
+	}
+
+	groups := groupPendingFiles(rows, sessionByUser)
+	for key, group := range groups {
+		partIDs, err := e.api.unreferencedParts(ctx, key.UserID, key.ChannelID, group.partIDs)
+		if err != nil {
+			return err
+		}
+		if err := e.api.deleteChannelMessages(ctx, key.Session, key.ChannelID, partIDs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func pendingFileFromVersion(version jetmodel.FileVersions) repositories.PendingFile {
+	row := repositories.PendingFile{
+		ID:        version.FileID.String(),
+		ChannelID: version.ChannelID,
+		UserID:    version.UserID,
+	}
+	if version.Parts != nil {
+		if b, err := json.Marshal(version.Parts.Data); err == nil {
+			parts := string(b)
+			row.Parts = &parts
+		}
+	}
+	return row
+}
+
 func (e *jobExecutor) RefreshFolderSizesForUser(ctx context.Context, userID int64) error {
 	return e.api.repo.Files.RefreshFolderSizesByUser(ctx, userID)
 }
@@ -291,14 +378,13 @@ func latestSessionsByUsers(ctx context.Context, apiSvc *apiService, userIDs []in
 	return out, nil
 }
 
-func deleteChannelMessages(ctx context.Context, tgConfig *config.TGConfig, session string, channelID int64, ids []int) error {
+func (a *apiService) deleteChannelMessages(ctx context.Context, session string, channelID int64, ids []int) error {
 	if session == "" || len(ids) == 0 {
 		return nil
 	}
-	middlewares := tgc.NewMiddleware(tgConfig, tgc.WithFloodWait(), tgc.WithRateLimit())
-	client, err := tgc.AuthClient(ctx, tgConfig, session, middlewares...)
+	client, err := a.telegram.AuthClient(ctx, session, 5)
 	if err != nil {
 		return err
 	}
-	return tgc.DeleteMessages(ctx, client, channelID, ids)
+	return a.telegram.DeleteMessages(ctx, client, channelID, ids)
 }
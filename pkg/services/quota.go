@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/tgdrive/teldrive/internal/api"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/pkg/repositories"
+)
+
+// storageUsage returns what counts against a user's quota: trashed files and
+// stored versions still take space until they are purged.
+func (a *apiService) storageUsage(ctx context.Context, userID int64) (repositories.UsageStats, error) {
+	usage, err := a.repo.Files.StorageUsage(ctx, userID)
+	if err != nil {
+		return repositories.UsageStats{}, err
+	}
+	return *usage, nil
+}
+
+// quotaUser returns the user when they have a storage limit, or nil when
+// nothing needs to be checked.
+func (a *apiService) quotaUser(ctx context.Context, userID int64) (*jetmodel.Users, error) {
+	user, err := a.repo.Users.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, &apiError{err: err}
+	}
+	if user.QuotaBytes == nil && user.QuotaFiles == nil {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// checkQuota returns a 507 error when adding files and bytes would take the
+// user over one of their storage limits. With nothing to add it only fails
+// when the user is already over a limit.
+func (a *apiService) checkQuota(ctx context.Context, userID, files, bytes int64) error {
+	user, err := a.quotaUser(ctx, userID)
+	if err != nil || user == nil {
+		return err
+	}
+	return a.enforceQuota(ctx, user, files, bytes)
+}
+
+func (a *apiService) enforceQuota(ctx context.Context, user *jetmodel.Users, files, bytes int64) error {
+	usage, err := a.storageUsage(ctx, user.UserID)
+	if err != nil {
+		return &apiError{err: err}
+	}
+	if user.QuotaFiles != nil && usage.TotalFiles+files > *user.QuotaFiles {
+		return &apiError{err: fmt.Errorf("file quota exceeded: %d of %d files used", usage.TotalFiles, *user.QuotaFiles), code: http.StatusInsufficientStorage}
+	}
+	if user.QuotaBytes != nil && usage.TotalSize+bytes > *user.QuotaBytes {
+		return &apiError{err: fmt.Errorf("storage quota exceeded: %d of %d bytes used", usage.TotalSize, *user.QuotaBytes), code: http.StatusInsufficientStorage}
+	}
+	return nil
+}
+
+// isQuotaError reports whether err is a quota rejection from checkQuota.
+func isQuotaError(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.code == http.StatusInsufficientStorage
+}
+
+// checkUploadQuota counts the parts already staged for an upload together
+// with the incoming one, so a large file is rejected before it is finished.
+func (a *apiService) checkUploadQuota(ctx context.Context, userID int64, uploadID string, size int64) error {
+	user, err := a.quotaUser(ctx, userID)
+	if err != nil || user == nil {
+		return err
+	}
+	parts, err := a.repo.Uploads.GetByUploadID(ctx, uploadID)
+	if err != nil {
+		return &apiError{err: err}
+	}
+	for _, part := range parts {
+		size += part.Size
+	}
+	return a.enforceQuota(ctx, user, 0, size)
+}
+
+// checkCopyQuota checks the quota for a copy of file, which for a folder
+// includes every file below it.
+func (a *apiService) checkCopyQuota(ctx context.Context, userID int64, file *jetmodel.Files) error {
+	if file.Type != string(api.FileTypeFolder) {
+		var size int64
+		if file.Size != nil {
+			size = *file.Size
+		}
+		return a.checkQuota(ctx, userID, 1, size)
+	}
+	stats, err := a.repo.Files.SubtreeStats(ctx, file.ID, userID)
+	if err != nil {
+		return &apiError{err: err}
+	}
+	return a.checkQuota(ctx, userID, stats.TotalFiles, stats.TotalSize)
+}
+
+func toUserQuota(user *jetmodel.Users) api.UserQuota {
+	var quota api.UserQuota
+	if user.QuotaBytes != nil {
+		quota.MaxBytes = api.NewOptInt64(*user.QuotaBytes)
+	}
+	if user.QuotaFiles != nil {
+		quota.MaxFiles = api.NewOptInt64(*user.QuotaFiles)
+	}
+	return quota
+}
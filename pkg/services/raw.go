@@ -2,10 +2,13 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"strconv"
 	"strings"
 	"time"
@@ -21,6 +24,7 @@ import (
 	"github.com/tgdrive/teldrive/internal/md5"
 	"github.com/tgdrive/teldrive/internal/reader"
 	"github.com/tgdrive/teldrive/pkg/mapper"
+	"github.com/tgdrive/teldrive/pkg/repositories"
 	"github.com/tgdrive/teldrive/pkg/types"
 	"go.uber.org/zap"
 )
@@ -54,6 +58,9 @@ func (s *rawService) EventsEventsStream(ctx context.Context, params api.EventsEv
 	eventChan := s.api.events.Subscribe(userID, eventTypes)
 	defer s.api.events.Unsubscribe(userID, eventChan)
 	fmt.Fprintf(w, ": connected\n\n")
+	// Subscribed before replaying, so nothing falls between the stored and
+	// the live events. Live events that were already replayed are skipped.
+	replayed := s.replayEvents(ctx, w, userID, params.LastEventID.Or(""), eventTypes)
 	flusher.Flush()
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
@@ -65,12 +72,13 @@ func (s *rawService) EventsEventsStream(ctx context.Context, params api.EventsEv
 			if !ok {
 				return nil
 			}
-			data := mapper.ToEventOutFromDTO(event)
-			jsonData, err := data.MarshalJSON()
-			if err != nil {
+			if _, ok := replayed[event.ID]; ok {
+				delete(replayed, event.ID)
+				continue
+			}
+			if writeSSEEvent(w, mapper.ToEventOutFromDTO(event)) != nil {
 				continue
 			}
-			fmt.Fprintf(w, "data: %s\n\n", jsonData)
 			flusher.Flush()
 		case <-ticker.C:
 			fmt.Fprintf(w, ": keepalive\n\n")
@@ -79,9 +87,66 @@ func (s *rawService) EventsEventsStream(ctx context.Context, params api.EventsEv
 	}
 }
 
+// sseReplayPageSize bounds each query while replaying missed events.
+const sseReplayPageSize = 500
+
+// replayEvents writes the stored events of a user that came after
+// lastEventID and returns their IDs. Unknown or pruned IDs replay nothing.
+func (s *rawService) replayEvents(ctx context.Context, w io.Writer, userID int64, lastEventID string, eventTypes []events.EventType) map[string]struct{} {
+	id, err := uuid.Parse(lastEventID)
+	if err != nil {
+		return nil
+	}
+	logger := logging.Component("EVENT").With(zap.Int64("user_id", userID), zap.String("last_event_id", lastEventID))
+	last, err := s.api.repo.Events.GetByIDAndUserID(ctx, id, userID)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrNotFound) {
+			logger.Error("events.replay_lookup_failed", zap.Error(err))
+		}
+		return nil
+	}
+
+	types := make([]string, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		types = append(types, string(eventType))
+	}
+	replayed := make(map[string]struct{})
+	until := time.Now().UTC()
+	after := last.Seq
+	for {
+		rows, err := s.api.repo.Events.ListAfterSeq(ctx, userID, after, until, types, sseReplayPageSize)
+		if err != nil {
+			logger.Error("events.replay_failed", zap.Error(err))
+			return replayed
+		}
+		for _, row := range rows {
+			if err := writeSSEEvent(w, mapper.ToEventOut(row)); err != nil {
+				continue
+			}
+			replayed[row.ID.String()] = struct{}{}
+		}
+		if len(rows) < sseReplayPageSize {
+			return replayed
+		}
+		after = rows[len(rows)-1].Seq
+	}
+}
+
+func writeSSEEvent(w io.Writer, event api.Event) error {
+	data, err := event.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\ndata: %s\n\n", uuid.UUID(event.ID), data)
+	return err
+}
+
 func (s *rawService) FilesStream(ctx context.Context, params api.FilesStreamParams, w http.ResponseWriter) error {
 	user := auth.JWTUser(ctx)
 	session := &jetmodel.Sessions{UserID: auth.User(ctx), TgSession: user.TgSession}
+	if err := s.api.checkKeyFolder(ctx, uuid.UUID(params.ID)); err != nil {
+		return err
+	}
 	download := false
 	if v, ok := params.Download.Get(); ok && v == api.FilesStreamDownload1 {
 		download = true
@@ -102,14 +167,36 @@ func (s *rawService) SharesStream(ctx context.Context, params api.SharesStreamPa
 	return s.streamFile(ctx, w, uuid.UUID(params.FileId), session, "", download)
 }
 
+func (s *rawService) FilesStreamVersion(ctx context.Context, params api.FilesStreamVersionParams, w http.ResponseWriter) error {
+	user := auth.JWTUser(ctx)
+	session := &jetmodel.Sessions{UserID: auth.User(ctx), TgSession: user.TgSession}
+	if err := s.api.checkKeyFolder(ctx, uuid.UUID(params.ID)); err != nil {
+		return err
+	}
+	file, err := s.api.fileVersionContent(ctx, session.UserID, uuid.UUID(params.ID), uuid.UUID(params.VersionId))
+	if err != nil {
+		return err
+	}
+	download := false
+	if v, ok := params.Download.Get(); ok && v == api.FilesStreamVersionDownload1 {
+		download = true
+	}
+	return s.serveContent(ctx, w, file, session, params.Range.Or(""), download)
+}
+
 func (s *rawService) streamFile(ctx context.Context, w http.ResponseWriter, fileID uuid.UUID, session *jetmodel.Sessions, rawRange string, download bool) error {
-	logger := logging.Component("FILE").With(zap.String("file_id", fileID.String()), zap.Int64("user_id", session.UserID))
 	file, err := cache.Fetch(ctx, s.api.cache, cache.KeyFile(fileID.String()), 0, func() (*jetmodel.Files, error) {
 		return s.api.repo.Files.GetByID(ctx, fileID)
 	})
 	if err != nil {
 		return &apiError{err: err, code: http.StatusBadRequest}
 	}
+	return s.serveContent(ctx, w, file, session, rawRange, download)
+}
+
+// serveContent writes the content of file to w, honouring byte ranges.
+func (s *rawService) serveContent(ctx context.Context, w http.ResponseWriter, file *jetmodel.Files, session *jetmodel.Sessions, rawRange string, download bool) error {
+	logger := logging.Component("FILE").With(zap.String("file_id", file.ID.String()), zap.Int64("user_id", session.UserID))
 	w.Header().Set("Accept-Ranges", "bytes")
 	contentType := defaultContentType
 	if file.MimeType != "" {
@@ -122,12 +209,9 @@ func (s *rawService) streamFile(ctx context.Context, w http.ResponseWriter, file
 		w.WriteHeader(http.StatusOK)
 		return nil
 	}
-	var start, end int64
+	start, end := int64(0), *file.Size-1
 	status := http.StatusOK
-	if rawRange == "" {
-		start = 0
-		end = *file.Size - 1
-	} else {
+	if rawRange != "" {
 		ranges, err := http_range.Parse(rawRange, *file.Size)
 		if err == http_range.ErrNoOverlap {
 			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", *file.Size))
@@ -137,53 +221,143 @@ func (s *rawService) streamFile(ctx context.Context, w http.ResponseWriter, file
 			return &apiError{err: err, code: http.StatusBadRequest}
 		}
 		if len(ranges) > 1 {
-			return &apiError{err: fmt.Errorf("multiple ranges are not supported"), code: http.StatusRequestedRangeNotSatisfiable}
+			return s.serveRanges(ctx, w, file, session, ranges, contentType, download, logger)
+		}
+		if len(ranges) == 1 {
+			start = ranges[0].Start
+			end = ranges[0].End
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, *file.Size))
+			status = http.StatusPartialContent
 		}
-		start = ranges[0].Start
-		end = ranges[0].End
-		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, *file.Size))
-		status = http.StatusPartialContent
 	}
 	contentLength := end - start + 1
 	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Content-Length", strconv.FormatInt(contentLength, 10))
-	w.Header().Set("ETag", fmt.Sprintf("\"%s\"", md5.FromString(fileID.String()+strconv.FormatInt(*file.Size, 10))))
+	setContentHeaders(w, file, download)
+	w.WriteHeader(status)
+	client, token, botID, err := s.api.streamClient(ctx, session, logger)
+	if err != nil {
+		return err
+	}
+	handleStream := func() error {
+		lr, err := s.api.openFileReader(ctx, client, botID, file, start, end)
+		if err != nil {
+			return err
+		}
+		defer lr.Close()
+		_, _ = io.CopyN(w, lr, contentLength)
+		return nil
+	}
+	return s.api.telegram.RunWithAuth(ctx, client, token, func(ctx context.Context) error { return handleStream() })
+}
+
+// serveRanges writes several byte ranges of file as a multipart/byteranges
+// body. Each range gets its own reader, so only the parts it covers are
+// fetched from Telegram.
+func (s *rawService) serveRanges(ctx context.Context, w http.ResponseWriter, file *jetmodel.Files, session *jetmodel.Sessions, ranges []*http_range.Range, contentType string, download bool, logger *zap.Logger) error {
+	client, token, botID, err := s.api.streamClient(ctx, session, logger)
+	if err != nil {
+		return err
+	}
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	w.Header().Set("Content-Length", strconv.FormatInt(byteRangesLength(ranges, mw.Boundary(), contentType, *file.Size), 10))
+	setContentHeaders(w, file, download)
+	w.WriteHeader(http.StatusPartialContent)
+
+	err = s.api.telegram.RunWithAuth(ctx, client, token, func(ctx context.Context) error {
+		for _, r := range ranges {
+			part, err := mw.CreatePart(byteRangeHeader(r, contentType, *file.Size))
+			if err != nil {
+				return err
+			}
+			lr, err := s.api.openFileReader(ctx, client, botID, file, r.Start, r.End)
+			if err != nil {
+				return err
+			}
+			_, err = io.CopyN(part, lr, r.End-r.Start+1)
+			_ = lr.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return mw.Close()
+	})
+	if err != nil {
+		logger.Debug("stream.ranges_interrupted", zap.Int("ranges", len(ranges)), zap.Error(err))
+	}
+	return nil
+}
+
+func setContentHeaders(w http.ResponseWriter, file *jetmodel.Files, download bool) {
+	w.Header().Set("ETag", fmt.Sprintf("\"%s\"", md5.FromString(file.ID.String()+strconv.FormatInt(*file.Size, 10))))
 	w.Header().Set("Last-Modified", file.UpdatedAt.UTC().Format(http.TimeFormat))
 	disposition := "inline"
 	if download {
 		disposition = "attachment"
 	}
 	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": file.Name}))
-	w.WriteHeader(status)
-	tokens, err := s.api.channelManager.BotTokens(ctx, session.UserID)
+}
+
+func byteRangeHeader(r *http_range.Range, contentType string, size int64) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Type":  {contentType},
+		"Content-Range": {fmt.Sprintf("bytes %d-%d/%d", r.Start, r.End, size)},
+	}
+}
+
+// byteRangesLength returns the size of the multipart/byteranges body that
+// serveRanges writes for ranges, without reading any content.
+func byteRangesLength(ranges []*http_range.Range, boundary, contentType string, size int64) int64 {
+	var cw countingWriter
+	mw := multipart.NewWriter(&cw)
+	_ = mw.SetBoundary(boundary)
+	for _, r := range ranges {
+		_, _ = mw.CreatePart(byteRangeHeader(r, contentType, size))
+		cw += countingWriter(r.End - r.Start + 1)
+	}
+	_ = mw.Close()
+	return int64(cw)
+}
+
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
+
+// streamClient picks the Telegram client used to read file contents, preferring
+// the user's bots over their own session.
+func (a *apiService) streamClient(ctx context.Context, session *jetmodel.Sessions, logger *zap.Logger) (TelegramClient, string, string, error) {
+	tokens, err := a.channelManager.BotTokens(ctx, session.UserID)
 	if err != nil {
 		logger.Error("stream.bots_fetch_failed", zap.Error(err))
-		return &apiError{err: fmt.Errorf("failed to get bots")}
+		return nil, "", "", &apiError{err: fmt.Errorf("failed to get bots")}
 	}
-	if limit := s.api.cnf.TG.Stream.BotsLimit; limit > 0 && len(tokens) > limit {
+	if limit := a.cnf.TG.Stream.BotsLimit; limit > 0 && len(tokens) > limit {
 		tokens = tokens[:limit]
 	}
 	var (
-		lr     io.ReadCloser
 		client TelegramClient
 		token  string
 	)
 	if len(tokens) == 0 {
-		client, err = s.api.telegram.AuthClient(ctx, session.TgSession, 5)
+		client, err = a.telegram.AuthClient(ctx, session.TgSession, 5)
 		if err != nil {
 			logger.Error("stream.auth_client_failed", zap.Error(err))
-			return err
+			return nil, "", "", err
 		}
 	} else {
-		token, _, err = s.api.telegram.SelectBotToken(ctx, TelegramOpStream, session.UserID, tokens)
+		token, _, err = a.telegram.SelectBotToken(ctx, TelegramOpStream, session.UserID, tokens)
 		if err != nil {
 			logger.Error("stream.bot_selection_failed", zap.Error(err))
-			return err
+			return nil, "", "", err
 		}
-		client, err = s.api.telegram.BotClient(ctx, token, 5)
+		client, err = a.telegram.BotClient(ctx, token, 5)
 		if err != nil {
 			logger.Error("stream.bot_client_failed", zap.Error(err))
-			return err
+			return nil, "", "", err
 		}
 	}
 	botID := strconv.FormatInt(session.UserID, 10)
@@ -193,34 +367,50 @@ func (s *rawService) streamFile(ctx context.Context, w http.ResponseWriter, file
 			botID = parts[0]
 		}
 	}
-	handleStream := func() error {
-		if file.ChannelID == nil {
-			return fmt.Errorf("missing channel id")
-		}
-		parts, err := s.fetchParts(ctx, client, file.ID.String(), *file.ChannelID, mapper.ToAPIParts(file.Parts), file.Encrypted)
-		if err != nil {
-			return err
-		}
-		fileRef := &reader.FileRef{ID: file.ID.String(), ChannelID: *file.ChannelID, Encrypted: file.Encrypted}
-		lr, err = reader.NewReader(ctx, client.API(), s.api.cache, fileRef, parts, start, end, &s.api.cnf.TG, botID)
-		if err != nil {
-			return err
-		}
-		if lr == nil {
-			return fmt.Errorf("failed to initialise reader")
-		}
-		_, err = io.CopyN(w, lr, contentLength)
-		if err != nil {
-			_ = lr.Close()
-		}
-		return nil
+	return client, token, botID, nil
+}
+
+// openFileReader returns a reader over bytes [start, end] of file. The client
+// must be running for as long as the reader is in use. With multi-bot
+// streaming, large reads also go through the user's other bots.
+func (a *apiService) openFileReader(ctx context.Context, client TelegramClient, botID string, file *jetmodel.Files, start, end int64) (io.ReadCloser, error) {
+	if file.ChannelID == nil {
+		return nil, fmt.Errorf("missing channel id")
 	}
-	return s.api.telegram.RunWithAuth(ctx, client, token, func(ctx context.Context) error { return handleStream() })
+	if file.Parts == nil || len(file.Parts.Data) == 0 {
+		return nil, fmt.Errorf("missing file parts")
+	}
+	parts, err := a.fetchParts(ctx, client, file.ID.String(), *file.ChannelID, mapper.ToAPIParts(file.Parts), file.Encrypted)
+	if err != nil {
+		return nil, err
+	}
+	bots := []reader.Bot{{Client: client.API(), ID: botID}}
+	stop := func() {}
+	if a.cnf.TG.Stream.MultiBots && end-start+1 >= multiBotMinRead {
+		var extra []reader.Bot
+		extra, stop = a.extraStreamBots(ctx, file.UserID, botID)
+		bots = append(bots, extra...)
+	}
+	fileRef := &reader.FileRef{ID: file.ID.String(), ChannelID: *file.ChannelID, Encrypted: file.Encrypted}
+	lr, err := reader.NewReader(ctx, a.cache, a.chunkCache, fileRef, parts, start, end, &a.cnf.TG, bots)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	if lr == nil {
+		stop()
+		return nil, fmt.Errorf("failed to initialise reader")
+	}
+	if len(bots) > 1 {
+		return &multiBotReader{ReadCloser: lr, stop: stop}, nil
+	}
+	stop()
+	return lr, nil
 }
 
-func (s *rawService) fetchParts(ctx context.Context, client TelegramClient, fileID string, channelID int64, fileParts []api.Part, encrypted bool) ([]types.Part, error) {
-	return cache.Fetch(ctx, s.api.cache, cache.KeyFileMessages(fileID), 60*time.Minute, func() ([]types.Part, error) {
-		parts, err := s.api.telegram.GetParts(ctx, client, channelID, fileParts, encrypted)
+func (a *apiService) fetchParts(ctx context.Context, client TelegramClient, fileID string, channelID int64, fileParts []api.Part, encrypted bool) ([]types.Part, error) {
+	return cache.Fetch(ctx, a.cache, cache.KeyFileMessages(fileID), 60*time.Minute, func() ([]types.Part, error) {
+		parts, err := a.telegram.GetParts(ctx, client, channelID, fileParts, encrypted)
 		if err != nil {
 			return nil, err
 		}
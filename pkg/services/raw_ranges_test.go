@@ -0,0 +1,47 @@
+package services
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tgdrive/teldrive/internal/http_range"
+)
+
+func TestByteRangesLength(t *testing.T) {
+	t.Parallel()
+
+	content := "0123456789abcdefghij"
+	ranges, err := http_range.Parse("bytes=0-4, 10-12,-3", int64(len(content)))
+	require.NoError(t, err)
+	require.Len(t, ranges, 3)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, r := range ranges {
+		part, err := mw.CreatePart(byteRangeHeader(r, "text/plain", int64(len(content))))
+		require.NoError(t, err)
+		_, err = io.WriteString(part, content[r.Start:r.End+1])
+		require.NoError(t, err)
+	}
+	require.NoError(t, mw.Close())
+
+	require.Equal(t, int64(body.Len()), byteRangesLength(ranges, mw.Boundary(), "text/plain", int64(len(content))))
+
+	mr := multipart.NewReader(&body, mw.Boundary())
+	var got []string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(part)
+		require.NoError(t, err)
+		got = append(got, part.Header.Get("Content-Range")+" "+string(data))
+	}
+	require.Equal(t, "bytes 0-4/20 01234|bytes 10-12/20 abc|bytes 17-19/20 hij", strings.Join(got, "|"))
+}
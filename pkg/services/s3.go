@@ -0,0 +1,1314 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/internal/auth"
+	"github.com/tgdrive/teldrive/internal/cache"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/events"
+	"github.com/tgdrive/teldrive/internal/http_range"
+	"github.com/tgdrive/teldrive/internal/logging"
+	teldrivemd5 "github.com/tgdrive/teldrive/internal/md5"
+	"github.com/tgdrive/teldrive/pkg/dto"
+	"github.com/tgdrive/teldrive/pkg/repositories"
+	"go.uber.org/zap"
+)
+
+const (
+	s3XMLNamespace       = "http://s3.amazonaws.com/doc/2006-03-01/"
+	s3MaxKeys            = 1000
+	s3MaxPartNumber      = 10000
+	s3LastModifiedFormat = "2006-01-02T15:04:05.000Z"
+)
+
+type s3Error struct {
+	Code    string
+	Message string
+	Status  int
+}
+
+func (e *s3Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+var (
+	s3ErrAccessDenied                      = &s3Error{"AccessDenied", "Access Denied.", http.StatusForbidden}
+	s3ErrInvalidAccessKeyID                = &s3Error{"InvalidAccessKeyId", "The access key ID you provided does not exist in our records.", http.StatusForbidden}
+	s3ErrSignatureDoesNotMatch             = &s3Error{"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.", http.StatusForbidden}
+	s3ErrSignatureVersionNotSupported      = &s3Error{"InvalidRequest", "Only AWS Signature Version 4 is supported.", http.StatusBadRequest}
+	s3ErrAuthorizationHeaderMalformed      = &s3Error{"AuthorizationHeaderMalformed", "The authorization header is malformed.", http.StatusBadRequest}
+	s3ErrAuthorizationQueryParametersError = &s3Error{"AuthorizationQueryParametersError", "Error parsing the X-Amz-Credential parameter.", http.StatusBadRequest}
+	s3ErrRequestTimeTooSkewed              = &s3Error{"RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.", http.StatusForbidden}
+	s3ErrExpiredPresignRequest             = &s3Error{"AccessDenied", "Request has expired.", http.StatusForbidden}
+	s3ErrRequestNotReadyYet                = &s3Error{"AccessDenied", "Request is not valid yet.", http.StatusForbidden}
+	s3ErrInvalidContentSHA256              = &s3Error{"InvalidArgument", "The provided 'x-amz-content-sha256' header is not valid.", http.StatusBadRequest}
+	s3ErrContentSHA256Mismatch             = &s3Error{"XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.", http.StatusBadRequest}
+	s3ErrBadDigest                         = &s3Error{"BadDigest", "The Content-MD5 you specified did not match what we received.", http.StatusBadRequest}
+	s3ErrInvalidDigest                     = &s3Error{"InvalidDigest", "The Content-MD5 you specified is not valid.", http.StatusBadRequest}
+	s3ErrMissingContentLength              = &s3Error{"MissingContentLength", "You must provide the Content-Length HTTP header.", http.StatusLengthRequired}
+	s3ErrIncompleteBody                    = &s3Error{"IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header.", http.StatusBadRequest}
+	s3ErrMalformedXML                      = &s3Error{"MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema.", http.StatusBadRequest}
+	s3ErrInvalidBucketName                 = &s3Error{"InvalidBucketName", "The specified bucket is not valid.", http.StatusBadRequest}
+	s3ErrInvalidObjectName                 = &s3Error{"InvalidArgument", "The specified key cannot be stored as a path.", http.StatusBadRequest}
+	s3ErrObjectParentConflict              = &s3Error{"InvalidArgument", "A parent of the specified key is an object.", http.StatusConflict}
+	s3ErrNoSuchBucket                      = &s3Error{"NoSuchBucket", "The specified bucket does not exist.", http.StatusNotFound}
+	s3ErrNoSuchKey                         = &s3Error{"NoSuchKey", "The specified key does not exist.", http.StatusNotFound}
+	s3ErrNoSuchUpload                      = &s3Error{"NoSuchUpload", "The specified multipart upload does not exist.", http.StatusNotFound}
+	s3ErrBucketAlreadyOwnedByYou           = &s3Error{"BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it.", http.StatusConflict}
+	s3ErrBucketNotEmpty                    = &s3Error{"BucketNotEmpty", "The bucket you tried to delete is not empty.", http.StatusConflict}
+	s3ErrInvalidPart                       = &s3Error{"InvalidPart", "One or more of the specified parts could not be found.", http.StatusBadRequest}
+	s3ErrInvalidPartOrder                  = &s3Error{"InvalidPartOrder", "The list of parts was not in ascending order.", http.StatusBadRequest}
+	s3ErrInvalidPartNumber                 = &s3Error{"InvalidArgument", "Part number must be an integer between 1 and 10000, inclusive.", http.StatusBadRequest}
+	s3ErrInvalidArgument                   = &s3Error{"InvalidArgument", "Invalid argument.", http.StatusBadRequest}
+	s3ErrInvalidRange                      = &s3Error{"InvalidRange", "The requested range is not satisfiable.", http.StatusRequestedRangeNotSatisfiable}
+	s3ErrMethodNotAllowed                  = &s3Error{"MethodNotAllowed", "The specified method is not allowed against this resource.", http.StatusMethodNotAllowed}
+	s3ErrNotImplemented                    = &s3Error{"NotImplemented", "A header or query you provided implies functionality that is not implemented.", http.StatusNotImplemented}
+	s3ErrInternal                          = &s3Error{"InternalError", "We encountered an internal error. Please try again.", http.StatusInternalServerError}
+	s3ErrQuotaExceeded                     = &s3Error{"QuotaExceeded", "Your storage quota has been exceeded.", http.StatusInsufficientStorage}
+)
+
+// s3Subresources are query parameters that select an operation other than
+// the plain bucket or object one.
+var s3Subresources = []string{
+	"accelerate", "acl", "analytics", "attributes", "cors", "delete", "encryption",
+	"intelligent-tiering", "inventory", "legal-hold", "lifecycle", "location",
+	"logging", "metrics", "notification", "object-lock", "ownershipControls",
+	"policy", "publicAccessBlock", "replication", "requestPayment", "restore",
+	"retention", "select", "tagging", "torrent", "uploadId", "uploads",
+	"versioning", "versions", "website",
+}
+
+// s3ResponseOverrides are the query parameters a presigned GET may use to
+// replace response headers.
+var s3ResponseOverrides = map[string]string{
+	"response-cache-control":       "Cache-Control",
+	"response-content-disposition": "Content-Disposition",
+	"response-content-encoding":    "Content-Encoding",
+	"response-content-language":    "Content-Language",
+	"response-content-type":        "Content-Type",
+	"response-expires":             "Expires",
+}
+
+type s3Service struct {
+	api *apiService
+}
+
+// NewS3Handler serves an S3-compatible API over the user's file tree. Buckets
+// are the top-level folders and object keys are paths below them. Requests
+// are signed with SigV4, using an API key ID as the access key ID.
+func NewS3Handler(api *apiService) http.Handler {
+	return &s3Service{api: api}
+}
+
+// s3Request is an authenticated request, resolved to a bucket and key.
+type s3Request struct {
+	ctx     context.Context
+	w       http.ResponseWriter
+	r       *http.Request
+	userID  int64
+	session *jetmodel.Sessions
+	bucket  string
+	key     string
+	body    io.Reader
+	size    int64
+	logger  *zap.Logger
+}
+
+func (s *s3Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := s.authenticate(w, r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if err := s.route(req); err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+func (s *s3Service) authenticate(w http.ResponseWriter, r *http.Request) (*s3Request, error) {
+	sig, err := parseS3Signature(r)
+	if err != nil {
+		return nil, err
+	}
+	claims, secret, err := auth.VerifyS3AccessKey(r.Context(), s.api.repo.Sessions, s.api.repo.APIKeys, s.api.cache, s.api.cnf.JWT.Secret, sig.accessKeyID)
+	if err != nil {
+		return nil, s3ErrInvalidAccessKeyID
+	}
+	signingKey, err := sig.verify(r, secret, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	write := r.Method != http.MethodGet && r.Method != http.MethodHead
+	if err := auth.CheckGatewayScope(claims, write); err != nil {
+		return nil, s3ErrAccessDenied
+	}
+	body, size, err := sig.body(r, signingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := auth.WithAuthSource(auth.WithJWTUser(r.Context(), claims), auth.AuthSourceAPIKey)
+	userID := auth.User(ctx)
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	return &s3Request{
+		ctx:     ctx,
+		w:       w,
+		r:       r,
+		userID:  userID,
+		session: &jetmodel.Sessions{UserID: userID, TgSession: claims.TgSession},
+		bucket:  bucket,
+		key:     key,
+		body:    body,
+		size:    size,
+		logger:  logging.Component("S3").With(zap.Int64("user_id", userID), zap.String("bucket", bucket), zap.String("key", key)),
+	}, nil
+}
+
+func (s *s3Service) route(req *s3Request) error {
+	query := req.r.URL.Query()
+	subresource := ""
+	for _, name := range s3Subresources {
+		if query.Has(name) {
+			subresource = name
+			break
+		}
+	}
+
+	switch {
+	case req.bucket == "":
+		if req.r.Method != http.MethodGet {
+			return s3ErrMethodNotAllowed
+		}
+		return s.listBuckets(req)
+
+	case req.key == "":
+		switch {
+		case req.r.Method == http.MethodGet && subresource == "location":
+			return s.getBucketLocation(req)
+		case req.r.Method == http.MethodPost && subresource == "delete":
+			return s.deleteObjects(req)
+		case subresource != "":
+			return s3ErrNotImplemented
+		}
+		switch req.r.Method {
+		case http.MethodGet:
+			if query.Get("list-type") == "2" {
+				return s.listObjectsV2(req)
+			}
+			return s.listObjectsV1(req)
+		case http.MethodHead:
+			_, err := s.bucketFolder(req)
+			return err
+		case http.MethodPut:
+			return s.createBucket(req)
+		case http.MethodDelete:
+			return s.deleteBucket(req)
+		}
+		return s3ErrMethodNotAllowed
+	}
+
+	if err := validateS3Key(req.key); err != nil {
+		return err
+	}
+
+	switch {
+	case req.r.Method == http.MethodPost && subresource == "uploads":
+		return s.createMultipartUpload(req)
+	case req.r.Method == http.MethodPut && subresource == "uploadId":
+		return s.uploadPart(req)
+	case req.r.Method == http.MethodPost && subresource == "uploadId":
+		return s.completeMultipartUpload(req)
+	case req.r.Method == http.MethodDelete && subresource == "uploadId":
+		return s.abortMultipartUpload(req)
+	case subresource != "":
+		return s3ErrNotImplemented
+	}
+	switch req.r.Method {
+	case http.MethodGet:
+		return s.getObject(req, false)
+	case http.MethodHead:
+		return s.getObject(req, true)
+	case http.MethodPut:
+		if req.r.Header.Get("X-Amz-Copy-Source") != "" {
+			return s3ErrNotImplemented
+		}
+		return s.putObject(req)
+	case http.MethodDelete:
+		return s.deleteObject(req)
+	}
+	return s3ErrMethodNotAllowed
+}
+
+// validateS3Key rejects keys that do not map onto a folder path, such as keys
+// with empty, "." or ".." segments. A trailing slash marks a folder.
+func validateS3Key(key string) error {
+	trimmed := strings.TrimSuffix(key, "/")
+	if trimmed == "" || path.Clean("/"+trimmed) != "/"+trimmed {
+		return s3ErrInvalidObjectName
+	}
+	return nil
+}
+
+func (s *s3Service) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var s3Err *s3Error
+	if !errors.As(err, &s3Err) {
+		switch {
+		case errors.Is(err, repositories.ErrNotFound):
+			s3Err = s3ErrNoSuchKey
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			s3Err = s3ErrIncompleteBody
+		case isQuotaError(err):
+			s3Err = s3ErrQuotaExceeded
+		default:
+			logging.FromContext(r.Context()).Error("s3.request_failed",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Error(err))
+			s3Err = s3ErrInternal
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(s3Err.Status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	_ = writeS3XML(w, struct {
+		XMLName  xml.Name `xml:"Error"`
+		Code     string   `xml:"Code"`
+		Message  string   `xml:"Message"`
+		Resource string   `xml:"Resource"`
+	}{Code: s3Err.Code, Message: s3Err.Message, Resource: r.URL.Path})
+}
+
+func writeS3XML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func (req *s3Request) respondXML(v any) error {
+	req.w.Header().Set("Content-Type", "application/xml")
+	req.w.WriteHeader(http.StatusOK)
+	return writeS3XML(req.w, v)
+}
+
+// s3ETag identifies an object version. The BLAKE3 hash is used when the file
+// has one; it is not an MD5 sum, so clients do not compare it with their own.
+func s3ETag(file *jetmodel.Files) string {
+	if file.Hash != nil && *file.Hash != "" {
+		return fmt.Sprintf("\"%s\"", *file.Hash)
+	}
+	return fmt.Sprintf("\"%s\"", teldrivemd5.FromString(fmt.Sprintf("%s:%d:%d", file.ID, s3Size(file), file.UpdatedAt.UnixNano())))
+}
+
+func s3Size(file *jetmodel.Files) int64 {
+	if file.Size == nil {
+		return 0
+	}
+	return *file.Size
+}
+
+// s3PartETag identifies a staged part by the Telegram message that holds it.
+func s3PartETag(part *jetmodel.Uploads) string {
+	return fmt.Sprintf("\"%s\"", teldrivemd5.FromString(fmt.Sprintf("%d:%d", part.ChannelID, part.PartID)))
+}
+
+func (s *s3Service) bucketFolder(req *s3Request) (*jetmodel.Files, error) {
+	if req.bucket == "." || req.bucket == ".." {
+		return nil, s3ErrInvalidBucketName
+	}
+	folder, err := s.api.fileByPath(req.ctx, req.userID, req.bucket)
+	if errors.Is(err, repositories.ErrNotFound) || (err == nil && folder.Type != string(api.FileTypeFolder)) {
+		return nil, s3ErrNoSuchBucket
+	}
+	return folder, err
+}
+
+// object returns the file stored at the request key. Folders are not objects.
+func (s *s3Service) object(req *s3Request) (*jetmodel.Files, error) {
+	if _, err := s.bucketFolder(req); err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(req.key, "/") {
+		return nil, s3ErrNoSuchKey
+	}
+	file, err := s.api.fileByPath(req.ctx, req.userID, path.Join(req.bucket, req.key))
+	if errors.Is(err, repositories.ErrNotFound) || (err == nil && file.Type != string(api.FileTypeFile)) {
+		return nil, s3ErrNoSuchKey
+	}
+	return file, err
+}
+
+func (s *s3Service) mkdir(req *s3Request, name string) (*uuid.UUID, error) {
+	id, err := s.api.repo.Files.CreateDirectories(req.ctx, req.userID, path.Join("/root", name))
+	if err != nil {
+		return nil, err
+	}
+	folder, err := s.api.repo.Files.GetByIDAndUser(req.ctx, *id, req.userID)
+	if err != nil {
+		return nil, err
+	}
+	if folder.Type != string(api.FileTypeFolder) {
+		return nil, s3ErrObjectParentConflict
+	}
+	return id, nil
+}
+
+// objectParent returns the folder an object at the request key belongs in,
+// creating any missing folders below the bucket.
+func (s *s3Service) objectParent(req *s3Request) (*uuid.UUID, error) {
+	bucket, err := s.bucketFolder(req)
+	if err != nil {
+		return nil, err
+	}
+	dir := path.Dir(strings.TrimSuffix(req.key, "/"))
+	if dir == "." {
+		return &bucket.ID, nil
+	}
+	return s.mkdir(req, path.Join(req.bucket, dir))
+}
+
+func (s *s3Service) listBuckets(req *s3Request) error {
+	root, err := s.api.fileByPath(req.ctx, req.userID, "/")
+	if err != nil {
+		return err
+	}
+	items, err := s.api.listFolder(req.ctx, req.userID, root.ID)
+	if err != nil {
+		return err
+	}
+
+	type bucket struct {
+		Name         string `xml:"Name"`
+		CreationDate string `xml:"CreationDate"`
+	}
+	var out struct {
+		XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Owner   struct {
+			ID          string `xml:"ID"`
+			DisplayName string `xml:"DisplayName"`
+		} `xml:"Owner"`
+		Buckets []bucket `xml:"Buckets>Bucket"`
+	}
+	out.Xmlns = s3XMLNamespace
+	out.Owner.ID = strconv.FormatInt(req.userID, 10)
+	out.Owner.DisplayName = out.Owner.ID
+	for _, item := range items {
+		if item.Type != string(api.FileTypeFolder) {
+			continue
+		}
+		out.Buckets = append(out.Buckets, bucket{Name: item.Name, CreationDate: item.CreatedAt.UTC().Format(s3LastModifiedFormat)})
+	}
+	return req.respondXML(out)
+}
+
+func (s *s3Service) getBucketLocation(req *s3Request) error {
+	if _, err := s.bucketFolder(req); err != nil {
+		return err
+	}
+	region := s.api.cnf.S3.Region
+	if region == "us-east-1" {
+		region = ""
+	}
+	return req.respondXML(struct {
+		XMLName xml.Name `xml:"LocationConstraint"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Region  string   `xml:",chardata"`
+	}{Xmlns: s3XMLNamespace, Region: region})
+}
+
+func (s *s3Service) createBucket(req *s3Request) error {
+	if _, err := s.bucketFolder(req); err == nil {
+		return s3ErrBucketAlreadyOwnedByYou
+	} else if !errors.Is(err, s3ErrNoSuchBucket) {
+		return err
+	}
+
+	root, err := s.api.fileByPath(req.ctx, req.userID, "/")
+	if err != nil {
+		return err
+	}
+	id, err := s.api.repo.Files.CreateDirectories(req.ctx, req.userID, path.Join("/root", req.bucket))
+	if err != nil {
+		return err
+	}
+	s.api.events.Record(events.OpCreate, req.userID, &dto.Source{
+		ID:       id.String(),
+		Type:     string(api.FileTypeFolder),
+		Name:     req.bucket,
+		ParentID: root.ID.String(),
+	})
+
+	req.w.Header().Set("Location", "/"+req.bucket)
+	req.w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *s3Service) deleteBucket(req *s3Request) error {
+	bucket, err := s.bucketFolder(req)
+	if err != nil {
+		return err
+	}
+	items, err := s.api.repo.Files.List(req.ctx, repositories.FileQueryParams{
+		UserID:    req.userID,
+		Operation: "list",
+		Status:    "active",
+		ParentID:  bucket.ID.String(),
+		Limit:     1,
+	})
+	if err != nil {
+		return err
+	}
+	if len(items) > 0 {
+		return s3ErrBucketNotEmpty
+	}
+	if err := s.api.FilesDeleteById(req.ctx, api.FilesDeleteByIdParams{ID: api.UUID(bucket.ID)}); err != nil {
+		return err
+	}
+	req.w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// s3Entry is a key found while listing a bucket. Folders that are not walked
+// are reported as common prefixes.
+type s3Entry struct {
+	key    string
+	file   *jetmodel.Files
+	prefix bool
+}
+
+// listEntries returns the keys below prefix, sorted. With a "/" delimiter
+// only the folder holding prefix is read; otherwise the tree below it is
+// walked.
+func (s *s3Service) listEntries(req *s3Request, bucket *jetmodel.Files, prefix, delimiter string) ([]s3Entry, error) {
+	dir := ""
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = prefix[:i+1]
+	}
+	folder := bucket
+	if dir != "" {
+		if validateS3Key(dir) != nil {
+			return nil, nil
+		}
+		found, err := s.api.fileByPath(req.ctx, req.userID, path.Join(req.bucket, dir))
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if found.Type != string(api.FileTypeFolder) {
+			return nil, nil
+		}
+		folder = found
+	}
+
+	var out []s3Entry
+	var walk func(folderID uuid.UUID, dir string) error
+	walk = func(folderID uuid.UUID, dir string) error {
+		items, err := s.api.listFolder(req.ctx, req.userID, folderID)
+		if err != nil {
+			return err
+		}
+		for i := range items {
+			item := &items[i]
+			key := dir + item.Name
+			if item.Type != string(api.FileTypeFolder) {
+				out = append(out, s3Entry{key: key, file: item})
+				continue
+			}
+			if !strings.HasPrefix(key+"/", prefix) && !strings.HasPrefix(prefix, key+"/") {
+				continue
+			}
+			if delimiter == "/" {
+				out = append(out, s3Entry{key: key + "/", prefix: true})
+				continue
+			}
+			if err := walk(item.ID, key+"/"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(folder.ID, dir); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].key < out[j].key })
+	return out, nil
+}
+
+type s3Object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type s3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type s3Listing struct {
+	contents  []s3Object
+	prefixes  []s3CommonPrefix
+	truncated bool
+	lastKey   string
+}
+
+// list pages through the keys of a bucket after marker, grouping keys that
+// contain delimiter past prefix into common prefixes.
+func (s *s3Service) list(req *s3Request, prefix, delimiter, marker string, maxKeys int) (*s3Listing, error) {
+	bucket, err := s.bucketFolder(req)
+	if err != nil {
+		return nil, err
+	}
+	out := &s3Listing{}
+	if maxKeys == 0 {
+		return out, nil
+	}
+	entries, err := s.listEntries(req, bucket, prefix, delimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	count := 0
+	lastPrefix := ""
+	for _, entry := range entries {
+		if entry.key <= marker || !strings.HasPrefix(entry.key, prefix) {
+			continue
+		}
+		// A marker that is a common prefix skips everything grouped under it.
+		if delimiter != "" && strings.HasSuffix(marker, delimiter) && strings.HasPrefix(entry.key, marker) {
+			continue
+		}
+
+		commonPrefix := ""
+		if delimiter != "" {
+			if i := strings.Index(entry.key[len(prefix):], delimiter); i >= 0 {
+				commonPrefix = entry.key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if commonPrefix != "" && commonPrefix == lastPrefix {
+			continue
+		}
+
+		if count == maxKeys {
+			out.truncated = true
+			break
+		}
+		count++
+		if commonPrefix != "" {
+			lastPrefix = commonPrefix
+			out.lastKey = commonPrefix
+			out.prefixes = append(out.prefixes, s3CommonPrefix{Prefix: commonPrefix})
+			continue
+		}
+		if entry.prefix {
+			continue
+		}
+		out.lastKey = entry.key
+		out.contents = append(out.contents, s3Object{
+			Key:          entry.key,
+			LastModified: entry.file.UpdatedAt.UTC().Format(s3LastModifiedFormat),
+			ETag:         s3ETag(entry.file),
+			Size:         s3Size(entry.file),
+			StorageClass: "STANDARD",
+		})
+	}
+	return out, nil
+}
+
+func s3MaxKeysParam(query url.Values) (int, error) {
+	value := query.Get("max-keys")
+	if value == "" {
+		return s3MaxKeys, nil
+	}
+	maxKeys, err := strconv.Atoi(value)
+	if err != nil || maxKeys < 0 {
+		return 0, s3ErrInvalidArgument
+	}
+	return min(maxKeys, s3MaxKeys), nil
+}
+
+// s3NameEncoder applies encoding-type=url to the keys and prefixes of a
+// listing.
+func s3NameEncoder(query url.Values) (string, func(string) string) {
+	if query.Get("encoding-type") == "url" {
+		return "url", url.QueryEscape
+	}
+	return "", func(s string) string { return s }
+}
+
+func (s *s3Service) listObjectsV2(req *s3Request) error {
+	query := req.r.URL.Query()
+	maxKeys, err := s3MaxKeysParam(query)
+	if err != nil {
+		return err
+	}
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	marker := query.Get("start-after")
+	token := query.Get("continuation-token")
+	if token != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			return s3ErrInvalidArgument
+		}
+		marker = string(decoded)
+	}
+
+	listing, err := s.list(req, prefix, delimiter, marker, maxKeys)
+	if err != nil {
+		return err
+	}
+
+	encodingType, encode := s3NameEncoder(query)
+	var out struct {
+		XMLName               xml.Name         `xml:"ListBucketResult"`
+		Xmlns                 string           `xml:"xmlns,attr"`
+		Name                  string           `xml:"Name"`
+		Prefix                string           `xml:"Prefix"`
+		Delimiter             string           `xml:"Delimiter,omitempty"`
+		StartAfter            string           `xml:"StartAfter,omitempty"`
+		ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+		NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+		KeyCount              int              `xml:"KeyCount"`
+		MaxKeys               int              `xml:"MaxKeys"`
+		EncodingType          string           `xml:"EncodingType,omitempty"`
+		IsTruncated           bool             `xml:"IsTruncated"`
+		Contents              []s3Object       `xml:"Contents"`
+		CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+	}
+	out.Xmlns = s3XMLNamespace
+	out.Name = req.bucket
+	out.Prefix = encode(prefix)
+	out.Delimiter = encode(delimiter)
+	out.StartAfter = encode(query.Get("start-after"))
+	out.ContinuationToken = token
+	out.MaxKeys = maxKeys
+	out.EncodingType = encodingType
+	out.IsTruncated = listing.truncated
+	if listing.truncated {
+		out.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(listing.lastKey))
+	}
+	for _, object := range listing.contents {
+		object.Key = encode(object.Key)
+		out.Contents = append(out.Contents, object)
+	}
+	for _, commonPrefix := range listing.prefixes {
+		out.CommonPrefixes = append(out.CommonPrefixes, s3CommonPrefix{Prefix: encode(commonPrefix.Prefix)})
+	}
+	out.KeyCount = len(out.Contents) + len(out.CommonPrefixes)
+	return req.respondXML(out)
+}
+
+func (s *s3Service) listObjectsV1(req *s3Request) error {
+	query := req.r.URL.Query()
+	maxKeys, err := s3MaxKeysParam(query)
+	if err != nil {
+		return err
+	}
+	prefix, delimiter, marker := query.Get("prefix"), query.Get("delimiter"), query.Get("marker")
+
+	listing, err := s.list(req, prefix, delimiter, marker, maxKeys)
+	if err != nil {
+		return err
+	}
+
+	encodingType, encode := s3NameEncoder(query)
+	var out struct {
+		XMLName        xml.Name         `xml:"ListBucketResult"`
+		Xmlns          string           `xml:"xmlns,attr"`
+		Name           string           `xml:"Name"`
+		Prefix         string           `xml:"Prefix"`
+		Marker         string           `xml:"Marker"`
+		NextMarker     string           `xml:"NextMarker,omitempty"`
+		Delimiter      string           `xml:"Delimiter,omitempty"`
+		MaxKeys        int              `xml:"MaxKeys"`
+		EncodingType   string           `xml:"EncodingType,omitempty"`
+		IsTruncated    bool             `xml:"IsTruncated"`
+		Contents       []s3Object       `xml:"Contents"`
+		CommonPrefixes []s3CommonPrefix `xml:"CommonPrefixes"`
+	}
+	out.Xmlns = s3XMLNamespace
+	out.Name = req.bucket
+	out.Prefix = encode(prefix)
+	out.Marker = encode(marker)
+	out.Delimiter = encode(delimiter)
+	out.MaxKeys = maxKeys
+	out.EncodingType = encodingType
+	out.IsTruncated = listing.truncated
+	if listing.truncated {
+		out.NextMarker = encode(listing.lastKey)
+	}
+	for _, object := range listing.contents {
+		object.Key = encode(object.Key)
+		out.Contents = append(out.Contents, object)
+	}
+	for _, commonPrefix := range listing.prefixes {
+		out.CommonPrefixes = append(out.CommonPrefixes, s3CommonPrefix{Prefix: encode(commonPrefix.Prefix)})
+	}
+	return req.respondXML(out)
+}
+
+func (s *s3Service) getObject(req *s3Request, head bool) error {
+	found, err := s.object(req)
+	if err != nil {
+		return err
+	}
+	file, err := cache.Fetch(req.ctx, s.api.cache, cache.KeyFile(found.ID.String()), 0, func() (*jetmodel.Files, error) {
+		return s.api.repo.Files.GetByID(req.ctx, found.ID)
+	})
+	if err != nil {
+		return err
+	}
+	size := s3Size(file)
+
+	header := req.w.Header()
+	contentType := file.MimeType
+	if contentType == "" {
+		contentType = defaultContentType
+	}
+	header.Set("Content-Type", contentType)
+	header.Set("Accept-Ranges", "bytes")
+	header.Set("ETag", s3ETag(file))
+	header.Set("Last-Modified", file.UpdatedAt.UTC().Format(http.TimeFormat))
+	if !head {
+		query := req.r.URL.Query()
+		for param, name := range s3ResponseOverrides {
+			if value := query.Get(param); value != "" {
+				header.Set(name, value)
+			}
+		}
+	}
+
+	start, end := int64(0), size-1
+	status := http.StatusOK
+	if rawRange := req.r.Header.Get("Range"); rawRange != "" && size > 0 {
+		ranges, err := http_range.Parse(rawRange, size)
+		if err == http_range.ErrNoOverlap {
+			header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			return s3ErrInvalidRange
+		}
+		if err != nil {
+			return s3ErrInvalidRange
+		}
+		if len(ranges) > 0 {
+			start, end = ranges[0].Start, ranges[0].End
+			header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+			status = http.StatusPartialContent
+		}
+	}
+	contentLength := end - start + 1
+	header.Set("Content-Length", strconv.FormatInt(contentLength, 10))
+
+	if head || contentLength == 0 {
+		req.w.WriteHeader(status)
+		return nil
+	}
+
+	client, token, botID, err := s.api.streamClient(req.ctx, req.session, req.logger)
+	if err != nil {
+		return err
+	}
+	return s.api.telegram.RunWithAuth(req.ctx, client, token, func(ctx context.Context) error {
+		lr, err := s.api.openFileReader(ctx, client, botID, file, start, end)
+		if err != nil {
+			return err
+		}
+		defer lr.Close()
+		req.w.WriteHeader(status)
+		if _, err := io.CopyN(req.w, lr, contentLength); err != nil {
+			req.logger.Debug("s3.get_object_interrupted", zap.Error(err))
+		}
+		return nil
+	})
+}
+
+// readBody wraps the request payload so that it fails on its last read when
+// the declared Content-MD5 does not match.
+func (req *s3Request) readBody() (io.Reader, error) {
+	if req.size < 0 {
+		return nil, s3ErrMissingContentLength
+	}
+	contentMD5 := req.r.Header.Get("Content-MD5")
+	if contentMD5 == "" {
+		return req.body, nil
+	}
+	expected, err := base64.StdEncoding.DecodeString(contentMD5)
+	if err != nil || len(expected) != md5.Size {
+		return nil, s3ErrInvalidDigest
+	}
+	return &s3HashReader{r: req.body, hash: md5.New(), expected: expected, err: s3ErrBadDigest}, nil
+}
+
+// drainBody reads the rest of a payload once the declared length has been
+// consumed, which runs the payload checks, and rejects trailing data.
+func drainBody(body io.Reader) error {
+	n, err := io.Copy(io.Discard, body)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return s3ErrIncompleteBody
+	}
+	return nil
+}
+
+// s3ContentType keeps the type sent by the client unless it is one of the
+// generic defaults most clients send, which would hide the real type from
+// the UI.
+func s3ContentType(r *http.Request, name string) string {
+	switch contentType := r.Header.Get("Content-Type"); contentType {
+	case "", defaultContentType, "binary/octet-stream":
+		return webdavMimeType(name)
+	default:
+		return contentType
+	}
+}
+
+func (s *s3Service) putObject(req *s3Request) error {
+	body, err := req.readBody()
+	if err != nil {
+		return err
+	}
+
+	parentID, err := s.objectParent(req)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(req.key, "/") {
+		if req.size > 0 {
+			return s3ErrInvalidObjectName
+		}
+		if err := drainBody(body); err != nil {
+			return err
+		}
+		if _, err := s.mkdir(req, path.Join(req.bucket, req.key)); err != nil {
+			return err
+		}
+		req.w.Header().Set("ETag", fmt.Sprintf("\"%s\"", hex.EncodeToString(md5.New().Sum(nil))))
+		req.w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	name := path.Base(req.key)
+	uploadID := uuid.NewString()
+	partSize := s.api.cnf.S3.ChunkSize
+	if partSize <= 0 {
+		partSize = defaultSyncChunkSize
+	}
+
+	fileReq := &api.File{
+		Name:     name,
+		Type:     api.FileTypeFile,
+		ParentId: api.NewOptUUID(api.UUID(*parentID)),
+		MimeType: api.NewOptString(s3ContentType(req.r, name)),
+		Size:     api.NewOptInt64(req.size),
+	}
+
+	if req.size > 0 {
+		if err := s.api.checkUploadQuota(req.ctx, req.userID, uploadID, req.size); err != nil {
+			return err
+		}
+		stager, err := s.api.newUploadStager(req.ctx, req.userID, 0)
+		if err != nil {
+			return err
+		}
+		defer stager.Close()
+
+		err = stager.Run(req.ctx, func(ctx context.Context) error {
+			partNo := 0
+			for remaining := req.size; remaining > 0; {
+				partNo++
+				n := min(partSize, remaining)
+				if _, err := stager.StagePart(ctx, uploadStagePartRequest{
+					UploadID: uploadID,
+					FileName: name,
+					PartNo:   partNo,
+					Reader:   io.LimitReader(body, n),
+					Size:     n,
+					Hashing:  true,
+					Threads:  s.api.cnf.TG.Uploads.Threads,
+				}, req.logger); err != nil {
+					return err
+				}
+				remaining -= n
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		fileReq.UploadId = api.NewOptString(uploadID)
+		fileReq.ChannelId = api.NewOptInt64(stager.channelID)
+	}
+	if err := drainBody(body); err != nil {
+		return err
+	}
+
+	return s.createObject(req, fileReq)
+}
+
+// createObject stores the file row for an uploaded object and replies with
+// its ETag.
+func (s *s3Service) createObject(req *s3Request, fileReq *api.File) error {
+	if _, err := s.api.FilesCreate(req.ctx, fileReq); err != nil {
+		return err
+	}
+	file, err := s.object(req)
+	if err != nil {
+		return err
+	}
+	req.w.Header().Set("ETag", s3ETag(file))
+	return nil
+}
+
+func (s *s3Service) deleteObject(req *s3Request) error {
+	if err := s.removeObject(req, req.key); err != nil {
+		return err
+	}
+	req.w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// removeObject deletes the object at key. Missing keys are not an error, and
+// a key ending in a slash only removes the folder when it is empty, so an
+// object delete never removes a whole tree.
+func (s *s3Service) removeObject(req *s3Request, key string) error {
+	if err := validateS3Key(key); err != nil {
+		return err
+	}
+	file, err := s.api.fileByPath(req.ctx, req.userID, path.Join(req.bucket, key))
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	isFolder := file.Type == string(api.FileTypeFolder)
+	if isFolder != strings.HasSuffix(key, "/") {
+		return nil
+	}
+	if isFolder {
+		children, err := s.api.repo.Files.List(req.ctx, repositories.FileQueryParams{
+			UserID:    req.userID,
+			Operation: "list",
+			Status:    "active",
+			ParentID:  file.ID.String(),
+			Limit:     1,
+		})
+		if err != nil {
+			return err
+		}
+		if len(children) > 0 {
+			return nil
+		}
+	}
+	return s.api.FilesDeleteById(req.ctx, api.FilesDeleteByIdParams{ID: api.UUID(file.ID)})
+}
+
+func (s *s3Service) deleteObjects(req *s3Request) error {
+	if _, err := s.bucketFolder(req); err != nil {
+		return err
+	}
+	var in struct {
+		Quiet   bool `xml:"Quiet"`
+		Objects []struct {
+			Key string `xml:"Key"`
+		} `xml:"Object"`
+	}
+	if err := xml.NewDecoder(req.body).Decode(&in); err != nil {
+		return s3ErrMalformedXML
+	}
+
+	type deleted struct {
+		Key string `xml:"Key"`
+	}
+	type deleteError struct {
+		Key     string `xml:"Key"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	var out struct {
+		XMLName xml.Name      `xml:"DeleteResult"`
+		Xmlns   string        `xml:"xmlns,attr"`
+		Deleted []deleted     `xml:"Deleted"`
+		Errors  []deleteError `xml:"Error"`
+	}
+	out.Xmlns = s3XMLNamespace
+	for _, object := range in.Objects {
+		if err := s.removeObject(req, object.Key); err != nil {
+			var s3Err *s3Error
+			if !errors.As(err, &s3Err) {
+				req.logger.Error("s3.delete_object_failed", zap.String("object", object.Key), zap.Error(err))
+				s3Err = s3ErrInternal
+			}
+			out.Errors = append(out.Errors, deleteError{Key: object.Key, Code: s3Err.Code, Message: s3Err.Message})
+			continue
+		}
+		if !in.Quiet {
+			out.Deleted = append(out.Deleted, deleted{Key: object.Key})
+		}
+	}
+	return req.respondXML(out)
+}
+
+func (s *s3Service) createMultipartUpload(req *s3Request) error {
+	if strings.HasSuffix(req.key, "/") {
+		return s3ErrInvalidObjectName
+	}
+	if _, err := s.bucketFolder(req); err != nil {
+		return err
+	}
+
+	// Every part of a file has to live in the same channel. Parts may be
+	// uploaded in parallel, so the channel is picked once here and carried in
+	// the upload ID rather than taken from whichever part lands first.
+	channelID, err := s.api.resolveUploadChannel(req.ctx, req.userID, 0)
+	if err != nil {
+		return err
+	}
+	return req.respondXML(struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		UploadID string   `xml:"UploadId"`
+	}{Xmlns: s3XMLNamespace, Bucket: req.bucket, Key: req.key, UploadID: s3UploadID(channelID)})
+}
+
+// s3UploadID returns a new multipart upload ID pinned to channelID.
+func s3UploadID(channelID int64) string {
+	return uuid.NewString() + "." + strconv.FormatInt(channelID, 10)
+}
+
+// s3UploadChannel returns the channel pinned in a multipart upload ID.
+func s3UploadChannel(uploadID string) (int64, bool) {
+	id, channel, ok := strings.Cut(uploadID, ".")
+	if !ok {
+		return 0, false
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return 0, false
+	}
+	channelID, err := strconv.ParseInt(channel, 10, 64)
+	if err != nil || channelID <= 0 {
+		return 0, false
+	}
+	return channelID, true
+}
+
+// uploadParts returns the parts staged so far for uploadID. An upload that
+// belongs to another user is reported as missing.
+func (s *s3Service) uploadParts(req *s3Request, uploadID string) ([]jetmodel.Uploads, error) {
+	if _, ok := s3UploadChannel(uploadID); !ok {
+		return nil, s3ErrNoSuchUpload
+	}
+	parts, err := s.api.repo.Uploads.GetByUploadID(req.ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		if part.UserID == nil || *part.UserID != req.userID {
+			return nil, s3ErrNoSuchUpload
+		}
+	}
+	return parts, nil
+}
+
+func (s *s3Service) uploadPart(req *s3Request) error {
+	query := req.r.URL.Query()
+	uploadID := query.Get("uploadId")
+	partNo, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil || partNo < 1 || partNo > s3MaxPartNumber {
+		return s3ErrInvalidPartNumber
+	}
+	body, err := req.readBody()
+	if err != nil {
+		return err
+	}
+	if req.size == 0 {
+		return s3ErrInvalidArgument
+	}
+	existing, err := s.uploadParts(req, uploadID)
+	if err != nil {
+		return err
+	}
+	if err := s.api.checkUploadQuota(req.ctx, req.userID, uploadID, req.size); err != nil {
+		return err
+	}
+
+	channelID, _ := s3UploadChannel(uploadID)
+	channel, err := s.api.repo.Channels.GetByChannelID(req.ctx, channelID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return s3ErrNoSuchUpload
+		}
+		return err
+	}
+	if channel.UserID != req.userID {
+		return s3ErrNoSuchUpload
+	}
+	stager, err := s.api.newUploadStager(req.ctx, req.userID, channelID)
+	if err != nil {
+		return err
+	}
+	defer stager.Close()
+
+	var part *jetmodel.Uploads
+	err = stager.Run(req.ctx, func(ctx context.Context) error {
+		staged, err := stager.StagePart(ctx, uploadStagePartRequest{
+			UploadID: uploadID,
+			FileName: path.Base(req.key),
+			PartNo:   partNo,
+			Reader:   io.LimitReader(body, req.size),
+			Size:     req.size,
+			Hashing:  true,
+			Threads:  s.api.cnf.TG.Uploads.Threads,
+		}, req.logger)
+		part = staged
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := drainBody(body); err != nil {
+		return err
+	}
+
+	// A retried part replaces the earlier upload of the same number. Its
+	// message is left behind as an orphan for the channel check to remove.
+	var replaced []int
+	for _, prev := range existing {
+		if int(prev.PartNo) == partNo && prev.PartID != part.PartID {
+			replaced = append(replaced, int(prev.PartID))
+		}
+	}
+	if err := s.api.repo.Uploads.DeleteParts(req.ctx, part.ChannelID, req.userID, replaced); err != nil {
+		return err
+	}
+
+	req.w.Header().Set("ETag", s3PartETag(part))
+	req.w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *s3Service) completeMultipartUpload(req *s3Request) error {
+	uploadID := req.r.URL.Query().Get("uploadId")
+	var in struct {
+		Parts []struct {
+			PartNumber int    `xml:"PartNumber"`
+			ETag       string `xml:"ETag"`
+		} `xml:"Part"`
+	}
+	if err := xml.NewDecoder(req.body).Decode(&in); err != nil || len(in.Parts) == 0 {
+		return s3ErrMalformedXML
+	}
+
+	staged, err := s.uploadParts(req, uploadID)
+	if err != nil {
+		return err
+	}
+	if len(staged) == 0 {
+		return s3ErrNoSuchUpload
+	}
+	byNumber := make(map[int]*jetmodel.Uploads, len(staged))
+	for i := range staged {
+		byNumber[int(staged[i].PartNo)] = &staged[i]
+	}
+
+	var size int64
+	used := make(map[int]bool, len(in.Parts))
+	for i, listed := range in.Parts {
+		if i > 0 && listed.PartNumber <= in.Parts[i-1].PartNumber {
+			return s3ErrInvalidPartOrder
+		}
+		part, ok := byNumber[listed.PartNumber]
+		if !ok || strings.Trim(listed.ETag, "\"") != strings.Trim(s3PartETag(part), "\"") {
+			return s3ErrInvalidPart
+		}
+		used[int(part.PartID)] = true
+		size += part.Size
+	}
+
+	// Parts that were uploaded but left out of the list are dropped, as S3
+	// does; their messages are left for the channel check.
+	var unused []int
+	for _, part := range staged {
+		if !used[int(part.PartID)] {
+			unused = append(unused, int(part.PartID))
+		}
+	}
+	if err := s.api.repo.Uploads.DeleteParts(req.ctx, staged[0].ChannelID, req.userID, unused); err != nil {
+		return err
+	}
+
+	parentID, err := s.objectParent(req)
+	if err != nil {
+		return err
+	}
+	name := path.Base(req.key)
+	fileReq := &api.File{
+		Name:      name,
+		Type:      api.FileTypeFile,
+		ParentId:  api.NewOptUUID(api.UUID(*parentID)),
+		MimeType:  api.NewOptString(webdavMimeType(name)),
+		Size:      api.NewOptInt64(size),
+		UploadId:  api.NewOptString(uploadID),
+		ChannelId: api.NewOptInt64(staged[0].ChannelID),
+	}
+	if err := s.createObject(req, fileReq); err != nil {
+		return err
+	}
+
+	return req.respondXML(struct {
+		XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Location string   `xml:"Location"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		ETag     string   `xml:"ETag"`
+	}{
+		Xmlns:    s3XMLNamespace,
+		Location: "/" + req.bucket + "/" + req.key,
+		Bucket:   req.bucket,
+		Key:      req.key,
+		ETag:     req.w.Header().Get("ETag"),
+	})
+}
+
+func (s *s3Service) abortMultipartUpload(req *s3Request) error {
+	uploadID := req.r.URL.Query().Get("uploadId")
+	if _, err := s.uploadParts(req, uploadID); err != nil {
+		return err
+	}
+	if err := s.api.repo.Uploads.Delete(req.ctx, uploadID); err != nil {
+		return err
+	}
+	req.w.WriteHeader(http.StatusNoContent)
+	return nil
+}
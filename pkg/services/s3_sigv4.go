@@ -0,0 +1,466 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm                = "AWS4-HMAC-SHA256"
+	s3TimeFormat               = "20060102T150405Z"
+	s3DateFormat               = "20060102"
+	s3UnsignedPayload          = "UNSIGNED-PAYLOAD"
+	s3StreamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	s3StreamingPayloadTrailer  = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	s3StreamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	s3MaxClockSkew             = 15 * time.Minute
+	s3MaxPresignExpiry         = 7 * 24 * time.Hour
+	s3EmptySHA256              = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// s3Signature is a parsed AWS Signature Version 4, taken either from the
+// Authorization header or from the query string of a presigned URL.
+type s3Signature struct {
+	accessKeyID   string
+	date          string
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+	amzDate       string
+	signedAt      time.Time
+	presigned     bool
+	expires       time.Duration
+}
+
+func (sig *s3Signature) scope() string {
+	return strings.Join([]string{sig.date, sig.region, sig.service, "aws4_request"}, "/")
+}
+
+func parseS3Signature(r *http.Request) (*s3Signature, error) {
+	query := r.URL.Query()
+	if query.Get("X-Amz-Algorithm") != "" {
+		return parseS3PresignedSignature(query)
+	}
+
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, s3ErrAccessDenied
+	}
+	rest, ok := strings.CutPrefix(header, s3Algorithm+" ")
+	if !ok {
+		return nil, s3ErrSignatureVersionNotSupported
+	}
+
+	sig := &s3Signature{}
+	for field := range strings.SplitSeq(rest, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return nil, s3ErrAuthorizationHeaderMalformed
+		}
+		switch key {
+		case "Credential":
+			if err := sig.parseCredential(value); err != nil {
+				return nil, err
+			}
+		case "SignedHeaders":
+			sig.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			sig.signature = value
+		}
+	}
+	if sig.accessKeyID == "" || len(sig.signedHeaders) == 0 || sig.signature == "" {
+		return nil, s3ErrAuthorizationHeaderMalformed
+	}
+
+	sig.amzDate = r.Header.Get("X-Amz-Date")
+	if sig.amzDate == "" {
+		date, err := http.ParseTime(r.Header.Get("Date"))
+		if err != nil {
+			return nil, s3ErrAccessDenied
+		}
+		sig.amzDate = date.UTC().Format(s3TimeFormat)
+	}
+	return sig, sig.parseDate()
+}
+
+func parseS3PresignedSignature(query url.Values) (*s3Signature, error) {
+	if query.Get("X-Amz-Algorithm") != s3Algorithm {
+		return nil, s3ErrSignatureVersionNotSupported
+	}
+
+	sig := &s3Signature{presigned: true}
+	if err := sig.parseCredential(query.Get("X-Amz-Credential")); err != nil {
+		return nil, err
+	}
+	sig.signedHeaders = strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+	sig.signature = query.Get("X-Amz-Signature")
+	sig.amzDate = query.Get("X-Amz-Date")
+	if sig.signature == "" || sig.amzDate == "" {
+		return nil, s3ErrAuthorizationQueryParametersError
+	}
+
+	expires, err := strconv.ParseInt(query.Get("X-Amz-Expires"), 10, 64)
+	if err != nil || expires < 0 || time.Duration(expires)*time.Second > s3MaxPresignExpiry {
+		return nil, s3ErrAuthorizationQueryParametersError
+	}
+	sig.expires = time.Duration(expires) * time.Second
+	return sig, sig.parseDate()
+}
+
+func (sig *s3Signature) parseCredential(value string) error {
+	parts := strings.Split(value, "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" {
+		return s3ErrAuthorizationHeaderMalformed
+	}
+	if parts[3] != "s3" {
+		return s3ErrAuthorizationHeaderMalformed
+	}
+	sig.accessKeyID = parts[0]
+	sig.date = parts[1]
+	sig.region = parts[2]
+	sig.service = parts[3]
+	return nil
+}
+
+func (sig *s3Signature) parseDate() error {
+	signedAt, err := time.Parse(s3TimeFormat, sig.amzDate)
+	if err != nil {
+		return s3ErrAccessDenied
+	}
+	if signedAt.Format(s3DateFormat) != sig.date {
+		return s3ErrAuthorizationHeaderMalformed
+	}
+	sig.signedAt = signedAt
+	return nil
+}
+
+// verify checks the request signature against secret and returns the signing
+// key, which is needed again to verify streamed payload chunks.
+func (sig *s3Signature) verify(r *http.Request, secret string, now time.Time) ([]byte, error) {
+	if sig.presigned {
+		if now.After(sig.signedAt.Add(sig.expires)) {
+			return nil, s3ErrExpiredPresignRequest
+		}
+		if sig.signedAt.After(now.Add(s3MaxClockSkew)) {
+			return nil, s3ErrRequestNotReadyYet
+		}
+	} else if now.Sub(sig.signedAt).Abs() > s3MaxClockSkew {
+		return nil, s3ErrRequestTimeTooSkewed
+	}
+
+	key := s3SigningKey(secret, sig.date, sig.region, sig.service)
+	expected := hex.EncodeToString(s3HMAC(key, sig.stringToSign(r)))
+	if !hmac.Equal([]byte(expected), []byte(sig.signature)) {
+		return nil, s3ErrSignatureDoesNotMatch
+	}
+	return key, nil
+}
+
+func (sig *s3Signature) payloadHash(r *http.Request) string {
+	if sig.presigned {
+		if hash := r.URL.Query().Get("X-Amz-Content-Sha256"); hash != "" {
+			return hash
+		}
+		return s3UnsignedPayload
+	}
+	if hash := r.Header.Get("X-Amz-Content-Sha256"); hash != "" {
+		return hash
+	}
+	return s3EmptySHA256
+}
+
+func (sig *s3Signature) stringToSign(r *http.Request) string {
+	canonical := sig.canonicalRequest(r)
+	sum := sha256.Sum256([]byte(canonical))
+	return strings.Join([]string{s3Algorithm, sig.amzDate, sig.scope(), hex.EncodeToString(sum[:])}, "\n")
+}
+
+func (sig *s3Signature) canonicalRequest(r *http.Request) string {
+	canonicalURI := s3URIEncode(r.URL.Path, false)
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+
+	var headers strings.Builder
+	for _, name := range sig.signedHeaders {
+		headers.WriteString(name)
+		headers.WriteByte(':')
+		headers.WriteString(s3CanonicalHeaderValue(r, name))
+		headers.WriteByte('\n')
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		canonicalURI,
+		s3CanonicalQuery(r.URL.Query(), sig.presigned),
+		headers.String(),
+		strings.Join(sig.signedHeaders, ";"),
+		sig.payloadHash(r),
+	}, "\n")
+}
+
+func s3CanonicalHeaderValue(r *http.Request, name string) string {
+	var values []string
+	switch name {
+	case "host":
+		values = []string{r.Host}
+	case "content-length":
+		values = r.Header.Values(name)
+		if len(values) == 0 && r.ContentLength >= 0 {
+			values = []string{strconv.FormatInt(r.ContentLength, 10)}
+		}
+	case "transfer-encoding":
+		values = r.TransferEncoding
+	default:
+		values = r.Header.Values(name)
+	}
+	for i, value := range values {
+		values[i] = strings.Join(strings.Fields(value), " ")
+	}
+	return strings.Join(values, ",")
+}
+
+func s3CanonicalQuery(query url.Values, presigned bool) string {
+	pairs := make([]string, 0, len(query))
+	for key, values := range query {
+		if presigned && key == "X-Amz-Signature" {
+			continue
+		}
+		for _, value := range values {
+			pairs = append(pairs, s3URIEncode(key, true)+"="+s3URIEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// s3URIEncode encodes s the way SigV4 expects: every byte except the
+// unreserved characters is percent-encoded, and slashes are kept unless
+// encodeSlash is set.
+func s3URIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func s3SigningKey(secret, date, region, service string) []byte {
+	key := s3HMAC([]byte("AWS4"+secret), date)
+	key = s3HMAC(key, region)
+	key = s3HMAC(key, service)
+	return s3HMAC(key, "aws4_request")
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// body returns the decoded request payload and its length, or -1 when the
+// client did not declare one. Signed payloads are verified as they are read,
+// so a tampered body fails with an error on its last read.
+func (sig *s3Signature) body(r *http.Request, signingKey []byte) (io.Reader, int64, error) {
+	payloadHash := sig.payloadHash(r)
+	switch payloadHash {
+	case s3UnsignedPayload:
+		return r.Body, r.ContentLength, nil
+	case s3StreamingPayload, s3StreamingPayloadTrailer, s3StreamingUnsignedTrailer:
+		size, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil || size < 0 {
+			return nil, 0, s3ErrMissingContentLength
+		}
+		chunked := &s3ChunkedReader{r: bufio.NewReader(r.Body), prevSig: sig.signature}
+		if payloadHash != s3StreamingUnsignedTrailer {
+			chunked.signingKey = signingKey
+			chunked.amzDate = sig.amzDate
+			chunked.scope = sig.scope()
+		}
+		return chunked, size, nil
+	default:
+		expected, err := hex.DecodeString(payloadHash)
+		if err != nil || len(expected) != sha256.Size {
+			return nil, 0, s3ErrInvalidContentSHA256
+		}
+		return &s3HashReader{r: r.Body, hash: sha256.New(), expected: expected, err: s3ErrContentSHA256Mismatch}, r.ContentLength, nil
+	}
+}
+
+// s3HashReader fails the final read when the payload does not hash to the
+// expected sum.
+type s3HashReader struct {
+	r        io.Reader
+	hash     hash.Hash
+	expected []byte
+	err      error
+}
+
+func (h *s3HashReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(h.hash.Sum(nil), h.expected) {
+		return n, h.err
+	}
+	return n, err
+}
+
+// s3ChunkedReader decodes an aws-chunked payload. When a signing key is set,
+// each chunk signature is checked against the previous one, starting from
+// the request signature.
+type s3ChunkedReader struct {
+	r          *bufio.Reader
+	signingKey []byte
+	amzDate    string
+	scope      string
+	prevSig    string
+	chunkSig   string
+	chunkHash  hash.Hash
+	remaining  int64
+	inChunk    bool
+	err        error
+}
+
+func (c *s3ChunkedReader) Read(p []byte) (int, error) {
+	for c.err == nil && c.remaining == 0 {
+		c.err = c.nextChunk()
+	}
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	n, err := c.r.Read(p[:min(int64(len(p)), c.remaining)])
+	c.remaining -= int64(n)
+	if c.chunkHash != nil {
+		c.chunkHash.Write(p[:n])
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// nextChunk finishes the current chunk and reads the next chunk header. It
+// returns io.EOF after the final, empty chunk and its trailers.
+func (c *s3ChunkedReader) nextChunk() error {
+	if c.inChunk {
+		if err := c.verifyChunk(); err != nil {
+			return err
+		}
+		if err := c.readCRLF(); err != nil {
+			return err
+		}
+		c.inChunk = false
+	}
+
+	line, err := c.readLine()
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	sizeField, ext, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+	if err != nil || size < 0 {
+		return s3ErrIncompleteBody
+	}
+	if c.signingKey != nil {
+		sig, ok := strings.CutPrefix(ext, "chunk-signature=")
+		if !ok {
+			return s3ErrSignatureDoesNotMatch
+		}
+		c.chunkSig = sig
+		c.chunkHash = sha256.New()
+	}
+
+	c.inChunk = true
+	c.remaining = size
+	if size > 0 {
+		return nil
+	}
+
+	if err := c.verifyChunk(); err != nil {
+		return err
+	}
+	// Trailers, if any, follow the final chunk and end with an empty line.
+	for {
+		line, err := c.readLine()
+		if err == io.EOF || (err == nil && line == "") {
+			return io.EOF
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *s3ChunkedReader) verifyChunk() error {
+	if c.signingKey == nil {
+		return nil
+	}
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256-PAYLOAD",
+		c.amzDate,
+		c.scope,
+		c.prevSig,
+		s3EmptySHA256,
+		hex.EncodeToString(c.chunkHash.Sum(nil)),
+	}, "\n")
+	expected := hex.EncodeToString(s3HMAC(c.signingKey, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(c.chunkSig)) {
+		return s3ErrSignatureDoesNotMatch
+	}
+	c.prevSig = c.chunkSig
+	return nil
+}
+
+func (c *s3ChunkedReader) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && line != "" {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (c *s3ChunkedReader) readCRLF() error {
+	line, err := c.readLine()
+	if err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if line != "" {
+		return s3ErrIncompleteBody
+	}
+	return nil
+}
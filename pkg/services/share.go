@@ -13,6 +13,8 @@ import (
 	"github.com/tgdrive/teldrive/internal/api"
 	"github.com/tgdrive/teldrive/internal/cache"
 	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/events"
+	"github.com/tgdrive/teldrive/pkg/dto"
 	"github.com/tgdrive/teldrive/pkg/mapper"
 	"github.com/tgdrive/teldrive/pkg/repositories"
 	"golang.org/x/crypto/bcrypt"
@@ -92,6 +94,20 @@ func (a *apiService) SharesGetById(ctx context.Context, params api.SharesGetById
 	return res, nil
 }
 
+// recordShareEvent tells the owner of a share about it. The source is the
+// shared file, with the share ID alongside.
+func (a *apiService) recordShareEvent(ctx context.Context, eventType events.EventType, share *jetmodel.FileShares) {
+	source := &dto.Source{ID: share.FileID.String(), ShareID: share.ID.String()}
+	if file, err := a.repo.Files.GetByID(ctx, share.FileID); err == nil {
+		source.Type = file.Type
+		source.Name = file.Name
+		if file.ParentID != nil {
+			source.ParentID = file.ParentID.String()
+		}
+	}
+	a.events.Record(eventType, share.UserID, source)
+}
+
 func (a *apiService) issueShareToken(share *jetmodel.FileShares) (string, time.Time, error) {
 	expiresAt := time.Now().UTC().Add(24 * time.Hour)
 	if share.ExpiresAt != nil && share.ExpiresAt.Before(expiresAt) {
@@ -149,6 +165,7 @@ func (a *apiService) SharesUnlock(ctx context.Context, req *api.ShareUnlock, par
 	if err != nil {
 		return nil, &apiError{err: err}
 	}
+	a.recordShareEvent(ctx, events.OpShareUnlock, share)
 	maxAge := int(time.Until(expiresAt).Seconds())
 	if maxAge < 0 {
 		maxAge = 0
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tgdrive/teldrive/internal/logging"
+	"github.com/tgdrive/teldrive/internal/reader"
+	"go.uber.org/zap"
+)
+
+// multiBotMinRead is the smallest read worth starting extra bots for. Shorter
+// reads fit in one batch of a single bot.
+const multiBotMinRead = 8 << 20
+
+const (
+	// streamBotStartTimeout caps how long a stream waits for extra bots to
+	// come up. Bots still starting are used by later streams.
+	streamBotStartTimeout = 5 * time.Second
+	// streamBotIdleTimeout is how long a bot keeps running after the last
+	// stream using it ended.
+	streamBotIdleTimeout = 5 * time.Minute
+)
+
+// streamBots keeps the extra bots of multi-bot streams running between
+// requests, so a stream reuses a connected bot instead of logging in again.
+type streamBots struct {
+	mu      sync.Mutex
+	running map[string]*streamBot
+}
+
+type streamBot struct {
+	bot    reader.Bot
+	ready  chan struct{} // closed once bot can be used
+	done   chan struct{} // closed when the bot stopped
+	cancel context.CancelFunc
+	users  int
+	idle   *time.Timer
+}
+
+// acquire returns the running bot for token, starting it when needed. The
+// caller must release it.
+func (s *streamBots) acquire(a *apiService, token, botID string, logger *zap.Logger) *streamBot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.running[token]; ok {
+		b.users++
+		if b.idle != nil {
+			b.idle.Stop()
+			b.idle = nil
+		}
+		return b
+	}
+	if s.running == nil {
+		s.running = make(map[string]*streamBot)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &streamBot{ready: make(chan struct{}), done: make(chan struct{}), cancel: cancel, users: 1}
+	s.running[token] = b
+	go func() {
+		defer close(b.done)
+		err := a.runStreamBot(ctx, token, botID, func(bot reader.Bot) {
+			b.bot = bot
+			close(b.ready)
+		})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			logger.Warn("stream.multi_bot_failed", zap.String("bot_id", botID), zap.Error(err))
+		}
+		s.mu.Lock()
+		if s.running[token] == b {
+			delete(s.running, token)
+		}
+		s.mu.Unlock()
+	}()
+	return b
+}
+
+// release stops b once no stream has used it for streamBotIdleTimeout.
+func (s *streamBots) release(token string, b *streamBot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b.users--
+	if b.users > 0 {
+		return
+	}
+	b.idle = time.AfterFunc(streamBotIdleTimeout, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if b.users > 0 {
+			return
+		}
+		if s.running[token] == b {
+			delete(s.running, token)
+		}
+		b.cancel()
+	})
+}
+
+// extraStreamBots returns the user's bots other than the one with primaryID so
+// that a multi-bot stream can fetch chunks through all of them. Each bot talks
+// to Telegram through its own connection pool and stays running between
+// streams. Bots that are not ready within streamBotStartTimeout are left out
+// of this stream. The returned function releases every bot.
+func (a *apiService) extraStreamBots(ctx context.Context, userID int64, primaryID string) ([]reader.Bot, func()) {
+	logger := logging.Component("FILE").With(zap.Int64("user_id", userID))
+	tokens, err := a.channelManager.BotTokens(ctx, userID)
+	if err != nil {
+		logger.Warn("stream.multi_bot_tokens_failed", zap.Error(err))
+		return nil, func() {}
+	}
+	if limit := a.cnf.TG.Stream.BotsLimit; limit > 0 && len(tokens) > limit {
+		tokens = tokens[:limit]
+	}
+
+	type acquired struct {
+		token string
+		bot   *streamBot
+	}
+	var used []acquired
+	for _, token := range tokens {
+		botID, _, _ := strings.Cut(token, ":")
+		if botID == primaryID {
+			continue
+		}
+		used = append(used, acquired{token: token, bot: a.streamBots.acquire(a, token, botID, logger)})
+	}
+
+	timeout := time.NewTimer(streamBotStartTimeout)
+	defer timeout.Stop()
+	waiting := true
+	var bots []reader.Bot
+	for _, u := range used {
+		if waiting {
+			select {
+			case <-u.bot.ready:
+				bots = append(bots, u.bot.bot)
+				continue
+			case <-u.bot.done:
+				continue
+			case <-timeout.C:
+				waiting = false
+			case <-ctx.Done():
+				waiting = false
+			}
+		}
+		select {
+		case <-u.bot.ready:
+			bots = append(bots, u.bot.bot)
+		default:
+		}
+	}
+	if len(used) > len(bots) {
+		logger.Debug("stream.multi_bot_partial", zap.Int("ready", len(bots)), zap.Int("bots", len(used)))
+	}
+
+	return bots, func() {
+		for _, u := range used {
+			a.streamBots.release(u.token, u.bot)
+		}
+	}
+}
+
+// runStreamBot runs the bot with token until ctx ends, passing its pooled API
+// client to ready once it is authorised.
+func (a *apiService) runStreamBot(ctx context.Context, token, botID string, ready func(reader.Bot)) error {
+	client, err := a.telegram.BotClient(ctx, token, 5)
+	if err != nil {
+		return err
+	}
+	return a.telegram.RunWithAuth(ctx, client, token, func(ctx context.Context) error {
+		pool, err := a.telegram.NewUploadPool(ctx, client, int64(max(a.cnf.TG.Stream.Concurrency, 1)), 5)
+		if err != nil {
+			return err
+		}
+		defer pool.Close()
+		ready(reader.Bot{Client: pool.Default(ctx), ID: botID})
+		<-ctx.Done()
+		return nil
+	})
+}
+
+// multiBotReader releases the extra bots of a stream once the reader is closed.
+type multiBotReader struct {
+	io.ReadCloser
+	stop func()
+}
+
+func (r *multiBotReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.stop()
+	return err
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/require"
+	"github.com/tgdrive/teldrive/internal/config"
+)
+
+type streamBotsTelegram struct {
+	TelegramService
+	mu     sync.Mutex
+	starts map[string]int
+	failed string
+}
+
+func (t *streamBotsTelegram) BotClient(ctx context.Context, token string, retries int) (TelegramClient, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.starts[token]++
+	if token == t.failed {
+		return nil, errors.New("bot unavailable")
+	}
+	return nil, nil
+}
+
+func (t *streamBotsTelegram) RunWithAuth(ctx context.Context, client TelegramClient, token string, f func(ctx context.Context) error) error {
+	return f(ctx)
+}
+
+func (t *streamBotsTelegram) NewUploadPool(ctx context.Context, client TelegramClient, size int64, retries int) (UploadPool, error) {
+	return streamBotsPool{}, nil
+}
+
+type streamBotsPool struct{}
+
+func (streamBotsPool) Default(context.Context) *tg.Client { return nil }
+func (streamBotsPool) Close()                             {}
+
+type streamBotsChannels struct {
+	ChannelManager
+	tokens []string
+}
+
+func (c streamBotsChannels) BotTokens(context.Context, int64) ([]string, error) {
+	return c.tokens, nil
+}
+
+func TestExtraStreamBotsReusesRunningBots(t *testing.T) {
+	t.Parallel()
+
+	telegram := &streamBotsTelegram{starts: map[string]int{}, failed: "3:c"}
+	a := &apiService{
+		cnf:            &config.ServerCmdConfig{},
+		telegram:       telegram,
+		channelManager: streamBotsChannels{tokens: []string{"1:a", "2:b", "3:c"}},
+	}
+
+	bots, stop := a.extraStreamBots(context.Background(), 7, "1")
+	require.Len(t, bots, 1)
+	require.Equal(t, "2", bots[0].ID)
+	stop()
+
+	bots, stop = a.extraStreamBots(context.Background(), 7, "1")
+	require.Len(t, bots, 1)
+	defer stop()
+
+	telegram.mu.Lock()
+	defer telegram.mu.Unlock()
+	require.Equal(t, 0, telegram.starts["1:a"])
+	require.Equal(t, 1, telegram.starts["2:b"])
+	require.Equal(t, 2, telegram.starts["3:c"])
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"encoding/json"
+	"strings"
 
 	"github.com/tgdrive/teldrive/internal/api"
 	"github.com/tgdrive/teldrive/pkg/queue"
@@ -83,5 +84,14 @@ func toQueueOptions(v api.OptSyncOptions) queue.SyncOptions {
 	if v.Value.Sync.IsSet() {
 		out.Sync = v.Value.Sync.Value
 	}
+	if v.Value.Conflict.IsSet() {
+		out.Conflict = string(v.Value.Conflict.Value)
+	}
+	if v.Value.Deletion.IsSet() {
+		out.Deletion = string(v.Value.Deletion.Value)
+	}
+	if v.Value.BackupDir.IsSet() {
+		out.BackupDir = strings.TrimSpace(v.Value.BackupDir.Value)
+	}
 	return out
 }
@@ -90,6 +90,7 @@ type TelegramService interface {
 	GetMessages(ctx context.Context, client TelegramClient, ids []int, channelID int64) ([]tg.MessageClass, error)
 	GetParts(ctx context.Context, client TelegramClient, channelID int64, fileParts []api.Part, encrypted bool) ([]types.Part, error)
 	CopyFileParts(ctx context.Context, client TelegramClient, sourceChannelID int64, destinationChannelID int64, sourceParts []api.Part) ([]api.Part, error)
+	DeleteMessages(ctx context.Context, client TelegramClient, channelID int64, ids []int) error
 	UploadPart(ctx context.Context, apiClient *tg.Client, channelID int64, partName string, fileStream io.Reader, fileSize int64, threads int) (int, int64, error)
 	ChannelByID(ctx context.Context, client TelegramClient, channelID int64) (*tg.InputChannel, error)
 	ChannelByIDRaw(ctx context.Context, api *tg.Client, channelID int64) (*tg.InputChannel, error)
@@ -418,6 +419,25 @@ func (g *telegramService) CopyFileParts(ctx context.Context, client TelegramClie
 	return out, nil
 }
 
+func (g *telegramService) DeleteMessages(ctx context.Context, client TelegramClient, channelID int64, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return client.Run(ctx, func(ctx context.Context) error {
+		channel, err := tgc.ChannelByID(ctx, client.API(), channelID)
+		if err != nil {
+			return err
+		}
+		for start := 0; start < len(ids); start += 100 {
+			end := min(start+100, len(ids))
+			if _, err := client.API().ChannelsDeleteMessages(ctx, &tg.ChannelsDeleteMessagesRequest{Channel: channel, ID: ids[start:end]}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (g *telegramService) UploadPart(ctx context.Context, apiClient *tg.Client, channelID int64, partName string, fileStream io.Reader, fileSize int64, threads int) (int, int64, error) {
 	channel, err := tgc.ChannelByID(ctx, apiClient, channelID)
 	if err != nil {
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	pathpkg "path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/internal/auth"
+	"github.com/tgdrive/teldrive/internal/cache"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/events"
+	"github.com/tgdrive/teldrive/pkg/dto"
+	"github.com/tgdrive/teldrive/pkg/repositories"
+)
+
+const defaultTrashListLimit = 100
+
+func (a *apiService) trashRetention() time.Duration {
+	if a == nil || a.cnf == nil || a.cnf.Trash.Retention < 0 {
+		return 0
+	}
+	return a.cnf.Trash.Retention
+}
+
+// removeFiles moves files and folders to the trash, or straight to pending
+// deletion when the trash is disabled.
+func (a *apiService) removeFiles(ctx context.Context, userID int64, ids []uuid.UUID) ([]jetmodel.Files, error) {
+	if a.trashRetention() == 0 {
+		return a.repo.Files.DeleteBulkReturning(ctx, ids, userID, "pending_deletion")
+	}
+	return a.repo.Files.TrashBulkReturning(ctx, ids, userID, time.Now().UTC())
+}
+
+func (a *apiService) FilesListTrash(ctx context.Context, params api.FilesListTrashParams) (*api.TrashList, error) {
+	userID := auth.User(ctx)
+
+	limit := params.Limit.Or(defaultTrashListLimit)
+	cursor, err := parseTrashCursor(params.Cursor.Value)
+	if err != nil {
+		return nil, &apiError{err: err, code: 400}
+	}
+
+	rows, err := a.repo.Files.ListTrashed(ctx, userID, cursor, limit)
+	if err != nil {
+		return nil, &apiError{err: err}
+	}
+
+	retention := a.trashRetention()
+	items := make([]api.TrashItem, 0, len(rows))
+	for _, row := range rows {
+		item := api.TrashItem{
+			ID:       api.UUID(row.ID),
+			Name:     row.Name,
+			Type:     api.TrashItemType(row.Type),
+			MimeType: row.MimeType,
+		}
+		if row.Size != nil {
+			item.Size = api.NewOptInt64(*row.Size)
+		}
+		if row.TrashedPath != nil {
+			item.Path = *row.TrashedPath
+		}
+		if row.TrashedAt != nil {
+			item.TrashedAt = *row.TrashedAt
+			if retention > 0 {
+				item.PurgeAt = api.NewOptDateTime(row.TrashedAt.Add(retention))
+			}
+		}
+		items = append(items, item)
+	}
+
+	var nextCursor api.OptString
+	if len(rows) > 0 && len(rows) == limit {
+		last := rows[len(rows)-1]
+		if last.TrashedAt != nil {
+			nextCursor.SetTo(fmt.Sprintf("%s:%s", last.TrashedAt.UTC().Format(time.RFC3339Nano), last.ID.String()))
+		}
+	}
+
+	return &api.TrashList{Items: items, Meta: api.Meta{NextCursor: nextCursor}}, nil
+}
+
+func (a *apiService) FilesRestoreTrash(ctx context.Context, req *api.TrashRestore) error {
+	userID := auth.User(ctx)
+	if len(req.Ids) == 0 {
+		return &apiError{err: errors.New("ids should not be empty"), code: 409}
+	}
+	// Trashed files already count against the quota, so a restore only fails
+	// for a user who is over a limit, for example after it was lowered.
+	if err := a.checkQuota(ctx, userID, 0, 0); err != nil {
+		return err
+	}
+
+	var restored []jetmodel.Files
+	roots := make([]jetmodel.Files, 0, len(req.Ids))
+	err := a.repo.WithTx(ctx, func(txCtx context.Context) error {
+		for _, id := range req.Ids {
+			item, err := a.repo.Files.GetTrashedByIDAndUser(txCtx, uuid.UUID(id), userID)
+			if err != nil {
+				if errors.Is(err, repositories.ErrNotFound) {
+					return &apiError{err: fmt.Errorf("trashed file %s not found", uuid.UUID(id)), code: 404}
+				}
+				return &apiError{err: err}
+			}
+
+			parentID, err := a.restoreParentID(txCtx, userID, item)
+			if err != nil {
+				return &apiError{err: err}
+			}
+
+			rows, err := a.repo.Files.RestoreTrashed(txCtx, item.ID, userID, parentID)
+			if err != nil {
+				if errors.Is(err, repositories.ErrConflict) {
+					return &apiError{err: fmt.Errorf("%s already exists in the original folder", item.Name), code: 409}
+				}
+				return &apiError{err: err}
+			}
+			item.ParentID = &parentID
+			roots = append(roots, *item)
+			restored = append(restored, rows...)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(restored)*2)
+	for _, item := range restored {
+		idStr := item.ID.String()
+		keys = append(keys, cache.KeyFile(idStr), cache.KeyFileMessages(idStr))
+	}
+	if len(keys) > 0 {
+		a.cache.Delete(ctx, keys...)
+	}
+
+	for _, item := range roots {
+		a.events.Record(events.OpCreate, userID, &dto.Source{
+			ID:       item.ID.String(),
+			Type:     item.Type,
+			Name:     item.Name,
+			ParentID: item.ParentID.String(),
+		})
+	}
+
+	return nil
+}
+
+// restoreParentID returns the folder a trashed entry goes back to: its
+// original parent while that is still active, otherwise a folder recreated
+// from the path the entry had when it was deleted.
+func (a *apiService) restoreParentID(ctx context.Context, userID int64, item *jetmodel.Files) (uuid.UUID, error) {
+	if item.ParentID != nil {
+		parent, err := a.repo.Files.GetByIDAndUser(ctx, *item.ParentID, userID)
+		if err == nil && parent.Type == "folder" {
+			return parent.ID, nil
+		}
+		if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			return uuid.Nil, err
+		}
+	}
+
+	trashedPath := "/"
+	if item.TrashedPath != nil {
+		trashedPath = *item.TrashedPath
+	}
+	parentID, err := a.repo.Files.CreateDirectories(ctx, userID, pathpkg.Dir(trashedPath))
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return *parentID, nil
+}
+
+func (a *apiService) FilesEmptyTrash(ctx context.Context, req *api.TrashEmpty) error {
+	userID := auth.User(ctx)
+
+	ids := make([]uuid.UUID, 0, len(req.Ids))
+	for _, id := range req.Ids {
+		ids = append(ids, uuid.UUID(id))
+	}
+
+	if err := a.repo.Files.PurgeTrashed(ctx, userID, ids, nil); err != nil {
+		return &apiError{err: err}
+	}
+	return nil
+}
+
+func parseTrashCursor(raw string) (*repositories.TrashCursor, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	idx := strings.LastIndex(raw, ":")
+	if idx < 0 {
+		return nil, errors.New("invalid cursor")
+	}
+	trashedAt, err := time.Parse(time.RFC3339Nano, raw[:idx])
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	id, err := uuid.Parse(raw[idx+1:])
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return &repositories.TrashCursor{TrashedAt: trashedAt, ID: id}, nil
+}
@@ -111,6 +111,10 @@ func (a *apiService) UploadsUpload(ctx context.Context, req *api.UploadsUploadRe
 		zap.Int64("size", params.ContentLength),
 	)
 
+	if err := a.checkUploadQuota(ctx, userId, params.ID, params.ContentLength); err != nil {
+		return nil, err
+	}
+
 	stager, err := a.newUploadStager(ctx, userId, params.ChannelId.Value)
 	if err != nil {
 		return nil, &apiError{err: err}
@@ -6,10 +6,13 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"strconv"
 
 	"github.com/google/uuid"
 	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/events"
 	"github.com/tgdrive/teldrive/internal/hash"
+	"github.com/tgdrive/teldrive/pkg/dto"
 	"go.uber.org/zap"
 )
 
@@ -48,7 +51,20 @@ func (a *apiService) resolveUploadChannel(ctx context.Context, userID, requested
 		return 0, err
 	}
 
-	return a.channelManager.CreateNewChannel(ctx, "", userID, true)
+	newChannelID, createErr := a.channelManager.CreateNewChannel(ctx, "", userID, true)
+	if createErr != nil {
+		return 0, createErr
+	}
+	// Only a full channel being replaced is a rollover, not a first channel
+	if err == nil && newChannelID != channelID {
+		a.events.Record(events.OpChannelRollover, userID, &dto.Source{
+			ID:                strconv.FormatInt(newChannelID, 10),
+			Type:              "channel",
+			ChannelID:         newChannelID,
+			PreviousChannelID: channelID,
+		})
+	}
+	return newChannelID, nil
 }
 
 func (a *apiService) newUploadStager(ctx context.Context, userID, requestedChannelID int64) (*uploadStager, error) {
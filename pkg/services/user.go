@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -15,8 +16,12 @@ import (
 	"github.com/tgdrive/teldrive/internal/auth"
 	"github.com/tgdrive/teldrive/internal/cache"
 	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/database/types"
+	"github.com/tgdrive/teldrive/internal/events"
 	"github.com/tgdrive/teldrive/internal/logging"
 	"github.com/tgdrive/teldrive/internal/tgstorage"
+	"github.com/tgdrive/teldrive/internal/utils"
+	"github.com/tgdrive/teldrive/pkg/dto"
 	"github.com/tgdrive/teldrive/pkg/repositories"
 	"go.uber.org/zap"
 )
@@ -242,6 +247,7 @@ func (a *apiService) UsersListApiKeys(ctx context.Context) ([]api.UserApiKey, er
 		if key.LastUsedAt != nil {
 			item.LastUsedAt = api.NewOptDateTime(key.LastUsedAt.UTC())
 		}
+		item.Scopes, item.FolderId = apiKeyRestrictions(&key)
 		out = append(out, item)
 	}
 
@@ -264,6 +270,28 @@ func (a *apiService) UsersCreateApiKey(ctx context.Context, req *api.UserApiKeyC
 		expiresAt = &expires
 	}
 
+	var scopes []string
+	for _, scope := range req.Scopes {
+		if !slices.Contains(scopes, string(scope)) {
+			scopes = append(scopes, string(scope))
+		}
+	}
+
+	var folderID *uuid.UUID
+	if req.FolderId.IsSet() {
+		folder, err := a.repo.Files.GetByIDAndUser(ctx, uuid.UUID(req.FolderId.Value), userID)
+		if err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				return nil, &apiError{err: errors.New("folder not found"), code: 404}
+			}
+			return nil, &apiError{err: err}
+		}
+		if folder.Type != string(api.FileTypeFolder) {
+			return nil, &apiError{err: errors.New("folderId must be a folder"), code: 400}
+		}
+		folderID = &folder.ID
+	}
+
 	raw, err := generateToken(32)
 	if err != nil {
 		return nil, &apiError{err: err}
@@ -275,23 +303,46 @@ func (a *apiService) UsersCreateApiKey(ctx context.Context, req *api.UserApiKeyC
 		Name:      name,
 		TokenHash: hashToken(keyValue),
 		ExpiresAt: expiresAt,
+		FolderID:  folderID,
+	}
+	if len(scopes) > 0 {
+		row.Scopes = utils.Ptr(types.NewJSONB(scopes))
 	}
 	if err := a.repo.APIKeys.Create(ctx, &row); err != nil {
 		return nil, &apiError{err: err}
 	}
+	a.events.Record(events.OpAPIKeyCreate, userID, &dto.Source{
+		ID:   row.ID.String(),
+		Type: "apiKey",
+		Name: row.Name,
+	})
 
 	res := &api.UserApiKeyCreateResult{}
 	res.ID = api.UUID(row.ID)
 	res.Name = row.Name
 	res.Key = keyValue
+	res.S3SecretKey = api.NewOptString(auth.S3SecretKey(a.cnf.JWT.Secret, row.TokenHash))
 	res.CreatedAt = row.CreatedAt.UTC()
 	if row.ExpiresAt != nil {
 		res.ExpiresAt = api.NewOptDateTime(row.ExpiresAt.UTC())
 	}
+	res.Scopes, res.FolderId = apiKeyRestrictions(&row)
 
 	return res, nil
 }
 
+func apiKeyRestrictions(key *jetmodel.APIKeys) ([]api.ApiKeyScope, api.OptUUID) {
+	var scopes []api.ApiKeyScope
+	if key.Scopes != nil {
+		scopes = utils.Map(key.Scopes.Data, func(scope string) api.ApiKeyScope { return api.ApiKeyScope(scope) })
+	}
+	var folderID api.OptUUID
+	if key.FolderID != nil {
+		folderID = api.NewOptUUID(api.UUID(*key.FolderID))
+	}
+	return scopes, folderID
+}
+
 func (a *apiService) UsersRemoveApiKey(ctx context.Context, params api.UsersRemoveApiKeyParams) error {
 	userID := auth.User(ctx)
 	if err := a.repo.APIKeys.Revoke(ctx, userID, uuid.UUID(params.ID)); err != nil {
@@ -359,7 +410,24 @@ func (a *apiService) UsersStats(ctx context.Context) (*api.UserConfig, error) {
 	if err != nil {
 		tokens = []string{}
 	}
-	return &api.UserConfig{Bots: tokens, ChannelId: channelId}, nil
+
+	usage, err := a.storageUsage(ctx, userId)
+	if err != nil {
+		return nil, &apiError{err: err}
+	}
+	var quota api.UserQuota
+	if user, err := a.repo.Users.GetByID(ctx, userId); err == nil {
+		quota = toUserQuota(user)
+	} else if !errors.Is(err, repositories.ErrNotFound) {
+		return nil, &apiError{err: err}
+	}
+
+	return &api.UserConfig{
+		Bots:      tokens,
+		ChannelId: channelId,
+		Usage:     api.StorageUsage{TotalFiles: usage.TotalFiles, TotalSize: usage.TotalSize},
+		Quota:     quota,
+	}, nil
 }
 
 func (a *apiService) UsersUpdateChannel(ctx context.Context, req *api.ChannelUpdate) error {
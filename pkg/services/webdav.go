@@ -0,0 +1,758 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/internal/auth"
+	"github.com/tgdrive/teldrive/internal/cache"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/events"
+	"github.com/tgdrive/teldrive/internal/logging"
+	"github.com/tgdrive/teldrive/internal/md5"
+	"github.com/tgdrive/teldrive/pkg/dto"
+	"github.com/tgdrive/teldrive/pkg/repositories"
+	"github.com/tgdrive/teldrive/pkg/types"
+	"go.uber.org/zap"
+	"golang.org/x/net/webdav"
+)
+
+// webdavReadMethods are the methods a key with only the files:read scope may
+// use.
+var webdavReadMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	"PROPFIND":         true,
+}
+
+type webdavService struct {
+	api    *apiService
+	prefix string
+	mu     sync.Mutex
+	locks  map[int64]webdav.LockSystem
+}
+
+// NewWebDAVHandler serves the user's file tree over WebDAV under prefix.
+// Clients authenticate with an API key, sent either as the basic auth password
+// or in the X-Api-Key header.
+func NewWebDAVHandler(api *apiService, prefix string) http.Handler {
+	return &webdavService{
+		api:    api,
+		prefix: prefix,
+		locks:  make(map[int64]webdav.LockSystem),
+	}
+}
+
+func (s *webdavService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	claims, err := s.authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Teldrive", charset="UTF-8"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	if err := auth.CheckGatewayScope(claims, !webdavReadMethods[r.Method]); err != nil {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	ctx := auth.WithAuthSource(auth.WithJWTUser(r.Context(), claims), auth.AuthSourceAPIKey)
+	userID := auth.User(ctx)
+	// The webdav package answers failed writes with 405, so a PUT that
+	// cannot fit is turned away here while its length is still known.
+	if r.Method == http.MethodPut && r.ContentLength > 0 {
+		if err := s.api.checkQuota(ctx, userID, 0, r.ContentLength); err != nil {
+			if isQuotaError(err) {
+				http.Error(w, err.Error(), http.StatusInsufficientStorage)
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+	fs := &webdavFS{
+		api:     s.api,
+		session: &jetmodel.Sessions{UserID: userID, TgSession: claims.TgSession},
+		entries: make(map[string]*jetmodel.Files),
+	}
+	defer fs.close()
+
+	handler := &webdav.Handler{
+		Prefix:     s.prefix,
+		FileSystem: fs,
+		LockSystem: s.lockSystem(userID),
+		Logger: func(r *http.Request, err error) {
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				logging.FromContext(r.Context()).Debug("webdav.request_failed",
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.Error(err))
+			}
+		},
+	}
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func (s *webdavService) authenticate(r *http.Request) (*types.JWTClaims, error) {
+	token := r.Header.Get("X-Api-Key")
+	if token == "" {
+		if _, password, ok := r.BasicAuth(); ok {
+			token = password
+		} else if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			token = bearer
+		}
+	}
+	if token == "" {
+		return nil, auth.ErrAuthAPIKeyInvalid
+	}
+	return auth.VerifyAPIKey(r.Context(), s.api.repo.Sessions, s.api.repo.APIKeys, s.api.cache, token)
+}
+
+// lockSystem keeps locks per user, since lock names are paths within a
+// user's own tree.
+func (s *webdavService) lockSystem(userID int64) webdav.LockSystem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ls, ok := s.locks[userID]
+	if !ok {
+		ls = webdav.NewMemLS()
+		s.locks[userID] = ls
+	}
+	return ls
+}
+
+// webdavFS implements webdav.FileSystem for a single request. Entries resolved
+// while serving the request are remembered so a PROPFIND does not walk the
+// tree once per child.
+type webdavFS struct {
+	api     *apiService
+	session *jetmodel.Sessions
+	entries map[string]*jetmodel.Files
+	stream  *webdavStream
+}
+
+// webdavStream keeps a Telegram client running for the rest of the request
+// once a file body is read.
+type webdavStream struct {
+	client TelegramClient
+	botID  string
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func webdavPath(name string) string {
+	return path.Clean("/" + name)
+}
+
+// teldrivePath maps a WebDAV path onto the user's tree, which is rooted at
+// the "root" folder.
+func teldrivePath(name string) string {
+	return path.Join("/root", name)
+}
+
+func webdavError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repositories.ErrNotFound):
+		return os.ErrNotExist
+	case errors.Is(err, repositories.ErrConflict):
+		return os.ErrExist
+	default:
+		return err
+	}
+}
+
+func (fs *webdavFS) userID() int64 {
+	return fs.session.UserID
+}
+
+func (fs *webdavFS) lookup(ctx context.Context, name string) (*jetmodel.Files, error) {
+	if file, ok := fs.entries[name]; ok {
+		return file, nil
+	}
+	file, err := fs.api.fileByPath(ctx, fs.userID(), name)
+	if err != nil {
+		return nil, webdavError(err)
+	}
+	fs.entries[name] = file
+	return file, nil
+}
+
+func (fs *webdavFS) forget(name string) {
+	for key := range fs.entries {
+		if key == name || strings.HasPrefix(key, name+"/") {
+			delete(fs.entries, key)
+		}
+	}
+}
+
+// parentOf returns the folder that will hold name. WebDAV does not create
+// intermediate collections, so a missing parent is reported as not found.
+func (fs *webdavFS) parentOf(ctx context.Context, name string) (*uuid.UUID, error) {
+	parent, err := fs.lookup(ctx, path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	if parent.Type != string(api.FileTypeFolder) {
+		return nil, os.ErrNotExist
+	}
+	return &parent.ID, nil
+}
+
+func (fs *webdavFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	name = webdavPath(name)
+	if name == "/" {
+		return os.ErrExist
+	}
+	if _, err := fs.lookup(ctx, name); err == nil {
+		return os.ErrExist
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	parentID, err := fs.parentOf(ctx, name)
+	if err != nil {
+		return err
+	}
+	id, err := fs.api.repo.Files.CreateDirectories(ctx, fs.userID(), teldrivePath(name))
+	if err != nil {
+		return webdavError(err)
+	}
+
+	fs.api.events.Record(events.OpCreate, fs.userID(), &dto.Source{
+		ID:       id.String(),
+		Type:     string(api.FileTypeFolder),
+		Name:     path.Base(name),
+		ParentID: parentID.String(),
+	})
+	return nil
+}
+
+func (fs *webdavFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	name = webdavPath(name)
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		return fs.openWriter(ctx, name, flag)
+	}
+	file, err := fs.lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &webdavFile{ctx: ctx, fs: fs, name: name, file: file}, nil
+}
+
+func (fs *webdavFS) openWriter(ctx context.Context, name string, flag int) (webdav.File, error) {
+	existing, err := fs.lookup(ctx, name)
+	switch {
+	case err == nil:
+		if existing.Type == string(api.FileTypeFolder) {
+			return nil, os.ErrInvalid
+		}
+		if flag&os.O_EXCL != 0 {
+			return nil, os.ErrExist
+		}
+	case errors.Is(err, os.ErrNotExist):
+		if flag&os.O_CREATE == 0 {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	parentID, err := fs.parentOf(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	partSize := fs.api.cnf.WebDAV.ChunkSize
+	if partSize <= 0 {
+		partSize = defaultSyncChunkSize
+	}
+	w := &webdavWriter{
+		ctx:      ctx,
+		fs:       fs,
+		name:     name,
+		parentID: *parentID,
+		uploadID: uuid.NewString(),
+		partSize: partSize,
+	}
+	w.file = &jetmodel.Files{
+		Name:      path.Base(name),
+		Type:      string(api.FileTypeFile),
+		MimeType:  webdavMimeType(name),
+		Size:      &w.size,
+		UpdatedAt: time.Now().UTC(),
+	}
+	return w, nil
+}
+
+func (fs *webdavFS) RemoveAll(ctx context.Context, name string) error {
+	name = webdavPath(name)
+	if name == "/" {
+		return os.ErrPermission
+	}
+	file, err := fs.lookup(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := fs.api.removeFiles(ctx, fs.userID(), []uuid.UUID{file.ID})
+	if err != nil {
+		return webdavError(err)
+	}
+	fs.forget(name)
+
+	keys := make([]string, 0, len(deleted)*2)
+	for _, item := range deleted {
+		idStr := item.ID.String()
+		keys = append(keys, cache.KeyFile(idStr), cache.KeyFileMessages(idStr))
+	}
+	if len(keys) > 0 {
+		fs.api.cache.Delete(ctx, keys...)
+	}
+
+	var parentID string
+	if file.ParentID != nil {
+		parentID = file.ParentID.String()
+	}
+	fs.api.events.Record(events.OpDelete, fs.userID(), &dto.Source{
+		ID:       file.ID.String(),
+		Type:     file.Type,
+		Name:     file.Name,
+		ParentID: parentID,
+	})
+	return nil
+}
+
+func (fs *webdavFS) Rename(ctx context.Context, oldName, newName string) error {
+	oldName, newName = webdavPath(oldName), webdavPath(newName)
+	if oldName == "/" || newName == "/" {
+		return os.ErrPermission
+	}
+	if strings.HasPrefix(newName, oldName+"/") {
+		return os.ErrInvalid
+	}
+	file, err := fs.lookup(ctx, oldName)
+	if err != nil {
+		return err
+	}
+	destParentID, err := fs.parentOf(ctx, newName)
+	if err != nil {
+		return err
+	}
+
+	name := path.Base(newName)
+	if err := fs.api.repo.Files.MoveSingle(ctx, file.ID, fs.userID(), destParentID, &name); err != nil {
+		return webdavError(err)
+	}
+	fs.forget(oldName)
+	fs.forget(newName)
+	fs.api.cache.Delete(ctx, cache.KeyFile(file.ID.String()))
+
+	var parentID string
+	if file.ParentID != nil {
+		parentID = file.ParentID.String()
+	}
+	fs.api.events.Record(events.OpMove, fs.userID(), &dto.Source{
+		ID:           file.ID.String(),
+		Type:         file.Type,
+		Name:         name,
+		ParentID:     parentID,
+		DestParentID: destParentID.String(),
+	})
+	return nil
+}
+
+func (fs *webdavFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	file, err := fs.lookup(ctx, webdavPath(name))
+	if err != nil {
+		return nil, err
+	}
+	return &webdavFileInfo{file: file}, nil
+}
+
+func (fs *webdavFS) readDir(ctx context.Context, name string, dir *jetmodel.Files) ([]os.FileInfo, error) {
+	items, err := fs.api.listFolder(ctx, fs.userID(), dir.ID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]os.FileInfo, 0, len(items))
+	for i := range items {
+		item := &items[i]
+		fs.entries[path.Join(name, item.Name)] = item
+		out = append(out, &webdavFileInfo{file: item})
+	}
+	return out, nil
+}
+
+// openReader returns a reader over bytes [start, end] of file, starting the
+// Telegram client on first use.
+func (fs *webdavFS) openReader(ctx context.Context, file *jetmodel.Files, start, end int64) (io.ReadCloser, error) {
+	stream, err := fs.startStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	full, err := cache.Fetch(ctx, fs.api.cache, cache.KeyFile(file.ID.String()), 0, func() (*jetmodel.Files, error) {
+		return fs.api.repo.Files.GetByID(ctx, file.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fs.api.openFileReader(stream.ctx, stream.client, stream.botID, full, start, end)
+}
+
+func (fs *webdavFS) startStream(ctx context.Context) (*webdavStream, error) {
+	if fs.stream != nil {
+		return fs.stream, nil
+	}
+
+	logger := logging.Component("WEBDAV").With(zap.Int64("user_id", fs.userID()))
+	client, token, botID, err := fs.api.streamClient(ctx, fs.session, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	ready := make(chan context.Context, 1)
+	done := make(chan struct{})
+	var runErr error
+	go func() {
+		defer close(done)
+		runErr = fs.api.telegram.RunWithAuth(runCtx, client, token, func(ctx context.Context) error {
+			ready <- ctx
+			<-ctx.Done()
+			return nil
+		})
+	}()
+
+	select {
+	case clientCtx := <-ready:
+		fs.stream = &webdavStream{client: client, botID: botID, ctx: clientCtx, cancel: cancel, done: done}
+		return fs.stream, nil
+	case <-done:
+		cancel()
+		if runErr == nil {
+			runErr = errors.New("telegram client stopped")
+		}
+		return nil, runErr
+	}
+}
+
+func (fs *webdavFS) close() {
+	if fs.stream != nil {
+		fs.stream.cancel()
+		<-fs.stream.done
+		fs.stream = nil
+	}
+}
+
+type webdavFileInfo struct {
+	file *jetmodel.Files
+}
+
+func (fi *webdavFileInfo) Name() string { return fi.file.Name }
+
+func (fi *webdavFileInfo) Size() int64 {
+	if fi.IsDir() || fi.file.Size == nil {
+		return 0
+	}
+	return *fi.file.Size
+}
+
+func (fi *webdavFileInfo) Mode() os.FileMode {
+	if fi.IsDir() {
+		return os.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+func (fi *webdavFileInfo) ModTime() time.Time { return fi.file.UpdatedAt }
+
+func (fi *webdavFileInfo) IsDir() bool { return fi.file.Type == string(api.FileTypeFolder) }
+
+func (fi *webdavFileInfo) Sys() any { return nil }
+
+// ContentType avoids webdav sniffing the first bytes of every file listed.
+func (fi *webdavFileInfo) ContentType(ctx context.Context) (string, error) {
+	if fi.file.MimeType != "" {
+		return fi.file.MimeType, nil
+	}
+	return defaultContentType, nil
+}
+
+// ETag matches the tag sent by the stream endpoint.
+func (fi *webdavFileInfo) ETag(ctx context.Context) (string, error) {
+	return fmt.Sprintf("\"%s\"", md5.FromString(fi.file.ID.String()+strconv.FormatInt(fi.Size(), 10))), nil
+}
+
+// webdavFile is opened for reading. Bodies are fetched lazily from the
+// current offset, so a seek only costs a new reader.
+type webdavFile struct {
+	ctx      context.Context
+	fs       *webdavFS
+	name     string
+	file     *jetmodel.Files
+	offset   int64
+	reader   io.ReadCloser
+	children []os.FileInfo
+	listed   bool
+}
+
+func (f *webdavFile) Close() error {
+	return f.closeReader()
+}
+
+func (f *webdavFile) closeReader() error {
+	if f.reader == nil {
+		return nil
+	}
+	err := f.reader.Close()
+	f.reader = nil
+	return err
+}
+
+func (f *webdavFile) Read(p []byte) (int, error) {
+	info := &webdavFileInfo{file: f.file}
+	if info.IsDir() {
+		return 0, os.ErrInvalid
+	}
+	size := info.Size()
+	if f.offset >= size {
+		return 0, io.EOF
+	}
+	if f.reader == nil {
+		reader, err := f.fs.openReader(f.ctx, f.file, f.offset, size-1)
+		if err != nil {
+			return 0, err
+		}
+		f.reader = reader
+	}
+	n, err := f.reader.Read(p)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *webdavFile) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = f.offset + offset
+	case io.SeekEnd:
+		abs = (&webdavFileInfo{file: f.file}).Size() + offset
+	default:
+		return 0, os.ErrInvalid
+	}
+	if abs < 0 {
+		return 0, os.ErrInvalid
+	}
+	if abs != f.offset {
+		if err := f.closeReader(); err != nil {
+			return 0, err
+		}
+		f.offset = abs
+	}
+	return abs, nil
+}
+
+func (f *webdavFile) Readdir(count int) ([]os.FileInfo, error) {
+	if f.file.Type != string(api.FileTypeFolder) {
+		return nil, os.ErrInvalid
+	}
+	if !f.listed {
+		children, err := f.fs.readDir(f.ctx, f.name, f.file)
+		if err != nil {
+			return nil, err
+		}
+		f.children = children
+		f.listed = true
+	}
+	if count <= 0 {
+		out := f.children
+		f.children = nil
+		return out, nil
+	}
+	if len(f.children) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(f.children))
+	out := f.children[:n]
+	f.children = f.children[n:]
+	return out, nil
+}
+
+func (f *webdavFile) Stat() (os.FileInfo, error) {
+	return &webdavFileInfo{file: f.file}, nil
+}
+
+func (f *webdavFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+// webdavWriter stores a file written over WebDAV. The body is spooled to disk
+// one part at a time and each full part is staged as soon as it is complete;
+// the file row is created on Close.
+type webdavWriter struct {
+	ctx      context.Context
+	fs       *webdavFS
+	name     string
+	parentID uuid.UUID
+	uploadID string
+	partSize int64
+	file     *jetmodel.Files
+	spool    *os.File
+	buffered int64
+	partNo   int
+	size     int64
+	stager   *uploadStager
+}
+
+func (w *webdavWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if w.spool == nil {
+			spool, err := os.CreateTemp("", "teldrive-webdav-*")
+			if err != nil {
+				return written, err
+			}
+			w.spool = spool
+		}
+		n := int(min(int64(len(p)), w.partSize-w.buffered))
+		m, err := w.spool.Write(p[:n])
+		written += m
+		w.buffered += int64(m)
+		w.size += int64(m)
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+		if w.buffered == w.partSize {
+			if err := w.flushPart(); err != nil {
+				return written, err
+			}
+		}
+	}
+	w.file.UpdatedAt = time.Now().UTC()
+	return written, nil
+}
+
+func (w *webdavWriter) flushPart() error {
+	if _, err := w.spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := w.fs.api.checkUploadQuota(w.ctx, w.fs.userID(), w.uploadID, w.buffered); err != nil {
+		return err
+	}
+	if w.stager == nil {
+		stager, err := w.fs.api.newUploadStager(w.ctx, w.fs.userID(), 0)
+		if err != nil {
+			return err
+		}
+		w.stager = stager
+	}
+
+	w.partNo++
+	err := w.stager.Run(w.ctx, func(ctx context.Context) error {
+		_, err := w.stager.StagePart(ctx, uploadStagePartRequest{
+			UploadID: w.uploadID,
+			FileName: w.file.Name,
+			PartNo:   w.partNo,
+			Reader:   w.spool,
+			Size:     w.buffered,
+			Hashing:  true,
+			Threads:  w.fs.api.cnf.TG.Uploads.Threads,
+		}, logging.FromContext(ctx))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	w.buffered = 0
+	if err := w.spool.Truncate(0); err != nil {
+		return err
+	}
+	_, err = w.spool.Seek(0, io.SeekStart)
+	return err
+}
+
+func (w *webdavWriter) Close() error {
+	defer w.cleanup()
+
+	if w.buffered > 0 {
+		if err := w.flushPart(); err != nil {
+			return err
+		}
+	}
+
+	fileReq := &api.File{
+		Name:     w.file.Name,
+		Type:     api.FileTypeFile,
+		ParentId: api.NewOptUUID(api.UUID(w.parentID)),
+		MimeType: api.NewOptString(w.file.MimeType),
+		Size:     api.NewOptInt64(w.size),
+	}
+	if w.partNo > 0 {
+		fileReq.UploadId = api.NewOptString(w.uploadID)
+		fileReq.ChannelId = api.NewOptInt64(w.stager.channelID)
+	}
+
+	created, err := w.fs.api.FilesCreate(w.ctx, fileReq)
+	if err != nil {
+		return err
+	}
+	w.file.ID = uuid.UUID(created.ID.Value)
+	w.fs.forget(w.name)
+	return nil
+}
+
+func (w *webdavWriter) cleanup() {
+	if w.stager != nil {
+		w.stager.Close()
+		w.stager = nil
+	}
+	if w.spool != nil {
+		_ = w.spool.Close()
+		_ = os.Remove(w.spool.Name())
+		w.spool = nil
+	}
+}
+
+func (w *webdavWriter) Read(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (w *webdavWriter) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && (whence == io.SeekCurrent || whence == io.SeekEnd) {
+		return w.size, nil
+	}
+	return 0, os.ErrInvalid
+}
+
+func (w *webdavWriter) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+func (w *webdavWriter) Stat() (os.FileInfo, error) {
+	return &webdavFileInfo{file: w.file}, nil
+}
+
+func webdavMimeType(name string) string {
+	if mimeType := mime.TypeByExtension(path.Ext(name)); mimeType != "" {
+		return mimeType
+	}
+	return defaultContentType
+}
@@ -0,0 +1,546 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/riverqueue/river"
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/internal/auth"
+	"github.com/tgdrive/teldrive/internal/cache"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/database/types"
+	"github.com/tgdrive/teldrive/internal/events"
+	"github.com/tgdrive/teldrive/internal/logging"
+	"github.com/tgdrive/teldrive/internal/version"
+	"github.com/tgdrive/teldrive/pkg/dto"
+	"github.com/tgdrive/teldrive/pkg/mapper"
+	"github.com/tgdrive/teldrive/pkg/queue"
+	"github.com/tgdrive/teldrive/pkg/repositories"
+	"go.uber.org/zap"
+)
+
+// Headers sent with every webhook delivery. The signature is the hex HMAC-SHA256
+// of "<timestamp>.<body>" keyed with the webhook secret.
+const (
+	webhookHeaderEvent     = "X-Teldrive-Event"
+	webhookHeaderDelivery  = "X-Teldrive-Delivery"
+	webhookHeaderTimestamp = "X-Teldrive-Timestamp"
+	webhookHeaderSignature = "X-Teldrive-Signature"
+)
+
+const (
+	webhookDeliveryLogSize  = 100
+	webhookSubscriptionsTTL = time.Minute
+	webhookDispatchTimeout  = 10 * time.Second
+)
+
+// webhookSubscription is the cached part of a webhook that dispatch needs.
+// Secrets stay in the database.
+type webhookSubscription struct {
+	ID     string
+	Events []string
+}
+
+func (a *apiService) WebhooksList(ctx context.Context) ([]api.Webhook, error) {
+	rows, err := a.repo.Webhooks.ListByUserID(ctx, auth.User(ctx))
+	if err != nil {
+		return nil, &apiError{err: err}
+	}
+	out := make([]api.Webhook, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, toAPIWebhook(row))
+	}
+	return out, nil
+}
+
+func (a *apiService) WebhooksCreate(ctx context.Context, req *api.WebhookCreate) (*api.WebhookCreateResult, error) {
+	userID := auth.User(ctx)
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, &apiError{err: errors.New("name is required"), code: 400}
+	}
+	endpoint, err := validateWebhookURL(req.URL, a.webhookAllowPrivate())
+	if err != nil {
+		return nil, &apiError{err: err, code: 400}
+	}
+	filters, err := webhookEventFilters(req.Events)
+	if err != nil {
+		return nil, &apiError{err: err, code: 400}
+	}
+	secret := strings.TrimSpace(req.Secret.Or(""))
+	if secret == "" {
+		if secret, err = generateToken(32); err != nil {
+			return nil, &apiError{err: err}
+		}
+	}
+
+	row := jetmodel.Webhooks{
+		UserID:  userID,
+		Name:    name,
+		URL:     endpoint,
+		Events:  types.NewJSONB(filters),
+		Secret:  secret,
+		Enabled: req.Enabled.Or(true),
+	}
+	if err := a.repo.Webhooks.Create(ctx, &row); err != nil {
+		return nil, &apiError{err: err}
+	}
+	a.invalidateWebhookSubscriptions(ctx, userID)
+
+	hook := toAPIWebhook(row)
+	return &api.WebhookCreateResult{
+		ID:        hook.ID,
+		Name:      hook.Name,
+		URL:       hook.URL,
+		Events:    hook.Events,
+		Enabled:   hook.Enabled,
+		CreatedAt: hook.CreatedAt,
+		UpdatedAt: hook.UpdatedAt,
+		Secret:    secret,
+	}, nil
+}
+
+func (a *apiService) WebhooksGet(ctx context.Context, params api.WebhooksGetParams) (*api.Webhook, error) {
+	row, err := a.getWebhook(ctx, uuid.UUID(params.ID), auth.User(ctx))
+	if err != nil {
+		return nil, err
+	}
+	hook := toAPIWebhook(*row)
+	return &hook, nil
+}
+
+func (a *apiService) WebhooksUpdate(ctx context.Context, req *api.WebhookUpdate, params api.WebhooksUpdateParams) (*api.Webhook, error) {
+	userID := auth.User(ctx)
+	id := uuid.UUID(params.ID)
+	update := repositories.WebhookUpdate{}
+	if req.Name.IsSet() {
+		name := strings.TrimSpace(req.Name.Value)
+		if name == "" {
+			return nil, &apiError{err: errors.New("name is required"), code: 400}
+		}
+		update.Name = &name
+	}
+	if req.URL.IsSet() {
+		endpoint, err := validateWebhookURL(req.URL.Value, a.webhookAllowPrivate())
+		if err != nil {
+			return nil, &apiError{err: err, code: 400}
+		}
+		update.URL = &endpoint
+	}
+	if req.Events != nil {
+		filters, err := webhookEventFilters(req.Events)
+		if err != nil {
+			return nil, &apiError{err: err, code: 400}
+		}
+		update.Events = &filters
+	}
+	if req.Secret.IsSet() {
+		secret := strings.TrimSpace(req.Secret.Value)
+		if secret == "" {
+			return nil, &apiError{err: errors.New("secret must not be empty"), code: 400}
+		}
+		update.Secret = &secret
+	}
+	if req.Enabled.IsSet() {
+		update.Enabled = &req.Enabled.Value
+	}
+
+	if err := a.repo.Webhooks.Update(ctx, id, userID, update); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, &apiError{err: errors.New("webhook not found"), code: 404}
+		}
+		return nil, &apiError{err: err}
+	}
+	a.invalidateWebhookSubscriptions(ctx, userID)
+	return a.WebhooksGet(ctx, api.WebhooksGetParams{ID: params.ID})
+}
+
+func (a *apiService) WebhooksDelete(ctx context.Context, params api.WebhooksDeleteParams) error {
+	userID := auth.User(ctx)
+	if err := a.repo.Webhooks.Delete(ctx, uuid.UUID(params.ID), userID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return &apiError{err: errors.New("webhook not found"), code: 404}
+		}
+		return &apiError{err: err}
+	}
+	a.invalidateWebhookSubscriptions(ctx, userID)
+	return nil
+}
+
+func (a *apiService) WebhooksListDeliveries(ctx context.Context, params api.WebhooksListDeliveriesParams) ([]api.WebhookDelivery, error) {
+	row, err := a.getWebhook(ctx, uuid.UUID(params.ID), auth.User(ctx))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := a.repo.Webhooks.ListDeliveries(ctx, row.ID, int(params.Limit.Or(50)))
+	if err != nil {
+		return nil, &apiError{err: err}
+	}
+	out := make([]api.WebhookDelivery, 0, len(rows))
+	for _, delivery := range rows {
+		out = append(out, toAPIWebhookDelivery(delivery))
+	}
+	return out, nil
+}
+
+// WebhooksTest sends a webhooks.test event right away, even to a disabled
+// webhook, and reports how the endpoint answered.
+func (a *apiService) WebhooksTest(ctx context.Context, params api.WebhooksTestParams) (*api.WebhookDelivery, error) {
+	userID := auth.User(ctx)
+	row, err := a.getWebhook(ctx, uuid.UUID(params.ID), userID)
+	if err != nil {
+		return nil, err
+	}
+	evt := dto.Event{
+		ID:        uuid.NewString(),
+		Type:      string(events.OpWebhookTest),
+		UserID:    userID,
+		Source:    &dto.Source{ID: row.ID.String(), Name: row.Name},
+		CreatedAt: time.Now().UTC(),
+	}
+	payload, err := webhookPayload(evt)
+	if err != nil {
+		return nil, &apiError{err: err}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.webhookTimeout())
+	defer cancel()
+	delivery, _ := a.deliverWebhook(ctx, row, uuid.MustParse(evt.ID), evt.Type, payload, 1)
+	out := toAPIWebhookDelivery(*delivery)
+	return &out, nil
+}
+
+// WebhookDeliver posts one event to a webhook. A failed attempt returns an
+// error so River retries it with backoff; deliveries to webhooks that were
+// deleted or disabled in the meantime are cancelled.
+func (e *jobExecutor) WebhookDeliver(ctx context.Context, args queue.WebhookDeliverArgs, attempt int) error {
+	id, err := uuid.Parse(args.WebhookID)
+	if err != nil {
+		return river.JobCancel(fmt.Errorf("invalid webhook id %q", args.WebhookID))
+	}
+	eventID, err := uuid.Parse(args.EventID)
+	if err != nil {
+		return river.JobCancel(fmt.Errorf("invalid event id %q", args.EventID))
+	}
+	hook, err := e.api.repo.Webhooks.GetByIDAndUserID(ctx, id, args.UserID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return river.JobCancel(fmt.Errorf("webhook %s was deleted", id))
+		}
+		return err
+	}
+	if !hook.Enabled {
+		return river.JobCancel(fmt.Errorf("webhook %s is disabled", id))
+	}
+	_, err = e.api.deliverWebhook(ctx, hook, eventID, args.EventType, args.Payload, attempt)
+	return err
+}
+
+// dispatchWebhooks is registered as a record hook on the event broadcaster.
+// It queues one webhooks.deliver job per enabled webhook of the user that
+// subscribes to the event type. Lookups run in the background so Record
+// never waits on the database.
+func (a *apiService) dispatchWebhooks(evt dto.Event) {
+	if a.jobs == nil || a.repo == nil || a.repo.Webhooks == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), webhookDispatchTimeout)
+		defer cancel()
+		logger := logging.Component("WEBHOOK").With(zap.Int64("user_id", evt.UserID), zap.String("event_id", evt.ID))
+
+		subs, err := a.webhookSubscriptions(ctx, evt.UserID)
+		if err != nil {
+			logger.Error("webhooks.lookup_failed", zap.Error(err))
+			return
+		}
+		var payload []byte
+		for _, sub := range subs {
+			if !webhookWants(sub.Events, events.EventType(evt.Type)) {
+				continue
+			}
+			if payload == nil {
+				if payload, err = webhookPayload(evt); err != nil {
+					logger.Error("webhooks.marshal_failed", zap.Error(err))
+					return
+				}
+			}
+			_, err := a.jobs.Insert(ctx, queue.WebhookDeliverArgs{
+				UserID:    evt.UserID,
+				WebhookID: sub.ID,
+				EventID:   evt.ID,
+				EventType: evt.Type,
+				Payload:   payload,
+			}, &river.InsertOpts{MaxAttempts: a.webhookMaxAttempts()})
+			if err != nil {
+				logger.Error("webhooks.enqueue_failed", zap.String("webhook_id", sub.ID), zap.Error(err))
+			}
+		}
+	}()
+}
+
+func (a *apiService) webhookSubscriptions(ctx context.Context, userID int64) ([]webhookSubscription, error) {
+	return cache.Fetch(ctx, a.cache, cache.KeyUserWebhooks(userID), webhookSubscriptionsTTL, func() ([]webhookSubscription, error) {
+		rows, err := a.repo.Webhooks.ListEnabledByUserID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]webhookSubscription, 0, len(rows))
+		for _, row := range rows {
+			out = append(out, webhookSubscription{ID: row.ID.String(), Events: row.Events.Data})
+		}
+		return out, nil
+	})
+}
+
+func (a *apiService) invalidateWebhookSubscriptions(ctx context.Context, userID int64) {
+	_ = a.cache.Delete(ctx, cache.KeyUserWebhooks(userID))
+}
+
+// deliverWebhook posts payload to hook and logs the attempt. The returned
+// error is nil only when the endpoint answered with a 2xx status.
+func (a *apiService) deliverWebhook(ctx context.Context, hook *jetmodel.Webhooks, eventID uuid.UUID, eventType string, payload []byte, attempt int) (*jetmodel.WebhookDeliveries, error) {
+	start := time.Now()
+	status, err := postWebhook(ctx, a.webhookHTTPClient(), hook.URL, hook.Secret, eventID.String(), eventType, payload, start)
+	delivery := &jetmodel.WebhookDeliveries{
+		WebhookID:  hook.ID,
+		EventID:    eventID,
+		EventType:  eventType,
+		Attempt:    int32(attempt),
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if status > 0 {
+		code := int32(status)
+		delivery.StatusCode = &code
+	}
+	if err != nil {
+		msg := err.Error()
+		delivery.Error = &msg
+	}
+
+	// The attempt is logged even when ctx ran out while waiting for the endpoint.
+	logCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if logErr := a.repo.Webhooks.CreateDelivery(logCtx, delivery, webhookDeliveryLogSize); logErr != nil {
+		logging.FromContext(ctx).Warn("webhooks.log_failed", zap.String("webhook_id", hook.ID.String()), zap.Error(logErr))
+	}
+	return delivery, err
+}
+
+// webhookPayload renders evt the way the SSE stream sends it.
+func webhookPayload(evt dto.Event) ([]byte, error) {
+	out := mapper.ToEventOutFromDTO(evt)
+	return out.MarshalJSON()
+}
+
+// webhookClients holds the delivery clients, one per value of
+// jobs.webhook-deliver.allow-private, so deliveries share their connections.
+type webhookClients struct {
+	mu      sync.Mutex
+	clients map[bool]*http.Client
+}
+
+// webhookHTTPClient returns the shared client for the current config,
+// creating it on first use.
+func (a *apiService) webhookHTTPClient() *http.Client {
+	allowPrivate := a.webhookAllowPrivate()
+	a.webhookClients.mu.Lock()
+	defer a.webhookClients.mu.Unlock()
+	if client, ok := a.webhookClients.clients[allowPrivate]; ok {
+		return client
+	}
+	if a.webhookClients.clients == nil {
+		a.webhookClients.clients = make(map[bool]*http.Client, 2)
+	}
+	client := newWebhookHTTPClient(allowPrivate, a.webhookTimeout())
+	a.webhookClients.clients[allowPrivate] = client
+	return client
+}
+
+func newWebhookHTTPClient(allowPrivate bool, timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		// Checked on the resolved address, so a public name that points at
+		// an internal host is refused as well.
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control: func(_, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if addr, err := netip.ParseAddr(host); err == nil && isPrivateWebhookAddr(addr) {
+					return fmt.Errorf("webhook destination %s is a private address", host)
+				}
+				return nil
+			},
+		}
+		transport.DialContext = dialer.DialContext
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		// A redirect is reported as a failed delivery instead of re-sending
+		// the body somewhere else.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func postWebhook(ctx context.Context, client *http.Client, endpoint, secret, deliveryID, eventType string, payload []byte, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "teldrive-webhooks/"+version.Version)
+	req.Header.Set(webhookHeaderEvent, eventType)
+	req.Header.Set(webhookHeaderDelivery, deliveryID)
+	req.Header.Set(webhookHeaderTimestamp, timestamp)
+	req.Header.Set(webhookHeaderSignature, "sha256="+signWebhookPayload(secret, timestamp, payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func signWebhookPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookEventFilters splits comma separated filters and checks them with
+// the same rules as the SSE stream. Wildcards are kept as given, so a webhook
+// on files.* also receives file event types added later.
+func webhookEventFilters(raw []string) ([]string, error) {
+	out := make([]string, 0, len(raw))
+	for _, item := range raw {
+		for _, token := range strings.Split(item, ",") {
+			name := strings.TrimSpace(token)
+			if name != "" && !slices.Contains(out, name) {
+				out = append(out, name)
+			}
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("at least one event type is required")
+	}
+	if _, err := events.ParseEventTypes(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func webhookWants(filters []string, eventType events.EventType) bool {
+	wanted, err := events.ParseEventTypes(filters)
+	if err != nil {
+		return false
+	}
+	return slices.Contains(wanted, eventType)
+}
+
+// validateWebhookURL checks raw and, unless allowPrivate is set, refuses hosts
+// that name a loopback, link-local or private address. Names that only
+// resolve to such an address are refused when a delivery dials them.
+func validateWebhookURL(raw string, allowPrivate bool) (string, error) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", errors.New("url must use http or https")
+	}
+	if u.Host == "" {
+		return "", errors.New("url must include a host")
+	}
+	if !allowPrivate {
+		host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+			return "", errors.New("url must not point at a private address")
+		}
+		if addr, err := netip.ParseAddr(host); err == nil && isPrivateWebhookAddr(addr) {
+			return "", errors.New("url must not point at a private address")
+		}
+	}
+	return raw, nil
+}
+
+func isPrivateWebhookAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast()
+}
+
+func (a *apiService) getWebhook(ctx context.Context, id uuid.UUID, userID int64) (*jetmodel.Webhooks, error) {
+	row, err := a.repo.Webhooks.GetByIDAndUserID(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, &apiError{err: errors.New("webhook not found"), code: 404}
+		}
+		return nil, &apiError{err: err}
+	}
+	return row, nil
+}
+
+func toAPIWebhook(row jetmodel.Webhooks) api.Webhook {
+	return api.Webhook{
+		ID:        api.UUID(row.ID),
+		Name:      row.Name,
+		URL:       row.URL,
+		Events:    row.Events.Data,
+		Enabled:   row.Enabled,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+	}
+}
+
+func toAPIWebhookDelivery(row jetmodel.WebhookDeliveries) api.WebhookDelivery {
+	out := api.WebhookDelivery{
+		ID:         api.UUID(row.ID),
+		EventId:    api.UUID(row.EventID),
+		EventType:  row.EventType,
+		Attempt:    row.Attempt,
+		Success:    row.Error == nil,
+		DurationMs: row.DurationMs,
+		CreatedAt:  row.CreatedAt,
+	}
+	if row.StatusCode != nil {
+		out.StatusCode = api.NewOptInt32(*row.StatusCode)
+	}
+	if row.Error != nil {
+		out.Error = api.NewOptString(*row.Error)
+	}
+	return out
+}
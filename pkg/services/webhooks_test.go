@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tgdrive/teldrive/internal/config"
+	"github.com/tgdrive/teldrive/internal/events"
+)
+
+func TestWebhookEventFilters(t *testing.T) {
+	t.Parallel()
+
+	got, err := webhookEventFilters([]string{"files.*, uploads.progress", " files.* ", ""})
+	require.NoError(t, err)
+	require.Equal(t, []string{"files.*", "uploads.progress"}, got)
+
+	_, err = webhookEventFilters([]string{" , "})
+	require.Error(t, err)
+	_, err = webhookEventFilters([]string{"files.renamed"})
+	require.Error(t, err)
+
+	require.True(t, webhookWants(got, events.OpMove))
+	require.True(t, webhookWants(got, events.OpUploadProgress))
+	require.False(t, webhookWants(got, events.OpJobProgress))
+
+	got, err = webhookEventFilters([]string{"jobs.*", "shares.created", "apiKeys.*"})
+	require.NoError(t, err)
+	require.True(t, webhookWants(got, events.OpJobFail))
+	require.True(t, webhookWants(got, events.OpShareCreate))
+	require.False(t, webhookWants(got, events.OpShareUnlock))
+	require.True(t, webhookWants(got, events.OpAPIKeyCreate))
+	require.False(t, webhookWants(got, events.OpSessionCreate))
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	t.Parallel()
+
+	got, err := validateWebhookURL(" https://hooks.example.com/teldrive ", false)
+	require.NoError(t, err)
+	require.Equal(t, "https://hooks.example.com/teldrive", got)
+
+	for _, raw := range []string{"ftp://example.com", "https://", "/relative", "::"} {
+		_, err := validateWebhookURL(raw, true)
+		require.Error(t, err, raw)
+	}
+
+	private := []string{
+		"http://127.0.0.1:8080/hook", "http://localhost/hook", "http://api.localhost/hook",
+		"http://10.0.0.5/hook", "http://192.168.1.10/hook", "http://169.254.169.254/latest",
+		"http://[::1]/hook", "http://[fe80::1]/hook", "http://[::ffff:10.0.0.1]/hook", "http://0.0.0.0/hook",
+	}
+	for _, raw := range private {
+		_, err := validateWebhookURL(raw, false)
+		require.Error(t, err, raw)
+		_, err = validateWebhookURL(raw, true)
+		require.NoError(t, err, raw)
+	}
+	_, err = validateWebhookURL("http://93.184.216.34/hook", false)
+	require.NoError(t, err)
+}
+
+func TestWebhookHTTPClientRefusesPrivateAddresses(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	_, err := postWebhook(context.Background(), (&apiService{}).webhookHTTPClient(), srv.URL, "s3cret", "e1", "files.created", []byte(`{}`), time.Now())
+	require.ErrorContains(t, err, "private address")
+
+	allowed := &apiService{cnf: &config.ServerCmdConfig{}}
+	allowed.cnf.Jobs.WebhookDeliver.AllowPrivate = true
+	status, err := postWebhook(context.Background(), allowed.webhookHTTPClient(), srv.URL, "s3cret", "e1", "files.created", []byte(`{}`), time.Now())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, status)
+}
+
+func TestWebhookHTTPClientIsShared(t *testing.T) {
+	t.Parallel()
+
+	a := &apiService{cnf: &config.ServerCmdConfig{}}
+	refusing := a.webhookHTTPClient()
+	require.Same(t, refusing, a.webhookHTTPClient())
+
+	a.cnf.Jobs.WebhookDeliver.AllowPrivate = true
+	allowing := a.webhookHTTPClient()
+	require.NotSame(t, refusing, allowing)
+	require.Same(t, allowing, a.webhookHTTPClient())
+}
+
+func TestPostWebhookSignsBody(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte(`{"id":"e1","type":"files.created"}`)
+	now := time.Unix(1700000000, 0)
+	var got http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "/ok", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	a := &apiService{cnf: &config.ServerCmdConfig{}}
+	a.cnf.Jobs.WebhookDeliver.AllowPrivate = true
+	client := a.webhookHTTPClient()
+
+	status, err := postWebhook(context.Background(), client, srv.URL+"/ok", "s3cret", "e1", "files.created", payload, now)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, status)
+	require.Equal(t, payload, body)
+	require.Equal(t, "files.created", got.Get(webhookHeaderEvent))
+	require.Equal(t, "e1", got.Get(webhookHeaderDelivery))
+	require.Equal(t, "1700000000", got.Get(webhookHeaderTimestamp))
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte("1700000000." + string(payload)))
+	require.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), got.Get(webhookHeaderSignature))
+
+	status, err = postWebhook(context.Background(), client, srv.URL+"/fail", "s3cret", "e1", "files.created", payload, now)
+	require.Error(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, status)
+
+	status, err = postWebhook(context.Background(), client, srv.URL+"/moved", "s3cret", "e1", "files.created", payload, now)
+	require.Error(t, err)
+	require.Equal(t, http.StatusFound, status)
+}
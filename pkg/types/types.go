@@ -20,6 +20,10 @@ type JWTClaims struct {
 	IsPremium bool      `json:"isPremium"`
 	SessionID uuid.UUID `json:"sessionId"`
 	TgSession string    `json:"tgSession,omitempty"`
+	// Scopes and FolderID carry the restrictions of the API key used for the
+	// request. They are empty for sessions and unrestricted keys.
+	Scopes   []string   `json:"scopes,omitempty"`
+	FolderID *uuid.UUID `json:"folderId,omitempty"`
 }
 
 type SessionData struct {
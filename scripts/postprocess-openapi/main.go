@@ -56,6 +56,13 @@ func run() error {
 			fieldKey:   "x-ogen-raw-response",
 			fieldValue: "true",
 		},
+		{
+			pathText:   "/files/{id}/versions/{versionId}/content:",
+			methodText: "get:",
+			mediaType:  "application/octet-stream:",
+			fieldKey:   "x-ogen-raw-response",
+			fieldValue: "true",
+		},
 		{
 			pathText:   "/events/stream:",
 			methodText: "get:",
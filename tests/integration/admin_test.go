@@ -0,0 +1,125 @@
+package integration_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tgdrive/teldrive/internal/api"
+)
+
+func TestAdmin_ManageUsersAndAllowList(t *testing.T) {
+	s := newSuite(t)
+	ctx := context.Background()
+	s.cfg.JWT.AdminUsers = []string{"admin8010"}
+
+	_, admin, _ := loginWithClient(t, s, 8010, "admin8010")
+	public, user, _ := loginWithClient(t, s, 8011, "user8011")
+
+	if _, err := user.AdminListUsers(ctx); statusCode(err) != 403 {
+		t.Fatalf("expected 403 for non-admin, got %d err=%v", statusCode(err), err)
+	}
+	if err := admin.AdminUpdateUser(ctx, &api.AdminUserUpdate{IsAdmin: api.NewOptBool(true)}, api.AdminUpdateUserParams{ID: 8011}); err != nil {
+		t.Fatalf("grant admin role failed: %v", err)
+	}
+	if _, err := user.AdminListUsers(ctx); err != nil {
+		t.Fatalf("expected admin role to allow AdminListUsers: %v", err)
+	}
+	if err := admin.AdminUpdateUser(ctx, &api.AdminUserUpdate{IsAdmin: api.NewOptBool(false)}, api.AdminUpdateUserParams{ID: 8011}); err != nil {
+		t.Fatalf("remove admin role failed: %v", err)
+	}
+
+	if _, err := user.FilesCreate(ctx, &api.File{
+		Name:      "a.txt",
+		Type:      api.FileTypeFile,
+		Path:      api.NewOptString("/"),
+		MimeType:  api.NewOptString("text/plain"),
+		ChannelId: api.NewOptInt64(801101),
+		Size:      api.NewOptInt64(5),
+		Parts:     []api.Part{{ID: 1}},
+	}); err != nil {
+		t.Fatalf("FilesCreate failed: %v", err)
+	}
+	users, err := admin.AdminListUsers(ctx)
+	if err != nil {
+		t.Fatalf("AdminListUsers failed: %v", err)
+	}
+	var found *api.AdminUser
+	for i := range users {
+		if users[i].UserId == 8011 {
+			found = &users[i]
+		}
+	}
+	if found == nil || found.Usage.TotalFiles != 1 || found.Usage.TotalSize != 5 || found.IsAdmin || found.Disabled {
+		t.Fatalf("unexpected user summary: %+v", found)
+	}
+
+	created, err := user.UsersCreateApiKey(ctx, &api.UserApiKeyCreate{Name: "admin-test"})
+	if err != nil {
+		t.Fatalf("UsersCreateApiKey failed: %v", err)
+	}
+	keyClient := s.newClientWithToken(created.Key)
+
+	if err := admin.AdminUpdateUser(ctx, &api.AdminUserUpdate{Disabled: api.NewOptBool(true)}, api.AdminUpdateUserParams{ID: 8010}); statusCode(err) != 400 {
+		t.Fatalf("expected 400 disabling own account, got %d err=%v", statusCode(err), err)
+	}
+	if err := admin.AdminUpdateUser(ctx, &api.AdminUserUpdate{Disabled: api.NewOptBool(true)}, api.AdminUpdateUserParams{ID: 999999}); statusCode(err) != 404 {
+		t.Fatalf("expected 404 for unknown user, got %d err=%v", statusCode(err), err)
+	}
+	if err := admin.AdminUpdateUser(ctx, &api.AdminUserUpdate{Disabled: api.NewOptBool(true)}, api.AdminUpdateUserParams{ID: 8011}); err != nil {
+		t.Fatalf("disable user failed: %v", err)
+	}
+	if _, err := user.UsersStats(ctx); statusCode(err) != 401 {
+		t.Fatalf("expected 401 for a disabled user's token, got %d err=%v", statusCode(err), err)
+	}
+	if _, err := keyClient.UsersStats(ctx); statusCode(err) != 401 {
+		t.Fatalf("expected 401 for a disabled user's API key, got %d err=%v", statusCode(err), err)
+	}
+	login := &api.AuthAttemptSession{Session: "1BvXNhK1zA5P-FAKE-SESSION-8011", UserId: 8011, UserName: "user8011", Name: "user8011"}
+	if _, err := public.AuthLogin(ctx, login); statusCode(err) != 403 {
+		t.Fatalf("expected 403 logging in as a disabled user, got %d err=%v", statusCode(err), err)
+	}
+
+	if err := admin.AdminUpdateUser(ctx, &api.AdminUserUpdate{Disabled: api.NewOptBool(false)}, api.AdminUpdateUserParams{ID: 8011}); err != nil {
+		t.Fatalf("enable user failed: %v", err)
+	}
+	_, user, _ = loginWithClient(t, s, 8011, "user8011")
+	if err := admin.AdminRevokeUser(ctx, api.AdminRevokeUserParams{ID: 8011}); err != nil {
+		t.Fatalf("AdminRevokeUser failed: %v", err)
+	}
+	if _, err := user.UsersStats(ctx); statusCode(err) != 401 {
+		t.Fatalf("expected 401 after revoke, got %d err=%v", statusCode(err), err)
+	}
+
+	if err := admin.AdminAddAllowedUser(ctx, &api.AllowedUserCreate{UserName: "user8012"}); err != nil {
+		t.Fatalf("AdminAddAllowedUser failed: %v", err)
+	}
+	allowed, err := admin.AdminListAllowedUsers(ctx)
+	if err != nil {
+		t.Fatalf("AdminListAllowedUsers failed: %v", err)
+	}
+	if len(allowed) != 1 || allowed[0].UserName != "user8012" || allowed[0].Source != api.AllowedUserSourceRuntime {
+		t.Fatalf("unexpected allow-list: %+v", allowed)
+	}
+	if _, err := public.AuthLogin(ctx, login); statusCode(err) != 403 {
+		t.Fatalf("expected 403 for a user missing from the allow-list, got %d err=%v", statusCode(err), err)
+	}
+	_, allowedUser, _ := loginWithClient(t, s, 8012, "user8012")
+	_, admin, _ = loginWithClient(t, s, 8010, "admin8010")
+
+	if err := admin.AdminAddAllowedUser(ctx, &api.AllowedUserCreate{UserName: "user8013"}); err != nil {
+		t.Fatalf("AdminAddAllowedUser failed: %v", err)
+	}
+	if err := admin.AdminRemoveAllowedUser(ctx, api.AdminRemoveAllowedUserParams{UserName: "user8012"}); err != nil {
+		t.Fatalf("AdminRemoveAllowedUser failed: %v", err)
+	}
+	if _, err := allowedUser.UsersStats(ctx); statusCode(err) != 401 {
+		t.Fatalf("expected 401 after removal from the allow-list, got %d err=%v", statusCode(err), err)
+	}
+	if err := admin.AdminRemoveAllowedUser(ctx, api.AdminRemoveAllowedUserParams{UserName: "user8012"}); statusCode(err) != 404 {
+		t.Fatalf("expected 404 removing a missing entry, got %d err=%v", statusCode(err), err)
+	}
+	if err := admin.AdminRemoveAllowedUser(ctx, api.AdminRemoveAllowedUserParams{UserName: "user8013"}); err != nil {
+		t.Fatalf("AdminRemoveAllowedUser failed: %v", err)
+	}
+	loginWithClient(t, s, 8011, "user8011")
+}
@@ -0,0 +1,179 @@
+package integration_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tgdrive/teldrive/internal/api"
+)
+
+func TestAPIKeys_ScopesAndFolder(t *testing.T) {
+	s := newSuite(t)
+	ctx := context.Background()
+	const userID int64 = 8020
+	_, client, _ := loginWithClient(t, s, userID, "user8020")
+
+	if _, err := s.repos.Files.CreateDirectories(ctx, userID, "/ci/builds"); err != nil {
+		t.Fatalf("CreateDirectories failed: %v", err)
+	}
+	ciID, err := s.repos.Files.ResolvePathID(ctx, "/ci", userID)
+	if err != nil {
+		t.Fatalf("resolve /ci: %v", err)
+	}
+	newFile := func(path, name string) *api.File {
+		return &api.File{
+			Name:      name,
+			Type:      api.FileTypeFile,
+			Path:      api.NewOptString(path),
+			MimeType:  api.NewOptString("text/plain"),
+			ChannelId: api.NewOptInt64(802001),
+			Size:      api.NewOptInt64(1),
+			Parts:     []api.Part{{ID: 1}},
+		}
+	}
+	inside, err := client.FilesCreate(ctx, newFile("/ci", "inside.txt"))
+	if err != nil {
+		t.Fatalf("FilesCreate inside.txt failed: %v", err)
+	}
+	outside, err := client.FilesCreate(ctx, newFile("/", "outside.txt"))
+	if err != nil {
+		t.Fatalf("FilesCreate outside.txt failed: %v", err)
+	}
+
+	if _, err := client.UsersCreateApiKey(ctx, &api.UserApiKeyCreate{Name: "bad", FolderId: api.NewOptUUID(inside.ID.Value)}); statusCode(err) != 400 {
+		t.Fatalf("expected 400 for a file as key folder, got %d err=%v", statusCode(err), err)
+	}
+
+	readKey, err := client.UsersCreateApiKey(ctx, &api.UserApiKeyCreate{
+		Name:   "media",
+		Scopes: []api.ApiKeyScope{api.ApiKeyScopeFilesRead},
+	})
+	if err != nil {
+		t.Fatalf("create read key failed: %v", err)
+	}
+	reader := s.newClientWithToken(readKey.Key)
+	if _, err := reader.AuthSession(ctx); err != nil {
+		t.Fatalf("AuthSession with read key failed: %v", err)
+	}
+	if _, err := reader.FilesList(ctx, api.FilesListParams{Path: api.NewOptString("/")}); err != nil {
+		t.Fatalf("FilesList with read key failed: %v", err)
+	}
+	if err := reader.FilesDeleteById(ctx, api.FilesDeleteByIdParams{ID: outside.ID.Value}); statusCode(err) != 403 {
+		t.Fatalf("expected 403 deleting with read key, got %d err=%v", statusCode(err), err)
+	}
+	if _, err := reader.UsersListApiKeys(ctx); statusCode(err) != 403 {
+		t.Fatalf("expected 403 listing keys with read key, got %d err=%v", statusCode(err), err)
+	}
+
+	ciKey, err := client.UsersCreateApiKey(ctx, &api.UserApiKeyCreate{
+		Name:     "ci",
+		Scopes:   []api.ApiKeyScope{api.ApiKeyScopeUploads, api.ApiKeyScopeFilesWrite},
+		FolderId: api.NewOptUUID(api.UUID(*ciID)),
+	})
+	if err != nil {
+		t.Fatalf("create ci key failed: %v", err)
+	}
+	ci := s.newClientWithToken(ciKey.Key)
+	if _, err := ci.FilesCreate(ctx, newFile("/ci/builds", "build.txt")); err != nil {
+		t.Fatalf("FilesCreate inside the key folder failed: %v", err)
+	}
+	if _, err := ci.FilesCreate(ctx, newFile("/", "escape.txt")); statusCode(err) != 403 {
+		t.Fatalf("expected 403 creating outside the key folder, got %d err=%v", statusCode(err), err)
+	}
+	if err := ci.FilesDeleteById(ctx, api.FilesDeleteByIdParams{ID: outside.ID.Value}); statusCode(err) != 403 {
+		t.Fatalf("expected 403 deleting outside the key folder, got %d err=%v", statusCode(err), err)
+	}
+	if _, err := ci.FilesGetById(ctx, api.FilesGetByIdParams{ID: inside.ID.Value}); statusCode(err) != 403 {
+		t.Fatalf("expected 403 reading without files:read, got %d err=%v", statusCode(err), err)
+	}
+	if err := ci.FilesMove(ctx, &api.FileMove{Ids: []api.UUID{inside.ID.Value}, DestinationParent: "/"}); statusCode(err) != 403 {
+		t.Fatalf("expected 403 moving out of the key folder, got %d err=%v", statusCode(err), err)
+	}
+	if err := ci.FilesDeleteById(ctx, api.FilesDeleteByIdParams{ID: inside.ID.Value}); err != nil {
+		t.Fatalf("delete inside the key folder failed: %v", err)
+	}
+
+	folderKey, err := client.UsersCreateApiKey(ctx, &api.UserApiKeyCreate{Name: "folder", FolderId: api.NewOptUUID(api.UUID(*ciID))})
+	if err != nil {
+		t.Fatalf("create folder key failed: %v", err)
+	}
+	folder := s.newClientWithToken(folderKey.Key)
+	listed, err := folder.FilesList(ctx, api.FilesListParams{Path: api.NewOptString("/ci/builds")})
+	if err != nil {
+		t.Fatalf("FilesList inside the key folder failed: %v", err)
+	}
+	if len(listed.Items) != 1 || listed.Items[0].Name != "build.txt" {
+		t.Fatalf("unexpected listing: %+v", listed.Items)
+	}
+	if _, err := folder.FilesList(ctx, api.FilesListParams{Path: api.NewOptString("/")}); statusCode(err) != 403 {
+		t.Fatalf("expected 403 listing outside the key folder, got %d err=%v", statusCode(err), err)
+	}
+	if _, err := folder.FilesList(ctx, api.FilesListParams{Query: api.NewOptString("outside")}); statusCode(err) != 403 {
+		t.Fatalf("expected 403 searching without a parent, got %d err=%v", statusCode(err), err)
+	}
+	if _, err := folder.FilesGetById(ctx, api.FilesGetByIdParams{ID: outside.ID.Value}); statusCode(err) != 403 {
+		t.Fatalf("expected 403 reading outside the key folder, got %d err=%v", statusCode(err), err)
+	}
+	if _, err := folder.FilesCategoryStats(ctx); statusCode(err) != 403 {
+		t.Fatalf("expected 403 for drive-wide stats, got %d err=%v", statusCode(err), err)
+	}
+
+	keys, err := client.UsersListApiKeys(ctx)
+	if err != nil {
+		t.Fatalf("UsersListApiKeys failed: %v", err)
+	}
+	for _, key := range keys {
+		if key.Name == "ci" && (len(key.Scopes) != 2 || uuid.UUID(key.FolderId.Value) != *ciID) {
+			t.Fatalf("unexpected ci key restrictions: %+v", key)
+		}
+		if key.Name == "media" && (len(key.Scopes) != 1 || key.FolderId.IsSet()) {
+			t.Fatalf("unexpected media key restrictions: %+v", key)
+		}
+	}
+}
+
+func TestAPIKeys_JobKindScopes(t *testing.T) {
+	s := newSuite(t)
+	ctx := context.Background()
+	_, client, _ := loginWithClient(t, s, 91110, "user91110")
+
+	newKey := func(name string, scopes ...api.ApiKeyScope) *api.Client {
+		t.Helper()
+		key, err := client.UsersCreateApiKey(ctx, &api.UserApiKeyCreate{Name: name, Scopes: scopes})
+		if err != nil {
+			t.Fatalf("create %s key failed: %v", name, err)
+		}
+		return s.newClientWithToken(key.Key)
+	}
+	insert := func(c *api.Client, kind string) error {
+		_, err := c.JobsInsert(ctx, &api.JobInsertRequest{Kind: kind, Args: []byte("{}")})
+		return err
+	}
+
+	jobsOnly := newKey("jobs", api.ApiKeyScopeJobs)
+	for _, kind := range []string{"files.copy", "files.move", "files.delete", "sync.run", "sync.push"} {
+		if err := insert(jobsOnly, kind); statusCode(err) != 403 {
+			t.Fatalf("expected 403 inserting %s with a jobs-only key, got %d err=%v", kind, statusCode(err), err)
+		}
+	}
+	if _, err := jobsOnly.PeriodicJobsCreate(ctx, &api.PeriodicJobCreate{Name: "nightly", CronExpression: "0 0 * * *"}); statusCode(err) != 403 {
+		t.Fatalf("expected 403 creating a sync schedule with a jobs-only key, got %d err=%v", statusCode(err), err)
+	}
+
+	writer := newKey("writer", api.ApiKeyScopeJobs, api.ApiKeyScopeFilesWrite)
+	if err := insert(writer, "files.delete"); err != nil {
+		t.Fatalf("files.delete with files:write failed: %v", err)
+	}
+	if err := insert(writer, "sync.push"); statusCode(err) != 403 {
+		t.Fatalf("expected 403 inserting sync.push without files:read, got %d err=%v", statusCode(err), err)
+	}
+
+	reader := newKey("reader", api.ApiKeyScopeJobs, api.ApiKeyScopeFilesRead)
+	if err := insert(reader, "sync.push"); err != nil {
+		t.Fatalf("sync.push with files:read failed: %v", err)
+	}
+	if err := insert(reader, "sync.run"); statusCode(err) != 403 {
+		t.Fatalf("expected 403 inserting sync.run without files:write, got %d err=%v", statusCode(err), err)
+	}
+}
@@ -0,0 +1,198 @@
+package integration_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/pkg/services"
+)
+
+func TestArchive_FolderAndShare(t *testing.T) {
+	s := newSuite(t)
+	ctx := context.Background()
+	token := loginAndGetToken(t, s, 8030, "user8030")
+	client := s.newClientWithToken(token)
+
+	if _, err := s.repos.Files.CreateDirectories(ctx, 8030, "/photos/2024"); err != nil {
+		t.Fatalf("CreateDirectories failed: %v", err)
+	}
+	folderID, err := s.repos.Files.ResolvePathID(ctx, "/photos", 8030)
+	if err != nil {
+		t.Fatalf("resolve /photos: %v", err)
+	}
+	file, err := client.FilesCreate(ctx, &api.File{Name: "empty.txt", Type: api.FileTypeFile, Path: api.NewOptString("/photos/2024"), MimeType: api.NewOptString("text/plain"), ChannelId: api.NewOptInt64(803001), Size: api.NewOptInt64(0)})
+	if err != nil {
+		t.Fatalf("FilesCreate failed: %v", err)
+	}
+
+	get := func(url string) (*http.Response, []byte) {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatalf("archive request: %v", err)
+		}
+		req.Header.Set("Cookie", "access_token="+token)
+		resp, err := s.httpCli.Do(req)
+		if err != nil {
+			t.Fatalf("archive do: %v", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("read archive: %v", err)
+		}
+		return resp, body
+	}
+	want := []string{"photos/2024/", "photos/2024/empty.txt"}
+
+	resp, body := get(fmt.Sprintf("%s/files/%s/archive", s.server.URL, folderID))
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/zip" {
+		t.Fatalf("unexpected zip response: %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if !slices.Equal(names, want) {
+		t.Fatalf("unexpected zip entries: %v", names)
+	}
+
+	resp, body = get(fmt.Sprintf("%s/files/%s/archive?format=tar", s.server.URL, folderID))
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Disposition") != `attachment; filename=photos.tar` {
+		t.Fatalf("unexpected tar response: %d %q", resp.StatusCode, resp.Header.Get("Content-Disposition"))
+	}
+	tr := tar.NewReader(bytes.NewReader(body))
+	names = nil
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read tar: %v", err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if !slices.Equal(names, want) {
+		t.Fatalf("unexpected tar entries: %v", names)
+	}
+
+	if resp, _ := get(fmt.Sprintf("%s/files/%s/archive", s.server.URL, uuid.UUID(file.ID.Value))); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 archiving a file, got %d", resp.StatusCode)
+	}
+	if resp, _ := get(fmt.Sprintf("%s/files/%s/archive", s.server.URL, uuid.New())); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing folder, got %d", resp.StatusCode)
+	}
+
+	if err := client.FilesCreateShare(ctx, &api.FileShareCreate{}, api.FilesCreateShareParams{ID: api.UUID(*folderID)}); err != nil {
+		t.Fatalf("FilesCreateShare failed: %v", err)
+	}
+	shares, err := client.FilesListShares(ctx, api.FilesListSharesParams{ID: api.UUID(*folderID)})
+	if err != nil || len(shares) == 0 {
+		t.Fatalf("FilesListShares failed: %v len=%d", err, len(shares))
+	}
+	resp, body = get(fmt.Sprintf("%s/shares/%s/archive", s.server.URL, uuid.UUID(shares[0].ID)))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected shared archive 200, got %d body=%s", resp.StatusCode, body)
+	}
+	if zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body))); err != nil || len(zr.File) != len(want) {
+		t.Fatalf("unexpected shared archive: %v", err)
+	}
+}
+
+func TestArchive_FileContent(t *testing.T) {
+	s := newSuite(t)
+	ctx := context.Background()
+	token := loginAndGetToken(t, s, 91090, "user91090")
+	client := s.newClientWithToken(token)
+
+	files := newTelegramFiles()
+	s.tgMock.authClientFn = func(context.Context, string, int) (services.TelegramClient, error) { return files.client(), nil }
+	s.tgMock.getPartsFn = files.getParts
+
+	content := []byte("archived file content")
+	files.add(910901, content)
+	if _, err := s.repos.Files.CreateDirectories(ctx, 91090, "/docs/inner"); err != nil {
+		t.Fatalf("CreateDirectories failed: %v", err)
+	}
+	folderID, err := s.repos.Files.ResolvePathID(ctx, "/docs", 91090)
+	if err != nil {
+		t.Fatalf("resolve /docs: %v", err)
+	}
+	file, err := client.FilesCreate(ctx, &api.File{
+		Name:      "notes.txt",
+		Type:      api.FileTypeFile,
+		Path:      api.NewOptString("/docs/inner"),
+		MimeType:  api.NewOptString("text/plain"),
+		ChannelId: api.NewOptInt64(910900),
+		Size:      api.NewOptInt64(int64(len(content))),
+		Parts:     []api.Part{{ID: 910901}},
+	})
+	if err != nil {
+		t.Fatalf("FilesCreate failed: %v", err)
+	}
+
+	get := func(url string) []byte {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		req.Header.Set("Cookie", "access_token="+token)
+		resp, err := s.httpCli.Do(req)
+		if err != nil {
+			t.Fatalf("do: %v", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("read body: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status %d for %s: %s", resp.StatusCode, url, body)
+		}
+		return body
+	}
+
+	body := get(fmt.Sprintf("%s/files/%s/archive", s.server.URL, folderID))
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	var got []byte
+	for _, f := range zr.File {
+		if f.Name != "docs/inner/notes.txt" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open zip entry: %v", err)
+		}
+		got, err = io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatalf("read zip entry: %v", err)
+		}
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("unexpected archived content: %q", got)
+	}
+
+	// Archiving must not leave a broken parts cache behind for streaming
+	if streamed := get(fmt.Sprintf("%s/files/%s/content", s.server.URL, uuid.UUID(file.ID.Value))); !bytes.Equal(streamed, content) {
+		t.Fatalf("unexpected streamed content after archiving: %q", streamed)
+	}
+}
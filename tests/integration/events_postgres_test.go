@@ -0,0 +1,129 @@
+package integration_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/events"
+	"github.com/tgdrive/teldrive/pkg/dto"
+	"go.uber.org/zap"
+)
+
+func TestPostgresBroadcaster_FansOutBetweenInstances(t *testing.T) {
+	s := newSuite(t)
+	const userID int64 = 91070
+
+	newInstance := func() events.EventBroadcaster {
+		b, err := events.NewBroadcaster(s.ctx, "postgres", s.repos.Events, nil, s.pool, 0, events.DefaultBroadcasterConfig(), zap.NewNop())
+		if err != nil {
+			t.Fatalf("create broadcaster: %v", err)
+		}
+		t.Cleanup(b.Shutdown)
+		return b
+	}
+	a, b := newInstance(), newInstance()
+	local := a.Subscribe(userID, nil)
+	remote := b.Subscribe(userID, []events.EventType{events.OpCreate, events.OpMove})
+
+	receive := func(ch chan dto.Event) (dto.Event, bool) {
+		select {
+		case evt := <-ch:
+			return evt, true
+		case <-time.After(5 * time.Second):
+			return dto.Event{}, false
+		}
+	}
+
+	// The listeners connect asynchronously, so retry until the first event
+	// crosses over.
+	var first dto.Event
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		a.Record(events.OpCreate, userID, &dto.Source{ID: "00000000-0000-0000-0000-000000000001", Name: "a.txt", Type: "file"})
+		if _, ok := receive(local); !ok {
+			t.Fatal("expected the recording instance to deliver locally")
+		}
+		if evt, ok := receive(remote); ok {
+			first = evt
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the other instance")
+		}
+	}
+	if first.Type != string(events.OpCreate) || first.Source == nil || first.Source.Name != "a.txt" {
+		t.Fatalf("unexpected remote event: %+v", first)
+	}
+
+	a.Record(events.OpUpdate, userID, nil)
+	a.Record(events.OpMove, userID, &dto.Source{ID: "00000000-0000-0000-0000-000000000002", Name: "big.txt", Type: "file", Path: "/" + strings.Repeat("x", 9000)})
+	evt, ok := receive(remote)
+	if !ok || evt.Type != string(events.OpMove) || evt.Source == nil || len(evt.Source.Path) != 9001 {
+		t.Fatalf("expected the oversized move to be loaded from the database, got %+v ok=%v", evt, ok)
+	}
+	if _, ok := receive(local); !ok {
+		t.Fatal("expected the update locally")
+	}
+	if evt, ok := receive(local); !ok || evt.Type != string(events.OpMove) {
+		t.Fatalf("expected the move locally once, got %+v ok=%v", evt, ok)
+	}
+	select {
+	case evt := <-local:
+		t.Fatalf("expected no duplicate on the recording instance, got %+v", evt)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestNewBroadcaster_RejectsMissingBackends(t *testing.T) {
+	for _, kind := range []string{"redis", "postgres", "kafka"} {
+		if _, err := events.NewBroadcaster(t.Context(), kind, nil, nil, nil, 0, events.DefaultBroadcasterConfig(), zap.NewNop()); err == nil {
+			t.Fatalf("expected an error for %q without its backend", kind)
+		}
+	}
+}
+
+func TestEventRepository_ListSincePages(t *testing.T) {
+	s := newSuite(t)
+	const userID int64 = 91160
+
+	since := time.Now().UTC().Add(-time.Minute)
+	var want []uuid.UUID
+	for range 5 {
+		id := uuid.New()
+		if err := s.repos.Events.Create(s.ctx, &jetmodel.Events{ID: id, Type: string(events.OpCreate), UserID: userID}); err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+		want = append(want, id)
+	}
+
+	var (
+		got      []uuid.UUID
+		afterSeq int64
+	)
+	for {
+		page, err := s.repos.Events.ListSince(s.ctx, since, afterSeq, 2)
+		if err != nil {
+			t.Fatalf("ListSince failed: %v", err)
+		}
+		for _, evt := range page {
+			if evt.UserID == userID {
+				got = append(got, evt.ID)
+			}
+		}
+		if len(page) < 2 {
+			break
+		}
+		afterSeq = page[len(page)-1].Seq
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d events across pages, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected events in sequence order, got %v want %v", got, want)
+		}
+	}
+}
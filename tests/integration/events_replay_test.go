@@ -0,0 +1,58 @@
+package integration_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+)
+
+func TestEventsStream_ReplaysAfterLastEventID(t *testing.T) {
+	s := newSuite(t)
+	const userID int64 = 91060
+	token := s.authTokenForUser(userID, "events-replay-session")
+
+	ids := make([]uuid.UUID, 0, 4)
+	createdAt := time.Now().UTC().Add(-time.Minute)
+	for _, typ := range []string{"files.created", "uploads.progress", "files.moved", "files.deleted"} {
+		id := uuid.New()
+		if err := s.repos.Events.Create(s.ctx, &jetmodel.Events{ID: id, Type: typ, UserID: userID, CreatedAt: createdAt}); err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/events/stream?types=files.*", s.server.URL), nil)
+	if err != nil {
+		t.Fatalf("events request: %v", err)
+	}
+	req.Header.Set("Cookie", "access_token="+token)
+	req.Header.Set("Last-Event-ID", ids[0].String())
+	resp, err := s.httpCli.Do(req)
+	if err != nil {
+		t.Fatalf("events do: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected stream 200, got %d", resp.StatusCode)
+	}
+
+	var got []string
+	scanner := bufio.NewScanner(resp.Body)
+	for len(got) < 2 && scanner.Scan() {
+		if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+			got = append(got, id)
+		}
+	}
+	if len(got) != 2 || got[0] != ids[2].String() || got[1] != ids[3].String() {
+		t.Fatalf("expected the move and delete to be replayed in order, got %v err=%v", got, scanner.Err())
+	}
+}
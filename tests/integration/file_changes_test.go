@@ -0,0 +1,112 @@
+package integration_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tgdrive/teldrive/internal/api"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+)
+
+func TestFilesChanges_PagesHoldsBackAndExpires(t *testing.T) {
+	s := newSuite(t)
+	const userID int64 = 91050
+	client := s.newClientWithToken(s.authTokenForUser(userID, "changes-session"))
+
+	start, err := client.FilesChanges(s.ctx, api.FilesChangesParams{})
+	if err != nil {
+		t.Fatalf("FilesChanges without cursor failed: %v", err)
+	}
+	if len(start.Changes) != 0 || start.Cursor == "" {
+		t.Fatalf("unexpected start page: %+v", start)
+	}
+
+	old := time.Now().UTC().Add(-time.Minute)
+	for _, typ := range []string{"files.created", "uploads.progress", "files.moved", "files.deleted"} {
+		if err := s.repos.Events.Create(s.ctx, &jetmodel.Events{ID: uuid.New(), Type: typ, UserID: userID, CreatedAt: old}); err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+	}
+	if err := s.repos.Events.Create(s.ctx, &jetmodel.Events{ID: uuid.New(), Type: "files.updated", UserID: userID, CreatedAt: time.Now().UTC()}); err != nil {
+		t.Fatalf("create recent event: %v", err)
+	}
+
+	page, err := client.FilesChanges(s.ctx, api.FilesChangesParams{Cursor: api.NewOptString(start.Cursor), Limit: api.NewOptInt32(2)})
+	if err != nil {
+		t.Fatalf("FilesChanges failed: %v", err)
+	}
+	if !page.HasMore || len(page.Changes) != 2 || page.Changes[0].Type != api.EventTypeFilesCreated || page.Changes[1].Type != api.EventTypeFilesMoved {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+	page, err = client.FilesChanges(s.ctx, api.FilesChangesParams{Cursor: api.NewOptString(page.Cursor), Limit: api.NewOptInt32(2)})
+	if err != nil {
+		t.Fatalf("FilesChanges failed: %v", err)
+	}
+	if page.HasMore || len(page.Changes) != 1 || page.Changes[0].Type != api.EventTypeFilesDeleted {
+		t.Fatalf("expected only the delete, recent events are held back: %+v", page)
+	}
+	caughtUp := page.Cursor
+
+	if _, err := s.repos.Events.DeleteOlderThanForUser(s.ctx, userID, time.Now().UTC()); err != nil {
+		t.Fatalf("prune events: %v", err)
+	}
+	if _, err := client.FilesChanges(s.ctx, api.FilesChangesParams{Cursor: api.NewOptString(start.Cursor)}); statusCode(err) != 410 {
+		t.Fatalf("expected 410 for a pruned cursor, got %d err=%v", statusCode(err), err)
+	}
+	if _, err := client.FilesChanges(s.ctx, api.FilesChangesParams{Cursor: api.NewOptString(caughtUp)}); statusCode(err) != 410 {
+		t.Fatalf("expected 410 once the held back event was pruned, got %d err=%v", statusCode(err), err)
+	}
+
+	fresh, err := client.FilesChanges(s.ctx, api.FilesChangesParams{})
+	if err != nil {
+		t.Fatalf("FilesChanges without cursor failed: %v", err)
+	}
+	page, err = client.FilesChanges(s.ctx, api.FilesChangesParams{Cursor: api.NewOptString(fresh.Cursor)})
+	if err != nil || len(page.Changes) != 0 {
+		t.Fatalf("expected a fresh cursor to work after pruning: %+v err=%v", page, err)
+	}
+
+	if _, err := client.FilesChanges(s.ctx, api.FilesChangesParams{Cursor: api.NewOptString("garbage!")}); statusCode(err) != 400 {
+		t.Fatalf("expected 400 for an invalid cursor, got %d err=%v", statusCode(err), err)
+	}
+}
+
+func TestFilesChanges_CutsBySequence(t *testing.T) {
+	s := newSuite(t)
+	const userID int64 = 91150
+	client := s.newClientWithToken(s.authTokenForUser(userID, "changes-seq-session"))
+
+	start, err := client.FilesChanges(s.ctx, api.FilesChangesParams{})
+	if err != nil {
+		t.Fatalf("FilesChanges without cursor failed: %v", err)
+	}
+
+	// The first event gets the lower sequence number but a later time, the
+	// way an event from a slow writer would.
+	if err := s.repos.Events.Create(s.ctx, &jetmodel.Events{ID: uuid.New(), Type: "files.created", UserID: userID, CreatedAt: time.Now().UTC()}); err != nil {
+		t.Fatalf("create event: %v", err)
+	}
+	if err := s.repos.Events.Create(s.ctx, &jetmodel.Events{ID: uuid.New(), Type: "files.moved", UserID: userID, CreatedAt: time.Now().UTC().Add(-time.Minute)}); err != nil {
+		t.Fatalf("create event: %v", err)
+	}
+
+	page, err := client.FilesChanges(s.ctx, api.FilesChangesParams{Cursor: api.NewOptString(start.Cursor)})
+	if err != nil {
+		t.Fatalf("FilesChanges failed: %v", err)
+	}
+	if len(page.Changes) != 2 || page.Changes[0].Type != api.EventTypeFilesCreated || page.Changes[1].Type != api.EventTypeFilesMoved {
+		t.Fatalf("expected both events up to the settled sequence number, got %+v", page)
+	}
+
+	if err := s.repos.Events.Create(s.ctx, &jetmodel.Events{ID: uuid.New(), Type: "files.deleted", UserID: userID}); err != nil {
+		t.Fatalf("create event without a time: %v", err)
+	}
+	page, err = client.FilesChanges(s.ctx, api.FilesChangesParams{Cursor: api.NewOptString(page.Cursor)})
+	if err != nil {
+		t.Fatalf("FilesChanges failed: %v", err)
+	}
+	if len(page.Changes) != 0 {
+		t.Fatalf("expected the event stamped by the database to be held back, got %+v", page)
+	}
+}
@@ -0,0 +1,109 @@
+package integration_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gotd/td/tg"
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/pkg/services"
+)
+
+func TestFileDedup_ReusesPartsAndKeepsSharedMessages(t *testing.T) {
+	s := newSuite(t)
+	ctx := context.Background()
+	s.cfg.TG.Uploads.Dedup = true
+
+	token := loginAndGetToken(t, s, 7970, "user7970")
+	client := s.newClientWithToken(token)
+
+	if err := client.UsersUpdateChannel(ctx, &api.ChannelUpdate{ChannelId: api.NewOptInt64(797001), ChannelName: api.NewOptString("dedup")}); err != nil {
+		t.Fatalf("UsersUpdateChannel failed: %v", err)
+	}
+
+	nextMessageID := 13000
+	s.tgMock.uploadPartFn = func(_ context.Context, _ *tg.Client, _ int64, _ string, fileStream io.Reader, fileSize int64, _ int) (int, int64, error) {
+		if _, err := io.Copy(io.Discard, fileStream); err != nil {
+			return 0, 0, err
+		}
+		nextMessageID++
+		return nextMessageID, fileSize, nil
+	}
+	var deleted []int
+	s.tgMock.deleteMessagesFn = func(_ context.Context, _ services.TelegramClient, channelID int64, ids []int) error {
+		if channelID != 797001 {
+			t.Errorf("unexpected channel id: %d", channelID)
+		}
+		deleted = append(deleted, ids...)
+		return nil
+	}
+
+	content := []byte("the same bytes twice")
+	var files []*api.File
+	for i, uploadID := range []string{"up-dedup-1", "up-dedup-2"} {
+		if _, status, raw := uploadPartRaw(t, s, token, uploadID, "iso.bin", 1, 797001, false, true, content); status != http.StatusOK {
+			t.Fatalf("upload %d: expected 200, got %d body=%s", i, status, string(raw))
+		}
+		file, err := client.FilesCreate(ctx, &api.File{
+			Name:      []string{"first.iso", "second.iso"}[i],
+			Type:      api.FileTypeFile,
+			Path:      api.NewOptString("/"),
+			MimeType:  api.NewOptString("application/octet-stream"),
+			ChannelId: api.NewOptInt64(797001),
+			Size:      api.NewOptInt64(int64(len(content))),
+			UploadId:  api.NewOptString(uploadID),
+		})
+		if err != nil {
+			t.Fatalf("FilesCreate %s failed: %v", uploadID, err)
+		}
+		files = append(files, file)
+	}
+
+	second, err := client.FilesGetById(ctx, api.FilesGetByIdParams{ID: files[1].ID.Value})
+	if err != nil {
+		t.Fatalf("FilesGetById failed: %v", err)
+	}
+	if len(second.Parts) != 1 || second.Parts[0].ID != 13001 {
+		t.Fatalf("expected second file to reuse the first upload's part, got %+v", second.Parts)
+	}
+	if !slices.Equal(deleted, []int{13002}) {
+		t.Fatalf("expected the duplicate upload message to be deleted, got %v", deleted)
+	}
+	rows, err := s.repos.Uploads.GetByUploadID(ctx, "up-dedup-2")
+	if err != nil {
+		t.Fatalf("GetByUploadID failed: %v", err)
+	}
+	if len(rows) != 0 {
+		t.Fatalf("expected duplicate upload rows to be removed, got %d", len(rows))
+	}
+
+	deleted = nil
+	if err := client.FilesDeleteById(ctx, api.FilesDeleteByIdParams{ID: files[0].ID.Value}); err != nil {
+		t.Fatalf("FilesDeleteById failed: %v", err)
+	}
+	if err := client.FilesEmptyTrash(ctx, &api.TrashEmpty{}); err != nil {
+		t.Fatalf("FilesEmptyTrash failed: %v", err)
+	}
+	if err := s.exec.CleanPendingFilesForUser(ctx, 7970); err != nil {
+		t.Fatalf("CleanPendingFilesForUser failed: %v", err)
+	}
+	if len(deleted) != 0 {
+		t.Fatalf("expected shared part to survive while referenced, got deleted %v", deleted)
+	}
+
+	if err := client.FilesDeleteById(ctx, api.FilesDeleteByIdParams{ID: files[1].ID.Value}); err != nil {
+		t.Fatalf("FilesDeleteById failed: %v", err)
+	}
+	if err := client.FilesEmptyTrash(ctx, &api.TrashEmpty{}); err != nil {
+		t.Fatalf("FilesEmptyTrash failed: %v", err)
+	}
+	if err := s.exec.CleanPendingFilesForUser(ctx, 7970); err != nil {
+		t.Fatalf("CleanPendingFilesForUser failed: %v", err)
+	}
+	if !slices.Equal(deleted, []int{13001}) {
+		t.Fatalf("expected the last reference to free the part, got deleted %v", deleted)
+	}
+}
@@ -0,0 +1,122 @@
+package integration_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/pkg/queue"
+)
+
+func TestFileVersions_UpdateListRestore(t *testing.T) {
+	s := newSuite(t)
+	ctx := context.Background()
+	_, client, _ := loginWithClient(t, s, 7960, "user7960")
+
+	file, err := client.FilesCreate(ctx, &api.File{
+		Name:      "notes.txt",
+		Type:      api.FileTypeFile,
+		Path:      api.NewOptString("/"),
+		MimeType:  api.NewOptString("text/plain"),
+		ChannelId: api.NewOptInt64(796001),
+		Size:      api.NewOptInt64(10),
+		Parts:     []api.Part{{ID: 1}},
+	})
+	if err != nil {
+		t.Fatalf("FilesCreate failed: %v", err)
+	}
+	fileID := file.ID.Value
+
+	for i, size := range []int64{20, 30} {
+		if _, err := client.FilesUpdate(ctx, &api.FileUpdate{
+			Size:  api.NewOptInt64(size),
+			Parts: []api.Part{{ID: i + 2}},
+		}, api.FilesUpdateParams{ID: fileID}); err != nil {
+			t.Fatalf("FilesUpdate content %d failed: %v", size, err)
+		}
+	}
+	if _, err := client.FilesUpdate(ctx, &api.FileUpdate{Name: api.NewOptString("notes-renamed.txt")}, api.FilesUpdateParams{ID: fileID}); err != nil {
+		t.Fatalf("FilesUpdate rename failed: %v", err)
+	}
+
+	versions, err := client.FilesListVersions(ctx, api.FilesListVersionsParams{ID: fileID})
+	if err != nil {
+		t.Fatalf("FilesListVersions failed: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 versions after two content updates, got %+v", versions)
+	}
+	if versions[0].Version != 2 || versions[0].Size.Value != 20 || versions[1].Version != 1 || versions[1].Size.Value != 10 {
+		t.Fatalf("unexpected versions: %+v", versions)
+	}
+
+	restored, err := client.FilesRestoreVersion(ctx, api.FilesRestoreVersionParams{ID: fileID, VersionId: versions[1].ID})
+	if err != nil {
+		t.Fatalf("FilesRestoreVersion failed: %v", err)
+	}
+	if restored.Size.Value != 10 || len(restored.Parts) != 1 || restored.Parts[0].ID != 1 {
+		t.Fatalf("expected first content restored, got %+v", restored)
+	}
+
+	versions, err = client.FilesListVersions(ctx, api.FilesListVersionsParams{ID: fileID})
+	if err != nil {
+		t.Fatalf("FilesListVersions after restore failed: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 3 || versions[0].Size.Value != 30 {
+		t.Fatalf("expected replaced content kept as version 3, got %+v", versions)
+	}
+
+	if _, err := client.FilesRestoreVersion(ctx, api.FilesRestoreVersionParams{ID: fileID, VersionId: api.UUID(uuid.New())}); statusCode(err) != 404 {
+		t.Fatalf("expected 404 restoring unknown version, got %d err=%v", statusCode(err), err)
+	}
+	if _, err := client.FilesListVersions(ctx, api.FilesListVersionsParams{ID: api.UUID(uuid.New())}); statusCode(err) != 404 {
+		t.Fatalf("expected 404 listing versions of unknown file, got %d err=%v", statusCode(err), err)
+	}
+}
+
+func TestFileVersions_CleanKeepsNewest(t *testing.T) {
+	s := newSuite(t)
+	ctx := context.Background()
+	_, client, _ := loginWithClient(t, s, 7961, "user7961")
+
+	file, err := client.FilesCreate(ctx, &api.File{
+		Name:      "draft.txt",
+		Type:      api.FileTypeFile,
+		Path:      api.NewOptString("/"),
+		MimeType:  api.NewOptString("text/plain"),
+		ChannelId: api.NewOptInt64(796101),
+		Size:      api.NewOptInt64(1),
+		Parts:     []api.Part{{ID: 1}},
+	})
+	if err != nil {
+		t.Fatalf("FilesCreate failed: %v", err)
+	}
+	fileID := file.ID.Value
+
+	for i := 2; i <= 4; i++ {
+		if _, err := client.FilesUpdate(ctx, &api.FileUpdate{
+			Size:  api.NewOptInt64(int64(i)),
+			Parts: []api.Part{{ID: i}},
+		}, api.FilesUpdateParams{ID: fileID}); err != nil {
+			t.Fatalf("FilesUpdate content %d failed: %v", i, err)
+		}
+	}
+
+	// Versions without a channel have no messages to delete, so the cleanup
+	// does not need a Telegram client.
+	if _, err := s.pool.Exec(ctx, `UPDATE teldrive.file_versions SET channel_id = NULL WHERE file_id = $1`, uuid.UUID(fileID)); err != nil {
+		t.Fatalf("detach versions from channel: %v", err)
+	}
+	if err := s.exec.CleanFileVersionsForUser(ctx, queue.CleanFileVersionsArgs{UserID: 7961, Keep: 1}); err != nil {
+		t.Fatalf("CleanFileVersionsForUser failed: %v", err)
+	}
+
+	versions, err := client.FilesListVersions(ctx, api.FilesListVersionsParams{ID: fileID})
+	if err != nil {
+		t.Fatalf("FilesListVersions failed: %v", err)
+	}
+	if len(versions) != 1 || versions[0].Version != 3 {
+		t.Fatalf("expected only the newest version kept, got %+v", versions)
+	}
+}
@@ -0,0 +1,242 @@
+package integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/internal/config"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/tgc"
+	"github.com/tgdrive/teldrive/pkg/queue"
+	"github.com/tgdrive/teldrive/pkg/services"
+)
+
+func TestFilesCopy_FolderRunsCopyJob(t *testing.T) {
+	s := newSuite(t)
+	resetRiverTables(t, s)
+	ctx := context.Background()
+	const userID int64 = 7980
+	_, client, _ := loginWithClient(t, s, userID, "user7980")
+
+	selected := true
+	if err := s.repos.Channels.Create(ctx, &jetmodel.Channels{
+		ChannelID:   798002,
+		ChannelName: "copy-target",
+		UserID:      userID,
+		Selected:    &selected,
+		CreatedAt:   time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create selected channel: %v", err)
+	}
+
+	for _, path := range []string{"/src/sub", "/src/empty", "/dst"} {
+		if _, err := s.repos.Files.CreateDirectories(ctx, userID, path); err != nil {
+			t.Fatalf("CreateDirectories %s failed: %v", path, err)
+		}
+	}
+	for _, f := range []struct {
+		path  string
+		name  string
+		parts []api.Part
+	}{
+		{"/src", "a.txt", []api.Part{{ID: 1}}},
+		{"/src/sub", "b.txt", []api.Part{{ID: 2}, {ID: 3}}},
+	} {
+		if _, err := client.FilesCreate(ctx, &api.File{
+			Name:      f.name,
+			Type:      api.FileTypeFile,
+			Path:      api.NewOptString(f.path),
+			MimeType:  api.NewOptString("text/plain"),
+			ChannelId: api.NewOptInt64(798001),
+			Size:      api.NewOptInt64(int64(len(f.parts))),
+			Parts:     f.parts,
+		}); err != nil {
+			t.Fatalf("FilesCreate %s failed: %v", f.name, err)
+		}
+	}
+	srcID, err := s.repos.Files.ResolvePathID(ctx, "/src", userID)
+	if err != nil {
+		t.Fatalf("resolve /src: %v", err)
+	}
+	dstID, err := s.repos.Files.ResolvePathID(ctx, "/dst", userID)
+	if err != nil {
+		t.Fatalf("resolve /dst: %v", err)
+	}
+
+	copied, err := client.FilesCopy(ctx, &api.FileCopy{Destination: dstID.String()}, api.FilesCopyParams{ID: api.UUID(*srcID)})
+	if err != nil {
+		t.Fatalf("FilesCopy folder failed: %v", err)
+	}
+	if copied.Type != api.FileTypeFolder || copied.Name != "src" {
+		t.Fatalf("expected the new top folder, got %+v", copied)
+	}
+	if _, err := client.FilesCopy(ctx, &api.FileCopy{Destination: dstID.String()}, api.FilesCopyParams{ID: api.UUID(*srcID)}); statusCode(err) != 409 {
+		t.Fatalf("expected 409 copying onto an existing folder, got %d err=%v", statusCode(err), err)
+	}
+	subID, err := s.repos.Files.ResolvePathID(ctx, "/src/sub", userID)
+	if err != nil {
+		t.Fatalf("resolve /src/sub: %v", err)
+	}
+	if _, err := client.FilesCopy(ctx, &api.FileCopy{Destination: subID.String()}, api.FilesCopyParams{ID: api.UUID(*srcID)}); statusCode(err) != 400 {
+		t.Fatalf("expected 400 copying a folder into itself, got %d err=%v", statusCode(err), err)
+	}
+
+	var copyCalls atomic.Int32
+	s.tgMock.copyFilePartsFn = func(_ context.Context, _ services.TelegramClient, fromChannelID, toChannelID int64, parts []api.Part) ([]api.Part, error) {
+		copyCalls.Add(1)
+		if fromChannelID != 798001 || toChannelID != 798002 {
+			t.Errorf("unexpected channels %d -> %d", fromChannelID, toChannelID)
+		}
+		out := make([]api.Part, 0, len(parts))
+		for _, part := range parts {
+			out = append(out, api.Part{ID: part.ID + 1000})
+		}
+		return out, nil
+	}
+
+	channelManager := tgc.NewChannelManager(s.repos, s.cache, &s.cfg.TG)
+	jobClientRef := services.NewJobClientRef()
+	apiSvc := services.NewApiService(s.repos, channelManager, s.cfg, s.cache, s.tgMock, s.events, jobClientRef, services.NewPeriodicJobRegistryRef())
+	riverClient, err := queue.NewClient(s.pool, services.NewJobExecutor(apiSvc), config.QueueConfig{}, config.JobsConfig{})
+	if err != nil {
+		t.Fatalf("create river client: %v", err)
+	}
+	jobClientRef.Set(riverClient)
+	if err := riverClient.Start(ctx); err != nil {
+		t.Fatalf("start river client: %v", err)
+	}
+	t.Cleanup(func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = riverClient.Stop(stopCtx)
+	})
+
+	inserted, err := riverClient.Insert(ctx, queue.FilesCopyJobArgs{
+		UserID:      userID,
+		Items:       []queue.JobItem{{ID: srcID.String()}},
+		Destination: dstID.String(),
+	}, &river.InsertOpts{MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("insert files.copy: %v", err)
+	}
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for files.copy completion")
+		}
+		job, err := riverClient.JobGet(ctx, inserted.Job.ID)
+		if err != nil {
+			t.Fatalf("job get: %v", err)
+		}
+		if job.State == rivertype.JobStateCompleted {
+			break
+		}
+		if job.State == rivertype.JobStateDiscarded || job.State == rivertype.JobStateCancelled {
+			t.Fatalf("files.copy finished in bad state: %s errors=%v", job.State, job.Errors)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	if calls := copyCalls.Load(); calls != 1 {
+		t.Fatalf("expected one batched part copy, got %d", calls)
+	}
+	if _, err := s.repos.Files.ResolvePathID(ctx, "/dst/src/empty", userID); err != nil {
+		t.Fatalf("expected empty folder to be recreated: %v", err)
+	}
+	assertCopiedParts(t, s, userID, "/dst/src", "a.txt", []int{1001})
+	assertCopiedParts(t, s, userID, "/dst/src/sub", "b.txt", []int{1002, 1003})
+
+	// A name already taken by anything but an earlier copy is a conflict
+	runCopy := func(destination string, items ...queue.JobItem) []map[string]any {
+		t.Helper()
+		inserted, err := riverClient.Insert(ctx, queue.FilesCopyJobArgs{UserID: userID, Items: items, Destination: destination}, &river.InsertOpts{MaxAttempts: 1})
+		if err != nil {
+			t.Fatalf("insert files.copy: %v", err)
+		}
+		deadline := time.Now().Add(30 * time.Second)
+		for {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for files.copy completion")
+			}
+			job, err := riverClient.JobGet(ctx, inserted.Job.ID)
+			if err != nil {
+				t.Fatalf("job get: %v", err)
+			}
+			if job.State == rivertype.JobStateCompleted {
+				var out struct {
+					Data struct {
+						Results []map[string]any `json:"results"`
+					} `json:"data"`
+				}
+				if err := json.Unmarshal(job.Output(), &out); err != nil {
+					t.Fatalf("decode files.copy output: %v", err)
+				}
+				return out.Data.Results
+			}
+			if job.State == rivertype.JobStateDiscarded || job.State == rivertype.JobStateCancelled {
+				t.Fatalf("files.copy finished in bad state: %s errors=%v", job.State, job.Errors)
+			}
+			time.Sleep(200 * time.Millisecond)
+		}
+	}
+	conflicts := func(results []map[string]any) []string {
+		var out []string
+		for _, r := range results {
+			if _, ok := r["error"]; ok {
+				out = append(out, r["id"].(string))
+			}
+		}
+		return out
+	}
+	aID, err := s.repos.Files.ResolvePathID(ctx, "/src/a.txt", userID)
+	if err != nil {
+		t.Fatalf("resolve /src/a.txt: %v", err)
+	}
+	bID, err := s.repos.Files.ResolvePathID(ctx, "/src/sub/b.txt", userID)
+	if err != nil {
+		t.Fatalf("resolve /src/sub/b.txt: %v", err)
+	}
+	if got := conflicts(runCopy(srcID.String(), queue.JobItem{ID: aID.String()})); !slices.Equal(got, []string{aID.String()}) {
+		t.Fatalf("expected copying into the source folder to conflict, got %v", got)
+	}
+	dstSrcID, err := s.repos.Files.ResolvePathID(ctx, "/dst/src", userID)
+	if err != nil {
+		t.Fatalf("resolve /dst/src: %v", err)
+	}
+	results := runCopy(dstSrcID.String(), queue.JobItem{ID: aID.String()}, queue.JobItem{ID: bID.String(), DestinationName: "a.txt"})
+	if got := conflicts(results); !slices.Equal(got, []string{bID.String()}) {
+		t.Fatalf("expected only the different file to conflict, got %v", got)
+	}
+	if calls := copyCalls.Load(); calls != 1 {
+		t.Fatalf("expected no parts to be copied for skipped items, got %d calls", calls)
+	}
+	assertCopiedParts(t, s, userID, "/dst/src", "a.txt", []int{1001})
+}
+
+func assertCopiedParts(t *testing.T, s *suite, userID int64, dir, name string, want []int) {
+	t.Helper()
+	parentID, err := s.repos.Files.ResolvePathID(context.Background(), dir, userID)
+	if err != nil {
+		t.Fatalf("resolve %s: %v", dir, err)
+	}
+	file, err := s.repos.Files.GetActiveByNameAndParent(context.Background(), userID, name, parentID)
+	if err != nil {
+		t.Fatalf("expected %s/%s to be copied: %v", dir, name, err)
+	}
+	if file.ChannelID == nil || *file.ChannelID != 798002 || file.Parts == nil || len(file.Parts.Data) != len(want) {
+		t.Fatalf("unexpected copy of %s/%s: %+v", dir, name, file)
+	}
+	for i, part := range file.Parts.Data {
+		if part.ID != want[i] {
+			t.Fatalf("unexpected parts for %s/%s: %+v", dir, name, file.Parts.Data)
+		}
+	}
+}
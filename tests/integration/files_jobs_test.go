@@ -0,0 +1,161 @@
+package integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/internal/config"
+	"github.com/tgdrive/teldrive/internal/tgc"
+	"github.com/tgdrive/teldrive/pkg/queue"
+	"github.com/tgdrive/teldrive/pkg/services"
+)
+
+func TestFilesJobs_MoveAndDeleteWithCheckpoint(t *testing.T) {
+	s := newSuite(t)
+	resetRiverTables(t, s)
+	ctx := context.Background()
+	const userID int64 = 7990
+	_, client, _ := loginWithClient(t, s, userID, "user7990")
+
+	for _, path := range []string{"/a/inner", "/b", "/dst"} {
+		if _, err := s.repos.Files.CreateDirectories(ctx, userID, path); err != nil {
+			t.Fatalf("CreateDirectories %s failed: %v", path, err)
+		}
+	}
+	ids := map[string]uuid.UUID{}
+	for _, name := range []string{"x.txt", "y.txt"} {
+		file, err := client.FilesCreate(ctx, &api.File{
+			Name:      name,
+			Type:      api.FileTypeFile,
+			Path:      api.NewOptString("/"),
+			MimeType:  api.NewOptString("text/plain"),
+			ChannelId: api.NewOptInt64(799001),
+			Size:      api.NewOptInt64(1),
+			Parts:     []api.Part{{ID: 1}},
+		})
+		if err != nil {
+			t.Fatalf("FilesCreate %s failed: %v", name, err)
+		}
+		ids[name] = uuid.UUID(file.ID.Value)
+	}
+	for _, path := range []string{"/a", "/a/inner", "/b", "/dst"} {
+		id, err := s.repos.Files.ResolvePathID(ctx, path, userID)
+		if err != nil {
+			t.Fatalf("resolve %s: %v", path, err)
+		}
+		ids[path] = *id
+	}
+
+	channelManager := tgc.NewChannelManager(s.repos, s.cache, &s.cfg.TG)
+	jobClientRef := services.NewJobClientRef()
+	apiSvc := services.NewApiService(s.repos, channelManager, s.cfg, s.cache, s.tgMock, s.events, jobClientRef, services.NewPeriodicJobRegistryRef())
+	riverClient, err := queue.NewClient(s.pool, services.NewJobExecutor(apiSvc), config.QueueConfig{}, config.JobsConfig{})
+	if err != nil {
+		t.Fatalf("create river client: %v", err)
+	}
+	jobClientRef.Set(riverClient)
+
+	// A delete job whose first item was handled by an earlier attempt.
+	deleteJob, err := riverClient.Insert(ctx, queue.FilesDeleteJobArgs{
+		UserID: userID,
+		Items:  []queue.JobItem{{ID: ids["y.txt"].String()}, {ID: ids["/b"].String()}},
+	}, &river.InsertOpts{MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("insert files.delete: %v", err)
+	}
+	if _, err := riverClient.JobUpdate(ctx, deleteJob.Job.ID, &river.JobUpdateParams{
+		Output: map[string]any{"progress": map[string]any{"done": 1, "total": 2}},
+	}); err != nil {
+		t.Fatalf("seed files.delete checkpoint: %v", err)
+	}
+
+	if err := riverClient.Start(ctx); err != nil {
+		t.Fatalf("start river client: %v", err)
+	}
+	t.Cleanup(func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = riverClient.Stop(stopCtx)
+	})
+
+	waitForJobCompleted(t, riverClient, deleteJob.Job.ID)
+	if _, err := s.repos.Files.ResolvePathID(ctx, "/b", userID); err == nil {
+		t.Fatalf("expected /b to be deleted")
+	}
+	if _, err := client.FilesGetById(ctx, api.FilesGetByIdParams{ID: api.UUID(ids["y.txt"])}); err != nil {
+		t.Fatalf("expected y.txt before the checkpoint to be kept: %v", err)
+	}
+
+	moveJob, err := riverClient.Insert(ctx, queue.FilesMoveJobArgs{
+		UserID: userID,
+		Items: []queue.JobItem{
+			{ID: ids["x.txt"].String(), DestinationName: "x-moved.txt"},
+			{ID: ids["/a"].String()},
+			{ID: ids["/dst"].String()},
+		},
+		Destination: ids["/dst"].String(),
+	}, &river.InsertOpts{MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("insert files.move: %v", err)
+	}
+	row := waitForJobCompleted(t, riverClient, moveJob.Job.ID)
+
+	if _, err := s.repos.Files.ResolvePathID(ctx, "/dst/a/inner", userID); err != nil {
+		t.Fatalf("expected /a to be moved with its content: %v", err)
+	}
+	assertFileStatus(t, s, ids["x.txt"], "active")
+	moved, err := client.FilesGetById(ctx, api.FilesGetByIdParams{ID: api.UUID(ids["x.txt"])})
+	if err != nil {
+		t.Fatalf("FilesGetById x.txt failed: %v", err)
+	}
+	if moved.Name != "x-moved.txt" || uuid.UUID(moved.ParentId.Value) != ids["/dst"] {
+		t.Fatalf("expected x.txt renamed into /dst, got %+v", moved)
+	}
+
+	var output struct {
+		Progress struct {
+			Done  int `json:"done"`
+			Total int `json:"total"`
+		} `json:"progress"`
+		Data struct {
+			Results []map[string]any `json:"results"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(row.Output(), &output); err != nil {
+		t.Fatalf("decode files.move output: %v", err)
+	}
+	if output.Progress.Done != 3 || output.Progress.Total != 3 {
+		t.Fatalf("unexpected files.move progress: %+v", output.Progress)
+	}
+	if len(output.Data.Results) != 1 || output.Data.Results[0]["id"] != ids["/dst"].String() {
+		t.Fatalf("expected moving /dst into itself to be reported, got %+v", output.Data.Results)
+	}
+}
+
+func waitForJobCompleted(t *testing.T, riverClient *river.Client[pgx.Tx], jobID int64) *rivertype.JobRow {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for job %d", jobID)
+		}
+		job, err := riverClient.JobGet(context.Background(), jobID)
+		if err != nil {
+			t.Fatalf("job get: %v", err)
+		}
+		if job.State == rivertype.JobStateCompleted {
+			return job
+		}
+		if job.State == rivertype.JobStateDiscarded || job.State == rivertype.JobStateCancelled {
+			t.Fatalf("job %d finished in bad state: %s errors=%v", jobID, job.State, job.Errors)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
@@ -0,0 +1,79 @@
+package integration_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tgdrive/teldrive/internal/api"
+	authpkg "github.com/tgdrive/teldrive/internal/auth"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/events"
+	"github.com/tgdrive/teldrive/internal/tgc"
+	"github.com/tgdrive/teldrive/pkg/services"
+)
+
+func TestLifecycleEvents_SharesAndAPIKeys(t *testing.T) {
+	s := newSuite(t)
+	token := loginAndGetToken(t, s, 91080, "user91080")
+
+	sec := authpkg.NewSecurityHandler(s.repos.Sessions, s.repos.APIKeys, s.cache, &s.cfg.JWT)
+	ctx, err := sec.HandleBearerAuth(context.Background(), api.OperationName("lifecycle"), api.BearerAuth{Token: token})
+	if err != nil {
+		t.Fatalf("security ctx: %v", err)
+	}
+
+	recorder := &recordingEventBroadcaster{}
+	cm := tgc.NewChannelManager(s.repos, s.cache, &s.cfg.TG)
+	svc := services.NewApiService(s.repos, cm, s.cfg, s.cache, s.tgMock, recorder, nil, nil)
+
+	root, err := s.repos.Files.ResolvePathID(ctx, "/", 91080)
+	if err != nil || root == nil {
+		t.Fatalf("resolve root: %v", err)
+	}
+	fileID := uuid.New()
+	status := "active"
+	now := time.Now().UTC()
+	if err := s.repos.Files.Create(ctx, &jetmodel.Files{ID: fileID, Name: "shared.txt", Type: "file", MimeType: "text/plain", UserID: 91080, ParentID: root, Status: &status, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+
+	if err := svc.FilesCreateShare(ctx, &api.FileShareCreate{Password: api.NewOptString("secret")}, api.FilesCreateShareParams{ID: api.UUID(fileID)}); err != nil {
+		t.Fatalf("create share: %v", err)
+	}
+	created := recorder.ofType(events.OpShareCreate)
+	if len(created) != 1 {
+		t.Fatalf("expected one shares.created event, got %d", len(created))
+	}
+	source := created[0].Source
+	if created[0].UserID != 91080 || source == nil || source.ID != fileID.String() || source.Name != "shared.txt" || source.ShareID == "" {
+		t.Fatalf("unexpected shares.created event: %+v %+v", created[0], source)
+	}
+
+	shareID, err := uuid.Parse(source.ShareID)
+	if err != nil {
+		t.Fatalf("parse share id: %v", err)
+	}
+	if _, err := svc.SharesUnlock(ctx, &api.ShareUnlock{Password: "wrong"}, api.SharesUnlockParams{ID: api.UUID(shareID)}); err == nil {
+		t.Fatalf("expected wrong password to fail")
+	}
+	if got := recorder.ofType(events.OpShareUnlock); len(got) != 0 {
+		t.Fatalf("failed unlock recorded %d events", len(got))
+	}
+	if _, err := svc.SharesUnlock(ctx, &api.ShareUnlock{Password: "secret"}, api.SharesUnlockParams{ID: api.UUID(shareID)}); err != nil {
+		t.Fatalf("unlock share: %v", err)
+	}
+	if got := recorder.ofType(events.OpShareUnlock); len(got) != 1 || got[0].Source.ShareID != shareID.String() {
+		t.Fatalf("expected one shares.unlocked event, got %+v", got)
+	}
+
+	key, err := svc.UsersCreateApiKey(ctx, &api.UserApiKeyCreate{Name: "ci"})
+	if err != nil {
+		t.Fatalf("create api key: %v", err)
+	}
+	keys := recorder.ofType(events.OpAPIKeyCreate)
+	if len(keys) != 1 || keys[0].Source.ID != uuid.UUID(key.ID).String() || keys[0].Source.Name != "ci" || keys[0].Source.Type != "apiKey" {
+		t.Fatalf("expected one apiKeys.created event, got %+v", keys)
+	}
+}
@@ -6,9 +6,11 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/gotd/contrib/storage"
+	"github.com/gotd/td/bin"
 	"github.com/gotd/td/session"
 	"github.com/gotd/td/telegram/auth/qrlogin"
 	"github.com/gotd/td/tg"
@@ -104,6 +106,77 @@ func (m *authFlowMockClient) Password(ctx context.Context, password string) (*se
 	return &services.TelegramUser{}, nil
 }
 
+// telegramFiles serves stored part content through a fake tg.Client, so
+// streams, archives and pushes can read real bytes without Telegram.
+type telegramFiles struct {
+	mu    sync.Mutex
+	parts map[int][]byte
+}
+
+func newTelegramFiles() *telegramFiles { return &telegramFiles{parts: map[int][]byte{}} }
+
+func (f *telegramFiles) add(partID int, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.parts[partID] = data
+}
+
+// client returns a Telegram client whose API reads from f
+func (f *telegramFiles) client() services.TelegramClient {
+	return &telegramFilesClient{api: tg.NewClient(f)}
+}
+
+func (f *telegramFiles) getParts(_ context.Context, _ services.TelegramClient, _ int64, parts []api.Part, _ bool) ([]types.Part, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]types.Part, 0, len(parts))
+	for _, part := range parts {
+		data, ok := f.parts[part.ID]
+		if !ok {
+			continue
+		}
+		out = append(out, types.Part{ID: int64(part.ID), Size: int64(len(data)), Salt: part.Salt.Value})
+	}
+	return out, nil
+}
+
+func (f *telegramFiles) Invoke(_ context.Context, input bin.Encoder, output bin.Decoder) error {
+	var resp bin.Encoder
+	switch req := input.(type) {
+	case *tg.ChannelsGetChannelsRequest:
+		channelID := req.ID[0].(*tg.InputChannel).ChannelID
+		resp = &tg.MessagesChats{Chats: []tg.ChatClass{&tg.Channel{ID: channelID, Photo: &tg.ChatPhotoEmpty{}}}}
+	case *tg.ChannelsGetMessagesRequest:
+		id := req.ID[0].(*tg.InputMessageID).ID
+		resp = &tg.MessagesChannelMessages{Messages: []tg.MessageClass{&tg.Message{
+			ID:     id,
+			PeerID: &tg.PeerChannel{},
+			Media:  &tg.MessageMediaDocument{Document: &tg.Document{ID: int64(id)}},
+		}}}
+	case *tg.UploadGetFileRequest:
+		f.mu.Lock()
+		data := f.parts[int(req.Location.(*tg.InputDocumentFileLocation).ID)]
+		f.mu.Unlock()
+		start := min(req.Offset, int64(len(data)))
+		end := min(start+int64(req.Limit), int64(len(data)))
+		resp = &tg.UploadFile{Type: &tg.StorageFilePartial{}, Bytes: data[start:end]}
+	default:
+		return errUnexpectedTelegramCall
+	}
+	var buf bin.Buffer
+	if err := resp.Encode(&buf); err != nil {
+		return err
+	}
+	return output.Decode(&buf)
+}
+
+type telegramFilesClient struct {
+	mockTelegramClient
+	api *tg.Client
+}
+
+func (c *telegramFilesClient) API() *tg.Client { return c.api }
+
 type mockUploadPool struct{}
 
 func (m *mockUploadPool) Default(context.Context) *tg.Client { return nil }
@@ -122,6 +195,7 @@ type mockTelegramService struct {
 	getMessagesFn    func(ctx context.Context, client services.TelegramClient, ids []int, channelID int64) ([]tg.MessageClass, error)
 	getPartsFn       func(ctx context.Context, client services.TelegramClient, channelID int64, parts []api.Part, encrypted bool) ([]types.Part, error)
 	copyFilePartsFn  func(ctx context.Context, client services.TelegramClient, fromChannelID int64, toChannelID int64, parts []api.Part) ([]api.Part, error)
+	deleteMessagesFn func(ctx context.Context, client services.TelegramClient, channelID int64, ids []int) error
 	selectBotTokenFn func(ctx context.Context, operation string, userID int64, tokens []string) (string, int, error)
 	uploadPartFn     func(ctx context.Context, apiClient *tg.Client, channelID int64, partName string, fileStream io.Reader, fileSize int64, threads int) (int, int64, error)
 	noAuthClientFn   func(ctx context.Context, dispatcher tg.UpdateDispatcher, storage session.Storage) (services.TelegramClient, error)
@@ -241,6 +315,13 @@ func (m *mockTelegramService) CopyFileParts(ctx context.Context, client services
 	return nil, errUnexpectedTelegramCall
 }
 
+func (m *mockTelegramService) DeleteMessages(ctx context.Context, client services.TelegramClient, channelID int64, ids []int) error {
+	if m.deleteMessagesFn != nil {
+		return m.deleteMessagesFn(ctx, client, channelID, ids)
+	}
+	return errUnexpectedTelegramCall
+}
+
 func (m *mockTelegramService) UploadPart(ctx context.Context, apiClient *tg.Client, channelID int64, partName string, fileStream io.Reader, fileSize int64, threads int) (int, int64, error) {
 	if m.uploadPartFn != nil {
 		return m.uploadPartFn(ctx, apiClient, channelID, partName, fileStream, fileSize, threads)
@@ -294,8 +375,34 @@ func (n *noopEventBroadcaster) Subscribe(int64, []events.EventType) chan dto.Eve
 
 func (n *noopEventBroadcaster) Unsubscribe(int64, chan dto.Event)           {}
 func (n *noopEventBroadcaster) Record(events.EventType, int64, *dto.Source) {}
+func (n *noopEventBroadcaster) AddRecordHook(events.RecordHook)             {}
 func (n *noopEventBroadcaster) Shutdown()                                   {}
 
+// recordingEventBroadcaster keeps every recorded event for assertions
+type recordingEventBroadcaster struct {
+	noopEventBroadcaster
+	mu       sync.Mutex
+	recorded []dto.Event
+}
+
+func (r *recordingEventBroadcaster) Record(eventType events.EventType, userID int64, source *dto.Source) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorded = append(r.recorded, dto.Event{Type: string(eventType), UserID: userID, Source: source})
+}
+
+func (r *recordingEventBroadcaster) ofType(eventType events.EventType) []dto.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []dto.Event
+	for _, evt := range r.recorded {
+		if evt.Type == string(eventType) {
+			out = append(out, evt)
+		}
+	}
+	return out
+}
+
 type noopJobClient struct{}
 
 func newNoopJobClient() *noopJobClient { return &noopJobClient{} }
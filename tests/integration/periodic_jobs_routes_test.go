@@ -36,6 +36,9 @@ func TestPeriodicJobsRoutes_CRUD_EnableDisable_RunNow(t *testing.T) {
 	if !foundKinds["refresh.folder_sizes"] {
 		t.Fatalf("expected refresh.folder_sizes preset, got %+v", foundKinds)
 	}
+	if !foundKinds["clean.file_versions"] {
+		t.Fatalf("expected clean.file_versions preset, got %+v", foundKinds)
+	}
 
 	assertMaintenanceRetention := func(jobKind api.PeriodicJobKind, expected string) {
 		t.Helper()
@@ -297,6 +300,41 @@ func TestPeriodicJobsRoutes_Validation(t *testing.T) {
 		}
 	})
 
+	t.Run("file versions keep can be updated", func(t *testing.T) {
+		items, err := client.PeriodicJobsList(ctx)
+		if err != nil {
+			t.Fatalf("PeriodicJobsList failed: %v", err)
+		}
+		var versionsID api.UUID
+		for _, item := range items {
+			if item.Kind == api.PeriodicJobKindCleanFileVersions {
+				versionsID = item.ID
+				break
+			}
+		}
+		if uuid.UUID(versionsID) == uuid.Nil {
+			t.Fatalf("expected clean.file_versions maintenance job")
+		}
+
+		updated, err := client.PeriodicJobsUpdate(ctx, &api.PeriodicJobUpdate{
+			Args: api.NewOptPeriodicJobUpdateArgs(api.PeriodicJobUpdateArgs{"keep": jx.Raw(`3`)}),
+		}, api.PeriodicJobsUpdateParams{ID: versionsID})
+		if err != nil {
+			t.Fatalf("PeriodicJobsUpdate failed: %v", err)
+		}
+		args, ok := updated.Args.Get()
+		if !ok || string(args["keep"]) != "3" {
+			t.Fatalf("expected keep=3, got %+v", args)
+		}
+
+		_, err = client.PeriodicJobsUpdate(ctx, &api.PeriodicJobUpdate{
+			Args: api.NewOptPeriodicJobUpdateArgs(api.PeriodicJobUpdateArgs{"keep": jx.Raw(`-1`)}),
+		}, api.PeriodicJobsUpdateParams{ID: versionsID})
+		if statusCode(err) != 400 {
+			t.Fatalf("expected 400 for negative keep, got %d err=%v", statusCode(err), err)
+		}
+	})
+
 	t.Run("run unknown periodic job returns 404", func(t *testing.T) {
 		_, err := client.PeriodicJobsRun(ctx, api.PeriodicJobsRunParams{ID: api.UUID(uuid.MustParse("00000000-0000-0000-0000-000000000000"))})
 		if statusCode(err) != 404 {
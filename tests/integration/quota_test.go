@@ -0,0 +1,157 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/tgdrive/teldrive/internal/api"
+)
+
+func TestQuota_AdminLimitsAndEnforcement(t *testing.T) {
+	s := newSuite(t)
+	ctx := context.Background()
+	s.cfg.JWT.AdminUsers = []string{"admin8001"}
+
+	_, admin, _ := loginWithClient(t, s, 8001, "admin8001")
+	token := loginAndGetToken(t, s, 8002, "user8002")
+	client := s.newClientWithToken(token)
+
+	quota := &api.UserQuota{MaxBytes: api.NewOptInt64(100), MaxFiles: api.NewOptInt64(1)}
+	if err := client.AdminSetUserQuota(ctx, quota, api.AdminSetUserQuotaParams{ID: 8002}); statusCode(err) != 403 {
+		t.Fatalf("expected 403 for non-admin, got %d err=%v", statusCode(err), err)
+	}
+	if err := admin.AdminSetUserQuota(ctx, quota, api.AdminSetUserQuotaParams{ID: 999999}); statusCode(err) != 404 {
+		t.Fatalf("expected 404 for unknown user, got %d err=%v", statusCode(err), err)
+	}
+	if err := admin.AdminSetUserQuota(ctx, quota, api.AdminSetUserQuotaParams{ID: 8002}); err != nil {
+		t.Fatalf("AdminSetUserQuota failed: %v", err)
+	}
+
+	newFile := func(name string, size int64) *api.File {
+		return &api.File{
+			Name:      name,
+			Type:      api.FileTypeFile,
+			Path:      api.NewOptString("/"),
+			MimeType:  api.NewOptString("text/plain"),
+			ChannelId: api.NewOptInt64(800201),
+			Size:      api.NewOptInt64(size),
+			Parts:     []api.Part{{ID: 1}},
+		}
+	}
+	first, err := client.FilesCreate(ctx, newFile("first.txt", 60))
+	if err != nil {
+		t.Fatalf("FilesCreate within quota failed: %v", err)
+	}
+	if _, err := client.FilesCreate(ctx, newFile("second.txt", 10)); statusCode(err) != http.StatusInsufficientStorage {
+		t.Fatalf("expected 507 over the file limit, got %d err=%v", statusCode(err), err)
+	}
+	if _, err := client.FilesCopy(ctx, &api.FileCopy{Destination: "/"}, api.FilesCopyParams{ID: first.ID.Value}); statusCode(err) != http.StatusInsufficientStorage {
+		t.Fatalf("expected 507 copying over the file limit, got %d err=%v", statusCode(err), err)
+	}
+
+	stats, err := client.UsersStats(ctx)
+	if err != nil {
+		t.Fatalf("UsersStats failed: %v", err)
+	}
+	if stats.Usage.TotalFiles != 1 || stats.Usage.TotalSize != 60 {
+		t.Fatalf("unexpected usage: %+v", stats.Usage)
+	}
+	if stats.Quota.MaxBytes.Value != 100 || stats.Quota.MaxFiles.Value != 1 {
+		t.Fatalf("unexpected quota: %+v", stats.Quota)
+	}
+
+	if err := admin.AdminSetUserQuota(ctx, &api.UserQuota{MaxBytes: api.NewOptInt64(100)}, api.AdminSetUserQuotaParams{ID: 8002}); err != nil {
+		t.Fatalf("AdminSetUserQuota without file limit failed: %v", err)
+	}
+	if _, status, raw := uploadPartRaw(t, s, token, "up-quota-1", "big.bin", 1, 800201, false, false, bytes.Repeat([]byte("x"), 50)); status != http.StatusInsufficientStorage {
+		t.Fatalf("expected 507 uploading over the byte limit, got %d body=%s", status, string(raw))
+	}
+	if _, err := client.FilesCreate(ctx, newFile("third.txt", 40)); err != nil {
+		t.Fatalf("FilesCreate after lifting the file limit failed: %v", err)
+	}
+
+	stats, err = client.UsersStats(ctx)
+	if err != nil {
+		t.Fatalf("UsersStats failed: %v", err)
+	}
+	if stats.Quota.MaxFiles.IsSet() || stats.Usage.TotalFiles != 2 {
+		t.Fatalf("expected file limit removed and two files counted, got %+v %+v", stats.Quota, stats.Usage)
+	}
+}
+
+func TestQuota_CountsTrashAndGatesRestoreAndWebDAV(t *testing.T) {
+	s := newSuite(t)
+	ctx := context.Background()
+	s.cfg.JWT.AdminUsers = []string{"admin91141"}
+	s.cfg.Trash.Retention = 24 * time.Hour
+
+	_, admin, _ := loginWithClient(t, s, 91141, "admin91141")
+	_, client, _ := loginWithClient(t, s, 91140, "user91140")
+	setQuota := func(maxBytes int64) {
+		t.Helper()
+		if err := admin.AdminSetUserQuota(ctx, &api.UserQuota{MaxBytes: api.NewOptInt64(maxBytes)}, api.AdminSetUserQuotaParams{ID: 91140}); err != nil {
+			t.Fatalf("AdminSetUserQuota failed: %v", err)
+		}
+	}
+	newFile := func(name string, size int64) *api.File {
+		return &api.File{
+			Name:      name,
+			Type:      api.FileTypeFile,
+			Path:      api.NewOptString("/"),
+			MimeType:  api.NewOptString("text/plain"),
+			ChannelId: api.NewOptInt64(911401),
+			Size:      api.NewOptInt64(size),
+			Parts:     []api.Part{{ID: 1}},
+		}
+	}
+	setQuota(100)
+
+	first, err := client.FilesCreate(ctx, newFile("first.txt", 60))
+	if err != nil {
+		t.Fatalf("FilesCreate within quota failed: %v", err)
+	}
+	if err := client.FilesDeleteById(ctx, api.FilesDeleteByIdParams{ID: first.ID.Value}); err != nil {
+		t.Fatalf("FilesDeleteById failed: %v", err)
+	}
+	stats, err := client.UsersStats(ctx)
+	if err != nil {
+		t.Fatalf("UsersStats failed: %v", err)
+	}
+	if stats.Usage.TotalFiles != 1 || stats.Usage.TotalSize != 60 {
+		t.Fatalf("expected the trashed file to count, got %+v", stats.Usage)
+	}
+	if _, err := client.FilesCreate(ctx, newFile("second.txt", 50)); statusCode(err) != http.StatusInsufficientStorage {
+		t.Fatalf("expected 507 with the trashed file counted, got %d err=%v", statusCode(err), err)
+	}
+
+	setQuota(50)
+	if err := client.FilesRestoreTrash(ctx, &api.TrashRestore{Ids: []api.UUID{first.ID.Value}}); statusCode(err) != http.StatusInsufficientStorage {
+		t.Fatalf("expected 507 restoring over a lowered limit, got %d err=%v", statusCode(err), err)
+	}
+	setQuota(100)
+	if err := client.FilesRestoreTrash(ctx, &api.TrashRestore{Ids: []api.UUID{first.ID.Value}}); err != nil {
+		t.Fatalf("FilesRestoreTrash within quota failed: %v", err)
+	}
+
+	uploads := 0
+	s.tgMock.uploadPartFn = func(_ context.Context, _ *tg.Client, _ int64, _ string, fileStream io.Reader, _ int64, _ int) (int, int64, error) {
+		uploads++
+		n, err := io.Copy(io.Discard, fileStream)
+		return uploads, n, err
+	}
+	created, err := client.UsersCreateApiKey(ctx, &api.UserApiKeyCreate{Name: "webdav"})
+	if err != nil {
+		t.Fatalf("UsersCreateApiKey failed: %v", err)
+	}
+	if res := webdavRequest(t, s, http.MethodPut, "/big.bin", created.Key, bytes.NewReader(bytes.Repeat([]byte("x"), 50)), nil); res.StatusCode != http.StatusInsufficientStorage {
+		t.Fatalf("expected 507 for a WebDAV PUT over quota, got %d", res.StatusCode)
+	}
+	if uploads != 0 {
+		t.Fatalf("expected no parts staged, got %d", uploads)
+	}
+}
@@ -0,0 +1,304 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gotd/td/tg"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/tgdrive/teldrive/internal/api"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/pkg/repositories"
+)
+
+func newS3Client(t *testing.T, s *suite, accessKeyID, secret string) *minio.Client {
+	t.Helper()
+	endpoint, err := url.Parse(s.s3.URL)
+	if err != nil {
+		t.Fatalf("parse s3 url: %v", err)
+	}
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(accessKeyID, secret, ""),
+		Region:       "us-east-1",
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		t.Fatalf("create s3 client: %v", err)
+	}
+	return client
+}
+
+func TestS3Gateway_BucketsAndObjects(t *testing.T) {
+	s := newSuite(t)
+	ctx := context.Background()
+	_, client, _ := loginWithClient(t, s, 7902, "user7902")
+
+	selected := true
+	if err := s.repos.Channels.Create(s.ctx, &jetmodel.Channels{
+		ChannelID:   7902001,
+		ChannelName: "s3-test",
+		UserID:      7902,
+		Selected:    &selected,
+		CreatedAt:   time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create selected channel: %v", err)
+	}
+	var messageID atomic.Int64
+	s.tgMock.uploadPartFn = func(_ context.Context, _ *tg.Client, _ int64, _ string, fileStream io.Reader, _ int64, _ int) (int, int64, error) {
+		n, err := io.Copy(io.Discard, fileStream)
+		if err != nil {
+			return 0, 0, err
+		}
+		return int(messageID.Add(1)), n, nil
+	}
+	s.cfg.S3.ChunkSize = 8
+
+	created, err := client.UsersCreateApiKey(ctx, &api.UserApiKeyCreate{Name: "s3"})
+	if err != nil {
+		t.Fatalf("UsersCreateApiKey failed: %v", err)
+	}
+	if !created.S3SecretKey.IsSet() {
+		t.Fatalf("expected s3 secret key in api key create response")
+	}
+	s3c := newS3Client(t, s, uuid.UUID(created.ID).String(), created.S3SecretKey.Value)
+
+	bad := newS3Client(t, s, uuid.UUID(created.ID).String(), "wrong-secret")
+	if _, err := bad.ListBuckets(ctx); minio.ToErrorResponse(err).Code != "SignatureDoesNotMatch" {
+		t.Fatalf("expected SignatureDoesNotMatch, got %v", err)
+	}
+	unknown := newS3Client(t, s, uuid.NewString(), created.S3SecretKey.Value)
+	if _, err := unknown.ListBuckets(ctx); minio.ToErrorResponse(err).Code != "InvalidAccessKeyId" {
+		t.Fatalf("expected InvalidAccessKeyId, got %v", err)
+	}
+
+	if err := s3c.MakeBucket(ctx, "backups", minio.MakeBucketOptions{}); err != nil {
+		t.Fatalf("MakeBucket failed: %v", err)
+	}
+	if err := s3c.MakeBucket(ctx, "backups", minio.MakeBucketOptions{}); minio.ToErrorResponse(err).Code != "BucketAlreadyOwnedByYou" {
+		t.Fatalf("expected BucketAlreadyOwnedByYou, got %v", err)
+	}
+	buckets, err := s3c.ListBuckets(ctx)
+	if err != nil {
+		t.Fatalf("ListBuckets failed: %v", err)
+	}
+	if len(buckets) != 1 || buckets[0].Name != "backups" {
+		t.Fatalf("expected bucket backups, got %+v", buckets)
+	}
+
+	payload := []byte("snapshot payload spanning parts")
+	info, err := s3c.PutObject(ctx, "backups", "data/ab/snapshot.bin", bytes.NewReader(payload), int64(len(payload)), minio.PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("PutObject failed: %v", err)
+	}
+	if info.ETag == "" {
+		t.Fatalf("expected ETag from PutObject")
+	}
+	if _, err := s3c.PutObject(ctx, "backups", "config", strings.NewReader("cfg"), 3, minio.PutObjectOptions{}); err != nil {
+		t.Fatalf("PutObject config failed: %v", err)
+	}
+
+	stat, err := s3c.StatObject(ctx, "backups", "data/ab/snapshot.bin", minio.StatObjectOptions{})
+	if err != nil {
+		t.Fatalf("StatObject failed: %v", err)
+	}
+	if stat.Size != int64(len(payload)) || stat.ETag != info.ETag {
+		t.Fatalf("unexpected stat: size=%d etag=%s want size=%d etag=%s", stat.Size, stat.ETag, len(payload), info.ETag)
+	}
+	if _, err := s3c.StatObject(ctx, "backups", "data/missing", minio.StatObjectOptions{}); minio.ToErrorResponse(err).StatusCode != 404 {
+		t.Fatalf("expected 404 for missing object, got %v", err)
+	}
+
+	listed, err := client.FilesList(ctx, api.FilesListParams{Path: api.NewOptString("/backups/data/ab"), Limit: api.NewOptInt(100)})
+	if err != nil {
+		t.Fatalf("FilesList failed: %v", err)
+	}
+	if len(listed.Items) != 1 || listed.Items[0].Name != "snapshot.bin" {
+		t.Fatalf("expected snapshot.bin in /backups/data/ab, got %+v", listed.Items)
+	}
+
+	var keys []string
+	for object := range s3c.ListObjects(ctx, "backups", minio.ListObjectsOptions{Recursive: true}) {
+		if object.Err != nil {
+			t.Fatalf("ListObjects recursive failed: %v", object.Err)
+		}
+		keys = append(keys, object.Key)
+	}
+	if strings.Join(keys, ",") != "config,data/ab/snapshot.bin" {
+		t.Fatalf("unexpected recursive listing: %v", keys)
+	}
+
+	keys = nil
+	for object := range s3c.ListObjects(ctx, "backups", minio.ListObjectsOptions{}) {
+		if object.Err != nil {
+			t.Fatalf("ListObjects failed: %v", object.Err)
+		}
+		keys = append(keys, object.Key)
+	}
+	if strings.Join(keys, ",") != "config,data/" {
+		t.Fatalf("unexpected delimited listing: %v", keys)
+	}
+
+	keys = nil
+	for object := range s3c.ListObjects(ctx, "backups", minio.ListObjectsOptions{Prefix: "data/a", Recursive: true, MaxKeys: 1}) {
+		if object.Err != nil {
+			t.Fatalf("ListObjects prefix failed: %v", object.Err)
+		}
+		keys = append(keys, object.Key)
+	}
+	if strings.Join(keys, ",") != "data/ab/snapshot.bin" {
+		t.Fatalf("unexpected prefix listing: %v", keys)
+	}
+
+	if err := s3c.RemoveBucket(ctx, "backups"); minio.ToErrorResponse(err).Code != "BucketNotEmpty" {
+		t.Fatalf("expected BucketNotEmpty, got %v", err)
+	}
+	if err := s3c.RemoveObject(ctx, "backups", "data/ab/snapshot.bin", minio.RemoveObjectOptions{}); err != nil {
+		t.Fatalf("RemoveObject failed: %v", err)
+	}
+	if err := s3c.RemoveObject(ctx, "backups", "data/ab/snapshot.bin", minio.RemoveObjectOptions{}); err != nil {
+		t.Fatalf("RemoveObject of missing key should succeed: %v", err)
+	}
+	if _, err := s3c.StatObject(ctx, "backups", "data/ab/snapshot.bin", minio.StatObjectOptions{}); minio.ToErrorResponse(err).StatusCode != 404 {
+		t.Fatalf("expected 404 after delete, got %v", err)
+	}
+}
+
+func TestS3Gateway_MultipartUpload(t *testing.T) {
+	s := newSuite(t)
+	ctx := context.Background()
+	_, client, _ := loginWithClient(t, s, 7903, "user7903")
+
+	selected := true
+	if err := s.repos.Channels.Create(s.ctx, &jetmodel.Channels{
+		ChannelID:   7903001,
+		ChannelName: "s3-multipart",
+		UserID:      7903,
+		Selected:    &selected,
+		CreatedAt:   time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create selected channel: %v", err)
+	}
+	var messageID atomic.Int64
+	s.tgMock.uploadPartFn = func(_ context.Context, _ *tg.Client, _ int64, _ string, fileStream io.Reader, _ int64, _ int) (int, int64, error) {
+		n, err := io.Copy(io.Discard, fileStream)
+		if err != nil {
+			return 0, 0, err
+		}
+		return int(messageID.Add(1)), n, nil
+	}
+
+	created, err := client.UsersCreateApiKey(ctx, &api.UserApiKeyCreate{Name: "s3"})
+	if err != nil {
+		t.Fatalf("UsersCreateApiKey failed: %v", err)
+	}
+	core := &minio.Core{Client: newS3Client(t, s, uuid.UUID(created.ID).String(), created.S3SecretKey.Value)}
+	if err := core.MakeBucket(ctx, "media", minio.MakeBucketOptions{}); err != nil {
+		t.Fatalf("MakeBucket failed: %v", err)
+	}
+
+	uploadID, err := core.NewMultipartUpload(ctx, "media", "videos/clip.mp4", minio.PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("NewMultipartUpload failed: %v", err)
+	}
+	first := []byte("first part")
+	second := []byte("second part")
+	if _, err := core.PutObjectPart(ctx, "media", "videos/clip.mp4", uuid.NewString()+".7903001", 1, bytes.NewReader(first), int64(len(first)), minio.PutObjectPartOptions{}); minio.ToErrorResponse(err).Code != "NoSuchUpload" {
+		t.Fatalf("expected NoSuchUpload for an unknown upload, got %v", err)
+	}
+
+	// A channel switch after the upload started must not split its parts
+	unselected := false
+	if err := s.repos.Channels.Update(s.ctx, 7903001, repositories.ChannelUpdate{Selected: &unselected}); err != nil {
+		t.Fatalf("unselect channel: %v", err)
+	}
+	if err := s.repos.Channels.Create(s.ctx, &jetmodel.Channels{
+		ChannelID:   7903002,
+		ChannelName: "s3-multipart-next",
+		UserID:      7903,
+		Selected:    &selected,
+		CreatedAt:   time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create next channel: %v", err)
+	}
+	if _, err := core.PutObjectPart(ctx, "media", "videos/clip.mp4", uploadID, 1, bytes.NewReader([]byte("stale")), 5, minio.PutObjectPartOptions{}); err != nil {
+		t.Fatalf("PutObjectPart stale failed: %v", err)
+	}
+	part1, err := core.PutObjectPart(ctx, "media", "videos/clip.mp4", uploadID, 1, bytes.NewReader(first), int64(len(first)), minio.PutObjectPartOptions{})
+	if err != nil {
+		t.Fatalf("PutObjectPart 1 failed: %v", err)
+	}
+	part2, err := core.PutObjectPart(ctx, "media", "videos/clip.mp4", uploadID, 2, bytes.NewReader(second), int64(len(second)), minio.PutObjectPartOptions{})
+	if err != nil {
+		t.Fatalf("PutObjectPart 2 failed: %v", err)
+	}
+
+	_, err = core.CompleteMultipartUpload(ctx, "media", "videos/clip.mp4", uploadID, []minio.CompletePart{
+		{PartNumber: 2, ETag: part2.ETag},
+		{PartNumber: 1, ETag: part1.ETag},
+	}, minio.PutObjectOptions{})
+	if minio.ToErrorResponse(err).Code != "InvalidPartOrder" {
+		t.Fatalf("expected InvalidPartOrder, got %v", err)
+	}
+
+	if _, err := core.CompleteMultipartUpload(ctx, "media", "videos/clip.mp4", uploadID, []minio.CompletePart{
+		{PartNumber: 1, ETag: part1.ETag},
+		{PartNumber: 2, ETag: part2.ETag},
+	}, minio.PutObjectOptions{}); err != nil {
+		t.Fatalf("CompleteMultipartUpload failed: %v", err)
+	}
+
+	stat, err := core.StatObject(ctx, "media", "videos/clip.mp4", minio.StatObjectOptions{})
+	if err != nil {
+		t.Fatalf("StatObject failed: %v", err)
+	}
+	if stat.Size != int64(len(first)+len(second)) {
+		t.Fatalf("expected size=%d, got %d", len(first)+len(second), stat.Size)
+	}
+	if stat.ContentType != "video/mp4" {
+		t.Fatalf("expected content type video/mp4, got %s", stat.ContentType)
+	}
+
+	file, err := client.FilesList(ctx, api.FilesListParams{Path: api.NewOptString("/media/videos"), Limit: api.NewOptInt(100)})
+	if err != nil {
+		t.Fatalf("FilesList failed: %v", err)
+	}
+	if len(file.Items) != 1 {
+		t.Fatalf("expected one file in /media/videos, got %d", len(file.Items))
+	}
+	fileDB, err := s.repos.Files.GetByID(ctx, uuid.UUID(file.Items[0].ID.Value))
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if fileDB.Parts == nil || len(fileDB.Parts.Data) != 2 {
+		t.Fatalf("expected 2 parts, got %+v", fileDB.Parts)
+	}
+	if fileDB.ChannelID == nil || *fileDB.ChannelID != 7903001 {
+		t.Fatalf("expected parts in the channel pinned at upload start, got %v", fileDB.ChannelID)
+	}
+
+	aborted, err := core.NewMultipartUpload(ctx, "media", "videos/aborted.mp4", minio.PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("NewMultipartUpload failed: %v", err)
+	}
+	if _, err := core.PutObjectPart(ctx, "media", "videos/aborted.mp4", aborted, 1, bytes.NewReader(first), int64(len(first)), minio.PutObjectPartOptions{}); err != nil {
+		t.Fatalf("PutObjectPart failed: %v", err)
+	}
+	if err := core.AbortMultipartUpload(ctx, "media", "videos/aborted.mp4", aborted); err != nil {
+		t.Fatalf("AbortMultipartUpload failed: %v", err)
+	}
+	parts, err := s.repos.Uploads.GetByUploadID(ctx, aborted)
+	if err != nil {
+		t.Fatalf("GetByUploadID failed: %v", err)
+	}
+	if len(parts) != 0 {
+		t.Fatalf("expected aborted upload parts to be removed, got %d", len(parts))
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/tgdrive/teldrive/internal/logging"
 	"github.com/tgdrive/teldrive/internal/requestmeta"
 	"github.com/tgdrive/teldrive/internal/tgc"
+	"github.com/tgdrive/teldrive/pkg/queue"
 	"github.com/tgdrive/teldrive/pkg/repositories"
 	"github.com/tgdrive/teldrive/pkg/services"
 	"github.com/tgdrive/teldrive/pkg/types"
@@ -41,9 +42,12 @@ type suite struct {
 	cfg     *config.ServerCmdConfig
 	repos   *repositories.Repositories
 	server  *httptest.Server
+	webdav  *httptest.Server
+	s3      *httptest.Server
 	pool    *pgxpool.Pool
 	cache   cache.Cacher
 	tgMock  *mockTelegramService
+	exec    queue.Executor
 	events  *noopEventBroadcaster
 	httpCli *http.Client
 }
@@ -104,6 +108,8 @@ func newSuite(t *testing.T) *suite {
 	cfg.TG.Uploads.MaxRetries = 1
 	cfg.TG.Uploads.Threads = 1
 	cfg.TG.Uploads.Retention = time.Hour
+	cfg.TG.Stream.Buffers = 2
+	cfg.TG.Stream.ChunkTimeout = 10 * time.Second
 
 	repos := repositories.NewRepositories(pool)
 	tgMock := newMockTelegramService()
@@ -119,12 +125,16 @@ func newSuite(t *testing.T) *suite {
 		t.Fatalf("create API server: %v", err)
 	}
 	httpSrv := httptest.NewServer(requestmeta.Middleware(srv))
+	davSrv := httptest.NewServer(services.NewWebDAVHandler(h, "/webdav"))
+	s3Srv := httptest.NewServer(services.NewS3Handler(h))
 	jar, err := cookiejar.New(nil)
 	if err != nil {
 		t.Fatalf("create cookie jar: %v", err)
 	}
 
 	t.Cleanup(httpSrv.Close)
+	t.Cleanup(davSrv.Close)
+	t.Cleanup(s3Srv.Close)
 
 	s := &suite{
 		t:       t,
@@ -132,6 +142,9 @@ func newSuite(t *testing.T) *suite {
 		cfg:     cfg,
 		repos:   repos,
 		server:  httpSrv,
+		exec:    services.NewJobExecutor(h),
+		webdav:  davSrv,
+		s3:      s3Srv,
 		pool:    pool,
 		cache:   c,
 		tgMock:  tgMock,
@@ -147,7 +160,7 @@ func newSuite(t *testing.T) *suite {
 func (s *suite) resetDB() {
 	s.t.Helper()
 
-	_, err := s.pool.Exec(s.ctx, "TRUNCATE TABLE teldrive.events, teldrive.file_shares, teldrive.uploads, teldrive.files, teldrive.sessions, teldrive.bots, teldrive.channels, teldrive.users, teldrive.kv, teldrive.periodic_jobs RESTART IDENTITY CASCADE")
+	_, err := s.pool.Exec(s.ctx, "TRUNCATE TABLE teldrive.events, teldrive.file_shares, teldrive.uploads, teldrive.files, teldrive.sessions, teldrive.bots, teldrive.channels, teldrive.users, teldrive.kv, teldrive.periodic_jobs, teldrive.allowed_users RESTART IDENTITY CASCADE")
 	if err != nil {
 		s.t.Fatalf("truncate test tables: %v", err)
 	}
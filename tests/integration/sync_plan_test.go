@@ -0,0 +1,138 @@
+package integration_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/tgdrive/teldrive/internal/api"
+)
+
+func TestPeriodicJobsPlan_PreviewsWithoutChanges(t *testing.T) {
+	s := newSuite(t)
+	const userID int64 = 91020
+	client := s.newClientWithToken(s.authTokenForUser(userID, "sync-plan-session"))
+
+	srcDir := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "alpha", "b.txt": "bravo-changed"} {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write source file: %v", err)
+		}
+	}
+
+	if _, err := s.repos.Files.CreateDirectories(s.ctx, userID, "/plan-dst/old"); err != nil {
+		t.Fatalf("CreateDirectories failed: %v", err)
+	}
+	for _, name := range []string{"b.txt", "extra.txt"} {
+		if _, err := client.FilesCreate(s.ctx, &api.File{Name: name, Type: api.FileTypeFile, Path: api.NewOptString("/plan-dst"), MimeType: api.NewOptString("text/plain"), ChannelId: api.NewOptInt64(9102001), Size: api.NewOptInt64(0)}); err != nil {
+			t.Fatalf("FilesCreate failed: %v", err)
+		}
+	}
+
+	args := &api.SyncArgs{Source: "local://" + srcDir, DestinationDir: "/plan-dst", Options: api.NewOptSyncOptions(api.SyncOptions{Sync: api.NewOptBool(true)})}
+	plan, err := client.PeriodicJobsPlan(s.ctx, args)
+	if err != nil {
+		t.Fatalf("PeriodicJobsPlan failed: %v", err)
+	}
+	reasons := func(items []api.SyncPlanItem) []string {
+		out := make([]string, 0, len(items))
+		for _, item := range items {
+			out = append(out, item.Path+":"+string(item.Reason))
+		}
+		slices.Sort(out)
+		return out
+	}
+	if plan.Planned != 2 || plan.BytesPlanned != int64(len("alpha")+len("bravo-changed")) {
+		t.Fatalf("unexpected plan totals: %+v", plan)
+	}
+	if got := reasons(plan.Upload); !slices.Equal(got, []string{"a.txt:new", "b.txt:changed"}) {
+		t.Fatalf("unexpected uploads: %v", got)
+	}
+	if got := reasons(plan.Delete); !slices.Equal(got, []string{"extra.txt:extra", "old:extra"}) {
+		t.Fatalf("unexpected deletes: %v", got)
+	}
+	if _, err := s.repos.Files.ResolvePathID(s.ctx, "/plan-dst/extra.txt", userID); err != nil {
+		t.Fatalf("plan must not delete anything: %v", err)
+	}
+
+	args.Options = api.NewOptSyncOptions(api.SyncOptions{Sync: api.NewOptBool(false)})
+	plan, err = client.PeriodicJobsPlan(s.ctx, args)
+	if err != nil {
+		t.Fatalf("PeriodicJobsPlan failed: %v", err)
+	}
+	if got := reasons(plan.Skip); !slices.Equal(got, []string{"b.txt:exists"}) || len(plan.Delete) != 0 {
+		t.Fatalf("unexpected plan without sync: skip=%v delete=%d", got, len(plan.Delete))
+	}
+
+	if _, err := client.PeriodicJobsPlan(s.ctx, &api.SyncArgs{Source: "local://" + srcDir, DestinationDir: "relative"}); err == nil {
+		t.Fatal("expected error for a relative destination")
+	}
+}
+
+func TestPeriodicJobsPlan_AppliesConflictAndDeletionPolicies(t *testing.T) {
+	s := newSuite(t)
+	const userID int64 = 91030
+	client := s.newClientWithToken(s.authTokenForUser(userID, "sync-policy-plan-session"))
+
+	srcDir := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "alpha-changed", "b.txt": "bravo-changed"} {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write source file: %v", err)
+		}
+	}
+	for _, name := range []string{"a.txt", "a (1).txt", "b.txt", "extra.txt"} {
+		if _, err := client.FilesCreate(s.ctx, &api.File{Name: name, Type: api.FileTypeFile, Path: api.NewOptString("/policy-dst"), MimeType: api.NewOptString("text/plain"), ChannelId: api.NewOptInt64(9103001), Size: api.NewOptInt64(0)}); err != nil {
+			t.Fatalf("FilesCreate failed: %v", err)
+		}
+	}
+
+	args := &api.SyncArgs{Source: "local://" + srcDir, DestinationDir: "/policy-dst", Options: api.NewOptSyncOptions(api.SyncOptions{
+		Conflict:  api.NewOptSyncConflictPolicy(api.SyncConflictPolicyKeepBoth),
+		Deletion:  api.NewOptSyncDeletionPolicy(api.SyncDeletionPolicyBackup),
+		BackupDir: api.NewOptString("/policy-backup"),
+	})}
+	plan, err := client.PeriodicJobsPlan(s.ctx, args)
+	if err != nil {
+		t.Fatalf("PeriodicJobsPlan failed: %v", err)
+	}
+	actions := func(items []api.SyncPlanItem) []string {
+		out := make([]string, 0, len(items))
+		for _, item := range items {
+			out = append(out, item.Path+":"+string(item.Action)+":"+item.Target.Or(""))
+		}
+		slices.Sort(out)
+		return out
+	}
+	if got := actions(plan.Upload); !slices.Equal(got, []string{"a.txt:keep-both:a (2).txt", "b.txt:keep-both:b (1).txt"}) {
+		t.Fatalf("unexpected uploads: %v", got)
+	}
+	if got := actions(plan.Delete); !slices.Equal(got, []string{"extra.txt:backup:/policy-backup/extra.txt"}) {
+		t.Fatalf("unexpected deletes: %v", got)
+	}
+
+	args.Options = api.NewOptSyncOptions(api.SyncOptions{
+		Conflict: api.NewOptSyncConflictPolicy(api.SyncConflictPolicyNewer),
+		Deletion: api.NewOptSyncDeletionPolicy(api.SyncDeletionPolicyIgnore),
+	})
+	plan, err = client.PeriodicJobsPlan(s.ctx, args)
+	if err != nil {
+		t.Fatalf("PeriodicJobsPlan failed: %v", err)
+	}
+	if len(plan.Upload) != 0 || len(plan.Skip) != 2 || len(plan.Delete) != 0 {
+		t.Fatalf("expected newer destination copies to be kept: %+v", plan)
+	}
+	for _, item := range plan.Skip {
+		if item.Reason != api.SyncPlanReasonOlder || item.Action != api.SyncActionSkip {
+			t.Fatalf("unexpected skip item: %+v", item)
+		}
+	}
+
+	args.Options = api.NewOptSyncOptions(api.SyncOptions{
+		Deletion:  api.NewOptSyncDeletionPolicy(api.SyncDeletionPolicyBackup),
+		BackupDir: api.NewOptString("/policy-dst/.backup"),
+	})
+	if _, err := client.PeriodicJobsPlan(s.ctx, args); err == nil {
+		t.Fatal("expected error for a backup folder inside the destination")
+	}
+}
@@ -0,0 +1,195 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/internal/config"
+	"github.com/tgdrive/teldrive/internal/tgc"
+	"github.com/tgdrive/teldrive/pkg/queue"
+	"github.com/tgdrive/teldrive/pkg/services"
+)
+
+func TestSyncPush_LocalDestinationMirrorsFolder(t *testing.T) {
+	s := newSuite(t)
+	resetRiverTables(t, s)
+
+	const userID int64 = 91010
+	client := s.newClientWithToken(s.authTokenForUser(userID, "sync-push-session"))
+
+	for _, f := range []struct{ path, name string }{{"/push-src/docs", "empty.txt"}, {"/push-src", "root.txt"}} {
+		if _, err := s.repos.Files.CreateDirectories(s.ctx, userID, f.path); err != nil {
+			t.Fatalf("CreateDirectories failed: %v", err)
+		}
+		if _, err := client.FilesCreate(s.ctx, &api.File{Name: f.name, Type: api.FileTypeFile, Path: api.NewOptString(f.path), MimeType: api.NewOptString("text/plain"), ChannelId: api.NewOptInt64(9101001), Size: api.NewOptInt64(0)}); err != nil {
+			t.Fatalf("FilesCreate failed: %v", err)
+		}
+	}
+
+	dst := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dst, "old", "nested"), 0o755); err != nil {
+		t.Fatalf("create stale dir: %v", err)
+	}
+	for _, stale := range []string{"stale.txt", "old/nested/x.txt", "docs/extra.txt"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dst, stale)), 0o755); err != nil {
+			t.Fatalf("create stale parent: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dst, stale), []byte("stale"), 0o644); err != nil {
+			t.Fatalf("write stale file: %v", err)
+		}
+	}
+
+	runSyncPush(t, s, queue.SyncPushJobArgs{
+		UserID:      userID,
+		SourceDir:   "/push-src",
+		Destination: "local://" + dst,
+		Options:     queue.SyncOptions{Sync: true},
+	})
+
+	for _, want := range []string{"root.txt", "docs/empty.txt"} {
+		if _, err := os.Stat(filepath.Join(dst, want)); err != nil {
+			t.Fatalf("expected %s on the destination: %v", want, err)
+		}
+	}
+	for _, gone := range []string{"stale.txt", "old", "docs/extra.txt"} {
+		if _, err := os.Stat(filepath.Join(dst, gone)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be pruned, stat err = %v", gone, err)
+		}
+	}
+}
+
+func TestSyncPush_StreamsFileContent(t *testing.T) {
+	s := newSuite(t)
+	resetRiverTables(t, s)
+
+	const userID int64 = 91100
+	client := s.newClientWithToken(s.authTokenForUser(userID, "sync-push-content-session"))
+
+	files := newTelegramFiles()
+	s.tgMock.authClientFn = func(context.Context, string, int) (services.TelegramClient, error) { return files.client(), nil }
+	s.tgMock.getPartsFn = files.getParts
+
+	content := []byte("pushed file content")
+	files.add(911001, content)
+	if _, err := s.repos.Files.CreateDirectories(s.ctx, userID, "/push-content/nested"); err != nil {
+		t.Fatalf("CreateDirectories failed: %v", err)
+	}
+	if _, err := client.FilesCreate(s.ctx, &api.File{
+		Name:      "data.txt",
+		Type:      api.FileTypeFile,
+		Path:      api.NewOptString("/push-content/nested"),
+		MimeType:  api.NewOptString("text/plain"),
+		ChannelId: api.NewOptInt64(9110001),
+		Size:      api.NewOptInt64(int64(len(content))),
+		Parts:     []api.Part{{ID: 911001}},
+	}); err != nil {
+		t.Fatalf("FilesCreate failed: %v", err)
+	}
+
+	dst := t.TempDir()
+	runSyncPush(t, s, queue.SyncPushJobArgs{
+		UserID:      userID,
+		SourceDir:   "/push-content",
+		Destination: "local://" + dst,
+	})
+
+	got, err := os.ReadFile(filepath.Join(dst, "nested", "data.txt"))
+	if err != nil {
+		t.Fatalf("read pushed file: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("unexpected pushed content: %q", got)
+	}
+}
+
+func TestSyncPush_AppliesConflictAndDeletionPolicies(t *testing.T) {
+	s := newSuite(t)
+	resetRiverTables(t, s)
+
+	const userID int64 = 91171
+	client := s.newClientWithToken(s.authTokenForUser(userID, "sync-push-policy-session"))
+
+	if _, err := s.repos.Files.CreateDirectories(s.ctx, userID, "/push-policy"); err != nil {
+		t.Fatalf("CreateDirectories failed: %v", err)
+	}
+	for _, name := range []string{"kept.txt", "new.txt"} {
+		if _, err := client.FilesCreate(s.ctx, &api.File{Name: name, Type: api.FileTypeFile, Path: api.NewOptString("/push-policy"), MimeType: api.NewOptString("text/plain"), ChannelId: api.NewOptInt64(9117101), Size: api.NewOptInt64(0)}); err != nil {
+			t.Fatalf("FilesCreate failed: %v", err)
+		}
+	}
+
+	dst := t.TempDir()
+	for _, name := range []string{"kept.txt", "stale.txt"} {
+		if err := os.WriteFile(filepath.Join(dst, name), []byte("remote"), 0o644); err != nil {
+			t.Fatalf("write remote file: %v", err)
+		}
+	}
+
+	runSyncPush(t, s, queue.SyncPushJobArgs{
+		UserID:      userID,
+		SourceDir:   "/push-policy",
+		Destination: "local://" + dst,
+		Options:     queue.SyncOptions{Sync: true, Conflict: queue.SyncConflictSkip, Deletion: queue.SyncDeletionIgnore},
+	})
+
+	for name, want := range map[string]string{"kept.txt": "remote", "stale.txt": "remote", "new.txt": ""} {
+		got, err := os.ReadFile(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatalf("expected %s on the destination: %v", name, err)
+		}
+		if string(got) != want {
+			t.Fatalf("unexpected content of %s: %q", name, got)
+		}
+	}
+}
+
+// runSyncPush runs one sync.push job to completion on a fresh River client
+func runSyncPush(t *testing.T, s *suite, args queue.SyncPushJobArgs) {
+	t.Helper()
+
+	channelManager := tgc.NewChannelManager(s.repos, s.cache, &s.cfg.TG)
+	jobClientRef := services.NewJobClientRef()
+	periodicRegistryRef := services.NewPeriodicJobRegistryRef()
+	apiSvc := services.NewApiService(s.repos, channelManager, s.cfg, s.cache, s.tgMock, s.events, jobClientRef, periodicRegistryRef)
+	riverClient, err := queue.NewClient(s.pool, services.NewJobExecutor(apiSvc), config.QueueConfig{}, config.JobsConfig{})
+	if err != nil {
+		t.Fatalf("create river client: %v", err)
+	}
+	jobClientRef.Set(riverClient)
+	if err := riverClient.Start(s.ctx); err != nil {
+		t.Fatalf("start river client: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = riverClient.Stop(s.ctx)
+	})
+
+	inserted, err := riverClient.Insert(s.ctx, args, &river.InsertOpts{MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("insert sync.push: %v", err)
+	}
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for sync.push completion")
+		}
+		job, err := riverClient.JobGet(s.ctx, inserted.Job.ID)
+		if err != nil {
+			t.Fatalf("job get: %v", err)
+		}
+		if job.State == rivertype.JobStateCompleted {
+			return
+		}
+		if job.State == rivertype.JobStateDiscarded || job.State == rivertype.JobStateCancelled {
+			t.Fatalf("sync.push finished in bad state %s: %+v", job.State, job.Errors)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
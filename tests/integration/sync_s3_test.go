@@ -0,0 +1,158 @@
+package integration_test
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/tgdrive/teldrive/internal/config"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+	"github.com/tgdrive/teldrive/internal/tgc"
+	"github.com/tgdrive/teldrive/pkg/queue"
+	"github.com/tgdrive/teldrive/pkg/services"
+)
+
+// s3Objects serves a fixed set of objects from one bucket: ListObjectsV2 and
+// GET or HEAD of single objects.
+type s3Objects map[string]string
+
+var s3ObjectsModTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func (o s3Objects) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if key == "" {
+		type content struct {
+			Key          string `xml:"Key"`
+			LastModified string `xml:"LastModified"`
+			ETag         string `xml:"ETag"`
+			Size         int64  `xml:"Size"`
+		}
+		res := struct {
+			XMLName  xml.Name  `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+			KeyCount int       `xml:"KeyCount"`
+			Contents []content `xml:"Contents"`
+		}{}
+		for k, v := range o {
+			sum := md5.Sum([]byte(v))
+			res.Contents = append(res.Contents, content{
+				Key:          k,
+				LastModified: s3ObjectsModTime.Format("2006-01-02T15:04:05.000Z"),
+				ETag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+				Size:         int64(len(v)),
+			})
+		}
+		res.KeyCount = len(res.Contents)
+		w.Header().Set("Content-Type", "application/xml")
+		_ = xml.NewEncoder(w).Encode(res)
+		return
+	}
+	content, ok := o[key]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	http.ServeContent(w, r, key, s3ObjectsModTime, strings.NewReader(content))
+}
+
+func TestSyncRunWorkflow_S3Source_SkipsUnchangedObjects(t *testing.T) {
+	s := newSuite(t)
+	resetRiverTables(t, s)
+
+	const userID int64 = 91120
+	_ = s.authTokenForUser(userID, "sync-s3-session")
+
+	selected := true
+	if err := s.repos.Channels.Create(s.ctx, &jetmodel.Channels{
+		ChannelID:   9112001,
+		ChannelName: "sync-s3",
+		UserID:      userID,
+		Selected:    &selected,
+		CreatedAt:   time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create selected channel: %v", err)
+	}
+
+	var uploads atomic.Int32
+	s.tgMock.uploadPartFn = func(_ context.Context, _ *tg.Client, _ int64, _ string, fileStream io.Reader, _ int64, _ int) (int, int64, error) {
+		uploads.Add(1)
+		n, err := io.Copy(io.Discard, fileStream)
+		if err != nil {
+			return 0, 0, err
+		}
+		return int(uploads.Load()), n, nil
+	}
+
+	srv := httptest.NewServer(s3Objects{"a.txt": "alpha", "docs/b.txt": "bravo"})
+	t.Cleanup(srv.Close)
+	source := "s3://minio:secret@" + strings.TrimPrefix(srv.URL, "http://") + "/bucket?scheme=http"
+
+	channelManager := tgc.NewChannelManager(s.repos, s.cache, &s.cfg.TG)
+	jobClientRef := services.NewJobClientRef()
+	periodicRegistryRef := services.NewPeriodicJobRegistryRef()
+	apiSvc := services.NewApiService(s.repos, channelManager, s.cfg, s.cache, s.tgMock, s.events, jobClientRef, periodicRegistryRef)
+	riverClient, err := queue.NewClient(s.pool, services.NewJobExecutor(apiSvc), config.QueueConfig{}, config.JobsConfig{})
+	if err != nil {
+		t.Fatalf("create river client: %v", err)
+	}
+	jobClientRef.Set(riverClient)
+	periodicRegistryRef.Set(riverClient.PeriodicJobs())
+	if err := riverClient.Start(s.ctx); err != nil {
+		t.Fatalf("start river client: %v", err)
+	}
+	t.Cleanup(func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = riverClient.Stop(stopCtx)
+	})
+
+	run := func() {
+		t.Helper()
+		inserted, err := riverClient.Insert(s.ctx, queue.SyncRunJobArgs{
+			UserID:         userID,
+			Source:         source,
+			DestinationDir: "/from-s3",
+			Options:        queue.SyncOptions{Sync: true},
+			PollInterval:   1,
+		}, &river.InsertOpts{MaxAttempts: 1})
+		if err != nil {
+			t.Fatalf("insert sync.run: %v", err)
+		}
+		deadline := time.Now().Add(45 * time.Second)
+		for {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for sync.run completion")
+			}
+			job, err := riverClient.JobGet(s.ctx, inserted.Job.ID)
+			if err != nil {
+				t.Fatalf("job get: %v", err)
+			}
+			switch job.State {
+			case rivertype.JobStateCompleted:
+				return
+			case rivertype.JobStateDiscarded, rivertype.JobStateCancelled:
+				t.Fatalf("sync.run finished in bad state %s: %+v", job.State, job.Errors)
+			}
+			time.Sleep(300 * time.Millisecond)
+		}
+	}
+
+	run()
+	if got := uploads.Load(); got != 2 {
+		t.Fatalf("expected both objects uploaded on the first run, got %d uploads", got)
+	}
+	run()
+	if got := uploads.Load(); got != 2 {
+		t.Fatalf("expected unchanged objects to be skipped on the second run, got %d uploads", got)
+	}
+}
@@ -2,10 +2,12 @@ package integration_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,6 +18,7 @@ import (
 	"github.com/gotd/td/tg"
 	"github.com/riverqueue/river"
 	"github.com/riverqueue/river/rivertype"
+	"github.com/tgdrive/teldrive/internal/api"
 	"github.com/tgdrive/teldrive/internal/config"
 	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
 	"github.com/tgdrive/teldrive/internal/tgc"
@@ -422,6 +425,135 @@ VerifyResume:
 	}
 }
 
+func TestSyncRunWorkflow_LocalSource_ResumesOutOfOrderParallelParts(t *testing.T) {
+	s := newSuite(t)
+	resetRiverTables(t, s)
+
+	const userID int64 = 91032
+	_ = s.authTokenForUser(userID, "sync-workflow-parallel-session")
+	s.cfg.TG.Uploads.ChunkNaming = "deterministic"
+	s.cfg.Jobs.SyncTransfer.ParallelParts = 3
+
+	selected := true
+	if err := s.repos.Channels.Create(s.ctx, &jetmodel.Channels{
+		ChannelID:   9103201,
+		ChannelName: "sync-test-parallel",
+		UserID:      userID,
+		Selected:    &selected,
+		CreatedAt:   time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create selected channel: %v", err)
+	}
+
+	// The first part fails once while the later ones succeed, so the retry
+	// has to resume around a gap at the start of the file.
+	var (
+		mu           sync.Mutex
+		attempts     = map[string]int{}
+		inFlight     int
+		maxInFlight  int
+		totalUploads atomic.Int32
+	)
+	s.tgMock.uploadPartFn = func(_ context.Context, _ *tg.Client, _ int64, partName string, fileStream io.Reader, fileSize int64, _ int) (int, int64, error) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		n := totalUploads.Add(1)
+		if _, err := io.Copy(io.Discard, fileStream); err != nil {
+			return 0, 0, err
+		}
+		time.Sleep(100 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[partName]++
+		if partName == "payload.bin" && attempts[partName] == 1 {
+			return 0, 0, errors.New("forced first part failure")
+		}
+		return int(n), fileSize, nil
+	}
+
+	channelManager := tgc.NewChannelManager(s.repos, s.cache, &s.cfg.TG)
+	jobClientRef := services.NewJobClientRef()
+	periodicRegistryRef := services.NewPeriodicJobRegistryRef()
+	apiSvc := services.NewApiService(s.repos, channelManager, s.cfg, s.cache, s.tgMock, s.events, jobClientRef, periodicRegistryRef)
+	riverClient, err := queue.NewClient(s.pool, services.NewJobExecutor(apiSvc), config.QueueConfig{}, config.JobsConfig{})
+	if err != nil {
+		t.Fatalf("create river client: %v", err)
+	}
+	jobClientRef.Set(riverClient)
+	if err := riverClient.Start(s.ctx); err != nil {
+		t.Fatalf("start river client: %v", err)
+	}
+	t.Cleanup(func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = riverClient.Stop(stopCtx)
+	})
+
+	srcDir := t.TempDir()
+	buf := make([]byte, 140*1024*1024)
+	for i := range buf {
+		buf[i] = byte(i % 251)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "payload.bin"), buf, 0o644); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+
+	inserted, err := riverClient.Insert(s.ctx, queue.SyncRunJobArgs{
+		UserID:         userID,
+		Source:         "local://" + srcDir,
+		DestinationDir: "/sync-int-parallel",
+		Options:        queue.SyncOptions{Sync: true, PartSize: 64 * 1024 * 1024},
+		PollInterval:   1,
+	}, &river.InsertOpts{MaxAttempts: 2})
+	if err != nil {
+		t.Fatalf("insert sync.run: %v", err)
+	}
+
+	deadline := time.Now().Add(90 * time.Second)
+	for {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for parallel sync.run completion")
+		}
+		job, err := riverClient.JobGet(s.ctx, inserted.Job.ID)
+		if err != nil {
+			t.Fatalf("job get: %v", err)
+		}
+		if job.State == rivertype.JobStateCompleted {
+			break
+		}
+		if job.State == rivertype.JobStateDiscarded || job.State == rivertype.JobStateCancelled {
+			t.Fatalf("sync.run finished in bad state: %s", job.State)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	// Three parts plus the retried first one.
+	if got := totalUploads.Load(); got != 4 {
+		t.Fatalf("expected 4 telegram uploads, got %d", got)
+	}
+	if maxInFlight < 2 {
+		t.Fatalf("expected parts to upload concurrently, max in flight = %d", maxInFlight)
+	}
+	parentID, err := s.repos.Files.ResolvePathID(s.ctx, "/sync-int-parallel", userID)
+	if err != nil {
+		t.Fatalf("resolve destination: %v", err)
+	}
+	file, err := s.repos.Files.GetActiveByNameAndParent(s.ctx, userID, "payload.bin", parentID)
+	if err != nil {
+		t.Fatalf("expected synced file: %v", err)
+	}
+	if file.Parts == nil || len(file.Parts.Data) != 3 {
+		t.Fatalf("expected 3 parts on the synced file, got %+v", file.Parts)
+	}
+}
+
 func TestSyncTransfer_LiveProgressVisibleOnRunningJob(t *testing.T) {
 	s := newSuite(t)
 	resetRiverTables(t, s)
@@ -683,6 +815,199 @@ func TestSyncRunWorkflow_CancelRunningSyncTransfer(t *testing.T) {
 	}
 }
 
+func TestSyncRunWorkflow_BackupDeletionRecordsActions(t *testing.T) {
+	s := newSuite(t)
+	resetRiverTables(t, s)
+
+	const userID int64 = 91031
+	client := s.newClientWithToken(s.authTokenForUser(userID, "sync-workflow-backup-session"))
+
+	for _, name := range []string{"keep.txt", "old.txt"} {
+		if _, err := client.FilesCreate(s.ctx, &api.File{Name: name, Type: api.FileTypeFile, Path: api.NewOptString("/sync-backup"), MimeType: api.NewOptString("text/plain"), ChannelId: api.NewOptInt64(9103101), Size: api.NewOptInt64(0)}); err != nil {
+			t.Fatalf("FilesCreate failed: %v", err)
+		}
+	}
+
+	channelManager := tgc.NewChannelManager(s.repos, s.cache, &s.cfg.TG)
+	jobClientRef := services.NewJobClientRef()
+	periodicRegistryRef := services.NewPeriodicJobRegistryRef()
+	apiSvc := services.NewApiService(s.repos, channelManager, s.cfg, s.cache, s.tgMock, s.events, jobClientRef, periodicRegistryRef)
+	riverClient, err := queue.NewClient(s.pool, services.NewJobExecutor(apiSvc), config.QueueConfig{}, config.JobsConfig{})
+	if err != nil {
+		t.Fatalf("create river client: %v", err)
+	}
+	jobClientRef.Set(riverClient)
+	if err := riverClient.Start(s.ctx); err != nil {
+		t.Fatalf("start river client: %v", err)
+	}
+	t.Cleanup(func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = riverClient.Stop(stopCtx)
+	})
+
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "keep.txt"), []byte("changed"), 0o644); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+
+	inserted, err := riverClient.Insert(s.ctx, queue.SyncRunJobArgs{
+		UserID:         userID,
+		Source:         "local://" + srcDir,
+		DestinationDir: "/sync-backup",
+		Options:        queue.SyncOptions{Conflict: queue.SyncConflictSkip, Deletion: queue.SyncDeletionBackup, BackupDir: "/sync-backup-old"},
+		PollInterval:   1,
+	}, &river.InsertOpts{MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("insert sync.run: %v", err)
+	}
+
+	var job *rivertype.JobRow
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for sync.run completion")
+		}
+		job, err = riverClient.JobGet(s.ctx, inserted.Job.ID)
+		if err != nil {
+			t.Fatalf("job get: %v", err)
+		}
+		if job.State == rivertype.JobStateCompleted {
+			break
+		}
+		if job.State == rivertype.JobStateDiscarded || job.State == rivertype.JobStateCancelled {
+			t.Fatalf("sync.run finished in bad state %s: %+v", job.State, job.Errors)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	if _, err := s.repos.Files.ResolvePathID(s.ctx, "/sync-backup/old.txt", userID); !errors.Is(err, repositories.ErrNotFound) {
+		t.Fatalf("expected old.txt to leave the destination, got err=%v", err)
+	}
+	if _, err := s.repos.Files.ResolvePathID(s.ctx, "/sync-backup-old/old.txt", userID); err != nil {
+		t.Fatalf("expected old.txt in the backup folder: %v", err)
+	}
+
+	var out struct {
+		Data struct {
+			Actions []struct {
+				Path   string `json:"path"`
+				Action string `json:"action"`
+				Reason string `json:"reason"`
+				Target string `json:"target"`
+			} `json:"actions"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(job.Output(), &out); err != nil {
+		t.Fatalf("decode summary output: %v", err)
+	}
+	got := make([]string, 0, len(out.Data.Actions))
+	for _, a := range out.Data.Actions {
+		got = append(got, a.Path+":"+a.Action+":"+a.Reason+":"+a.Target)
+	}
+	slices.Sort(got)
+	if want := []string{"keep.txt:skip:exists:", "old.txt:backup:extra:/sync-backup-old/old.txt"}; !slices.Equal(got, want) {
+		t.Fatalf("summary actions = %v, want %v", got, want)
+	}
+}
+
+func TestSyncRunWorkflow_KeepBothCopiesSurviveLaterRuns(t *testing.T) {
+	s := newSuite(t)
+	resetRiverTables(t, s)
+
+	const userID int64 = 91170
+	client := s.newClientWithToken(s.authTokenForUser(userID, "sync-workflow-keep-both-session"))
+
+	selected := true
+	if err := s.repos.Channels.Create(s.ctx, &jetmodel.Channels{
+		ChannelID:   9117001,
+		ChannelName: "sync-keep-both",
+		UserID:      userID,
+		Selected:    &selected,
+		CreatedAt:   time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create selected channel: %v", err)
+	}
+	if _, err := client.FilesCreate(s.ctx, &api.File{Name: "a.txt", Type: api.FileTypeFile, Path: api.NewOptString("/sync-keep-both"), MimeType: api.NewOptString("text/plain"), ChannelId: api.NewOptInt64(9117001), Size: api.NewOptInt64(0)}); err != nil {
+		t.Fatalf("FilesCreate failed: %v", err)
+	}
+
+	var messageID atomic.Int64
+	s.tgMock.uploadPartFn = func(_ context.Context, _ *tg.Client, _ int64, _ string, fileStream io.Reader, _ int64, _ int) (int, int64, error) {
+		n, err := io.Copy(io.Discard, fileStream)
+		if err != nil {
+			return 0, 0, err
+		}
+		return int(messageID.Add(1)), n, nil
+	}
+
+	channelManager := tgc.NewChannelManager(s.repos, s.cache, &s.cfg.TG)
+	jobClientRef := services.NewJobClientRef()
+	periodicRegistryRef := services.NewPeriodicJobRegistryRef()
+	apiSvc := services.NewApiService(s.repos, channelManager, s.cfg, s.cache, s.tgMock, s.events, jobClientRef, periodicRegistryRef)
+	riverClient, err := queue.NewClient(s.pool, services.NewJobExecutor(apiSvc), config.QueueConfig{}, config.JobsConfig{})
+	if err != nil {
+		t.Fatalf("create river client: %v", err)
+	}
+	jobClientRef.Set(riverClient)
+	if err := riverClient.Start(s.ctx); err != nil {
+		t.Fatalf("start river client: %v", err)
+	}
+	t.Cleanup(func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = riverClient.Stop(stopCtx)
+	})
+
+	srcDir := t.TempDir()
+	run := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte(content), 0o644); err != nil {
+			t.Fatalf("write source file: %v", err)
+		}
+		inserted, err := riverClient.Insert(s.ctx, queue.SyncRunJobArgs{
+			UserID:         userID,
+			Source:         "local://" + srcDir,
+			DestinationDir: "/sync-keep-both",
+			Options:        queue.SyncOptions{Sync: true, Conflict: queue.SyncConflictKeepBoth},
+			PollInterval:   1,
+		}, &river.InsertOpts{MaxAttempts: 1})
+		if err != nil {
+			t.Fatalf("insert sync.run: %v", err)
+		}
+		deadline := time.Now().Add(30 * time.Second)
+		for {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for sync.run completion")
+			}
+			job, err := riverClient.JobGet(s.ctx, inserted.Job.ID)
+			if err != nil {
+				t.Fatalf("job get: %v", err)
+			}
+			if job.State == rivertype.JobStateCompleted {
+				return
+			}
+			if job.State == rivertype.JobStateDiscarded || job.State == rivertype.JobStateCancelled {
+				t.Fatalf("sync.run finished in bad state %s: %+v", job.State, job.Errors)
+			}
+			time.Sleep(200 * time.Millisecond)
+		}
+	}
+
+	run("first version")
+	run("second, longer version")
+	run("second, longer version")
+
+	for _, name := range []string{"a.txt", "a (1).txt", "a (2).txt"} {
+		if _, err := s.repos.Files.ResolvePathID(s.ctx, "/sync-keep-both/"+name, userID); err != nil {
+			t.Fatalf("expected %s to survive later runs: %v", name, err)
+		}
+	}
+	if _, err := s.repos.Files.ResolvePathID(s.ctx, "/sync-keep-both/a (3).txt", userID); !errors.Is(err, repositories.ErrNotFound) {
+		t.Fatalf("expected an unchanged source not to add a copy, got err=%v", err)
+	}
+}
+
 func resetRiverTables(t *testing.T, s *suite) {
 	t.Helper()
 	if _, err := s.pool.Exec(s.ctx, `
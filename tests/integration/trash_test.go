@@ -0,0 +1,218 @@
+package integration_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tgdrive/teldrive/internal/api"
+)
+
+func TestTrash_DeleteListRestore(t *testing.T) {
+	s := newSuite(t)
+	ctx := context.Background()
+	s.cfg.Trash.Retention = 30 * 24 * time.Hour
+	_, client, _ := loginWithClient(t, s, 7950, "user7950")
+
+	docs, err := client.FilesCreate(ctx, &api.File{Name: "docs", Type: api.FileTypeFolder, Path: api.NewOptString("/")})
+	if err != nil {
+		t.Fatalf("FilesCreate docs failed: %v", err)
+	}
+	report, err := client.FilesCreate(ctx, &api.File{
+		Name:      "report.txt",
+		Type:      api.FileTypeFile,
+		Path:      api.NewOptString("/docs"),
+		MimeType:  api.NewOptString("text/plain"),
+		ChannelId: api.NewOptInt64(795001),
+		Size:      api.NewOptInt64(12),
+	})
+	if err != nil {
+		t.Fatalf("FilesCreate report failed: %v", err)
+	}
+
+	if err := client.FilesDeleteById(ctx, api.FilesDeleteByIdParams{ID: docs.ID.Value}); err != nil {
+		t.Fatalf("FilesDeleteById failed: %v", err)
+	}
+	if _, err := client.FilesGetById(ctx, api.FilesGetByIdParams{ID: report.ID.Value}); statusCode(err) != 404 {
+		t.Fatalf("expected 404 for trashed child, got %d err=%v", statusCode(err), err)
+	}
+
+	trash, err := client.FilesListTrash(ctx, api.FilesListTrashParams{})
+	if err != nil {
+		t.Fatalf("FilesListTrash failed: %v", err)
+	}
+	if len(trash.Items) != 1 {
+		t.Fatalf("expected only the deleted folder in trash, got %+v", trash.Items)
+	}
+	item := trash.Items[0]
+	if item.ID != docs.ID.Value || item.Path != "/docs" || item.Type != api.TrashItemTypeFolder {
+		t.Fatalf("unexpected trash item: %+v", item)
+	}
+	if !item.PurgeAt.IsSet() || !item.PurgeAt.Value.Equal(item.TrashedAt.Add(s.cfg.Trash.Retention)) {
+		t.Fatalf("expected purgeAt=trashedAt+retention, got %+v", item)
+	}
+
+	if err := client.FilesRestoreTrash(ctx, &api.TrashRestore{Ids: []api.UUID{docs.ID.Value}}); err != nil {
+		t.Fatalf("FilesRestoreTrash failed: %v", err)
+	}
+	restored, err := client.FilesGetById(ctx, api.FilesGetByIdParams{ID: report.ID.Value})
+	if err != nil {
+		t.Fatalf("FilesGetById after restore failed: %v", err)
+	}
+	if restored.Path.Value != "/docs/report.txt" {
+		t.Fatalf("expected restored path /docs/report.txt, got %s", restored.Path.Value)
+	}
+	trash, err = client.FilesListTrash(ctx, api.FilesListTrashParams{})
+	if err != nil {
+		t.Fatalf("FilesListTrash after restore failed: %v", err)
+	}
+	if len(trash.Items) != 0 {
+		t.Fatalf("expected empty trash after restore, got %+v", trash.Items)
+	}
+
+	if err := client.FilesDelete(ctx, &api.FileDelete{Ids: []api.UUID{report.ID.Value}}); err != nil {
+		t.Fatalf("FilesDelete report failed: %v", err)
+	}
+	if err := client.FilesDeleteById(ctx, api.FilesDeleteByIdParams{ID: docs.ID.Value}); err != nil {
+		t.Fatalf("FilesDeleteById docs failed: %v", err)
+	}
+	if _, err := client.FilesCreate(ctx, &api.File{Name: "docs", Type: api.FileTypeFolder, Path: api.NewOptString("/")}); err != nil {
+		t.Fatalf("FilesCreate replacement docs failed: %v", err)
+	}
+
+	if err := client.FilesRestoreTrash(ctx, &api.TrashRestore{Ids: []api.UUID{docs.ID.Value}}); statusCode(err) != 409 {
+		t.Fatalf("expected 409 restoring over an existing folder, got %d err=%v", statusCode(err), err)
+	}
+
+	if err := client.FilesRestoreTrash(ctx, &api.TrashRestore{Ids: []api.UUID{report.ID.Value}}); err != nil {
+		t.Fatalf("FilesRestoreTrash report failed: %v", err)
+	}
+	restored, err = client.FilesGetById(ctx, api.FilesGetByIdParams{ID: report.ID.Value})
+	if err != nil {
+		t.Fatalf("FilesGetById restored report failed: %v", err)
+	}
+	if restored.Path.Value != "/docs/report.txt" || restored.ParentId.Value == docs.ID.Value {
+		t.Fatalf("expected report restored into the new /docs folder, got %+v", restored)
+	}
+
+	if err := client.FilesRestoreTrash(ctx, &api.TrashRestore{Ids: []api.UUID{api.UUID(uuid.New())}}); statusCode(err) != 404 {
+		t.Fatalf("expected 404 restoring unknown id, got %d err=%v", statusCode(err), err)
+	}
+}
+
+func TestTrash_EmptyAndRetention(t *testing.T) {
+	s := newSuite(t)
+	ctx := context.Background()
+	s.cfg.Trash.Retention = 24 * time.Hour
+	_, client, _ := loginWithClient(t, s, 7951, "user7951")
+
+	var ids []api.UUID
+	for _, name := range []string{"old", "recent", "purge"} {
+		folder, err := client.FilesCreate(ctx, &api.File{Name: name, Type: api.FileTypeFolder, Path: api.NewOptString("/")})
+		if err != nil {
+			t.Fatalf("FilesCreate %s failed: %v", name, err)
+		}
+		ids = append(ids, folder.ID.Value)
+	}
+	if err := client.FilesDelete(ctx, &api.FileDelete{Ids: ids}); err != nil {
+		t.Fatalf("FilesDelete failed: %v", err)
+	}
+
+	page, err := client.FilesListTrash(ctx, api.FilesListTrashParams{Limit: api.NewOptInt(2)})
+	if err != nil {
+		t.Fatalf("FilesListTrash page 1 failed: %v", err)
+	}
+	if len(page.Items) != 2 || !page.Meta.NextCursor.IsSet() {
+		t.Fatalf("expected 2 items and a next cursor, got %+v", page)
+	}
+	next, err := client.FilesListTrash(ctx, api.FilesListTrashParams{Limit: api.NewOptInt(2), Cursor: page.Meta.NextCursor})
+	if err != nil {
+		t.Fatalf("FilesListTrash page 2 failed: %v", err)
+	}
+	if len(next.Items) != 1 {
+		t.Fatalf("expected 1 item on second page, got %+v", next.Items)
+	}
+
+	if err := client.FilesEmptyTrash(ctx, &api.TrashEmpty{Ids: []api.UUID{ids[2]}}); err != nil {
+		t.Fatalf("FilesEmptyTrash by id failed: %v", err)
+	}
+	assertFileStatus(t, s, uuid.UUID(ids[2]), "pending_deletion")
+	assertFileStatus(t, s, uuid.UUID(ids[1]), "trashed")
+
+	if _, err := s.pool.Exec(ctx, `UPDATE teldrive.files SET trashed_at = trashed_at - interval '2 days' WHERE id = $1`, uuid.UUID(ids[0])); err != nil {
+		t.Fatalf("age trashed folder: %v", err)
+	}
+	if err := s.exec.CleanPendingFilesForUser(ctx, 7951); err != nil {
+		t.Fatalf("CleanPendingFilesForUser failed: %v", err)
+	}
+
+	assertFileStatus(t, s, uuid.UUID(ids[0]), "pending_deletion")
+	assertFileStatus(t, s, uuid.UUID(ids[1]), "trashed")
+
+	if err := client.FilesEmptyTrash(ctx, &api.TrashEmpty{}); err != nil {
+		t.Fatalf("FilesEmptyTrash failed: %v", err)
+	}
+	assertFileStatus(t, s, uuid.UUID(ids[1]), "pending_deletion")
+}
+
+func assertFileStatus(t *testing.T, s *suite, id uuid.UUID, want string) {
+	t.Helper()
+	var status string
+	if err := s.pool.QueryRow(context.Background(), `SELECT status FROM teldrive.files WHERE id = $1`, id).Scan(&status); err != nil {
+		t.Fatalf("read status of %s: %v", id, err)
+	}
+	if status != want {
+		t.Fatalf("expected status %s for %s, got %s", want, id, status)
+	}
+}
+
+func TestTrash_CheckKeepsTrashedFiles(t *testing.T) {
+	s := newSuite(t)
+	ctx := context.Background()
+	s.cfg.Trash.Retention = 30 * 24 * time.Hour
+	_, client, _ := loginWithClient(t, s, 91130, "user91130")
+
+	const channelID = int64(911301)
+	kept, err := client.FilesCreate(ctx, &api.File{
+		Name:      "kept.txt",
+		Type:      api.FileTypeFile,
+		Path:      api.NewOptString("/"),
+		MimeType:  api.NewOptString("text/plain"),
+		ChannelId: api.NewOptInt64(channelID),
+		Size:      api.NewOptInt64(5),
+		Parts:     []api.Part{{ID: 11}},
+	})
+	if err != nil {
+		t.Fatalf("FilesCreate kept failed: %v", err)
+	}
+	trashed, err := client.FilesCreate(ctx, &api.File{
+		Name:      "trashed.txt",
+		Type:      api.FileTypeFile,
+		Path:      api.NewOptString("/"),
+		MimeType:  api.NewOptString("text/plain"),
+		ChannelId: api.NewOptInt64(channelID),
+		Size:      api.NewOptInt64(5),
+		Parts:     []api.Part{{ID: 12}},
+	})
+	if err != nil {
+		t.Fatalf("FilesCreate trashed failed: %v", err)
+	}
+	if err := client.FilesDeleteById(ctx, api.FilesDeleteByIdParams{ID: trashed.ID.Value}); err != nil {
+		t.Fatalf("FilesDeleteById failed: %v", err)
+	}
+
+	files, err := s.repos.Files.ListCheckFiles(ctx, 91130, channelID, false)
+	if err != nil {
+		t.Fatalf("ListCheckFiles failed: %v", err)
+	}
+	got := map[uuid.UUID]int{}
+	for _, f := range files {
+		if len(f.Parts) == 1 {
+			got[f.ID] = f.Parts[0].ID
+		}
+	}
+	if got[uuid.UUID(kept.ID.Value)] != 11 || got[uuid.UUID(trashed.ID.Value)] != 12 || len(got) != 2 {
+		t.Fatalf("expected active and trashed files with their parts, got %+v", files)
+	}
+}
@@ -0,0 +1,120 @@
+package integration_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/tgdrive/teldrive/internal/api"
+	jetmodel "github.com/tgdrive/teldrive/internal/database/jet/gen/model"
+)
+
+func webdavRequest(t *testing.T, s *suite, method, name, key string, body io.Reader, headers map[string]string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, s.webdav.URL+"/webdav"+name, body)
+	if err != nil {
+		t.Fatalf("build %s request: %v", method, err)
+	}
+	if key != "" {
+		req.SetBasicAuth("teldrive", key)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := s.httpCli.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, name, err)
+	}
+	t.Cleanup(func() { _ = res.Body.Close() })
+	return res
+}
+
+func TestWebDAV_TreeOperations(t *testing.T) {
+	s := newSuite(t)
+	ctx := context.Background()
+	_, client, _ := loginWithClient(t, s, 7901, "user7901")
+
+	selected := true
+	if err := s.repos.Channels.Create(s.ctx, &jetmodel.Channels{
+		ChannelID:   7901001,
+		ChannelName: "webdav-test",
+		UserID:      7901,
+		Selected:    &selected,
+		CreatedAt:   time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create selected channel: %v", err)
+	}
+	s.tgMock.uploadPartFn = func(_ context.Context, _ *tg.Client, _ int64, _ string, fileStream io.Reader, _ int64, _ int) (int, int64, error) {
+		n, err := io.Copy(io.Discard, fileStream)
+		if err != nil {
+			return 0, 0, err
+		}
+		return 1, n, nil
+	}
+
+	created, err := client.UsersCreateApiKey(ctx, &api.UserApiKeyCreate{Name: "webdav"})
+	if err != nil {
+		t.Fatalf("UsersCreateApiKey failed: %v", err)
+	}
+	key := created.Key
+
+	if res := webdavRequest(t, s, "PROPFIND", "/", "", nil, map[string]string{"Depth": "0"}); res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without credentials, got %d", res.StatusCode)
+	}
+	if res := webdavRequest(t, s, "PROPFIND", "/", "tdk_invalid", nil, map[string]string{"Depth": "0"}); res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for invalid key, got %d", res.StatusCode)
+	}
+
+	if res := webdavRequest(t, s, "MKCOL", "/docs", key, nil, nil); res.StatusCode != http.StatusCreated {
+		t.Fatalf("MKCOL expected 201, got %d", res.StatusCode)
+	}
+	if res := webdavRequest(t, s, "MKCOL", "/missing/child", key, nil, nil); res.StatusCode != http.StatusConflict {
+		t.Fatalf("MKCOL without parent expected 409, got %d", res.StatusCode)
+	}
+
+	payload := "webdav integration payload"
+	if res := webdavRequest(t, s, http.MethodPut, "/docs/hello.txt", key, strings.NewReader(payload), nil); res.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT expected 201, got %d", res.StatusCode)
+	}
+
+	listed, err := client.FilesList(ctx, api.FilesListParams{Path: api.NewOptString("/docs"), Limit: api.NewOptInt(100)})
+	if err != nil {
+		t.Fatalf("FilesList failed: %v", err)
+	}
+	if len(listed.Items) != 1 || listed.Items[0].Name != "hello.txt" {
+		t.Fatalf("expected hello.txt in /docs, got %+v", listed.Items)
+	}
+	if listed.Items[0].Size.Value != int64(len(payload)) {
+		t.Fatalf("expected size=%d, got %d", len(payload), listed.Items[0].Size.Value)
+	}
+
+	res := webdavRequest(t, s, "PROPFIND", "/docs", key, nil, map[string]string{"Depth": "1"})
+	if res.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("PROPFIND expected 207, got %d", res.StatusCode)
+	}
+	body, _ := io.ReadAll(res.Body)
+	if !strings.Contains(string(body), "hello.txt") {
+		t.Fatalf("PROPFIND response missing hello.txt: %s", body)
+	}
+
+	res = webdavRequest(t, s, "MOVE", "/docs/hello.txt", key, nil, map[string]string{
+		"Destination": s.webdav.URL + "/webdav/docs/renamed.txt",
+	})
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("MOVE expected 201, got %d", res.StatusCode)
+	}
+	if res := webdavRequest(t, s, "PROPFIND", "/docs/renamed.txt", key, nil, map[string]string{"Depth": "0"}); res.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("PROPFIND after MOVE expected 207, got %d", res.StatusCode)
+	}
+
+	if res := webdavRequest(t, s, http.MethodDelete, "/docs", key, nil, nil); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE expected 204, got %d", res.StatusCode)
+	}
+	if res := webdavRequest(t, s, "PROPFIND", "/docs", key, nil, map[string]string{"Depth": "0"}); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("PROPFIND after DELETE expected 404, got %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,111 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tgdrive/teldrive/internal/api"
+	"github.com/tgdrive/teldrive/pkg/queue"
+)
+
+func TestWebhooks_CRUDTestAndDelivery(t *testing.T) {
+	s := newSuite(t)
+	const userID int64 = 91040
+	client := s.newClientWithToken(s.authTokenForUser(userID, "webhooks-session"))
+
+	var mu sync.Mutex
+	var received []http.Header
+	fail := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r.Header.Clone())
+		if fail && r.Header.Get("X-Teldrive-Event") != "webhooks.test" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	if _, err := client.WebhooksCreate(s.ctx, &api.WebhookCreate{Name: "bad", URL: srv.URL, Events: []string{"files.renamed"}}); statusCode(err) != 400 {
+		t.Fatalf("expected 400 for an unknown event type, got %d err=%v", statusCode(err), err)
+	}
+	if _, err := client.WebhooksCreate(s.ctx, &api.WebhookCreate{Name: "bad", URL: "ftp://example.com", Events: []string{"files.*"}}); statusCode(err) != 400 {
+		t.Fatalf("expected 400 for a non-http url, got %d err=%v", statusCode(err), err)
+	}
+	if _, err := client.WebhooksCreate(s.ctx, &api.WebhookCreate{Name: "local", URL: srv.URL, Events: []string{"files.*"}}); statusCode(err) != 400 {
+		t.Fatalf("expected 400 for a loopback url, got %d err=%v", statusCode(err), err)
+	}
+	// The test endpoint listens on loopback.
+	s.cfg.Jobs.WebhookDeliver.AllowPrivate = true
+
+	created, err := client.WebhooksCreate(s.ctx, &api.WebhookCreate{Name: "uploads", URL: srv.URL, Events: []string{"files.created,files.updated"}})
+	if err != nil {
+		t.Fatalf("WebhooksCreate failed: %v", err)
+	}
+	if created.Secret == "" || !created.Enabled || len(created.Events) != 2 {
+		t.Fatalf("unexpected webhook: %+v", created)
+	}
+	list, err := client.WebhooksList(s.ctx)
+	if err != nil || len(list) != 1 || list[0].ID != created.ID {
+		t.Fatalf("unexpected list %+v err=%v", list, err)
+	}
+
+	result, err := client.WebhooksTest(s.ctx, api.WebhooksTestParams{ID: created.ID})
+	if err != nil {
+		t.Fatalf("WebhooksTest failed: %v", err)
+	}
+	if !result.Success || result.StatusCode.Or(0) != http.StatusOK || result.EventType != "webhooks.test" {
+		t.Fatalf("unexpected test delivery: %+v", result)
+	}
+
+	eventID := uuid.NewString()
+	payload, _ := json.Marshal(map[string]any{"id": eventID, "type": "files.created"})
+	args := queue.WebhookDeliverArgs{UserID: userID, WebhookID: uuid.UUID(created.ID).String(), EventID: eventID, EventType: "files.created", Payload: payload}
+	if err := s.exec.WebhookDeliver(s.ctx, args, 1); err == nil {
+		t.Fatal("expected an error for a 502 answer so the job is retried")
+	}
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	if err := s.exec.WebhookDeliver(s.ctx, args, 2); err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	mu.Lock()
+	last := received[len(received)-1]
+	mu.Unlock()
+	if last.Get("X-Teldrive-Delivery") != eventID || last.Get("X-Teldrive-Signature") == "" {
+		t.Fatalf("unexpected delivery headers: %v", last)
+	}
+
+	deliveries, err := client.WebhooksListDeliveries(s.ctx, api.WebhooksListDeliveriesParams{ID: created.ID})
+	if err != nil {
+		t.Fatalf("WebhooksListDeliveries failed: %v", err)
+	}
+	if len(deliveries) != 3 || deliveries[0].Attempt != 2 || !deliveries[0].Success || deliveries[1].Success || deliveries[1].StatusCode.Or(0) != http.StatusBadGateway {
+		t.Fatalf("unexpected deliveries: %+v", deliveries)
+	}
+
+	updated, err := client.WebhooksUpdate(s.ctx, &api.WebhookUpdate{Enabled: api.NewOptBool(false), Events: []string{"jobs.*"}}, api.WebhooksUpdateParams{ID: created.ID})
+	if err != nil {
+		t.Fatalf("WebhooksUpdate failed: %v", err)
+	}
+	if updated.Enabled || len(updated.Events) != 1 || updated.Events[0] != "jobs.*" {
+		t.Fatalf("unexpected update: %+v", updated)
+	}
+	if err := s.exec.WebhookDeliver(s.ctx, args, 3); err == nil {
+		t.Fatal("expected delivery to a disabled webhook to be cancelled")
+	}
+
+	if err := client.WebhooksDelete(s.ctx, api.WebhooksDeleteParams{ID: created.ID}); err != nil {
+		t.Fatalf("WebhooksDelete failed: %v", err)
+	}
+	if _, err := client.WebhooksGet(s.ctx, api.WebhooksGetParams{ID: created.ID}); statusCode(err) != 404 {
+		t.Fatalf("expected 404 after delete, got %d err=%v", statusCode(err), err)
+	}
+}